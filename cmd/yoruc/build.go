@@ -0,0 +1,467 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/you-not-fish/yoru/internal/codegen"
+	"github.com/you-not-fish/yoru/internal/loader"
+	"github.com/you-not-fish/yoru/internal/rtabi"
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/ssa/passes"
+	"github.com/you-not-fish/yoru/internal/syntax"
+	"github.com/you-not-fish/yoru/internal/types"
+	"github.com/you-not-fish/yoru/internal/types2"
+)
+
+// checkedPackage is a parsed and type-checked main package.
+type checkedPackage struct {
+	prog  *loader.Program // the whole program, including imports
+	files []*syntax.File
+	info  *types2.Info
+	pkg   *types.Package
+	sizes *types.Sizes
+}
+
+// sourceFiles expands the command-line inputs into a list of source files.
+// A single directory argument selects every .yoru file in it, in name order;
+// otherwise each argument names one file of the package.
+func sourceFiles(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no input file")
+	}
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			continue
+		}
+		if len(args) > 1 {
+			return nil, fmt.Errorf("%s: a directory must be the only input", arg)
+		}
+		files, err := filepath.Glob(filepath.Join(arg, "*.yoru"))
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("%s: no .yoru files in directory", arg)
+		}
+		sort.Strings(files)
+		return files, nil
+	}
+	return args, nil
+}
+
+// parseAndCheck parses and type-checks the main package given by the
+// command-line inputs (see sourceFiles) together with every package it
+// imports. Diagnostics are written to stderr. It returns nil if the main
+// package could not be read or parsed; otherwise typeOK reports whether
+// all packages passed checking.
+func parseAndCheck(inputs []string) (cp *checkedPackage, typeOK bool) {
+	filenames, err := sourceFiles(inputs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return nil, false
+	}
+
+	var errs []string
+	errh := func(pos syntax.Pos, msg string) {
+		errs = append(errs, fmt.Sprintf("%s: %s", pos, msg))
+	}
+
+	sizes := targetSizes()
+	conf := &loader.Config{
+		Sizes: sizes,
+		NoASI: *noASI,
+		Error: errh,
+	}
+	prog, err := loader.Load(conf, filenames)
+
+	for _, e := range errs {
+		fmt.Fprintln(os.Stderr, e)
+	}
+	if prog == nil {
+		if len(errs) == 0 {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		return nil, false
+	}
+
+	cp = &checkedPackage{
+		prog:  prog,
+		files: prog.Main.Files,
+		info:  prog.Info,
+		pkg:   prog.Main.Types,
+		sizes: sizes,
+	}
+	return cp, len(errs) == 0
+}
+
+// compileSSA parses, type-checks, builds SSA, and runs the pass pipeline.
+// Diagnostics are written to stderr; ok is false if any stage failed.
+func compileSSA(inputs []string) (pkgs []*ssa.Package, ok bool) {
+	cp, ok := parseAndCheck(inputs)
+	if !ok {
+		return nil, false
+	}
+
+	// Build SSA for every package, dependencies first.
+	for _, pkg := range cp.prog.Packages {
+		pkgs = append(pkgs, ssa.BuildPackage(pkg.Files, cp.info, cp.sizes))
+	}
+
+	// Functions are compiled callees first, so that the inliner copies
+	// optimized bodies.
+	funcs := passes.BottomUp(allFuncs(pkgs))
+
+	pipeline, err := newPipeline(pipelineNames(), funcs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return nil, false
+	}
+	if !runPipeline(funcs, pipeline, *ssaVerify) {
+		return nil, false
+	}
+	return pkgs, true
+}
+
+// pipelineNames returns the names of the passes to run: those listed by
+// -passes, or else the preset of the -O level.
+func pipelineNames() []string {
+	if *passList != "" {
+		return strings.Split(*passList, ",")
+	}
+	return passes.Preset(optLevel)
+}
+
+// newPipeline returns the named passes set up for the program made of
+// funcs.
+func newPipeline(names []string, funcs []*ssa.Func) ([]passes.Pass, error) {
+	return passes.NewPipeline(names, &passes.Context{
+		Funcs:           funcs,
+		CheckElimReport: checkElimReport(),
+	})
+}
+
+// runPipeline runs pipeline on each of funcs in order, verifying them
+// before the pipeline and after each pass if verify is set. Errors are
+// written to stderr.
+func runPipeline(funcs []*ssa.Func, pipeline []passes.Pass, verify bool) bool {
+	passCfg := passes.Config{
+		DumpBefore: *dumpBefore,
+		DumpAfter:  *dumpAfter,
+		Verify:     verify,
+		DumpFunc:   *dumpFunc,
+	}
+	// runs holds the pass runs of each function for -ssa-stats and -trace.
+	runs := make([][]passes.PassRun, len(funcs))
+	for i, fn := range funcs {
+		if *ssaStats || trace != "" {
+			passCfg.Record = func(r passes.PassRun) { runs[i] = append(runs[i], r) }
+		}
+		if verify {
+			if err := ssa.Verify(fn); err != nil {
+				fmt.Fprintf(os.Stderr, "SSA verification failed for %s (before passes):\n%v\n", fn.Name, err)
+				return false
+			}
+		}
+		ssa.ComputeDom(fn)
+		if err := passes.Run(fn, pipeline, passCfg); err != nil {
+			fmt.Fprintf(os.Stderr, "pass pipeline failed for %s:\n%v\n", fn.Name, err)
+			return false
+		}
+	}
+
+	if *ssaStats {
+		writeSSAStats(os.Stderr, runs)
+	}
+	switch trace {
+	case "table":
+		writeTraceTable(os.Stderr, runs)
+	case "json":
+		if err := writeChromeTrace(os.Stderr, runs); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return false
+		}
+	}
+	return true
+}
+
+// checkElimReport returns the function reporting the checks removed by
+// checkelim to stderr with -d=checkelim, or nil.
+func checkElimReport() func(check *ssa.Value, reason string) {
+	if !debugEnabled("checkelim") {
+		return nil
+	}
+	return func(check *ssa.Value, reason string) {
+		what := "nil check"
+		if check.Op == ssa.OpBoundsCheck {
+			what = "bounds check"
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s removed in %s: %s\n", check.Pos, what, check.Block.Func.Name, reason)
+	}
+}
+
+// allFuncs returns the functions of all packages, including the package
+// initializers, in package order.
+func allFuncs(pkgs []*ssa.Package) []*ssa.Func {
+	var funcs []*ssa.Func
+	for _, pkg := range pkgs {
+		funcs = append(funcs, pkg.Funcs...)
+		if pkg.Init != nil {
+			funcs = append(funcs, pkg.Init)
+		}
+	}
+	return funcs
+}
+
+// runBuild compiles the input package to a native executable.
+//
+// The LLVM IR is written to a temporary directory, compiled to an object
+// file with clang, and linked against the (cached) runtime object. With
+// -keep-temps the temporary directory is kept and its path reported.
+func runBuild(inputs []string) int {
+	out := *output
+	if out == "" {
+		out = defaultOutputName(inputs)
+	}
+	if err := buildExecutable(inputs, out); err != nil {
+		if !errors.Is(err, errCompile) {
+			fmt.Fprintf(os.Stderr, "yoruc: %v\n", err)
+		}
+		return 1
+	}
+	return 0
+}
+
+// errCompile is returned when the front or middle end reported diagnostics.
+// The diagnostics themselves have already been printed.
+var errCompile = errors.New("compilation failed")
+
+// buildExecutable compiles the input package and links the result into out.
+func buildExecutable(inputs []string, out string) error {
+	target := selectedTarget()
+	clang, err := findClang()
+	if err != nil {
+		return err
+	}
+
+	pkgs, ok := compileSSA(inputs)
+	if !ok {
+		return errCompile
+	}
+
+	tmpDir, err := os.MkdirTemp("", "yoru-build-")
+	if err != nil {
+		return err
+	}
+	if *keepTemps {
+		fmt.Fprintf(os.Stderr, "yoruc: keeping temporary files in %s\n", tmpDir)
+	} else {
+		defer os.RemoveAll(tmpDir)
+	}
+
+	base := defaultOutputName(inputs)
+	llFile := filepath.Join(tmpDir, base+".ll")
+	objFile := filepath.Join(tmpDir, base+".o")
+
+	// Generate LLVM IR.
+	llOut, err := os.Create(llFile)
+	if err != nil {
+		return err
+	}
+	if err := codegen.GenerateProgram(llOut, pkgs, types.NewSizes(target)); err != nil {
+		llOut.Close()
+		return fmt.Errorf("codegen error: %v", err)
+	}
+	if err := llOut.Close(); err != nil {
+		return err
+	}
+
+	// Compile IR to an object file.
+	if err := runTool(clang, "-target", target.Triple, "-c", llFile, "-o", objFile); err != nil {
+		return err
+	}
+
+	// Link against the runtime.
+	rtObj, err := runtimeObject(clang, target)
+	if err != nil {
+		return err
+	}
+	return runTool(clang, "-target", target.Triple, objFile, rtObj, "-o", out)
+}
+
+// defaultOutputName returns the executable name used when -o is not given:
+// the base name of the first input without its extension (for a directory,
+// the directory name), in the current directory.
+func defaultOutputName(inputs []string) string {
+	name := "a.out"
+	if len(inputs) > 0 {
+		name = filepath.Base(inputs[0])
+		if fi, err := os.Stat(inputs[0]); err == nil && fi.IsDir() {
+			if abs, err := filepath.Abs(inputs[0]); err == nil {
+				name = filepath.Base(abs)
+			}
+		} else {
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+	}
+	if name == "" || name == "." {
+		name = "a.out"
+	}
+	return name
+}
+
+// findClang locates the clang executable checked by -doctor.
+func findClang() (string, error) {
+	path, err := exec.LookPath("clang")
+	if err != nil {
+		return "", errors.New("clang not found in PATH (run 'yoruc -doctor', see docs/toolchain.md)")
+	}
+	return path, nil
+}
+
+// runTool runs an external tool, forwarding its output to stderr.
+func runTool(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s: %v", filepath.Base(name), strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// findRuntime locates runtime/runtime.c.
+//
+// The search order is $YORU_RUNTIME (a directory containing runtime.c),
+// then the directory of the yoruc executable and its parents, then the
+// current directory and its parents.
+func findRuntime() (string, error) {
+	if dir := os.Getenv("YORU_RUNTIME"); dir != "" {
+		path := filepath.Join(dir, "runtime.c")
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("YORU_RUNTIME: %v", err)
+		}
+		return path, nil
+	}
+
+	var starts []string
+	if exe, err := os.Executable(); err == nil {
+		if exe, err := filepath.EvalSymlinks(exe); err == nil {
+			starts = append(starts, filepath.Dir(exe))
+		}
+	}
+	if wd, err := os.Getwd(); err == nil {
+		starts = append(starts, wd)
+	}
+
+	for _, dir := range starts {
+		for {
+			path := filepath.Join(dir, "runtime", "runtime.c")
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	return "", errors.New("cannot find runtime/runtime.c (set YORU_RUNTIME to the runtime directory)")
+}
+
+// runtimeObject returns the path of the compiled runtime, compiling it
+// on first use. Objects are cached in the user cache directory, keyed by
+// the runtime sources, the target triple, and the clang version, so a
+// changed runtime or toolchain is rebuilt automatically.
+func runtimeObject(clang string, target *rtabi.Target) (string, error) {
+	src, err := findRuntime()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, name := range []string{"runtime.c", "runtime.h"} {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(src), name))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s %d\n", name, len(data))
+		h.Write(data)
+	}
+	clangVersion, _ := checkTool(clang, "--version")
+	fmt.Fprintf(h, "target %s\nclang %s\n", target.Triple, clangVersion)
+	key := hex.EncodeToString(h.Sum(nil))[:16]
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	cacheDir = filepath.Join(cacheDir, "yoru")
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", err
+	}
+
+	obj := filepath.Join(cacheDir, "runtime-"+key+".o")
+	if _, err := os.Stat(obj); err == nil {
+		return obj, nil
+	}
+
+	// Compile to a temporary name and rename, so concurrent builds never
+	// observe a partially written object.
+	tmp, err := os.CreateTemp(cacheDir, "runtime-*.o")
+	if err != nil {
+		return "", err
+	}
+	tmpName := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpName)
+
+	if err := runTool(clang, "-target", target.Triple, "-O2", "-c", src, "-o", tmpName); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpName, obj); err != nil {
+		return "", err
+	}
+	return obj, nil
+}
+
+// writeLL generates LLVM IR for the input package into w.
+func writeLL(inputs []string, w io.Writer) int {
+	pkgs, ok := compileSSA(inputs)
+	if !ok {
+		return 1
+	}
+	if err := codegen.GenerateProgram(w, pkgs, targetSizes()); err != nil {
+		fmt.Fprintf(os.Stderr, "codegen error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// selectedTarget returns the target chosen by -target (the host by default).
+// main validates the flag, so an unknown name only reaches here from tests.
+func selectedTarget() *rtabi.Target {
+	t, err := rtabi.LookupTarget(*targetName)
+	if err != nil {
+		return rtabi.HostTarget()
+	}
+	return t
+}
+
+// targetSizes returns the type sizes for the selected target.
+func targetSizes() *types.Sizes {
+	return types.NewSizes(selectedTarget())
+}
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 
-	"github.com/you-not-fish/yoru/internal/codegen"
+	"github.com/you-not-fish/yoru/internal/rtabi"
 	"github.com/you-not-fish/yoru/internal/ssa"
 	"github.com/you-not-fish/yoru/internal/ssa/passes"
 	"github.com/you-not-fish/yoru/internal/syntax"
@@ -27,26 +30,101 @@ var (
 	emitSSA      = flag.Bool("emit-ssa", false, "Output SSA")
 	emitLL       = flag.Bool("emit-ll", false, "Output LLVM IR")
 	emitLayout   = flag.Bool("emit-layout", false, "Output struct layouts")
+	emitTypeDesc = flag.Bool("emit-typedesc", false, "Output GC type descriptors")
 	output       = flag.String("o", "", "Output file")
+	targetName   = flag.String("target", "", "Target platform (default: host)")
+	keepTemps    = flag.Bool("keep-temps", false, "Keep intermediate .ll and object files")
 	doctor       = flag.Bool("doctor", false, "Check toolchain")
 	version      = flag.Bool("version", false, "Print version")
-	trace        = flag.Bool("trace", false, "Output timing trace")
 	dumpFunc     = flag.String("dump-func", "", "Only dump specific function")
 	ssaVerify    = flag.Bool("ssa-verify", false, "Verify SSA after each pass")
+	ssaStats     = flag.Bool("ssa-stats", false, "Print block, value, phi and call counts of each function before the first pass and after each pass, with pass times")
+	passList     = flag.String("passes", "", "Comma-separated SSA passes to run, instead of the -O preset")
 	dumpBefore   = flag.String("dump-before", "", "Dump SSA before pass (name or \"*\")")
 	dumpAfter    = flag.String("dump-after", "", "Dump SSA after pass (name or \"*\")")
-	gcStats      = flag.Bool("gc-stats", false, "Print GC statistics")
-	gcVerbose    = flag.Bool("gc-verbose", false, "Verbose GC output")
-	gcStress     = flag.Bool("gc-stress", false, "Trigger GC on every allocation")
+	gcStats      = flag.Bool("gc-stats", false, "Print GC statistics (run mode)")
+	gcVerbose    = flag.Bool("gc-verbose", false, "Verbose GC output (run mode)")
+	gcStress     = flag.Bool("gc-stress", false, "Trigger GC on every allocation (run mode)")
+	debugOpts    = flag.String("d", "", "Debug options, comma-separated (checkelim)")
+	interpret    = flag.Bool("interp", false, "Run the program with the SSA interpreter instead of compiling it")
+	runPassList  = flag.String("run-passes", "", "Run the comma-separated passes on a .ssa file and output the SSA")
 )
 
+// trace is the -trace output format: "" (off), "table" or "json".
+var trace traceFlag
+
+// optLevel is the optimization level selected by -O0, -O1 or -O2.
+var optLevel = passes.MaxOptLevel
+
+func init() {
+	for level := 0; level <= passes.MaxOptLevel; level++ {
+		flag.Var(optLevelFlag(level), fmt.Sprintf("O%d", level),
+			fmt.Sprintf("Run the -O%d pass preset: %s", level, presetDesc(level)))
+	}
+	flag.Var(&trace, "trace", "Output a timing trace of the SSA passes as a table; -trace=json for Chrome trace format")
+}
+
+// optLevelFlag is a boolean flag selecting an optimization level.
+type optLevelFlag int
+
+func (f optLevelFlag) String() string   { return "" }
+func (f optLevelFlag) IsBoolFlag() bool { return true }
+
+func (f optLevelFlag) Set(s string) error {
+	if on, err := strconv.ParseBool(s); err != nil || !on {
+		return fmt.Errorf("invalid value %q", s)
+	}
+	optLevel = int(f)
+	return nil
+}
+
+// traceFlag is a boolean flag that also accepts a trace format: -trace
+// selects the table format, -trace=json the Chrome trace format.
+type traceFlag string
+
+func (f *traceFlag) String() string   { return string(*f) }
+func (f *traceFlag) IsBoolFlag() bool { return true }
+
+func (f *traceFlag) Set(s string) error {
+	switch s {
+	case "table", "json":
+		*f = traceFlag(s)
+		return nil
+	}
+	on, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("unknown trace format %q (valid: table, json)", s)
+	}
+	*f = ""
+	if on {
+		*f = "table"
+	}
+	return nil
+}
+
+// presetDesc describes the passes of the preset of level for -help.
+func presetDesc(level int) string {
+	names := passes.Preset(level)
+	if len(names) == 0 {
+		return "no optimization"
+	}
+	desc := strings.Join(names, ",")
+	if level == passes.MaxOptLevel {
+		desc += " (default)"
+	}
+	return desc
+}
+
 // Version information
 const Version = "0.1.0-dev"
 
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Yoru Compiler %s\n\n", Version)
-		fmt.Fprintf(os.Stderr, "Usage: yoruc [options] <file.yoru>\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: yoruc [options] <file.yoru>... | <dir>\n")
+		fmt.Fprintf(os.Stderr, "       yoruc build [options] <file.yoru>... | <dir>\n")
+		fmt.Fprintf(os.Stderr, "       yoruc run [options] <file.yoru>... | <dir> [-- args...]\n")
+		fmt.Fprintf(os.Stderr, "       yoruc -run-passes=<pass,...> [options] <file.ssa>\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 	}
@@ -63,13 +141,58 @@ func main() {
 		os.Exit(runDoctor())
 	}
 
-	args := flag.Args()
+	args := parseInterspersed(flag.Args())
+
+	if _, err := rtabi.LookupTarget(*targetName); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkDebugOpts(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkPassFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// "build" is the same as the default mode.
+	if len(args) > 0 && args[0] == "build" {
+		args = args[1:]
+	}
+
+	// "run" builds and executes; remaining arguments go to the program.
+	if len(args) > 0 && args[0] == "run" {
+		inputs, progArgs := splitRunArgs(args[1:])
+		if len(inputs) == 0 {
+			fmt.Fprintln(os.Stderr, "error: no input file")
+			fmt.Fprintln(os.Stderr, "usage: yoruc run [options] <file.yoru>... | <dir> [-- args...]")
+			os.Exit(1)
+		}
+		if *interpret {
+			os.Exit(runInterp(inputs, progArgs))
+		}
+		os.Exit(runRun(inputs, progArgs))
+	}
+
+	// Outside "run", "--" only ends the flags.
+	if i := slices.Index(args, "--"); i >= 0 {
+		args = slices.Delete(args, i, i+1)
+	}
+
 	if len(args) == 0 {
 		fmt.Fprintln(os.Stderr, "error: no input file")
-		fmt.Fprintln(os.Stderr, "usage: yoruc [options] <file.yoru>")
+		fmt.Fprintln(os.Stderr, "usage: yoruc [options] <file.yoru>... | <dir>")
 		os.Exit(1)
 	}
 
+	// Handle -run-passes
+	if *runPassList != "" {
+		os.Exit(runRunPasses(args))
+	}
+
+	// Token and AST dumps work on a single file; everything from
+	// type checking on works on the whole package.
 	filename := args[0]
 
 	// Handle -emit-tokens
@@ -84,28 +207,89 @@ func main() {
 
 	// Handle -emit-typed-ast
 	if *emitTypedAST {
-		os.Exit(runEmitTypedAST(filename))
+		os.Exit(runEmitTypedAST(args))
 	}
 
 	// Handle -emit-layout
 	if *emitLayout {
-		os.Exit(runEmitLayout(filename))
+		os.Exit(runEmitLayout(args))
+	}
+
+	// Handle -emit-typedesc
+	if *emitTypeDesc {
+		os.Exit(runEmitTypeDesc(args))
 	}
 
 	// Handle -emit-ssa
 	if *emitSSA {
-		os.Exit(runEmitSSA(filename))
+		os.Exit(runEmitSSA(args))
 	}
 
 	// Handle -emit-ll
 	if *emitLL {
-		os.Exit(runEmitLL(filename))
+		os.Exit(runEmitLL(args))
+	}
+
+	// Handle -interp
+	if *interpret {
+		os.Exit(runInterp(args, nil))
 	}
 
-	// TODO: Implement rest of compilation pipeline
-	fmt.Fprintf(os.Stderr, "yoruc: compilation not yet implemented\n")
-	fmt.Fprintf(os.Stderr, "input file: %s\n", filename)
-	os.Exit(1)
+	// Default: build a native executable.
+	os.Exit(runBuild(args))
+}
+
+// parseInterspersed parses flags that follow positional arguments, so
+// "yoruc foo.yoru -o foo" and "yoruc build -o foo foo.yoru" both work.
+// It returns the positional arguments in order. A "--" ends the flags and
+// is kept, so that "yoruc run" can tell the inputs from the program
+// arguments.
+func parseInterspersed(args []string) []string {
+	var positional []string
+	for len(args) > 0 {
+		if args[0] == "--" {
+			return append(positional, args...)
+		}
+		if !strings.HasPrefix(args[0], "-") || args[0] == "-" {
+			positional = append(positional, args[0])
+			args = args[1:]
+			continue
+		}
+		// flag.ExitOnError: Parse exits on bad flags.
+		_ = flag.CommandLine.Parse(args)
+		rest := flag.Args()
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			return append(positional, args[n-1:]...)
+		}
+		args = rest
+	}
+	return positional
+}
+
+// debugOptions lists the options accepted by -d.
+var debugOptions = []string{
+	"checkelim", // report the nil and bounds checks removed by checkelim
+}
+
+// checkDebugOpts returns an error if -d names an unknown option.
+func checkDebugOpts() error {
+	for _, opt := range strings.Split(*debugOpts, ",") {
+		if opt != "" && !slices.Contains(debugOptions, opt) {
+			return fmt.Errorf("unknown debug option %q (valid: %s)", opt, strings.Join(debugOptions, ", "))
+		}
+	}
+	return nil
+}
+
+// checkPassFlags returns an error if -passes names an unknown pass.
+func checkPassFlags() error {
+	_, err := passes.NewPipeline(pipelineNames(), &passes.Context{})
+	return err
+}
+
+// debugEnabled reports whether -d includes the option name.
+func debugEnabled(name string) bool {
+	return slices.Contains(strings.Split(*debugOpts, ","), name)
 }
 
 // runEmitAST parses the input file and outputs the AST.
@@ -343,61 +527,18 @@ func checkTool(name string, args ...string) (string, bool) {
 }
 
 // runEmitTypedAST parses, type-checks, and outputs the typed AST.
-func runEmitTypedAST(filename string) int {
-	f, err := os.Open(filename)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+func runEmitTypedAST(inputs []string) int {
+	cp, typeOK := parseAndCheck(inputs)
+	if cp == nil {
 		return 1
 	}
-	defer f.Close()
-
-	var parseErrs []string
-	parseErrh := func(pos syntax.Pos, msg string) {
-		parseErrs = append(parseErrs, fmt.Sprintf("%s: %s", pos, msg))
-	}
-
-	p := syntax.NewParser(filename, f, parseErrh)
-	if *noASI {
-		p.SetASIEnabled(false)
-	}
-	ast := p.Parse()
-
-	// Print parse errors
-	for _, e := range parseErrs {
-		fmt.Fprintln(os.Stderr, e)
-	}
-	if len(parseErrs) > 0 {
-		return 1
-	}
-
-	// Type check
-	var typeErrs []string
-	typeErrh := func(pos syntax.Pos, msg string) {
-		typeErrs = append(typeErrs, fmt.Sprintf("%s: %s", pos, msg))
-	}
-
-	conf := &types2.Config{
-		Error: typeErrh,
-		Sizes: types.DefaultSizes,
-	}
-	info := &types2.Info{
-		Types:  make(map[syntax.Expr]types2.TypeAndValue),
-		Defs:   make(map[*syntax.Name]types.Object),
-		Uses:   make(map[*syntax.Name]types.Object),
-		Scopes: make(map[syntax.Node]*types.Scope),
-	}
-
-	pkg, _ := types2.Check(filename, ast, conf, info)
-
-	// Print type errors
-	for _, e := range typeErrs {
-		fmt.Fprintln(os.Stderr, e)
-	}
 
 	// Output typed AST
-	printTypedAST(ast, info, pkg)
+	for _, file := range cp.files {
+		printTypedAST(file, cp.info, cp.pkg)
+	}
 
-	if len(typeErrs) > 0 {
+	if !typeOK {
 		return 1
 	}
 	return 0
@@ -493,9 +634,9 @@ func printTypedStmt(stmt syntax.Stmt, info *types2.Info, indent string) {
 
 	case *syntax.ReturnStmt:
 		fmt.Printf("%sReturnStmt\n", indent)
-		if s.Result != nil {
-			fmt.Printf("%s  Result: ", indent)
-			printTypedExpr(s.Result, info)
+		for i, r := range s.Results {
+			fmt.Printf("%s  Result[%d]: ", indent, i)
+			printTypedExpr(r, info)
 			fmt.Println()
 		}
 
@@ -616,69 +757,20 @@ func typedExprString(expr syntax.Expr, info *types2.Info) string {
 }
 
 // runEmitLayout parses, type-checks, and outputs struct layouts.
-func runEmitLayout(filename string) int {
-	f, err := os.Open(filename)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		return 1
-	}
-	defer f.Close()
-
-	var parseErrs []string
-	parseErrh := func(pos syntax.Pos, msg string) {
-		parseErrs = append(parseErrs, fmt.Sprintf("%s: %s", pos, msg))
-	}
-
-	p := syntax.NewParser(filename, f, parseErrh)
-	if *noASI {
-		p.SetASIEnabled(false)
-	}
-	ast := p.Parse()
-
-	// Print parse errors
-	for _, e := range parseErrs {
-		fmt.Fprintln(os.Stderr, e)
-	}
-	if len(parseErrs) > 0 {
+func runEmitLayout(inputs []string) int {
+	cp, typeOK := parseAndCheck(inputs)
+	if cp == nil {
 		return 1
 	}
 
-	// Type check
-	var typeErrs []string
-	typeErrh := func(pos syntax.Pos, msg string) {
-		typeErrs = append(typeErrs, fmt.Sprintf("%s: %s", pos, msg))
-	}
-
-	conf := &types2.Config{
-		Error: typeErrh,
-		Sizes: types.DefaultSizes,
-	}
-	info := &types2.Info{
-		Types:  make(map[syntax.Expr]types2.TypeAndValue),
-		Defs:   make(map[*syntax.Name]types.Object),
-		Uses:   make(map[*syntax.Name]types.Object),
-		Scopes: make(map[syntax.Node]*types.Scope),
-	}
-
-	_, _ = types2.Check(filename, ast, conf, info)
-
-	// Print type errors
-	for _, e := range typeErrs {
-		fmt.Fprintln(os.Stderr, e)
-	}
-
 	// Output struct layouts
+	sizes := cp.sizes
 	fmt.Println("=== Struct Layouts ===")
 	fmt.Println()
 
-	for _, decl := range ast.Decls {
-		td, ok := decl.(*syntax.TypeDecl)
-		if !ok {
-			continue
-		}
-
+	for _, td := range typeDecls(cp.files) {
 		// Get the type object
-		obj := info.Defs[td.Name]
+		obj := cp.info.Defs[td.Name]
 		if obj == nil {
 			continue
 		}
@@ -699,11 +791,12 @@ func runEmitLayout(filename string) int {
 		}
 
 		// Print struct layout
+		sizes.ComputeLayout(st)
 		fmt.Printf("type %s struct {\n", td.Name.Value)
 		for i, field := range st.Fields() {
 			offset := st.Offset(i)
-			size := types.DefaultSizes.Sizeof(field.Type())
-			align := types.DefaultSizes.Alignof(field.Type())
+			size := sizes.Sizeof(field.Type())
+			align := sizes.Alignof(field.Type())
 			fmt.Printf("    %-10s %-15s // offset: %d, size: %d, align: %d\n",
 				field.Name(), field.Type(), offset, size, align)
 		}
@@ -712,185 +805,123 @@ func runEmitLayout(filename string) int {
 		fmt.Println()
 	}
 
-	if len(typeErrs) > 0 {
+	if !typeOK {
 		return 1
 	}
 	return 0
 }
 
-// runEmitSSA parses, type-checks, and outputs SSA for all functions.
-func runEmitSSA(filename string) int {
-	f, err := os.Open(filename)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+// runEmitTypeDesc parses, type-checks, and outputs the GC type descriptor
+// of each declared type: its size and the offsets of its ref slots, as
+// emitted into the TypeDesc globals by the code generator.
+func runEmitTypeDesc(inputs []string) int {
+	cp, typeOK := parseAndCheck(inputs)
+	if cp == nil {
 		return 1
 	}
-	defer f.Close()
-
-	var parseErrs []string
-	parseErrh := func(pos syntax.Pos, msg string) {
-		parseErrs = append(parseErrs, fmt.Sprintf("%s: %s", pos, msg))
-	}
 
-	p := syntax.NewParser(filename, f, parseErrh)
-	if *noASI {
-		p.SetASIEnabled(false)
+	for _, td := range typeDecls(cp.files) {
+		tn, ok := cp.info.Defs[td.Name].(*types.TypeName)
+		if !ok || tn.Type() == nil {
+			continue
+		}
+		t := tn.Type()
+		offs := cp.sizes.RefOffsets(t)
+		strs := make([]string, len(offs))
+		for i, off := range offs {
+			strs[i] = strconv.FormatInt(off, 10)
+		}
+		fmt.Printf("typedesc %s size=%d num_ptrs=%d offsets=[%s]\n",
+			td.Name.Value, cp.sizes.Sizeof(t), len(offs), strings.Join(strs, " "))
 	}
-	ast := p.Parse()
 
-	for _, e := range parseErrs {
-		fmt.Fprintln(os.Stderr, e)
-	}
-	if len(parseErrs) > 0 {
+	if !typeOK {
 		return 1
 	}
+	return 0
+}
 
-	// Type check.
-	var typeErrs []string
-	typeErrh := func(pos syntax.Pos, msg string) {
-		typeErrs = append(typeErrs, fmt.Sprintf("%s: %s", pos, msg))
-	}
-
-	conf := &types2.Config{
-		Error: typeErrh,
-		Sizes: types.DefaultSizes,
-	}
-	info := &types2.Info{
-		Types:  make(map[syntax.Expr]types2.TypeAndValue),
-		Defs:   make(map[*syntax.Name]types.Object),
-		Uses:   make(map[*syntax.Name]types.Object),
-		Scopes: make(map[syntax.Node]*types.Scope),
+// typeDecls returns the top-level type declarations of all files in
+// source order.
+func typeDecls(files []*syntax.File) []*syntax.TypeDecl {
+	var tds []*syntax.TypeDecl
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if td, ok := decl.(*syntax.TypeDecl); ok {
+				tds = append(tds, td)
+			}
+		}
 	}
+	return tds
+}
 
-	_, _ = types2.Check(filename, ast, conf, info)
-
-	for _, e := range typeErrs {
-		fmt.Fprintln(os.Stderr, e)
-	}
-	if len(typeErrs) > 0 {
+// runEmitSSA parses, type-checks, and outputs SSA for all functions.
+func runEmitSSA(inputs []string) int {
+	pkgs, ok := compileSSA(inputs)
+	if !ok {
 		return 1
 	}
 
-	// Build SSA.
-	funcs := ssa.BuildFile(ast, info, types.DefaultSizes)
-
-	// Define pass pipeline.
-	pipeline := []passes.Pass{
-		{Name: "mem2reg", Fn: passes.Mem2Reg},
-	}
-	passCfg := passes.Config{
-		DumpBefore: *dumpBefore,
-		DumpAfter:  *dumpAfter,
-		Verify:     *ssaVerify,
-		DumpFunc:   *dumpFunc,
-	}
+	printSSA(allFuncs(pkgs))
+	return 0
+}
 
-	// Run pass pipeline on each function.
-	for _, fn := range funcs {
-		if *ssaVerify {
-			if err := ssa.Verify(fn); err != nil {
-				fmt.Fprintf(os.Stderr, "SSA verification failed for %s (before passes):\n%v\n", fn.Name, err)
-				return 1
+// printSSA outputs the functions (or only the -dump-func one) in the
+// format read by ssa.Parse, preceded by the declarations they need.
+func printSSA(funcs []*ssa.Func) {
+	if *dumpFunc != "" {
+		var dumped []*ssa.Func
+		for _, fn := range funcs {
+			if fn.Name == *dumpFunc {
+				dumped = append(dumped, fn)
 			}
 		}
-		ssa.ComputeDom(fn)
-		if err := passes.Run(fn, pipeline, passCfg); err != nil {
-			fmt.Fprintf(os.Stderr, "pass pipeline failed for %s:\n%v\n", fn.Name, err)
-			return 1
-		}
+		funcs = dumped
 	}
-
-	// Print SSA functions.
+	ssa.FprintDecls(os.Stdout, funcs)
 	for i, fn := range funcs {
-		if *dumpFunc != "" && fn.Name != *dumpFunc {
-			continue
-		}
 		if i > 0 {
 			fmt.Println()
 		}
 		ssa.Print(fn)
 	}
-
-	return 0
 }
 
-// runEmitLL parses, type-checks, builds SSA, and outputs LLVM IR.
-func runEmitLL(filename string) int {
-	f, err := os.Open(filename)
+// runRunPasses parses a .ssa file, runs the passes named by -run-passes
+// on its functions, and outputs the resulting SSA.
+func runRunPasses(inputs []string) int {
+	if len(inputs) != 1 || filepath.Ext(inputs[0]) != ".ssa" {
+		fmt.Fprintln(os.Stderr, "error: -run-passes takes a single .ssa file")
+		return 1
+	}
+	f, err := os.Open(inputs[0])
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		return 1
 	}
 	defer f.Close()
-
-	var parseErrs []string
-	parseErrh := func(pos syntax.Pos, msg string) {
-		parseErrs = append(parseErrs, fmt.Sprintf("%s: %s", pos, msg))
-	}
-
-	p := syntax.NewParser(filename, f, parseErrh)
-	if *noASI {
-		p.SetASIEnabled(false)
-	}
-	ast := p.Parse()
-
-	for _, e := range parseErrs {
-		fmt.Fprintln(os.Stderr, e)
-	}
-	if len(parseErrs) > 0 {
+	funcs, err := ssa.Parse(inputs[0], f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		return 1
 	}
 
-	// Type check.
-	var typeErrs []string
-	typeErrh := func(pos syntax.Pos, msg string) {
-		typeErrs = append(typeErrs, fmt.Sprintf("%s: %s", pos, msg))
-	}
-
-	conf := &types2.Config{
-		Error: typeErrh,
-		Sizes: types.DefaultSizes,
-	}
-	info := &types2.Info{
-		Types:  make(map[syntax.Expr]types2.TypeAndValue),
-		Defs:   make(map[*syntax.Name]types.Object),
-		Uses:   make(map[*syntax.Name]types.Object),
-		Scopes: make(map[syntax.Node]*types.Scope),
-	}
-
-	_, _ = types2.Check(filename, ast, conf, info)
-
-	for _, e := range typeErrs {
-		fmt.Fprintln(os.Stderr, e)
-	}
-	if len(typeErrs) > 0 {
+	// As in compileSSA, callees are optimized before their callers.
+	pipeline, err := newPipeline(strings.Split(*runPassList, ","), funcs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		return 1
 	}
-
-	// Build SSA.
-	funcs := ssa.BuildFile(ast, info, types.DefaultSizes)
-
-	// Define pass pipeline.
-	pipeline := []passes.Pass{
-		{Name: "mem2reg", Fn: passes.Mem2Reg},
-	}
-	passCfg := passes.Config{
-		DumpBefore: *dumpBefore,
-		DumpAfter:  *dumpAfter,
-		Verify:     *ssaVerify,
-		DumpFunc:   *dumpFunc,
+	if !runPipeline(passes.BottomUp(funcs), pipeline, true) {
+		return 1
 	}
 
-	// Run pass pipeline on each function.
-	for _, fn := range funcs {
-		ssa.ComputeDom(fn)
-		if err := passes.Run(fn, pipeline, passCfg); err != nil {
-			fmt.Fprintf(os.Stderr, "pass pipeline failed for %s:\n%v\n", fn.Name, err)
-			return 1
-		}
-	}
+	printSSA(funcs)
+	return 0
+}
 
-	// Generate LLVM IR.
+// runEmitLL parses, type-checks, builds SSA, and outputs LLVM IR.
+func runEmitLL(inputs []string) int {
 	w := os.Stdout
 	if *output != "" {
 		outFile, err := os.Create(*output)
@@ -901,11 +932,5 @@ func runEmitLL(filename string) int {
 		defer outFile.Close()
 		w = outFile
 	}
-
-	if err := codegen.Generate(w, funcs, types.DefaultSizes); err != nil {
-		fmt.Fprintf(os.Stderr, "codegen error: %v\n", err)
-		return 1
-	}
-
-	return 0
+	return writeLL(inputs, w)
 }
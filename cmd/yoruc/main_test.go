@@ -1,11 +1,18 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/you-not-fish/yoru/internal/ssa/passes"
 )
 
 func TestRunEmitTypedASTIncludesNestedExprTypes(t *testing.T) {
@@ -27,7 +34,7 @@ func main() {
 `
 	filename := writeTempYoruFile(t, src)
 	code, out, errOut := captureOutput(t, func() int {
-		return runEmitTypedAST(filename)
+		return runEmitTypedAST([]string{filename})
 	})
 
 	if code != 0 {
@@ -57,7 +64,7 @@ type Pair struct {
 `
 	filename := writeTempYoruFile(t, src)
 	code, out, errOut := captureOutput(t, func() int {
-		return runEmitLayout(filename)
+		return runEmitLayout([]string{filename})
 	})
 
 	if code != 0 {
@@ -80,6 +87,167 @@ type Pair struct {
 	}
 }
 
+func TestRunEmitTypeDesc(t *testing.T) {
+	src := `package main
+
+type Node struct {
+	val  int
+	next ref Node
+}
+
+type Pair struct {
+	flag  bool
+	nodes [2]ref Node
+	p     *int
+}
+`
+	filename := writeTempYoruFile(t, src)
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitTypeDesc([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitTypeDesc exit=%d\nstderr:\n%s\nstdout:\n%s", code, errOut, out)
+	}
+	want := "typedesc Node size=16 num_ptrs=1 offsets=[8]\n" +
+		"typedesc Pair size=32 num_ptrs=2 offsets=[8 16]\n"
+	if out != want {
+		t.Fatalf("typedesc output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestRunEmitLLTypeDescs(t *testing.T) {
+	src := `package main
+
+type Node struct {
+	val  int
+	next ref Node
+}
+
+func main() {
+	var a ref Node = new(Node)
+	var b ref Node = new(Node)
+	a.next = b
+	var n ref int = new(int)
+	println(a.val, b.val, n == nil)
+}
+`
+	filename := writeTempYoruFile(t, src)
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitLL([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitLL exit=%d\nstderr:\n%s", code, errOut)
+	}
+	for _, want := range []string{
+		"; Type descriptors",
+		"@.typedesc.0.offsets = private unnamed_addr constant [1 x i32] [i32 8]",
+		"@.typedesc.0 = private constant { i64, i64, ptr } { i64 16, i64 1, ptr @.typedesc.0.offsets }",
+		"@.typedesc.1 = private constant { i64, i64, ptr } { i64 8, i64 0, ptr null }",
+		"call ptr @rt_alloc(i64 16, ptr @.typedesc.0)",
+		"call ptr @rt_alloc(i64 8, ptr @.typedesc.1)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LLVM IR missing %q:\n%s", want, out)
+		}
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.Contains(line, "@rt_alloc(i64") && strings.Contains(line, "ptr null") {
+			t.Errorf("rt_alloc called without a type descriptor: %s", line)
+		}
+	}
+	if n := strings.Count(out, "private constant { i64, i64, ptr }"); n != 2 {
+		t.Errorf("got %d type descriptors, want 2:\n%s", n, out)
+	}
+}
+
+func TestRunEmitLLGCRoots(t *testing.T) {
+	src := `package main
+
+type Node struct {
+	val  int
+	next ref Node
+}
+
+type Pair struct {
+	left  ref Node
+	right ref Node
+}
+
+//yoru:noinline
+func mk(v int) ref Node {
+	var n ref Node = new(Node)
+	n.val = v
+	return n
+}
+
+//yoru:noinline
+func add(x ref Node, y ref Node) int {
+	return x.val + y.val
+}
+
+//yoru:noinline
+func twice(x int) int {
+	return x * 2
+}
+
+//yoru:noinline
+func first(p Pair) int {
+	var n ref Node = mk(0)
+	return p.left.val + n.val
+}
+
+//yoru:noinline
+func (p *Pair) setLeft(n ref Node) {
+	p.left = n
+}
+
+func main() {
+	println(add(mk(1), mk(2)), twice(3))
+	var p Pair
+	p.setLeft(mk(4))
+	println(first(p))
+}
+`
+	filename := writeTempYoruFile(t, src)
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitLL([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitLL exit=%d\nstderr:\n%s", code, errOut)
+	}
+	for _, want := range []string{
+		"declare void @llvm.gcroot(ptr, ptr)",
+		`define void @yoru_main() gc "shadow-stack" {`,
+		// p is an aggregate root described by its TypeDesc.
+		"call void @llvm.gcroot(ptr %v8, ptr @.typedesc.1)",
+		// The result of mk(1) is spilled while mk(2) runs, then reloaded.
+		"call void @llvm.gcroot(ptr %v1.root, ptr null)",
+		"store ptr %v1, ptr %v1.root",
+		"= load ptr, ptr %v1.root",
+		", ptr %v3)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LLVM IR missing %q:\n%s", want, out)
+		}
+	}
+	// Functions without refs live across a safepoint need no frame.
+	for _, want := range []string{
+		"define i64 @main.twice(i64 %x) {",
+		"define i64 @main.add(ptr %x, ptr %y) {",
+		"define ptr @main.mk(i64 %v) {",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LLVM IR missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "store ptr %v1, ptr %v1.root") > strings.Index(out, "%v3 = call ptr @main.mk(i64 2)") {
+		t.Errorf("mk(1) spilled after mk(2) is called:\n%s", out)
+	}
+	if !strings.Contains(out, `define i64 @main.first({ ptr, ptr } %p) gc "shadow-stack" {`) {
+		t.Errorf("aggregate parameter is not rooted:\n%s", out)
+	}
+}
+
 func writeTempYoruFile(t *testing.T, src string) string {
 	t.Helper()
 	dir := t.TempDir()
@@ -122,3 +290,1034 @@ func captureOutput(t *testing.T, fn func() int) (code int, stdout string, stderr
 
 	return code, string(outBytes), string(errBytes)
 }
+
+func TestDefaultOutputName(t *testing.T) {
+	tests := map[string]string{
+		"hello.yoru":          "hello",
+		"dir/fib.yoru":        "fib",
+		"noext":               "noext",
+		"/tmp/x/prog.v2.yoru": "prog.v2",
+	}
+	for in, want := range tests {
+		if got := defaultOutputName([]string{in}); got != want {
+			t.Errorf("defaultOutputName(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "myprog")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := defaultOutputName([]string{dir + "/"}); got != "myprog" {
+		t.Errorf("defaultOutputName(dir) = %q, want %q", got, "myprog")
+	}
+}
+
+func TestRunBuildReportsMissingClang(t *testing.T) {
+	filename := writeTempYoruFile(t, "package main\n\nfunc main() {\n\tprintln(1)\n}\n")
+	t.Setenv("PATH", t.TempDir())
+
+	code, _, errOut := captureOutput(t, func() int {
+		return runBuild([]string{filename})
+	})
+	if code == 0 {
+		t.Fatal("runBuild succeeded without clang")
+	}
+	if !strings.Contains(errOut, "clang not found") {
+		t.Fatalf("missing toolchain error not reported:\n%s", errOut)
+	}
+}
+
+func TestRunBuildProducesExecutable(t *testing.T) {
+	if _, err := exec.LookPath("clang"); err != nil {
+		t.Skip("clang not found, skipping build test")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	filename := writeTempYoruFile(t, "package main\n\nfunc main() {\n\tprintln(6 * 7)\n}\n")
+	bin := filepath.Join(t.TempDir(), "prog")
+	*output = bin
+	defer func() { *output = "" }()
+
+	code, out, errOut := captureOutput(t, func() int {
+		return runBuild([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runBuild exit=%d\nstderr:\n%s\nstdout:\n%s", code, errOut, out)
+	}
+
+	got, err := exec.Command(bin).Output()
+	if err != nil {
+		t.Fatalf("running %s: %v", bin, err)
+	}
+	if string(got) != "42\n" {
+		t.Fatalf("output = %q, want %q", got, "42\n")
+	}
+}
+
+func TestGCEnvFromFlags(t *testing.T) {
+	*gcStats, *gcStress = true, true
+	defer func() { *gcStats, *gcStress = false, false }()
+
+	got := strings.Join(gcEnv(), " ")
+	if got != "YORU_GC_STATS=1 YORU_GC_STRESS=1" {
+		t.Fatalf("gcEnv() = %q", got)
+	}
+}
+
+func TestRunRunForwardsExitStatus(t *testing.T) {
+	if _, err := exec.LookPath("clang"); err != nil {
+		t.Skip("clang not found, skipping run test")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	filename := writeTempYoruFile(t, "package main\n\nfunc main() {\n\tprintln(1)\n\tpanic(\"boom\")\n}\n")
+	code, out, errOut := captureOutput(t, func() int {
+		return runRun([]string{filename}, nil)
+	})
+	if code != 1 {
+		t.Fatalf("runRun exit=%d, want 1\nstderr:\n%s", code, errOut)
+	}
+	if out != "1\n" {
+		t.Fatalf("stdout = %q, want %q", out, "1\n")
+	}
+	if !strings.Contains(errOut, "panic: boom") {
+		t.Fatalf("stderr missing panic message:\n%s", errOut)
+	}
+}
+
+func TestRunEmitLLUsesTarget(t *testing.T) {
+	filename := writeTempYoruFile(t, "package main\n\nfunc main() {\n\tprintln(1)\n}\n")
+	*targetName = "x86_64-linux-gnu"
+	defer func() { *targetName = "" }()
+
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitLL([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitLL exit=%d\nstderr:\n%s", code, errOut)
+	}
+	if !strings.Contains(out, `target triple = "x86_64-unknown-linux-gnu"`) {
+		t.Fatalf("LLVM IR missing x86-64 triple:\n%s", out)
+	}
+	if !strings.Contains(out, `target datalayout = "e-m:e-`) {
+		t.Fatalf("LLVM IR missing ELF data layout:\n%s", out)
+	}
+}
+
+func TestRunEmitSSAMultiFilePackage(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yoru": `package main
+
+func main() {
+	var p Point
+	p.x = 40
+	println(p.Add(2))
+}
+`,
+		"b.yoru": `package main
+
+type Point struct {
+	x int
+}
+
+func (p Point) Add(n int) int {
+	return p.x + n
+}
+`,
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitSSA([]string{dir})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitSSA exit=%d\nstderr:\n%s", code, errOut)
+	}
+	if !strings.Contains(out, "func main()") || !strings.Contains(out, "func (p Point) Add(") {
+		t.Fatalf("SSA missing functions from both files:\n%s", out)
+	}
+}
+
+func TestRunEmitSSAMultiFileDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yoru")
+	b := filepath.Join(dir, "b.yoru")
+	if err := os.WriteFile(a, []byte("package main\n\nfunc main() {\n\thelper()\n}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("package main\n\nfunc helper() {\n\tvar x int = true\n}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, errOut := captureOutput(t, func() int {
+		return runEmitSSA([]string{a, b})
+	})
+	if code == 0 {
+		t.Fatal("runEmitSSA succeeded on ill-typed package")
+	}
+	if !strings.Contains(errOut, b+":4:") {
+		t.Fatalf("diagnostic does not name b.yoru:\n%s", errOut)
+	}
+	if strings.Contains(errOut, "undefined: helper") {
+		t.Fatalf("cross-file function not resolved:\n%s", errOut)
+	}
+}
+
+func TestRunEmitLLImportedPackages(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"yoru.mod": "module example.com/app\n",
+		"main.yoru": `package main
+
+import "example.com/app/util"
+
+func Add(a int, b int) int {
+	return a - b
+}
+
+func main() {
+	println(util.Add(1, 2), Add(1, 2))
+}
+`,
+		"util/util.yoru": `package util
+
+//yoru:noinline
+func Add(a int, b int) int {
+	return a + b
+}
+`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitLL([]string{dir})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitLL exit=%d\nstderr:\n%s", code, errOut)
+	}
+	for _, want := range []string{
+		`define i64 @"example.com/app/util.Add"(`,
+		`define i64 @main.Add(`,
+		`call i64 @"example.com/app/util.Add"(`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LLVM IR missing %q:\n%s", want, out)
+		}
+	}
+}
+
+// TestRunEmitLLMethodPackageCollision checks that the method T.F of the main
+// package and the function F of a package with import path T (imported
+// through mid, since main cannot import it next to its type T) get
+// distinct symbols.
+func TestRunEmitLLMethodPackageCollision(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.yoru": `package main
+
+import "mid"
+
+type T struct {
+	x int
+}
+
+//yoru:noinline
+func (t T) F() int {
+	return 2
+}
+
+func main() {
+	var t T
+	println(mid.G(), t.F())
+}
+`,
+		"mid/mid.yoru": `package mid
+
+import "T"
+
+func G() int {
+	return T.F()
+}
+`,
+		"T/t.yoru": `package T
+
+//yoru:noinline
+func F() int {
+	return 1
+}
+`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitLL([]string{dir})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitLL exit=%d\nstderr:\n%s", code, errOut)
+	}
+	for _, want := range []string{
+		`define i64 @T.F() {`,
+		`define i64 @main.T$F({ i64 } %recv) {`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LLVM IR missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunEmitLLGlobals(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"yoru.mod": "module example.com/app\n",
+		"main.yoru": `package main
+
+import "example.com/app/util"
+
+var total int = util.Scale * 2
+
+func main() {
+	println(total, util.Last.N)
+}
+`,
+		"util/util.yoru": `package util
+
+type Box struct {
+	N    int
+	Next ref Box
+}
+
+var Scale int = 21
+var Last ref Box = new(Box)
+`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitLL([]string{dir})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitLL exit=%d\nstderr:\n%s", code, errOut)
+	}
+	for _, want := range []string{
+		`@"example.com/app/util.Scale" = global i64 21`,
+		`@"example.com/app/util.Last" = global ptr zeroinitializer`,
+		`@main.total = global i64 zeroinitializer`,
+		`load i64, ptr @"example.com/app/util.Scale"`,
+		`define void @yoru_init() {`,
+		`call void @rt_register_root(ptr @"example.com/app/util.Last")`,
+		`call void @"example.com/app/util.init$"()`,
+		`call void @main.init$()`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LLVM IR missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, `call void @"example.com/app/util.init$"()`) > strings.Index(out, `call void @main.init$()`) {
+		t.Errorf("main initialized before its dependency:\n%s", out)
+	}
+}
+
+func TestRunEmitLLBoundsCheck(t *testing.T) {
+	src := `package main
+
+//yoru:noinline
+func get(a [3]int, i int) int {
+	return a[i] + a[2]
+}
+
+func main() {
+	var a [3]int
+	println(get(a, 1))
+}
+`
+	filename := writeTempYoruFile(t, src)
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitLL([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitLL exit=%d\nstderr:\n%s", code, errOut)
+	}
+	pos := filename + ":5:11"
+	for _, want := range []string{
+		"declare void @rt_bounds_check(i64, i64, { ptr, i64 })",
+		`c"` + pos + `"`,
+		"i64 " + strconv.Itoa(len(pos)) + ", 1",
+		", i64 3, { ptr, i64 } %",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LLVM IR missing %q:\n%s", want, out)
+		}
+	}
+	// The constant index a[2] is not checked.
+	if n := strings.Count(out, "call void @rt_bounds_check"); n != 1 {
+		t.Errorf("got %d rt_bounds_check calls, want 1:\n%s", n, out)
+	}
+}
+
+func TestRunEmitLLMultipleResults(t *testing.T) {
+	src := `package main
+
+//yoru:noinline
+func lookup(k int) (int, bool) {
+	if k > 0 {
+		return k * 2, true
+	}
+	return 0, false
+}
+
+func main() {
+	v, ok := lookup(3)
+	if ok {
+		println(v)
+	}
+}
+`
+	filename := writeTempYoruFile(t, src)
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitLL([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitLL exit=%d\nstderr:\n%s", code, errOut)
+	}
+	for _, want := range []string{
+		"define { i64, i1 } @main.lookup(i64 %k) {",
+		"insertvalue { i64, i1 } undef, i64 0, 0",
+		"ret { i64, i1 } %",
+		"call { i64, i1 } @main.lookup(i64 3)",
+		"extractvalue { i64, i1 } %",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LLVM IR missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunEmitLLInterfaces(t *testing.T) {
+	src := `package main
+
+type Shape interface {
+	Area() int
+	Scale(k int)
+}
+
+type Rect struct {
+	w int
+	h int
+}
+
+func (r Rect) Area() int {
+	return r.w * r.h
+}
+
+func (r *Rect) Scale(k int) {
+	r.w = r.w * k
+}
+
+//yoru:noinline
+func grow(s Shape) int {
+	s.Scale(2)
+	return s.Area()
+}
+
+func main() {
+	var r ref Rect = new(Rect)
+	r.w = 1
+	r.h = 2
+	println(grow(r))
+}
+`
+	filename := writeTempYoruFile(t, src)
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitLL([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitLL exit=%d\nstderr:\n%s", code, errOut)
+	}
+	for _, want := range []string{
+		"@.itab.0 = private unnamed_addr constant { ptr, { ptr, i64 }, [2 x ptr] } { ptr @.typedesc.0, { ptr, i64 } { ptr @.str.1, i64 8 }, [2 x ptr] [ptr @main.Rect$Area$iface, ptr @main.Rect$Scale] }",
+		"define i64 @main.grow({ ptr, ptr } %s)",
+		"insertvalue { ptr, ptr } undef, ptr @.itab.0, 0",
+		"getelementptr { ptr, { ptr, i64 }, [0 x ptr] }, ptr %",
+		"define private i64 @main.Rect$Area$iface(ptr %data) {",
+		"call i64 @main.Rect$Area({ i64, i64 } %recv)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LLVM IR missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunEmitLLTypeAssertions(t *testing.T) {
+	src := `package main
+
+type Shape interface {
+	Area() int
+}
+
+type Rect struct {
+	w int
+}
+
+func (r Rect) Area() int {
+	return r.w
+}
+
+//yoru:noinline
+func isRect(s Shape) bool {
+	_, ok := s.(Rect)
+	return ok
+}
+
+//yoru:noinline
+func width(s Shape) int {
+	return s.(Rect).w
+}
+
+func main() {
+	var s Shape = Rect{w: 3}
+	println(isRect(s), width(s))
+}
+`
+	filename := writeTempYoruFile(t, src)
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitLL([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitLL exit=%d\nstderr:\n%s", code, errOut)
+	}
+	for _, want := range []string{
+		"declare void @rt_panic_assert(ptr, { ptr, i64 }, { ptr, i64 })",
+		"load ptr, ptr %",
+		"icmp eq ptr %",
+		"phi i1 [ false, %",
+		"call void @rt_panic_assert(ptr %",
+		"unreachable",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LLVM IR missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunEmitLLSlices(t *testing.T) {
+	src := `package main
+
+//yoru:noinline
+func push(s []ref int, x ref int) []ref int {
+	return append(s, x)
+}
+
+//yoru:noinline
+func mid(s []ref int) []ref int {
+	return s[1:2]
+}
+
+func main() {
+	s := make([]ref int, 2)
+	s = push(s, new(int))
+	println(len(mid(s)), cap(s))
+}
+`
+	filename := writeTempYoruFile(t, src)
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitLL([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitLL exit=%d\nstderr:\n%s", code, errOut)
+	}
+	pos := filename + ":10:9"
+	for _, want := range []string{
+		"declare ptr @rt_makeslice(ptr, i64, i64)",
+		"declare void @rt_growslice(ptr, ptr, i64)",
+		"declare void @rt_slice_check(i64, i64, i64, { ptr, i64 })",
+		// The slice TypeDesc traces the data pointer.
+		"@.typedesc.2 = private constant { i64, i64, ptr } { i64 24, i64 1, ptr @.typedesc.2.offsets }",
+		"define { ptr, i64, i64 } @main.push({ ptr, i64, i64 } %s, ptr %x) gc \"shadow-stack\" {",
+		"call void @rt_growslice(ptr %",
+		"call void @rt_slice_check(i64 1, i64 2, i64 %",
+		`c"` + pos + `"`,
+		"call ptr @rt_makeslice(ptr @.typedesc.0, i64 2, i64 2)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LLVM IR missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunEmitLLMaps(t *testing.T) {
+	src := `package main
+
+//yoru:noinline
+func put(m map[string]ref int, k string) {
+	m[k] = new(int)
+}
+
+func main() {
+	m := make(map[string]ref int)
+	put(m, "a")
+	v, ok := m["a"]
+	delete(m, "a")
+	println(v != nil, ok, len(m))
+}
+`
+	filename := writeTempYoruFile(t, src)
+	code, out, errOut := captureOutput(t, func() int {
+		return runEmitLL([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitLL exit=%d\nstderr:\n%s", code, errOut)
+	}
+	for _, want := range []string{
+		"declare ptr @rt_makemap(ptr, ptr, i64, i64)",
+		"declare i8 @rt_mapaccess(ptr, ptr, ptr)",
+		"declare void @rt_mapassign(ptr, ptr, ptr)",
+		"declare void @rt_mapdelete(ptr, ptr)",
+		"declare i64 @rt_maplen(ptr)",
+		"define void @main.put(ptr %m, { ptr, i64 } %k) gc \"shadow-stack\" {",
+		"call void @rt_mapassign(ptr %",
+		// String keys are hashed by their contents.
+		", i64 1, i64 0)",
+		"call i8 @rt_mapaccess(ptr %",
+		"call i64 @rt_maplen(ptr %",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("LLVM IR missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestSplitRunArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args             []string
+		inputs, progArgs string
+	}{
+		{[]string{"a.yoru", "b.yoru", "x", "y.yoru"}, "a.yoru b.yoru", "x y.yoru"},
+		{[]string{"main.yoru", "--", "data.yoru", "x"}, "main.yoru", "data.yoru x"},
+		{[]string{"main.yoru", "x", "--", "y"}, "main.yoru", "x -- y"},
+		{[]string{"--", "x"}, "", "x"},
+	} {
+		inputs, progArgs := splitRunArgs(tc.args)
+		if strings.Join(inputs, " ") != tc.inputs || strings.Join(progArgs, " ") != tc.progArgs {
+			t.Errorf("splitRunArgs(%q) = %q, %q, want %q, %q", tc.args, inputs, progArgs, tc.inputs, tc.progArgs)
+		}
+	}
+}
+
+func TestRunEmitSSADumpAfterInline(t *testing.T) {
+	src := `package main
+
+func double(x int) int {
+	return x * 2
+}
+
+//yoru:noinline
+func triple(x int) int {
+	return x * 3
+}
+
+func main() {
+	println(double(2), triple(2))
+}
+`
+	filename := writeTempYoruFile(t, src)
+	*dumpAfter = "inline"
+	*dumpFunc = "main"
+	defer func() { *dumpAfter, *dumpFunc = "", "" }()
+
+	code, _, errOut := captureOutput(t, func() int {
+		return runEmitSSA([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitSSA exit=%d\nstderr:\n%s", code, errOut)
+	}
+	if !strings.Contains(errOut, "--- after inline (main) ---") {
+		t.Fatalf("stderr missing inline dump:\n%s", errOut)
+	}
+	if strings.Contains(errOut, "{double}") {
+		t.Errorf("call to double not inlined:\n%s", errOut)
+	}
+	if !strings.Contains(errOut, "StaticCall <int> {triple}") {
+		t.Errorf("call to noinline triple was inlined:\n%s", errOut)
+	}
+}
+
+func TestRunEmitSSADebugCheckElim(t *testing.T) {
+	src := `package main
+
+type Rect struct {
+	width  int
+	height int
+}
+
+func main() {
+	var r ref Rect = new(Rect)
+	r.width = 3
+	r.height = 4
+	var a [4]int
+	var i int = 0
+	for i < 4 {
+		a[i] = r.width * i
+		i = i + 1
+	}
+	println(a[3])
+}
+`
+	filename := writeTempYoruFile(t, src)
+	*debugOpts = "checkelim"
+	defer func() { *debugOpts = "" }()
+
+	code, _, errOut := captureOutput(t, func() int {
+		return runEmitSSA([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitSSA exit=%d\nstderr:\n%s", code, errOut)
+	}
+	for _, want := range []string{
+		":10:2: nil check removed in main: pointer comes from new()",
+		":11:2: nil check removed in main: pointer comes from new()",
+		":15:5: bounds check removed in main: index in [0, 4) by loop condition",
+	} {
+		if !strings.Contains(errOut, want) {
+			t.Errorf("stderr missing %q:\n%s", want, errOut)
+		}
+	}
+}
+
+func TestCheckDebugOpts(t *testing.T) {
+	defer func() { *debugOpts = "" }()
+	for _, tc := range []struct {
+		opts string
+		ok   bool
+	}{
+		{"", true},
+		{"checkelim", true},
+		{"checkelim,", true},
+		{"checkelim,nope", false},
+	} {
+		*debugOpts = tc.opts
+		if err := checkDebugOpts(); (err == nil) != tc.ok {
+			t.Errorf("checkDebugOpts(%q) = %v, want ok=%v", tc.opts, err, tc.ok)
+		}
+	}
+}
+
+func TestRunInterp(t *testing.T) {
+	src := `package main
+
+func main() {
+	var a [3]int
+	a[1] = 4
+	println("sum", a[0] + a[1])
+	var i int = 3
+	println(a[i])
+}
+`
+	filename := writeTempYoruFile(t, src)
+	code, out, errOut := captureOutput(t, func() int {
+		return runInterp([]string{filename}, nil)
+	})
+	if code != 1 {
+		t.Errorf("runInterp exit=%d, want 1", code)
+	}
+	if out != "sum 4\n" {
+		t.Errorf("stdout = %q, want %q", out, "sum 4\n")
+	}
+	if !strings.Contains(errOut, "panic: ") || !strings.Contains(errOut, ":8:12: index out of range [3] with length 3") {
+		t.Errorf("stderr missing bounds check panic:\n%s", errOut)
+	}
+
+	code, out, errOut = captureOutput(t, func() int {
+		return runInterp([]string{filename}, []string{"a", "b"})
+	})
+	if code != 1 || out != "" || !strings.Contains(errOut, "program arguments are not supported with -interp") {
+		t.Errorf("runInterp with arguments: exit=%d stdout=%q stderr=%q", code, out, errOut)
+	}
+}
+
+func TestRunRunPasses(t *testing.T) {
+	src := `// twice(x) is inlined into f, then mem2reg removes the alloca.
+func f(x int) int:
+  b0: (entry)
+    v0 = Arg <int> {x}
+    v1 = Alloca <*int> {y}
+    v2 = StaticCall <int> {twice} v0
+    Store v1 v2
+    v3 = Load <int> v1
+    Return v3
+
+func twice(x int) int:
+  b0: (entry)
+    v0 = Arg <int> {x}
+    v1 = Add64 <int> v0 v0
+    Return v1
+`
+	filename := filepath.Join(t.TempDir(), "f.ssa")
+	if err := os.WriteFile(filename, []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { *runPassList = "" }()
+
+	*runPassList = "inline,mem2reg,deadcode"
+	code, out, errOut := captureOutput(t, func() int {
+		return runRunPasses([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runRunPasses exit=%d\nstderr:\n%s", code, errOut)
+	}
+	if strings.Contains(out, "StaticCall") || strings.Contains(out, "Alloca") {
+		t.Errorf("call or alloca left in f:\n%s", out)
+	}
+	if !strings.Contains(out, "Add64 <int> v0 v0\n    Return") || !strings.Contains(out, "func twice(x int) int:") {
+		t.Errorf("unexpected SSA:\n%s", out)
+	}
+
+	*runPassList = "mem2reg,nope"
+	code, _, errOut = captureOutput(t, func() int {
+		return runRunPasses([]string{filename})
+	})
+	if code != 1 || !strings.Contains(errOut, `unknown pass "nope"`) {
+		t.Errorf("unknown pass: exit=%d, stderr:\n%s", code, errOut)
+	}
+
+	*runPassList = "mem2reg"
+	bad := filepath.Join(t.TempDir(), "bad.ssa")
+	if err := os.WriteFile(bad, []byte("func f():\n  b0:\n    Return v9\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	code, _, errOut = captureOutput(t, func() int {
+		return runRunPasses([]string{bad})
+	})
+	if code != 1 || !strings.Contains(errOut, "bad.ssa:3: undefined value v9") {
+		t.Errorf("parse error: exit=%d, stderr:\n%s", code, errOut)
+	}
+}
+
+// TestRunEmitSSARoundTrip checks that -emit-ssa output, declarations
+// included, is read back by -run-passes and printed unchanged.
+func TestRunEmitSSARoundTrip(t *testing.T) {
+	src := `package main
+
+type Point struct {
+	x int
+	y int
+}
+
+var origin Point
+
+func (p *Point) Move(dx int) {
+	p.x = p.x + dx
+}
+
+func main() {
+	var p ref Point = new(Point)
+	p.Move(2)
+	origin.Move(p.x)
+	println(origin.x)
+}
+`
+	filename := writeTempYoruFile(t, src)
+	defer func() { *runPassList, *dumpFunc = "", "" }()
+
+	for _, fn := range []string{"", "main"} {
+		*dumpFunc = fn
+		code, emitted, errOut := captureOutput(t, func() int {
+			return runEmitSSA([]string{filename})
+		})
+		if code != 0 {
+			t.Fatalf("runEmitSSA exit=%d\nstderr:\n%s", code, errOut)
+		}
+		if !strings.HasPrefix(emitted, "type Point struct{x int; y int}\nvar origin Point\n") {
+			t.Errorf("-dump-func=%q: missing declarations:\n%s", fn, emitted)
+		}
+
+		ssaFile := filepath.Join(t.TempDir(), "prog.ssa")
+		if err := os.WriteFile(ssaFile, []byte(emitted), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		*runPassList = "deadcode"
+		code, out, errOut := captureOutput(t, func() int {
+			return runRunPasses([]string{ssaFile})
+		})
+		if code != 0 {
+			t.Fatalf("-dump-func=%q: runRunPasses exit=%d\nstderr:\n%s", fn, code, errOut)
+		}
+		if out != emitted {
+			t.Errorf("-dump-func=%q: round trip mismatch\ngot:\n%s\nwant:\n%s", fn, out, emitted)
+		}
+	}
+}
+
+func TestCheckPassFlags(t *testing.T) {
+	defer func() { *passList = "" }()
+	for _, tc := range []struct {
+		passes string
+		ok     bool
+	}{
+		{"", true},
+		{"mem2reg,dce,cse", true},
+		{"mem2reg,nope", false},
+	} {
+		*passList = tc.passes
+		if err := checkPassFlags(); (err == nil) != tc.ok {
+			t.Errorf("checkPassFlags(-passes=%q) = %v, want ok=%v", tc.passes, err, tc.ok)
+		}
+	}
+}
+
+func TestTraceFlag(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want traceFlag
+		ok   bool
+	}{
+		{[]string{"prog.yoru"}, "", true},
+		{[]string{"-trace", "prog.yoru"}, "table", true},
+		{[]string{"-trace=table", "prog.yoru"}, "table", true},
+		{[]string{"-trace=json", "prog.yoru"}, "json", true},
+		{[]string{"-trace=false", "prog.yoru"}, "", true},
+		{[]string{"-trace=jsonl", "prog.yoru"}, "", false},
+	} {
+		fs := flag.NewFlagSet("yoruc", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		var got traceFlag
+		fs.Var(&got, "trace", "")
+		err := fs.Parse(tc.args)
+		if (err == nil) != tc.ok {
+			t.Errorf("%v: err = %v, want ok=%v", tc.args, err, tc.ok)
+			continue
+		}
+		if tc.ok && (got != tc.want || len(fs.Args()) != 1 || fs.Arg(0) != "prog.yoru") {
+			t.Errorf("%v: trace = %q, args = %v; want %q, [prog.yoru]", tc.args, got, fs.Args(), tc.want)
+		}
+	}
+}
+
+func TestOptLevelFlags(t *testing.T) {
+	defer func() { optLevel = passes.MaxOptLevel }()
+	for level := 0; level <= passes.MaxOptLevel; level++ {
+		if err := flag.Set(fmt.Sprintf("O%d", level), "true"); err != nil {
+			t.Fatalf("-O%d: %v", level, err)
+		}
+		if got, want := strings.Join(pipelineNames(), ","), strings.Join(passes.Preset(level), ","); got != want {
+			t.Errorf("-O%d pipeline = %s, want %s", level, got, want)
+		}
+	}
+	if err := flag.Set("O1", "false"); err == nil {
+		t.Error("-O1=false accepted")
+	}
+}
+
+func TestRunEmitSSAStatsAndTrace(t *testing.T) {
+	src := `package main
+
+func inc(x int) int {
+	return x + 1
+}
+
+func main() {
+	var x int = inc(1)
+	if x > 0 {
+		x = x + 1
+	}
+	println(x)
+}
+`
+	filename := writeTempYoruFile(t, src)
+	defer func() { *ssaStats, trace, *passList, *dumpFunc = false, "", "", "" }()
+
+	*ssaStats, *passList, *dumpFunc = true, "mem2reg,dce", "main"
+	code, _, errOut := captureOutput(t, func() int {
+		return runEmitSSA([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitSSA exit=%d\nstderr:\n%s", code, errOut)
+	}
+	if strings.Contains(errOut, "ssa-stats inc:") || !strings.Contains(errOut, "ssa-stats main:\n  pass          blocks  values    phis   calls         time\n  (before)           3      13       0       1\n") {
+		t.Fatalf("unexpected -ssa-stats output:\n%s", errOut)
+	}
+	for _, pass := range []string{"mem2reg", "deadcode"} {
+		if !strings.Contains(errOut, "\n  "+pass+" ") {
+			t.Errorf("-ssa-stats output missing %s:\n%s", pass, errOut)
+		}
+	}
+
+	*ssaStats, *dumpFunc, trace = false, "", "table"
+	code, _, errOut = captureOutput(t, func() int {
+		return runEmitSSA([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitSSA exit=%d\nstderr:\n%s", code, errOut)
+	}
+	lines := strings.Split(strings.TrimSpace(errOut), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[1], "mem2reg            2       2") ||
+		!strings.HasPrefix(lines[2], "deadcode           2") || !strings.HasPrefix(lines[3], "total") {
+		t.Errorf("unexpected -trace=table output:\n%s", errOut)
+	}
+
+	trace = "json"
+	code, _, errOut = captureOutput(t, func() int {
+		return runEmitSSA([]string{filename})
+	})
+	if code != 0 {
+		t.Fatalf("runEmitSSA exit=%d\nstderr:\n%s", code, errOut)
+	}
+	var events struct {
+		TraceEvents []struct {
+			Name, Cat, Ph string
+			Args          struct {
+				Func          string
+				Before, After map[string]int
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(errOut), &events); err != nil {
+		t.Fatalf("-trace=json output is not JSON: %v\n%s", err, errOut)
+	}
+	var names []string
+	for _, e := range events.TraceEvents {
+		if e.Ph != "X" {
+			t.Errorf("event %s has phase %q, want X", e.Name, e.Ph)
+		}
+		if e.Cat == "pass" {
+			names = append(names, e.Args.Func+"/"+e.Name)
+			if e.Name == "mem2reg" && e.Args.Func == "main" && e.Args.After["phis"] != e.Args.Before["phis"]+1 {
+				t.Errorf("mem2reg on main: stats %v -> %v, want one new phi", e.Args.Before, e.Args.After)
+			}
+		} else {
+			names = append(names, e.Name)
+		}
+	}
+	if got := strings.Join(names, " "); got != "inc inc/mem2reg inc/deadcode main main/mem2reg main/deadcode" {
+		t.Errorf("trace events = %s", got)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/you-not-fish/yoru/internal/ssa/interp"
+)
+
+// runRun builds the input package into a temporary directory and executes it.
+//
+// The program inherits yoruc's stdin, stdout and stderr, receives args as
+// its command-line arguments, and its exit status becomes yoruc's exit
+// status. The -gc-* flags are passed to the runtime via YORU_GC_*
+// environment variables.
+func runRun(inputs []string, args []string) int {
+	tmpDir, err := os.MkdirTemp("", "yoru-run-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yoruc: %v\n", err)
+		return 1
+	}
+	if *keepTemps {
+		fmt.Fprintf(os.Stderr, "yoruc: keeping executable in %s\n", tmpDir)
+	} else {
+		defer os.RemoveAll(tmpDir)
+	}
+
+	name := defaultOutputName(inputs)
+	bin := filepath.Join(tmpDir, name)
+	if err := buildExecutable(inputs, bin); err != nil {
+		if !errors.Is(err, errCompile) {
+			fmt.Fprintf(os.Stderr, "yoruc: %v\n", err)
+		}
+		return 1
+	}
+
+	cmd := exec.Command(bin, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), gcEnv()...)
+
+	err = cmd.Run()
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		fmt.Fprintf(os.Stderr, "yoruc: %v\n", err)
+		return 1
+	}
+	// Follow the shell convention for signals: 128 + signal number.
+	if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		fmt.Fprintf(os.Stderr, "yoruc: %s: %v\n", name, ws.Signal())
+		return 128 + int(ws.Signal())
+	}
+	return exitErr.ExitCode()
+}
+
+// runInterp compiles the input package to optimized SSA and executes it
+// with the SSA interpreter, which needs neither clang nor the runtime. A
+// panic is reported like the runtime reports it. Interpreted programs
+// cannot receive arguments, so passing any is an error.
+func runInterp(inputs, progArgs []string) int {
+	if len(progArgs) > 0 {
+		fmt.Fprintln(os.Stderr, "error: program arguments are not supported with -interp")
+		return 1
+	}
+	pkgs, ok := compileSSA(inputs)
+	if !ok {
+		return 1
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	err := interp.Run(pkgs, out)
+	out.Flush()
+	if err != nil {
+		var p *interp.Panic
+		if !errors.As(err, &p) {
+			fmt.Fprintf(os.Stderr, "yoruc: %v\n", err)
+			return 1
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// gcEnv returns the runtime environment variables selected by the
+// -gc-stats, -gc-verbose and -gc-stress flags.
+func gcEnv() []string {
+	var env []string
+	if *gcStats {
+		env = append(env, "YORU_GC_STATS=1")
+	}
+	if *gcVerbose {
+		env = append(env, "YORU_GC_VERBOSE=1")
+	}
+	if *gcStress {
+		env = append(env, "YORU_GC_STRESS=1")
+	}
+	return env
+}
+
+// splitRunArgs splits the positional arguments of "yoruc run" into the
+// package inputs and the program arguments: either a single directory, or
+// the leading run of .yoru files. A "--" ends the inputs early and is
+// dropped, so "yoruc run main.yoru -- data.yoru" passes data.yoru to the
+// program.
+func splitRunArgs(args []string) (inputs, progArgs []string) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	n := 0
+	if fi, err := os.Stat(args[0]); err == nil && fi.IsDir() {
+		n = 1
+	} else {
+		for n < len(args) && args[n] != "--" && strings.HasSuffix(args[n], ".yoru") {
+			n++
+		}
+		if n == 0 && args[0] != "--" {
+			n = 1
+		}
+	}
+	inputs, progArgs = args[:n], args[n:]
+	if len(progArgs) > 0 && progArgs[0] == "--" {
+		progArgs = progArgs[1:]
+	}
+	return inputs, progArgs
+}
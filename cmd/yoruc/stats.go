@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/you-not-fish/yoru/internal/ssa/passes"
+)
+
+// writeSSAStats writes the -ssa-stats report: for each function (or only
+// the -dump-func one), its stats before the first pass and after each
+// pass, with the pass's wall time. runs holds the pass runs of each
+// function.
+func writeSSAStats(w io.Writer, runs [][]passes.PassRun) {
+	for _, fnRuns := range runs {
+		if len(fnRuns) == 0 || *dumpFunc != "" && fnRuns[0].Func != *dumpFunc {
+			continue
+		}
+		fmt.Fprintf(w, "ssa-stats %s:\n", fnRuns[0].Func)
+		fmt.Fprintf(w, "  %-12s %7s %7s %7s %7s %12s\n", "pass", "blocks", "values", "phis", "calls", "time")
+		writeStatsRow(w, "(before)", fnRuns[0].Before, "")
+		for _, r := range fnRuns {
+			writeStatsRow(w, r.Pass, r.After, r.Time.String())
+		}
+	}
+}
+
+func writeStatsRow(w io.Writer, name string, st passes.Stats, elapsed string) {
+	row := fmt.Sprintf("  %-12s %7d %7d %7d %7d %12s", name, st.Blocks, st.Values, st.Phis, st.Calls, elapsed)
+	fmt.Fprintln(w, strings.TrimRight(row, " "))
+}
+
+// writeTraceTable writes the -trace=table report: for each pass, the
+// number of functions it ran on, how many of them it changed the stats
+// of, and its total wall time.
+func writeTraceTable(w io.Writer, runs [][]passes.PassRun) {
+	type passTotal struct {
+		name    string
+		funcs   int
+		changed int
+		time    time.Duration
+	}
+	var totals []*passTotal
+	byName := make(map[string]*passTotal)
+	var total time.Duration
+	for _, fnRuns := range runs {
+		for _, r := range fnRuns {
+			t := byName[r.Pass]
+			if t == nil {
+				t = &passTotal{name: r.Pass}
+				byName[r.Pass] = t
+				totals = append(totals, t)
+			}
+			t.funcs++
+			if r.After != r.Before {
+				t.changed++
+			}
+			t.time += r.Time
+			total += r.Time
+		}
+	}
+
+	fmt.Fprintf(w, "%-12s %7s %7s %12s %6s\n", "pass", "funcs", "changed", "time", "%")
+	for _, t := range totals {
+		fmt.Fprintf(w, "%-12s %7d %7d %12s %6.1f\n", t.name, t.funcs, t.changed, t.time, percent(t.time, total))
+	}
+	fmt.Fprintf(w, "%-12s %7s %7s %12s %6.1f\n", "total", "", "", total, 100.0)
+}
+
+// percent returns d as a percentage of total.
+func percent(d, total time.Duration) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(d) / float64(total)
+}
+
+// A traceEvent is a complete event ("ph": "X") of the Chrome trace event
+// format, as read by chrome://tracing and Perfetto.
+type traceEvent struct {
+	Name string     `json:"name"`
+	Cat  string     `json:"cat"`
+	Ph   string     `json:"ph"`
+	Ts   float64    `json:"ts"`  // start, in microseconds
+	Dur  float64    `json:"dur"` // duration, in microseconds
+	Pid  int        `json:"pid"`
+	Tid  int        `json:"tid"`
+	Args *traceArgs `json:"args,omitempty"`
+}
+
+type traceArgs struct {
+	Func   string     `json:"func"`
+	Before traceStats `json:"before"`
+	After  traceStats `json:"after"`
+}
+
+type traceStats struct {
+	Blocks int `json:"blocks"`
+	Values int `json:"values"`
+	Phis   int `json:"phis"`
+	Calls  int `json:"calls"`
+}
+
+// writeChromeTrace writes the -trace=json report: one event per function
+// spanning its passes, and one per pass run, with the function's stats
+// before and after it.
+func writeChromeTrace(w io.Writer, runs [][]passes.PassRun) error {
+	var t0 time.Time
+	for _, fnRuns := range runs {
+		if len(fnRuns) > 0 {
+			t0 = fnRuns[0].Start
+			break
+		}
+	}
+	micros := func(d time.Duration) float64 { return float64(d) / float64(time.Microsecond) }
+
+	events := []traceEvent{}
+	for _, fnRuns := range runs {
+		if len(fnRuns) == 0 {
+			continue
+		}
+		first, last := fnRuns[0], fnRuns[len(fnRuns)-1]
+		events = append(events, traceEvent{
+			Name: first.Func,
+			Cat:  "func",
+			Ph:   "X",
+			Ts:   micros(first.Start.Sub(t0)),
+			Dur:  micros(last.Start.Add(last.Time).Sub(first.Start)),
+			Pid:  1,
+			Tid:  1,
+		})
+		for _, r := range fnRuns {
+			events = append(events, traceEvent{
+				Name: r.Pass,
+				Cat:  "pass",
+				Ph:   "X",
+				Ts:   micros(r.Start.Sub(t0)),
+				Dur:  micros(r.Time),
+				Pid:  1,
+				Tid:  1,
+				Args: &traceArgs{
+					Func:   r.Func,
+					Before: traceStats(r.Before),
+					After:  traceStats(r.After),
+				},
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}{events})
+}
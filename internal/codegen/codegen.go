@@ -6,6 +6,7 @@ package codegen
 
 import (
 	"fmt"
+	"go/constant"
 	"io"
 
 	"github.com/you-not-fish/yoru/internal/rtabi"
@@ -21,18 +22,71 @@ type generator struct {
 	// String constant table: maps string content to index.
 	strings   []string
 	stringMap map[string]int
+
+	// TypeDesc table: heap-allocated types, indexed by descriptor number.
+	typeDescs []types.Type
+
+	// Itables of the interface conversions, and the value-receiver methods
+	// they reach through wrappers.
+	itabs    []itab
+	wrappers []*types.FuncObj
+
+	// GC roots of each function with roots, and the state of the function
+	// being lowered: its frame and, for each rooted value, the operand
+	// holding it that is valid at the current point of its block.
+	frames map[*ssa.Func]*gcFrame
+	frame  *gcFrame
+	fresh  map[*ssa.Value]string
 }
 
 // Generate writes LLVM IR for the given SSA functions to w.
 func Generate(w io.Writer, funcs []*ssa.Func, sizes *types.Sizes) error {
+	return GenerateProgram(w, []*ssa.Package{{Funcs: funcs}}, sizes)
+}
+
+// GenerateProgram writes LLVM IR for a whole program to w. Packages must be
+// in initialization (dependency) order. If the program has package-level
+// variables, a yoru_init function is generated that registers their ref
+// slots as GC roots and runs the package initializers in order.
+func GenerateProgram(w io.Writer, pkgs []*ssa.Package, sizes *types.Sizes) error {
 	g := &generator{
 		e:         emitter{w: w},
 		sizes:     sizes,
 		stringMap: make(map[string]int),
+		frames:    make(map[*ssa.Func]*gcFrame),
 	}
 
-	// Pre-scan: collect all string constants from all functions.
+	var funcs []*ssa.Func
+	var globals []*ssa.Global
+	for _, pkg := range pkgs {
+		funcs = append(funcs, pkg.Funcs...)
+		if pkg.Init != nil {
+			funcs = append(funcs, pkg.Init)
+		}
+		globals = append(globals, pkg.Globals...)
+	}
+
+	// Pre-scan: collect all string constants from all functions and globals.
 	g.collectStrings(funcs)
+	for _, gv := range globals {
+		if gv.Const != nil && gv.Const.Kind() == constant.String {
+			g.stringIndex(constant.StringVal(gv.Const))
+		}
+	}
+
+	// Pre-scan: collect the itables of interface conversions. Their
+	// wrappers may need the nil dereference panic string.
+	g.collectItabs(funcs)
+
+	// Pre-scan: collect the types allocated on the heap, and the GC roots
+	// of each function with the TypeDescs describing aggregate roots.
+	g.collectTypeDescs(funcs)
+	for _, fn := range funcs {
+		if f := g.analyzeGCRoots(fn); f != nil {
+			g.frames[fn] = f
+			g.collectRootTypeDescs(f)
+		}
+	}
 
 	// Module header.
 	g.emitHeader()
@@ -46,6 +100,33 @@ func Generate(w io.Writer, funcs []*ssa.Func, sizes *types.Sizes) error {
 		g.e.emitLine()
 	}
 
+	// Package-level variables.
+	if len(globals) > 0 {
+		g.e.emitComment("Global variables")
+		for _, gv := range globals {
+			g.emitGlobal(gv)
+		}
+		g.e.emitLine()
+	}
+
+	// GC type descriptors.
+	if len(g.typeDescs) > 0 {
+		g.e.emitComment("Type descriptors")
+		for i, t := range g.typeDescs {
+			g.emitTypeDesc(i, t)
+		}
+		g.e.emitLine()
+	}
+
+	// Interface method tables.
+	if len(g.itabs) > 0 {
+		g.e.emitComment("Interface method tables")
+		for i := range g.itabs {
+			g.emitItab(i)
+		}
+		g.e.emitLine()
+	}
+
 	// Runtime function declarations.
 	g.emitRuntimeDecls()
 
@@ -61,6 +142,18 @@ func Generate(w io.Writer, funcs []*ssa.Func, sizes *types.Sizes) error {
 		g.lowerFunc(fn)
 	}
 
+	// Wrappers of value-receiver methods called through interfaces.
+	for _, m := range g.wrappers {
+		g.e.emitLine()
+		g.emitWrapper(m)
+	}
+
+	// Program initialization.
+	if len(globals) > 0 {
+		g.e.emitLine()
+		g.emitYoruInit(pkgs)
+	}
+
 	g.e.emitLine()
 
 	return g.e.err
@@ -69,8 +162,9 @@ func Generate(w io.Writer, funcs []*ssa.Func, sizes *types.Sizes) error {
 // emitHeader writes the module header (target triple, data layout).
 func (g *generator) emitHeader() {
 	g.e.emitComment("Generated by Yoru compiler")
-	g.e.emit("target datalayout = %q", rtabi.DataLayout)
-	g.e.emit("target triple = %q", rtabi.TargetTriple)
+	target := g.sizes.Target()
+	g.e.emit("target datalayout = %q", target.DataLayout)
+	g.e.emit("target triple = %q", target.Triple)
 	g.e.emitLine()
 }
 
@@ -116,7 +210,11 @@ func (g *generator) emitRuntimeDecls() {
 // emitIntrinsicDecls writes LLVM intrinsic declarations if needed.
 func (g *generator) emitIntrinsicDecls(funcs []*ssa.Func) {
 	needsMemset := false
+	needsGCRoot := false
 	for _, fn := range funcs {
+		if g.frames[fn] != nil {
+			needsGCRoot = true
+		}
 		for _, b := range fn.Blocks {
 			for _, v := range b.Values {
 				if v.Op == ssa.OpZero {
@@ -126,9 +224,14 @@ func (g *generator) emitIntrinsicDecls(funcs []*ssa.Func) {
 		}
 	}
 
-	if needsMemset {
+	if needsMemset || needsGCRoot {
 		g.e.emitComment("LLVM intrinsics")
-		g.e.emit("declare void @llvm.memset.p0.i64(ptr, i8, i64, i1)")
+		if needsMemset {
+			g.e.emit("declare void @llvm.memset.p0.i64(ptr, i8, i64, i1)")
+		}
+		if needsGCRoot {
+			g.e.emit("declare void @%s(ptr, ptr)", rtabi.LLVMGCRoot)
+		}
 		g.e.emitLine()
 	}
 }
@@ -148,10 +251,20 @@ func (g *generator) collectStrings(funcs []*ssa.Func) {
 				if v.Op == ssa.OpPrintln && len(v.Args) > 1 {
 					g.stringIndex(" ")
 				}
-				// OpNilCheck uses "nil pointer dereference" panic string.
-				if v.Op == ssa.OpNilCheck {
+				// OpNilCheck and OpInterfaceCall use "nil pointer dereference"
+				// panic string.
+				if v.Op == ssa.OpNilCheck || v.Op == ssa.OpInterfaceCall {
 					g.stringIndex("nil pointer dereference")
 				}
+				// Checked divisions panic with "integer divide by zero".
+				if (v.Op == ssa.OpDiv64 || v.Op == ssa.OpMod64) && checksDivisor(v) {
+					g.stringIndex("integer divide by zero")
+				}
+				// OpBoundsCheck and OpSliceCheck pass their source
+				// position string.
+				if v.Op == ssa.OpBoundsCheck || v.Op == ssa.OpSliceCheck {
+					g.stringIndex(boundsCheckPos(v))
+				}
 				// OpPanic with no args uses "panic" string.
 				if v.Op == ssa.OpPanic && len(v.Args) == 0 {
 					g.stringIndex("panic")
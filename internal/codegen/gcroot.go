@@ -0,0 +1,279 @@
+package codegen
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/you-not-fish/yoru/internal/rtabi"
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// GC roots
+//
+// Functions use LLVM's shadow-stack GC strategy. A ref held only in a
+// register is invisible to the collector, so every SSA value holding refs
+// that is live across a safepoint (a call or an allocation) gets a root
+// slot: an entry-block alloca registered with llvm.gcroot. The value is
+// stored to its slot where it is defined, and uses after a safepoint reload
+// it from the slot. Non-promoted allocas holding refs (aggregates and
+// address-taken variables) are registered as roots themselves.
+//
+// Slots holding a single ref are registered with null metadata. Aggregate
+// slots pass their TypeDesc as metadata, so the runtime can find the refs
+// inside them.
+
+// gcFrame describes the GC roots of a function.
+type gcFrame struct {
+	// values lists the SSA values with a root slot, in ID order.
+	values []*ssa.Value
+
+	// allocas lists the allocas registered as roots, in ID order.
+	allocas []*ssa.Value
+
+	rooted     map[*ssa.Value]bool
+	rootAlloca map[*ssa.Value]bool
+}
+
+// isSafepoint reports whether v may trigger a garbage collection.
+func isSafepoint(v *ssa.Value) bool {
+	switch v.Op {
+	case ssa.OpStaticCall, ssa.OpCall, ssa.OpInterfaceCall, ssa.OpNewAlloc,
+		ssa.OpNewSlice, ssa.OpGrowSlice, ssa.OpMakeMap, ssa.OpMapAssign:
+		return true
+	}
+	return false
+}
+
+// hasRefs reports whether a value of type t contains GC-managed refs.
+func (g *generator) hasRefs(t types.Type) bool {
+	return t != nil && len(g.sizes.RefOffsets(t)) > 0
+}
+
+// gcUses adds to live the values holding refs that a use of v keeps alive.
+// A pointer derived from a ref or slice (a field or element address) keeps
+// its base alive.
+func (g *generator) gcUses(v *ssa.Value, live map[*ssa.Value]bool) {
+	for {
+		switch v.Op {
+		case ssa.OpConstNil:
+			return
+		case ssa.OpMakeAggregate:
+			if len(v.Args) == 0 {
+				return
+			}
+		case ssa.OpStructFieldPtr, ssa.OpArrayIndexPtr, ssa.OpAddr,
+			ssa.OpSlicePtr, ssa.OpSliceIndexPtr:
+			v = v.Args[0]
+			continue
+		}
+		if g.hasRefs(v.Type) {
+			live[v] = true
+		}
+		return
+	}
+}
+
+// analyzeGCRoots computes the GC roots of fn, or returns nil if it has none.
+func (g *generator) analyzeGCRoots(fn *ssa.Func) *gcFrame {
+	f := &gcFrame{
+		rooted:     make(map[*ssa.Value]bool),
+		rootAlloca: make(map[*ssa.Value]bool),
+	}
+
+	for _, b := range fn.Blocks {
+		for _, v := range b.Values {
+			if v.Op == ssa.OpAlloca {
+				if pt, ok := v.Type.Underlying().(*types.Pointer); ok && g.hasRefs(pt.Elem()) {
+					f.rootAlloca[v] = true
+					f.allocas = append(f.allocas, v)
+				}
+			}
+		}
+	}
+
+	// Liveness of ref-holding values, iterated to a fixed point. Phi
+	// arguments are live at the end of the corresponding predecessor.
+	liveIn := make(map[*ssa.Block]map[*ssa.Value]bool)
+	liveOut := func(b *ssa.Block) map[*ssa.Value]bool {
+		live := make(map[*ssa.Value]bool)
+		for _, s := range b.Succs {
+			for v := range liveIn[s] {
+				live[v] = true
+			}
+			for _, phi := range s.Values {
+				if phi.Op != ssa.OpPhi {
+					continue
+				}
+				for i, pred := range s.Preds {
+					if pred == b && i < len(phi.Args) {
+						g.gcUses(phi.Args[i], live)
+					}
+				}
+			}
+		}
+		return live
+	}
+	// scan walks b backwards from its live-out set, calling visit at each
+	// safepoint with the values live across it, and returns the live-in set.
+	scan := func(b *ssa.Block, visit func(live map[*ssa.Value]bool)) map[*ssa.Value]bool {
+		live := liveOut(b)
+		for _, c := range b.Controls {
+			if c != nil {
+				g.gcUses(c, live)
+			}
+		}
+		for i := len(b.Values) - 1; i >= 0; i-- {
+			v := b.Values[i]
+			delete(live, v)
+			if isSafepoint(v) && visit != nil {
+				visit(live)
+			}
+			if v.Op == ssa.OpPhi {
+				continue
+			}
+			for _, arg := range v.Args {
+				g.gcUses(arg, live)
+			}
+		}
+		return live
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := len(fn.Blocks) - 1; i >= 0; i-- {
+			b := fn.Blocks[i]
+			in := scan(b, nil)
+			if len(in) != len(liveIn[b]) {
+				liveIn[b] = in
+				changed = true
+			}
+		}
+	}
+	for _, b := range fn.Blocks {
+		scan(b, func(live map[*ssa.Value]bool) {
+			for v := range live {
+				f.rooted[v] = true
+			}
+		})
+	}
+
+	for v := range f.rooted {
+		f.values = append(f.values, v)
+	}
+	sort.Slice(f.values, func(i, j int) bool { return f.values[i].ID < f.values[j].ID })
+
+	if len(f.values) == 0 && len(f.allocas) == 0 {
+		return nil
+	}
+	return f
+}
+
+// rootSlot returns the name of the root slot of a rooted SSA value.
+func rootSlot(v *ssa.Value) string {
+	return valueName(v) + ".root"
+}
+
+// rootMeta returns the llvm.gcroot metadata for a root slot of type t:
+// null for a single ref or map, otherwise the TypeDesc describing its refs.
+func (g *generator) rootMeta(t types.Type) string {
+	switch t.Underlying().(type) {
+	case *types.Ref, *types.Map:
+		return "null"
+	}
+	return g.typeDescSymbol(t)
+}
+
+// collectRootTypeDescs adds the TypeDescs used as root metadata to the
+// TypeDesc table.
+func (g *generator) collectRootTypeDescs(f *gcFrame) {
+	for _, v := range f.values {
+		g.rootMeta(v.Type)
+	}
+	for _, a := range f.allocas {
+		g.rootMeta(a.Type.Underlying().(*types.Pointer).Elem())
+	}
+}
+
+// emitGCPrologue emits the root slots of the current function at the top
+// of its entry block: the allocas, their zero initialization, and the
+// llvm.gcroot registrations. The zero stores precede any safepoint so the
+// collector never sees uninitialized slots.
+func (g *generator) emitGCPrologue() {
+	f := g.frame
+	for _, v := range f.values {
+		g.e.emitInst("%s = alloca %s", rootSlot(v), llvmType(v.Type))
+	}
+	for _, a := range f.allocas {
+		g.e.emitInst("%s = alloca %s", valueName(a), allocaElemType(a))
+	}
+	for _, v := range f.values {
+		g.e.emitInst("store %s %s, ptr %s", llvmType(v.Type), zeroValue(llvmType(v.Type)), rootSlot(v))
+	}
+	for _, a := range f.allocas {
+		g.e.emitInst("store %s %s, ptr %s", allocaElemType(a), zeroValue(allocaElemType(a)), valueName(a))
+	}
+	for _, v := range f.values {
+		g.e.emitInst("call void @%s(ptr %s, ptr %s)", rtabi.LLVMGCRoot, rootSlot(v), g.rootMeta(v.Type))
+	}
+	for _, a := range f.allocas {
+		elem := a.Type.Underlying().(*types.Pointer).Elem()
+		g.e.emitInst("call void @%s(ptr %s, ptr %s)", rtabi.LLVMGCRoot, valueName(a), g.rootMeta(elem))
+	}
+}
+
+// zeroValue returns the zero constant of the LLVM type ty.
+func zeroValue(ty string) string {
+	if ty == rtabi.LLVMTypePtr {
+		return "null"
+	}
+	return "zeroinitializer"
+}
+
+// spillRoot stores a rooted value to its slot right after its definition.
+// name is the LLVM operand holding the value.
+func (g *generator) spillRoot(v *ssa.Value, name string) {
+	g.e.emitInst("store %s %s, ptr %s", llvmType(v.Type), name, rootSlot(v))
+	g.fresh[v] = name
+}
+
+// rootedOperand returns the operand for a use of the rooted value v. After
+// a safepoint, the value is reloaded from its slot once per block.
+func (g *generator) rootedOperand(v *ssa.Value) string {
+	if name, ok := g.fresh[v]; ok {
+		return name
+	}
+	name := g.e.nextTmp()
+	g.e.emitInst("%s = load %s, ptr %s", name, llvmType(v.Type), rootSlot(v))
+	g.fresh[v] = name
+	return name
+}
+
+// phiRootName returns the name of the reload of the rooted phi argument v
+// emitted at the end of the predecessor pred.
+func phiRootName(v *ssa.Value, pred *ssa.Block) string {
+	return fmt.Sprintf("%s.%s", valueName(v), blockName(pred))
+}
+
+// emitPhiReloads reloads the rooted values that b passes to phis of its
+// successors. It is called before b's terminator.
+func (g *generator) emitPhiReloads(b *ssa.Block) {
+	done := make(map[*ssa.Value]bool)
+	for _, s := range b.Succs {
+		for _, phi := range s.Values {
+			if phi.Op != ssa.OpPhi {
+				continue
+			}
+			for i, pred := range s.Preds {
+				if pred != b || i >= len(phi.Args) {
+					continue
+				}
+				arg := phi.Args[i]
+				if !g.frame.rooted[arg] || done[arg] {
+					continue
+				}
+				done[arg] = true
+				g.e.emitInst("%s = load %s, ptr %s", phiRootName(arg, b), llvmType(arg.Type), rootSlot(arg))
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package codegen
+
+import (
+	"fmt"
+	"go/constant"
+	"strconv"
+
+	"github.com/you-not-fish/yoru/internal/rtabi"
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// emitGlobal writes the definition of a package-level variable.
+func (g *generator) emitGlobal(gv *ssa.Global) {
+	t := gv.Var.Type()
+	g.e.emit("@%s = global %s %s, align %d",
+		llvmSymbol(gv.LinkName), llvmType(t), g.globalInit(t, gv.Const), g.sizes.Alignof(t))
+}
+
+// globalInit returns the LLVM constant initializing a global of type t
+// with the value c, or zeroinitializer if c is nil.
+func (g *generator) globalInit(t types.Type, c constant.Value) string {
+	if c == nil {
+		return "zeroinitializer"
+	}
+	switch {
+	case isIntType(t):
+		// go/constant may represent integer-valued results as rationals.
+		if c.Kind() == constant.Float {
+			f, _ := constant.Float64Val(c)
+			return strconv.FormatInt(int64(f), 10)
+		}
+		n, _ := constant.Int64Val(c)
+		return strconv.FormatInt(n, 10)
+	case isFloatType(t):
+		f, _ := constant.Float64Val(constant.ToFloat(c))
+		return formatFloat(f)
+	case isBoolType(t):
+		if constant.BoolVal(c) {
+			return "true"
+		}
+		return "false"
+	case isStringType(t):
+		s := constant.StringVal(c)
+		return fmt.Sprintf("{ ptr @.str.%d, i64 %d }", g.stringIndex(s), len(s))
+	}
+	return "zeroinitializer"
+}
+
+// emitYoruInit writes the program initialization function called by the
+// runtime before yoru_main. It registers the ref slots of all globals as
+// GC roots, then runs the package initializers in dependency order.
+func (g *generator) emitYoruInit(pkgs []*ssa.Package) {
+	g.e.emitComment("Program initialization")
+	g.e.emit("define void @%s() {", rtabi.YoruInit)
+	g.e.emit("entry:")
+	for _, pkg := range pkgs {
+		for _, gv := range pkg.Globals {
+			sym := "@" + llvmSymbol(gv.LinkName)
+			for _, off := range g.sizes.RefOffsets(gv.Var.Type()) {
+				slot := sym
+				if off != 0 {
+					slot = fmt.Sprintf("getelementptr (i8, ptr %s, i64 %d)", sym, off)
+				}
+				g.e.emitInst("call void @%s(ptr %s)", rtabi.FnRegisterRoot, slot)
+			}
+		}
+	}
+	for _, pkg := range pkgs {
+		if pkg.Init != nil {
+			g.e.emitInst("call void @%s()", llvmSymbol(pkg.Init.LinkName))
+		}
+	}
+	g.e.emitInst("ret void")
+	g.e.emit("}")
+}
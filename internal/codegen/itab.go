@@ -0,0 +1,300 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/you-not-fish/yoru/internal/rtabi"
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// Interfaces
+//
+// An interface value is a { ptr, ptr } pair of a method table (itable) and
+// a data pointer. The data pointer always points to heap data: a ref is
+// stored as is, and any other value is boxed by the SSA builder. The nil
+// interface is zeroinitializer.
+//
+// The itable of a (dynamic type, interface) pair is a private constant
+// { ptr, { ptr, i64 }, [N x ptr] }: the TypeDesc of the dynamic type, which
+// type assertions compare against, its name for panic messages, and one
+// function pointer per interface method in method order. Every method
+// takes the data pointer as its receiver. Methods with a pointer receiver
+// already do; methods with a value receiver are reached through a wrapper
+// that loads the receiver from the data pointer.
+
+// itab is a method table for a dynamic type stored in an interface type.
+type itab struct {
+	dyn   types.Type
+	iface *types.Interface
+}
+
+// collectItabs collects the itables of all interface conversions and
+// assigns each distinct (dynamic type, interface) pair an itable index.
+// It also collects the TypeDescs and type names type assertions need.
+func (g *generator) collectItabs(funcs []*ssa.Func) {
+	for _, fn := range funcs {
+		for _, b := range fn.Blocks {
+			for _, v := range b.Values {
+				switch v.Op {
+				case ssa.OpMakeInterface:
+					g.itabIndex(v)
+				case ssa.OpTypeAssert, ssa.OpTypeTest:
+					T := v.Aux.(types.Type)
+					if !types.IsInterface(T) {
+						g.typeDescIndex(T)
+					}
+					if v.Op == ssa.OpTypeAssert {
+						g.stringIndex(v.Args[0].Type.String())
+						g.stringIndex(T.String())
+					}
+				}
+			}
+		}
+	}
+}
+
+// itabIndex returns the index of the itable for the MakeInterface v,
+// adding it and the method wrappers it needs to the tables if needed.
+func (g *generator) itabIndex(v *ssa.Value) int {
+	dyn := v.Aux.(types.Type)
+	iface := v.Type.Underlying().(*types.Interface)
+	for i, t := range g.itabs {
+		if types.Identical(t.dyn, dyn) && types.Identical(t.iface, iface) {
+			return i
+		}
+	}
+	g.itabs = append(g.itabs, itab{dyn: dyn, iface: iface})
+	g.typeDescIndex(dyn)
+	g.stringIndex(dyn.String())
+	for _, m := range iface.Methods() {
+		f := types.MethodSet(dyn, m.Name())
+		if f != nil && !types.HasPointerRecv(f) && !g.hasWrapper(f) {
+			g.wrappers = append(g.wrappers, f)
+			g.stringIndex("nil pointer dereference")
+		}
+	}
+	return len(g.itabs) - 1
+}
+
+// hasWrapper reports whether the value-receiver method m already has a
+// wrapper.
+func (g *generator) hasWrapper(m *types.FuncObj) bool {
+	for _, w := range g.wrappers {
+		if w == m {
+			return true
+		}
+	}
+	return false
+}
+
+// itabSymbol returns the LLVM operand naming the itable with index idx.
+func itabSymbol(idx int) string {
+	return fmt.Sprintf("@.itab.%d", idx)
+}
+
+// wrapperName returns the symbol name of the wrapper of the value-receiver
+// method m. The '$' cannot occur in Yoru identifiers.
+func wrapperName(m *types.FuncObj) string {
+	return ssa.LinkName(m) + "$iface"
+}
+
+// emitItab writes the itable with index idx.
+func (g *generator) emitItab(idx int) {
+	t := g.itabs[idx]
+	g.e.emitComment(fmt.Sprintf("%s in %s", t.dyn, t.iface))
+	elems := make([]string, t.iface.NumMethods())
+	for i, m := range t.iface.Methods() {
+		f := types.MethodSet(t.dyn, m.Name())
+		name := ssa.LinkName(f)
+		if !types.HasPointerRecv(f) {
+			name = wrapperName(f)
+		}
+		elems[i] = "ptr @" + llvmSymbol(name)
+	}
+	methods := "zeroinitializer"
+	if len(elems) > 0 {
+		methods = "[" + strings.Join(elems, ", ") + "]"
+	}
+	g.e.emit("%s = private unnamed_addr constant { ptr, { ptr, i64 }, [%d x ptr] } { ptr %s, %s, [%d x ptr] %s }",
+		itabSymbol(idx), len(elems), g.typeDescSymbol(t.dyn), g.stringConstant(t.dyn.String()), len(elems), methods)
+}
+
+// stringConstant returns the { ptr, i64 } constant of the collected
+// string s, with its type.
+func (g *generator) stringConstant(s string) string {
+	return fmt.Sprintf("{ ptr, i64 } { ptr @.str.%d, i64 %d }", g.stringIndex(s), len(s))
+}
+
+// emitWrapper writes the wrapper of the value-receiver method m. It loads
+// the receiver from the data pointer, which is nil if the interface holds
+// a nil ref, and calls the method.
+func (g *generator) emitWrapper(m *types.FuncObj) {
+	sig := m.Signature()
+	retType := llvmReturnType(sig)
+	params := []string{"ptr %data"}
+	args := []string{llvmType(sig.Recv().Type()) + " %recv"}
+	for i := 0; i < sig.NumParams(); i++ {
+		p := fmt.Sprintf("%s %%p%d", llvmType(sig.Param(i).Type()), i)
+		params = append(params, p)
+		args = append(args, p)
+	}
+
+	g.e.emit("define private %s @%s(%s) {", retType, llvmSymbol(wrapperName(m)), strings.Join(params, ", "))
+	g.e.emit("entry:")
+	g.e.emitInst("%%isnil = icmp eq ptr %%data, null")
+	g.e.emitInst("br i1 %%isnil, label %%nilchk.fail, label %%nilchk.ok")
+	g.e.emit("nilchk.fail:")
+	g.emitNilPanic()
+	g.e.emit("nilchk.ok:")
+	g.e.emitInst("%%recv = load %s, ptr %%data", llvmType(sig.Recv().Type()))
+	callee := llvmSymbol(ssa.LinkName(m))
+	if retType == "void" {
+		g.e.emitInst("call void @%s(%s)", callee, strings.Join(args, ", "))
+		g.e.emitInst("ret void")
+	} else {
+		g.e.emitInst("%%ret = call %s @%s(%s)", retType, callee, strings.Join(args, ", "))
+		g.e.emitInst("ret %s %%ret", retType)
+	}
+	g.e.emit("}")
+}
+
+// lowerMakeInterface builds an interface value from the itable of the
+// dynamic type and the data pointer.
+func (g *generator) lowerMakeInterface(v *ssa.Value) {
+	t0 := g.e.nextTmp()
+	g.e.emitInst("%s = insertvalue { ptr, ptr } undef, ptr %s, 0", t0, itabSymbol(g.itabIndex(v)))
+	g.e.emitInst("%s = insertvalue { ptr, ptr } %s, ptr %s, 1", valueName(v), t0, g.operand(v.Args[0]))
+}
+
+// lowerInterfaceIsNil tests whether the itable of an interface is null.
+func (g *generator) lowerInterfaceIsNil(v *ssa.Value) {
+	tab := g.e.nextTmp()
+	g.e.emitInst("%s = extractvalue { ptr, ptr } %s, 0", tab, g.operand(v.Args[0]))
+	g.e.emitInst("%s = icmp eq ptr %s, null", valueName(v), tab)
+}
+
+// lowerInterfaceCall calls a method through the itable of an interface.
+// Calling a method of a nil interface panics; like a nil check, this
+// splits the block.
+func (g *generator) lowerInterfaceCall(v *ssa.Value) {
+	iface := g.operand(v.Args[0])
+	tab := g.e.nextTmp()
+	g.e.emitInst("%s = extractvalue { ptr, ptr } %s, 0", tab, iface)
+	g.emitNilCheck(v, tab)
+
+	slot := g.e.nextTmp()
+	fnPtr := g.e.nextTmp()
+	data := g.e.nextTmp()
+	g.e.emitInst("%s = getelementptr { ptr, { ptr, i64 }, [0 x ptr] }, ptr %s, i64 0, i32 2, i64 %d", slot, tab, v.AuxInt)
+	g.e.emitInst("%s = load ptr, ptr %s", fnPtr, slot)
+	g.e.emitInst("%s = extractvalue { ptr, ptr } %s, 1", data, iface)
+
+	argStrs := []string{"ptr " + data}
+	for _, arg := range v.Args[1:] {
+		argStrs = append(argStrs, fmt.Sprintf("%s %s", llvmType(arg.Type), g.operand(arg)))
+	}
+	if v.Type == nil {
+		g.e.emitInst("call void %s(%s)", fnPtr, strings.Join(argStrs, ", "))
+	} else {
+		g.e.emitInst("%s = call %s %s(%s)", valueName(v), llvmType(v.Type), fnPtr, strings.Join(argStrs, ", "))
+	}
+}
+
+// lowerTypeTest tests whether the dynamic type of an interface is the
+// type T of the TypeTest v: whether its itable is non-null and, unless T
+// is an interface type, starts with the TypeDesc of T. The itable is only
+// loaded from if it is non-null, so a test for a concrete type splits the
+// block.
+func (g *generator) lowerTypeTest(v *ssa.Value) {
+	tab := g.e.nextTmp()
+	g.e.emitInst("%s = extractvalue { ptr, ptr } %s, 0", tab, g.operand(v.Args[0]))
+	T := v.Aux.(types.Type)
+	if types.IsInterface(T) {
+		g.e.emitInst("%s = icmp ne ptr %s, null", valueName(v), tab)
+		return
+	}
+
+	from := labelBefore(v)
+	isNil := g.e.nextTmp()
+	loadLabel := fmt.Sprintf("typetest.load.%d", v.ID)
+	g.e.emitInst("%s = icmp eq ptr %s, null", isNil, tab)
+	g.e.emitInst("br i1 %s, label %%%s, label %%%s", isNil, contLabel(v), loadLabel)
+	g.e.emit("%s:", loadLabel)
+	desc := g.e.nextTmp()
+	same := g.e.nextTmp()
+	g.e.emitInst("%s = load ptr, ptr %s", desc, tab)
+	g.e.emitInst("%s = icmp eq ptr %s, %s", same, desc, g.typeDescSymbol(T))
+	g.e.emitInst("br label %%%s", contLabel(v))
+	g.e.emit("%s:", contLabel(v))
+	g.e.emitInst("%s = phi i1 [ false, %%%s ], [ %s, %%%s ]", valueName(v), from, same, loadLabel)
+}
+
+// lowerTypeAssert asserts that the dynamic type of an interface is the
+// type T of the TypeAssert v, like lowerTypeTest, and yields the value of
+// type T: the interface itself for an interface type, the data pointer for
+// a ref, or else the value loaded from it. If the assertion fails, it calls
+// rt_panic_assert with the itable and both type names.
+func (g *generator) lowerTypeAssert(v *ssa.Value) {
+	iface := g.operand(v.Args[0])
+	tab := g.e.nextTmp()
+	g.e.emitInst("%s = extractvalue { ptr, ptr } %s, 0", tab, iface)
+	T := v.Aux.(types.Type)
+	failLabel := fmt.Sprintf("assert.fail.%d", v.ID)
+	checkLabel := fmt.Sprintf("assert.check.%d", v.ID)
+	if types.IsInterface(T) {
+		checkLabel = contLabel(v)
+	}
+
+	isNil := g.e.nextTmp()
+	g.e.emitInst("%s = icmp eq ptr %s, null", isNil, tab)
+	g.e.emitInst("br i1 %s, label %%%s, label %%%s", isNil, failLabel, checkLabel)
+	if !types.IsInterface(T) {
+		g.e.emit("%s:", checkLabel)
+		desc := g.e.nextTmp()
+		same := g.e.nextTmp()
+		g.e.emitInst("%s = load ptr, ptr %s", desc, tab)
+		g.e.emitInst("%s = icmp eq ptr %s, %s", same, desc, g.typeDescSymbol(T))
+		g.e.emitInst("br i1 %s, label %%%s, label %%%s", same, contLabel(v), failLabel)
+	}
+	g.e.emit("%s:", failLabel)
+	g.e.emitInst("call void @%s(ptr %s, %s, %s)", rtabi.FnPanicAssert, tab,
+		g.stringConstant(v.Args[0].Type.String()), g.stringConstant(T.String()))
+	g.e.emitInst("unreachable")
+	g.e.emit("%s:", contLabel(v))
+
+	_, isRef := T.Underlying().(*types.Ref)
+	switch {
+	case types.IsInterface(T):
+		data := g.e.nextTmp()
+		t0 := g.e.nextTmp()
+		g.e.emitInst("%s = extractvalue { ptr, ptr } %s, 1", data, iface)
+		g.e.emitInst("%s = insertvalue { ptr, ptr } undef, ptr %s, 0", t0, tab)
+		g.e.emitInst("%s = insertvalue { ptr, ptr } %s, ptr %s, 1", valueName(v), t0, data)
+	case isRef:
+		g.e.emitInst("%s = extractvalue { ptr, ptr } %s, 1", valueName(v), iface)
+	default:
+		data := g.e.nextTmp()
+		g.e.emitInst("%s = extractvalue { ptr, ptr } %s, 1", data, iface)
+		g.e.emitInst("%s = load %s, ptr %s", valueName(v), llvmType(T), data)
+	}
+}
+
+// emitNilPanic emits the runtime panic for a nil dereference, ending the
+// current LLVM block.
+func (g *generator) emitNilPanic() {
+	g.emitPanicMessage("nil pointer dereference")
+}
+
+// emitPanicMessage emits a runtime panic with msg, ending the current LLVM
+// block.
+func (g *generator) emitPanicMessage(msg string) {
+	strGlobal := fmt.Sprintf("@.str.%d", g.stringIndex(msg))
+	t0 := g.e.nextTmp()
+	t1 := g.e.nextTmp()
+	g.e.emitInst("%s = insertvalue { ptr, i64 } undef, ptr %s, 0", t0, strGlobal)
+	g.e.emitInst("%s = insertvalue { ptr, i64 } %s, i64 %d, 1", t1, t0, len(msg))
+	g.e.emitInst("call void @%s({ ptr, i64 } %s)", rtabi.FnPanicString, t1)
+	g.e.emitInst("unreachable")
+}
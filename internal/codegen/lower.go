@@ -30,28 +30,74 @@ func (g *generator) lowerFunc(fn *ssa.Func) {
 		}
 	}
 
-	funcName := fn.Name
-	if funcName == "main" {
-		funcName = rtabi.YoruMain
+	funcName := fn.LinkName
+	if funcName == "" {
+		funcName = fn.Name
+		if funcName == "main" {
+			funcName = rtabi.YoruMain
+		}
 	}
 
-	g.e.emit("define %s @%s(%s) {", retType, funcName, strings.Join(params, ", "))
+	g.frame = g.frames[fn]
+	gc := ""
+	if g.frame != nil {
+		gc = fmt.Sprintf(" gc %q", rtabi.GCStrategy)
+	}
+	g.e.emit("define %s @%s(%s)%s {", retType, llvmSymbol(funcName), strings.Join(params, ", "), gc)
 
 	for _, b := range fn.Blocks {
 		g.lowerBlock(b, fn)
 	}
 
 	g.e.emit("}")
+	g.frame = nil
 }
 
 // lowerBlock emits the LLVM IR for a single basic block.
 func (g *generator) lowerBlock(b *ssa.Block, fn *ssa.Func) {
 	g.e.emitLabel(b)
 
+	if g.frame == nil {
+		for _, v := range b.Values {
+			g.lowerValue(v)
+		}
+		g.lowerTerminator(b)
+		return
+	}
+
+	g.fresh = make(map[*ssa.Value]string)
+	if b == fn.Entry {
+		g.emitGCPrologue()
+	}
+	// Phis must stay grouped at the top of the block, so rooted phis are
+	// spilled after the last one.
+	var phis []*ssa.Value
 	for _, v := range b.Values {
+		if v.Op != ssa.OpPhi {
+			for _, phi := range phis {
+				g.spillRoot(phi, valueName(phi))
+			}
+			phis = nil
+		}
+		if g.frame.rootAlloca[v] {
+			continue // emitted by the prologue
+		}
 		g.lowerValue(v)
+		if isSafepoint(v) {
+			clear(g.fresh)
+		}
+		if g.frame.rooted[v] {
+			if v.Op == ssa.OpPhi {
+				phis = append(phis, v)
+			} else {
+				g.spillRoot(v, g.valueOperand(v))
+			}
+		}
 	}
-
+	for _, phi := range phis {
+		g.spillRoot(phi, valueName(phi))
+	}
+	g.emitPhiReloads(b)
 	g.lowerTerminator(b)
 }
 
@@ -62,6 +108,10 @@ func (g *generator) lowerValue(v *ssa.Value) {
 	case ssa.OpConst64, ssa.OpConstFloat, ssa.OpConstBool, ssa.OpConstNil:
 		return
 
+	// Global addresses are inlined as @symbol.
+	case ssa.OpGlobal:
+		return
+
 	// String constants need to build a {ptr, i64} struct.
 	case ssa.OpConstString:
 		g.lowerConstString(v)
@@ -74,13 +124,27 @@ func (g *generator) lowerValue(v *ssa.Value) {
 		g.emitBinOp("sub", "i64", v)
 	case ssa.OpMul64:
 		g.emitBinOp("mul", "i64", v)
-	case ssa.OpDiv64:
-		g.emitBinOp("sdiv", "i64", v)
-	case ssa.OpMod64:
-		g.emitBinOp("srem", "i64", v)
+	case ssa.OpDiv64, ssa.OpMod64:
+		g.lowerDiv(v)
 	case ssa.OpNeg64:
 		g.e.emitInst("%s = sub i64 0, %s", valueName(v), g.operand(v.Args[0]))
 
+	// Integer bitwise operations and shifts
+	case ssa.OpAnd64:
+		g.emitBinOp("and", "i64", v)
+	case ssa.OpOr64:
+		g.emitBinOp("or", "i64", v)
+	case ssa.OpXor64:
+		g.emitBinOp("xor", "i64", v)
+	case ssa.OpAndNot64:
+		t0 := g.e.nextTmp()
+		g.e.emitInst("%s = xor i64 %s, -1", t0, g.operand(v.Args[1]))
+		g.e.emitInst("%s = and i64 %s, %s", valueName(v), g.operand(v.Args[0]), t0)
+	case ssa.OpCom64:
+		g.e.emitInst("%s = xor i64 %s, -1", valueName(v), g.operand(v.Args[0]))
+	case ssa.OpLsh64, ssa.OpRsh64:
+		g.lowerShift(v)
+
 	// Float arithmetic
 	case ssa.OpAddF64:
 		g.emitBinOp("fadd", "double", v)
@@ -168,6 +232,12 @@ func (g *generator) lowerValue(v *ssa.Value) {
 		arrayType := arrayTypeFromPtr(v.Args[0])
 		g.e.emitInst("%s = getelementptr %s, ptr %s, i64 0, i64 %s", valueName(v), arrayType, basePtr, idx)
 
+	// Aggregate values
+	case ssa.OpExtractValue:
+		g.e.emitInst("%s = extractvalue %s %s, %d", valueName(v), llvmType(v.Args[0].Type), g.operand(v.Args[0]), v.AuxInt)
+	case ssa.OpMakeAggregate:
+		g.lowerMakeAggregate(v)
+
 	// Address
 	case ssa.OpAddr:
 		// OpAddr produces the same pointer as its alloca argument.
@@ -204,6 +274,56 @@ func (g *generator) lowerValue(v *ssa.Value) {
 	case ssa.OpNilCheck:
 		g.lowerNilCheck(v)
 
+	// Bounds check
+	case ssa.OpBoundsCheck:
+		g.lowerBoundsCheck(v)
+
+	// Interfaces
+	case ssa.OpMakeInterface:
+		g.lowerMakeInterface(v)
+	case ssa.OpInterfaceCall:
+		g.lowerInterfaceCall(v)
+	case ssa.OpTypeAssert:
+		g.lowerTypeAssert(v)
+	case ssa.OpTypeTest:
+		g.lowerTypeTest(v)
+	case ssa.OpInterfaceIsNil:
+		g.lowerInterfaceIsNil(v)
+
+	// Slices
+	case ssa.OpNewSlice:
+		g.lowerNewSlice(v)
+	case ssa.OpSliceMake:
+		g.emitSliceValue(valueName(v), g.operand(v.Args[0]), g.operand(v.Args[1]), g.operand(v.Args[2]))
+	case ssa.OpSlicePtr:
+		g.e.emitInst("%s = extractvalue %s %s, 0", valueName(v), llvmSliceType, g.operand(v.Args[0]))
+	case ssa.OpSliceLen:
+		g.e.emitInst("%s = extractvalue %s %s, 1", valueName(v), llvmSliceType, g.operand(v.Args[0]))
+	case ssa.OpSliceCap:
+		g.e.emitInst("%s = extractvalue %s %s, 2", valueName(v), llvmSliceType, g.operand(v.Args[0]))
+	case ssa.OpSliceIndexPtr:
+		g.lowerSliceIndexPtr(v)
+	case ssa.OpSliceSlice:
+		g.lowerSliceSlice(v)
+	case ssa.OpSliceCheck:
+		g.lowerSliceCheck(v)
+	case ssa.OpGrowSlice:
+		g.lowerGrowSlice(v)
+	case ssa.OpSliceCopy:
+		g.lowerSliceCopy(v)
+
+	// Maps
+	case ssa.OpMakeMap:
+		g.lowerMakeMap(v)
+	case ssa.OpMapAccess:
+		g.lowerMapAccess(v)
+	case ssa.OpMapAssign:
+		g.lowerMapAssign(v)
+	case ssa.OpMapDelete:
+		g.lowerMapDelete(v)
+	case ssa.OpMapLen:
+		g.lowerMapLen(v)
+
 	// String operations
 	case ssa.OpStringLen:
 		// Extract length from {ptr, i64} string.
@@ -252,8 +372,18 @@ func (g *generator) lowerTerminator(b *ssa.Block) {
 }
 
 // operand returns the LLVM IR operand string for an SSA value.
-// Constants are inlined, others use their %vN name.
+// Constants are inlined, others use their %vN name. GC-rooted values are
+// reloaded from their root slot after a safepoint.
 func (g *generator) operand(v *ssa.Value) string {
+	if g.frame != nil && g.frame.rooted[v] {
+		return g.rootedOperand(v)
+	}
+	return g.valueOperand(v)
+}
+
+// valueOperand returns the operand string for v as defined, ignoring any
+// root slot.
+func (g *generator) valueOperand(v *ssa.Value) string {
 	switch v.Op {
 	case ssa.OpConst64:
 		return strconv.FormatInt(v.AuxInt, 10)
@@ -268,7 +398,17 @@ func (g *generator) operand(v *ssa.Value) string {
 		// The {ptr, i64} was built by lowerConstString and named %vN.
 		return valueName(v)
 	case ssa.OpConstNil:
+		// A nil interface is a { ptr, ptr } pair.
+		if v.Type != nil {
+			return zeroValue(llvmType(v.Type))
+		}
 		return "null"
+	case ssa.OpMakeAggregate:
+		if len(v.Args) == 0 {
+			return "zeroinitializer"
+		}
+	case ssa.OpGlobal:
+		return "@" + llvmSymbol(ssa.GlobalLinkName(v.Aux.(*types.Var)))
 	case ssa.OpArg:
 		// Return the parameter name.
 		name, ok := v.Aux.(string)
@@ -304,7 +444,13 @@ func (g *generator) lowerPhi(v *ssa.Value) {
 	parts := make([]string, len(v.Args))
 	for i, arg := range v.Args {
 		pred := v.Block.Preds[i]
-		parts[i] = fmt.Sprintf("[ %s, %%%s ]", g.operand(arg), blockName(pred))
+		var val string
+		if g.frame != nil && g.frame.rooted[arg] {
+			val = phiRootName(arg, pred)
+		} else {
+			val = g.operand(arg)
+		}
+		parts[i] = fmt.Sprintf("[ %s, %%%s ]", val, exitLabel(pred))
 	}
 	g.e.emitInst("%s = phi %s %s", valueName(v), lt, strings.Join(parts, ", "))
 }
@@ -401,10 +547,7 @@ func (g *generator) lowerStaticCall(v *ssa.Value) {
 	sig := funcObj.Signature()
 	retType := llvmReturnType(sig)
 
-	calleeName := funcObj.Name()
-	if calleeName == "main" {
-		calleeName = rtabi.YoruMain
-	}
+	calleeName := llvmSymbol(ssa.LinkName(funcObj))
 
 	// Build argument list.
 	var argStrs []string
@@ -441,27 +584,231 @@ func (g *generator) lowerNewAlloc(v *ssa.Value) {
 		return
 	}
 	size := g.sizes.Sizeof(elemType)
-	// For now, pass null as the type descriptor (Phase 5C will generate proper TypeDescs).
-	g.e.emitInst("%s = call ptr @%s(i64 %d, ptr null)", valueName(v), rtabi.FnAlloc, size)
+	g.e.emitInst("%s = call ptr @%s(i64 %d, ptr %s)", valueName(v), rtabi.FnAlloc, size, g.typeDescSymbol(elemType))
+}
+
+// lowerShift emits a shift with Go semantics. LLVM leaves shifts by the
+// bit width or more undefined, so such counts are clamped: x << s is 0 and
+// x >> s is 0 or -1. Negative counts panic via rt_shift_check; the check
+// is omitted for non-negative constant counts.
+func (g *generator) lowerShift(v *ssa.Value) {
+	x := g.operand(v.Args[0])
+	count := v.Args[1]
+	isLeft := v.Op == ssa.OpLsh64
+
+	if count.Op == ssa.OpConst64 && count.AuxInt >= 0 {
+		switch {
+		case count.AuxInt < 64 && isLeft:
+			g.e.emitInst("%s = shl i64 %s, %d", valueName(v), x, count.AuxInt)
+		case count.AuxInt < 64:
+			g.e.emitInst("%s = ashr i64 %s, %d", valueName(v), x, count.AuxInt)
+		case isLeft:
+			g.e.emitInst("%s = and i64 %s, 0", valueName(v), x)
+		default:
+			g.e.emitInst("%s = ashr i64 %s, 63", valueName(v), x)
+		}
+		return
+	}
+
+	s := g.operand(count)
+	g.e.emitInst("call void @%s(i64 %s)", rtabi.FnShiftCheck, s)
+	inRange := g.e.nextTmp()
+	g.e.emitInst("%s = icmp ult i64 %s, 64", inRange, s)
+	clamped := g.e.nextTmp()
+	if isLeft {
+		g.e.emitInst("%s = select i1 %s, i64 %s, i64 0", clamped, inRange, s)
+		shifted := g.e.nextTmp()
+		g.e.emitInst("%s = shl i64 %s, %s", shifted, x, clamped)
+		g.e.emitInst("%s = select i1 %s, i64 %s, i64 0", valueName(v), inRange, shifted)
+		return
+	}
+	g.e.emitInst("%s = select i1 %s, i64 %s, i64 63", clamped, inRange, s)
+	g.e.emitInst("%s = ashr i64 %s, %s", valueName(v), x, clamped)
+}
+
+// lowerDiv emits an integer division or remainder with Go semantics. A
+// zero divisor branches to a block that panics, so the sdiv or srem, which
+// LLVM may otherwise execute before a call, only runs in the block
+// divCheckContLabel(v). MinInt64 / -1, which LLVM leaves undefined, wraps
+// to MinInt64 with remainder 0: a divisor of -1 is replaced by 1 and the
+// quotient negated. Non-zero constant divisors need neither the check nor
+// the select.
+func (g *generator) lowerDiv(v *ssa.Value) {
+	x := g.operand(v.Args[0])
+	d := v.Args[1]
+	isDiv := v.Op == ssa.OpDiv64
+
+	if !checksDivisor(v) {
+		switch {
+		case d.AuxInt != -1 && isDiv:
+			g.e.emitInst("%s = sdiv i64 %s, %d", valueName(v), x, d.AuxInt)
+		case d.AuxInt != -1:
+			g.e.emitInst("%s = srem i64 %s, %d", valueName(v), x, d.AuxInt)
+		case isDiv:
+			g.e.emitInst("%s = sub i64 0, %s", valueName(v), x)
+		default:
+			g.e.emitInst("%s = and i64 %s, 0", valueName(v), x)
+		}
+		return
+	}
+
+	y := g.operand(d)
+	isZero := g.e.nextTmp()
+	g.e.emitInst("%s = icmp eq i64 %s, 0", isZero, y)
+	failLabel := fmt.Sprintf("divchk.fail.%d", v.ID)
+	g.e.emitInst("br i1 %s, label %%%s, label %%%s", isZero, failLabel, divCheckContLabel(v))
+	g.e.emit("%s:", failLabel)
+	g.emitPanicMessage("integer divide by zero")
+	g.e.emit("%s:", divCheckContLabel(v))
+	isNeg1 := g.e.nextTmp()
+	g.e.emitInst("%s = icmp eq i64 %s, -1", isNeg1, y)
+	safe := g.e.nextTmp()
+	g.e.emitInst("%s = select i1 %s, i64 1, i64 %s", safe, isNeg1, y)
+	if !isDiv {
+		// x % 1 is 0, as is x % -1.
+		g.e.emitInst("%s = srem i64 %s, %s", valueName(v), x, safe)
+		return
+	}
+	q := g.e.nextTmp()
+	g.e.emitInst("%s = sdiv i64 %s, %s", q, x, safe)
+	neg := g.e.nextTmp()
+	g.e.emitInst("%s = sub i64 0, %s", neg, q)
+	g.e.emitInst("%s = select i1 %s, i64 %s, i64 %s", valueName(v), isNeg1, neg, q)
 }
 
 // lowerNilCheck emits a nil check with panic.
 func (g *generator) lowerNilCheck(v *ssa.Value) {
+	g.emitNilCheck(v, g.operand(v.Args[0]))
+}
+
+// emitNilCheck emits a check of the pointer operand ptr for v that panics
+// if it is nil, and continues in the block nilCheckContLabel(v).
+func (g *generator) emitNilCheck(v *ssa.Value, ptr string) {
 	cmp := g.e.nextTmp()
-	g.e.emitInst("%s = icmp eq ptr %s, null", cmp, g.operand(v.Args[0]))
+	g.e.emitInst("%s = icmp eq ptr %s, null", cmp, ptr)
 	thenLabel := fmt.Sprintf("nilchk.fail.%d", v.ID)
-	contLabel := fmt.Sprintf("nilchk.ok.%d", v.ID)
+	contLabel := nilCheckContLabel(v)
 	g.e.emitInst("br i1 %s, label %%%s, label %%%s", cmp, thenLabel, contLabel)
 	g.e.emit("%s:", thenLabel)
-	idx := g.stringIndex("nil pointer dereference")
-	strGlobal := fmt.Sprintf("@.str.%d", idx)
+	g.emitNilPanic()
+	g.e.emit("%s:", contLabel)
+}
+
+// lowerBoundsCheck emits a call to rt_bounds_check, which panics with the
+// source position of the index if it is out of range.
+func (g *generator) lowerBoundsCheck(v *ssa.Value) {
+	index := g.operand(v.Args[0])
+	length := g.operand(v.Args[1])
+	pos := boundsCheckPos(v)
+	strGlobal := fmt.Sprintf("@.str.%d", g.stringIndex(pos))
 	t0 := g.e.nextTmp()
 	t1 := g.e.nextTmp()
 	g.e.emitInst("%s = insertvalue { ptr, i64 } undef, ptr %s, 0", t0, strGlobal)
-	g.e.emitInst("%s = insertvalue { ptr, i64 } %s, i64 %d, 1", t1, t0, len("nil pointer dereference"))
-	g.e.emitInst("call void @%s({ ptr, i64 } %s)", rtabi.FnPanicString, t1)
-	g.e.emitInst("unreachable")
-	g.e.emit("%s:", contLabel)
+	g.e.emitInst("%s = insertvalue { ptr, i64 } %s, i64 %d, 1", t1, t0, len(pos))
+	g.e.emitInst("call void @%s(i64 %s, i64 %s, { ptr, i64 } %s)", rtabi.FnBoundsCheck, index, length, t1)
+}
+
+// lowerMakeAggregate builds a struct or array value with a chain of
+// insertvalue instructions. The zero value (no args) is the constant
+// zeroinitializer and emits nothing.
+func (g *generator) lowerMakeAggregate(v *ssa.Value) {
+	if len(v.Args) == 0 {
+		return
+	}
+	lt := llvmType(v.Type)
+	agg := "undef"
+	for i, arg := range v.Args {
+		dst := valueName(v)
+		if i < len(v.Args)-1 {
+			dst = g.e.nextTmp()
+		}
+		g.e.emitInst("%s = insertvalue %s %s, %s %s, %d", dst, lt, agg, llvmType(arg.Type), g.operand(arg), i)
+		agg = dst
+	}
+}
+
+// boundsCheckPos returns the position string passed to rt_bounds_check
+// for the bounds check v.
+func boundsCheckPos(v *ssa.Value) string {
+	if !v.Pos.IsValid() {
+		return ""
+	}
+	return v.Pos.String()
+}
+
+// nilCheckContLabel returns the label of the block a nil check continues in.
+func nilCheckContLabel(v *ssa.Value) string {
+	return fmt.Sprintf("nilchk.ok.%d", v.ID)
+}
+
+// checksDivisor reports whether the division or remainder v checks its
+// divisor for zero: unless it is a non-zero constant.
+func checksDivisor(v *ssa.Value) bool {
+	d := v.Args[1]
+	return d.Op != ssa.OpConst64 || d.AuxInt == 0
+}
+
+// divCheckContLabel returns the label of the block a division or
+// remainder continues in once its divisor is checked.
+func divCheckContLabel(v *ssa.Value) string {
+	return fmt.Sprintf("divchk.ok.%d", v.ID)
+}
+
+// splitsBlock reports whether the lowering of v ends the current LLVM
+// block and continues in the block contLabel(v).
+func splitsBlock(v *ssa.Value) bool {
+	switch v.Op {
+	case ssa.OpNilCheck, ssa.OpInterfaceCall, ssa.OpTypeAssert:
+		return true
+	case ssa.OpTypeTest:
+		return !types.IsInterface(v.Aux.(types.Type))
+	case ssa.OpDiv64, ssa.OpMod64:
+		return checksDivisor(v)
+	}
+	return false
+}
+
+// contLabel returns the label of the block the lowering of v continues in
+// if it splits its block.
+func contLabel(v *ssa.Value) string {
+	switch v.Op {
+	case ssa.OpTypeAssert:
+		return fmt.Sprintf("assert.ok.%d", v.ID)
+	case ssa.OpTypeTest:
+		return fmt.Sprintf("typetest.done.%d", v.ID)
+	case ssa.OpDiv64, ssa.OpMod64:
+		return divCheckContLabel(v)
+	}
+	return nilCheckContLabel(v)
+}
+
+// labelBefore returns the label of the LLVM block the lowering of v starts
+// in: the continuation of the last value before it that splits its block.
+func labelBefore(v *ssa.Value) string {
+	b := v.Block
+	i := 0
+	for b.Values[i] != v {
+		i++
+	}
+	return labelAt(b, i)
+}
+
+// labelAt returns the label of the LLVM block that ends the lowering of the
+// first n values of b.
+func labelAt(b *ssa.Block, n int) string {
+	for i := n - 1; i >= 0; i-- {
+		if v := b.Values[i]; splitsBlock(v) {
+			return contLabel(v)
+		}
+	}
+	return blockName(b)
+}
+
+// exitLabel returns the label of the LLVM block that ends the lowering of
+// b. Nil checks, interface calls, type assertions and checked divisions
+// split a block, so it is the continuation of the last one.
+func exitLabel(b *ssa.Block) string {
+	return labelAt(b, len(b.Values))
 }
 
 // allocaElemType returns the LLVM element type for an alloca instruction.
@@ -541,3 +888,18 @@ func llvmEscapeString(s string) string {
 	}
 	return b.String()
 }
+
+// llvmSymbol returns a global symbol name as it appears after '@':
+// names outside the plain identifier charset (e.g. package paths with '/')
+// are quoted.
+func llvmSymbol(name string) string {
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		plain := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-' || c == '$' || c == '.' || c == '_' ||
+			i > 0 && c >= '0' && c <= '9'
+		if !plain {
+			return "\"" + llvmEscapeString(name) + "\""
+		}
+	}
+	return name
+}
@@ -0,0 +1,55 @@
+package codegen
+
+import (
+	"github.com/you-not-fish/yoru/internal/rtabi"
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// Maps
+//
+// A map value is a pointer to the runtime's YoruMap object, null for the
+// nil map. Every map operation is a call into the runtime, which takes
+// keys and elements by address: the SSA builder stores them to
+// temporaries.
+
+// mapKeyKind returns the rtabi key kind of the map type t.
+func mapKeyKind(t types.Type) int {
+	key := t.Underlying().(*types.Map).Key()
+	if b, ok := key.Underlying().(*types.Basic); ok && b.Kind() == types.String {
+		return rtabi.MapKeyString
+	}
+	return rtabi.MapKeyScalar
+}
+
+// lowerMakeMap allocates the map of make(map[K]V, hint).
+func (g *generator) lowerMakeMap(v *ssa.Value) {
+	mt := v.Aux.(types.Type).Underlying().(*types.Map)
+	g.e.emitInst("%s = call ptr @%s(ptr %s, ptr %s, i64 %d, i64 %s)", valueName(v), rtabi.FnMakeMap,
+		g.typeDescSymbol(mt.Key()), g.typeDescSymbol(mt.Elem()), mapKeyKind(mt), g.operand(v.Args[0]))
+}
+
+// lowerMapAccess looks up a key; the runtime returns found as an i8.
+func (g *generator) lowerMapAccess(v *ssa.Value) {
+	found := g.e.nextTmp()
+	g.e.emitInst("%s = call i8 @%s(ptr %s, ptr %s, ptr %s)", found, rtabi.FnMapAccess,
+		g.operand(v.Args[0]), g.operand(v.Args[1]), g.operand(v.Args[2]))
+	g.e.emitInst("%s = trunc i8 %s to i1", valueName(v), found)
+}
+
+// lowerMapAssign stores an element into a map.
+func (g *generator) lowerMapAssign(v *ssa.Value) {
+	g.e.emitInst("call void @%s(ptr %s, ptr %s, ptr %s)", rtabi.FnMapAssign,
+		g.operand(v.Args[0]), g.operand(v.Args[1]), g.operand(v.Args[2]))
+}
+
+// lowerMapDelete removes a key from a map.
+func (g *generator) lowerMapDelete(v *ssa.Value) {
+	g.e.emitInst("call void @%s(ptr %s, ptr %s)", rtabi.FnMapDelete,
+		g.operand(v.Args[0]), g.operand(v.Args[1]))
+}
+
+// lowerMapLen emits len(m).
+func (g *generator) lowerMapLen(v *ssa.Value) {
+	g.e.emitInst("%s = call i64 @%s(ptr %s)", valueName(v), rtabi.FnMapLen, g.operand(v.Args[0]))
+}
@@ -0,0 +1,105 @@
+package codegen
+
+import (
+	"github.com/you-not-fish/yoru/internal/rtabi"
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// Slices
+//
+// A slice value is a { ptr, i64, i64 } triple of a data pointer, a length
+// and a capacity. The data pointer points into an array object allocated
+// by rt_makeslice or rt_growslice, or into any other heap object or global
+// holding an array; the collector resolves interior pointers to their
+// object. The nil slice is zeroinitializer.
+//
+// The runtime slice functions take the fields of a slice, or the address
+// of a slice for rt_growslice, since a 24-byte struct is not passed by
+// value the same way by C and by LLVM.
+
+// llvmSliceType is the LLVM type of every slice.
+const llvmSliceType = "{ ptr, i64, i64 }"
+
+// sliceElem returns the element type of the slice type t.
+func sliceElem(t types.Type) types.Type {
+	return t.Underlying().(*types.Slice).Elem()
+}
+
+// emitSliceValue builds the slice named dst from its three fields.
+func (g *generator) emitSliceValue(dst, ptr, length, capacity string) {
+	t0 := g.e.nextTmp()
+	t1 := g.e.nextTmp()
+	g.e.emitInst("%s = insertvalue %s undef, ptr %s, 0", t0, llvmSliceType, ptr)
+	g.e.emitInst("%s = insertvalue %s %s, i64 %s, 1", t1, llvmSliceType, t0, length)
+	g.e.emitInst("%s = insertvalue %s %s, i64 %s, 2", dst, llvmSliceType, t1, capacity)
+}
+
+// extractSliceField extracts field i (0 = ptr, 1 = len, 2 = cap) of the
+// slice s into a fresh temporary.
+func (g *generator) extractSliceField(s string, i int) string {
+	t := g.e.nextTmp()
+	g.e.emitInst("%s = extractvalue %s %s, %d", t, llvmSliceType, s, i)
+	return t
+}
+
+// lowerNewSlice allocates the backing array of make([]T, len, cap).
+func (g *generator) lowerNewSlice(v *ssa.Value) {
+	elem := v.Aux.(types.Type)
+	length := g.operand(v.Args[0])
+	capacity := g.operand(v.Args[1])
+	ptr := g.e.nextTmp()
+	g.e.emitInst("%s = call ptr @%s(ptr %s, i64 %s, i64 %s)",
+		ptr, rtabi.FnMakeSlice, g.typeDescSymbol(elem), length, capacity)
+	g.emitSliceValue(valueName(v), ptr, length, capacity)
+}
+
+// lowerSliceIndexPtr computes the address of element i of a slice.
+func (g *generator) lowerSliceIndexPtr(v *ssa.Value) {
+	ptr := g.extractSliceField(g.operand(v.Args[0]), 0)
+	g.e.emitInst("%s = getelementptr %s, ptr %s, i64 %s",
+		valueName(v), llvmType(sliceElem(v.Args[0].Type)), ptr, g.operand(v.Args[1]))
+}
+
+// lowerSliceSlice computes s[lo:hi] of a slice whose bounds were checked.
+func (g *generator) lowerSliceSlice(v *ssa.Value) {
+	s := g.operand(v.Args[0])
+	lo := g.operand(v.Args[1])
+	hi := g.operand(v.Args[2])
+	ptr := g.extractSliceField(s, 0)
+	capacity := g.extractSliceField(s, 2)
+	newPtr := g.e.nextTmp()
+	g.e.emitInst("%s = getelementptr %s, ptr %s, i64 %s", newPtr, llvmType(sliceElem(v.Type)), ptr, lo)
+	newLen := g.e.nextTmp()
+	g.e.emitInst("%s = sub i64 %s, %s", newLen, hi, lo)
+	newCap := g.e.nextTmp()
+	g.e.emitInst("%s = sub i64 %s, %s", newCap, capacity, lo)
+	g.emitSliceValue(valueName(v), newPtr, newLen, newCap)
+}
+
+// lowerSliceCheck emits the bounds check of a slice expression.
+func (g *generator) lowerSliceCheck(v *ssa.Value) {
+	g.e.emitInst("call void @%s(i64 %s, i64 %s, i64 %s, %s)", rtabi.FnSliceCheck,
+		g.operand(v.Args[0]), g.operand(v.Args[1]), g.operand(v.Args[2]),
+		g.stringConstant(boundsCheckPos(v)))
+}
+
+// lowerGrowSlice grows the slice stored at Args[0] in place.
+func (g *generator) lowerGrowSlice(v *ssa.Value) {
+	elem := v.Aux.(types.Type)
+	g.e.emitInst("call void @%s(ptr %s, ptr %s, i64 %s)", rtabi.FnGrowSlice,
+		g.operand(v.Args[0]), g.typeDescSymbol(elem), g.operand(v.Args[1]))
+}
+
+// lowerSliceCopy emits copy(dst, src).
+func (g *generator) lowerSliceCopy(v *ssa.Value) {
+	dst := g.operand(v.Args[0])
+	src := g.operand(v.Args[1])
+	dstPtr := g.extractSliceField(dst, 0)
+	dstLen := g.extractSliceField(dst, 1)
+	srcPtr := g.extractSliceField(src, 0)
+	srcLen := g.extractSliceField(src, 1)
+	size := g.sizes.Sizeof(sliceElem(v.Args[0].Type))
+	g.e.emitInst("%s = call i64 @%s(ptr %s, i64 %s, ptr %s, i64 %s, i64 %d)", valueName(v),
+		rtabi.FnSliceCopy, dstPtr, dstLen, srcPtr, srcLen, size)
+}
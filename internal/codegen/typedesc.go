@@ -0,0 +1,82 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// collectTypeDescs collects the heap-allocated types of all functions,
+// including the element types of slice backing arrays and the key and
+// element types of maps, and assigns each distinct type a TypeDesc global
+// index.
+func (g *generator) collectTypeDescs(funcs []*ssa.Func) {
+	for _, fn := range funcs {
+		for _, b := range fn.Blocks {
+			for _, v := range b.Values {
+				switch v.Op {
+				case ssa.OpNewAlloc, ssa.OpNewSlice, ssa.OpGrowSlice:
+				case ssa.OpMakeMap:
+					mt := v.Aux.(types.Type).Underlying().(*types.Map)
+					g.typeDescIndex(mt.Key())
+					g.typeDescIndex(mt.Elem())
+					continue
+				default:
+					continue
+				}
+				if t, ok := v.Aux.(types.Type); ok {
+					g.typeDescIndex(t)
+				}
+			}
+		}
+	}
+}
+
+// typeDescIndex returns the index of the TypeDesc global for t, adding it
+// to the table if needed. Types are compared structurally, so distinct
+// named types with the same spelling in different packages get their own
+// descriptors.
+func (g *generator) typeDescIndex(t types.Type) int {
+	for i, d := range g.typeDescs {
+		if types.Identical(d, t) {
+			return i
+		}
+	}
+	g.typeDescs = append(g.typeDescs, t)
+	return len(g.typeDescs) - 1
+}
+
+// typeDescSymbol returns the LLVM operand naming the TypeDesc of t.
+func (g *generator) typeDescSymbol(t types.Type) string {
+	return fmt.Sprintf("@.typedesc.%d", g.typeDescIndex(t))
+}
+
+// emitTypeDesc writes the TypeDesc global with index idx and, if the type
+// contains refs, its offsets table. The layout matches runtime.h:
+//
+//	typedef struct TypeDesc {
+//	    size_t size;
+//	    size_t num_ptrs;
+//	    const uint32_t* offsets;
+//	} TypeDesc;
+func (g *generator) emitTypeDesc(idx int, t types.Type) {
+	name := fmt.Sprintf(".typedesc.%d", idx)
+	offs := g.sizes.RefOffsets(t)
+	sizeT := fmt.Sprintf("i%d", g.sizes.Target().PtrSize*8)
+
+	g.e.emitComment(t.String())
+	offsetsPtr := "null"
+	if len(offs) > 0 {
+		elems := make([]string, len(offs))
+		for i, off := range offs {
+			elems[i] = fmt.Sprintf("i32 %d", off)
+		}
+		g.e.emit("@%s.offsets = private unnamed_addr constant [%d x i32] [%s]",
+			name, len(offs), strings.Join(elems, ", "))
+		offsetsPtr = "@" + name + ".offsets"
+	}
+	g.e.emit("@%s = private constant { %s, %s, ptr } { %s %d, %s %d, ptr %s }",
+		name, sizeT, sizeT, sizeT, g.sizes.Sizeof(t), sizeT, len(offs), offsetsPtr)
+}
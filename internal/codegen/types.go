@@ -12,7 +12,7 @@ func llvmType(t types.Type) string {
 	switch u := t.Underlying().(type) {
 	case *types.Basic:
 		return llvmBasicType(u)
-	case *types.Pointer, *types.Ref:
+	case *types.Pointer, *types.Ref, *types.Map:
 		return rtabi.LLVMTypePtr
 	case *types.Func:
 		return rtabi.LLVMTypePtr
@@ -20,6 +20,12 @@ func llvmType(t types.Type) string {
 		return fmt.Sprintf("[%d x %s]", u.Len(), llvmType(u.Elem()))
 	case *types.Struct:
 		return llvmStructType(u)
+	case *types.Tuple:
+		return llvmTupleType(u)
+	case *types.Interface:
+		return "{ ptr, ptr }"
+	case *types.Slice:
+		return llvmSliceType
 	}
 	return "void"
 }
@@ -58,6 +64,20 @@ func llvmStructType(s *types.Struct) string {
 	return result
 }
 
+// llvmTupleType returns the LLVM struct type literal for the results of a
+// function with several results; such functions return the struct by value.
+func llvmTupleType(t *types.Tuple) string {
+	result := "{ "
+	for i, elem := range t.Types() {
+		if i > 0 {
+			result += ", "
+		}
+		result += llvmType(elem)
+	}
+	result += " }"
+	return result
+}
+
 // llvmReturnType returns the LLVM return type for a function signature.
 // Returns "void" if the function has no result.
 func llvmReturnType(sig *types.Func) string {
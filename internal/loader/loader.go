@@ -0,0 +1,360 @@
+// Package loader loads a Yoru program: the main package and, transitively,
+// every package it imports.
+//
+// Import paths are resolved against the module root, the directory holding
+// the yoru.mod file found by walking up from the main package directory:
+//
+//	module example.com/app
+//
+// The import path "example.com/app/util" then names the package in
+// <root>/util. Without a yoru.mod file the main package directory is the
+// module root and import paths are relative to it. Each package is the set
+// of .yoru files in one directory.
+//
+// Dependencies are parsed and type-checked before the packages importing
+// them; all packages share a single types2.Info.
+package loader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/you-not-fish/yoru/internal/syntax"
+	"github.com/you-not-fish/yoru/internal/types"
+	"github.com/you-not-fish/yoru/internal/types2"
+)
+
+// ModFile is the name of the module file marking the module root.
+const ModFile = "yoru.mod"
+
+// MainPath is the import path of the program's main package.
+const MainPath = "main"
+
+// Config configures the loader.
+type Config struct {
+	// Sizes provides type size and alignment information.
+	// If nil, types.DefaultSizes is used.
+	Sizes *types.Sizes
+
+	// NoASI disables automatic semicolon insertion in the parser.
+	NoASI bool
+
+	// Error is called for each syntax and type error, in any package.
+	// If nil, errors are silently ignored.
+	Error func(pos syntax.Pos, msg string)
+}
+
+// Package is a loaded package.
+type Package struct {
+	Path    string         // import path (MainPath for the main package)
+	Dir     string         // directory containing the files
+	Files   []*syntax.File // parsed files, in name order
+	Types   *types.Package // type-checked package
+	Imports []*Package     // directly imported packages, in import order
+}
+
+// Program is a loaded program.
+type Program struct {
+	// Packages lists all packages in dependency order: every package
+	// appears after the packages it imports, and Main is last.
+	Packages []*Package
+
+	// Main is the main package.
+	Main *Package
+
+	// Info holds the type-checking results of all packages.
+	Info *types2.Info
+
+	// ModulePath is the module path declared in yoru.mod, if any.
+	ModulePath string
+
+	// ModuleRoot is the directory import paths are resolved against.
+	ModuleRoot string
+}
+
+// loader holds the state of a single Load call.
+type loader struct {
+	conf *Config
+	prog *Program
+
+	pkgs    map[string]*Package // loaded or loading packages by import path
+	loading []string            // import paths being loaded, for cycle detection
+	errors  int                 // number of reported errors
+	first   error               // first reported error
+}
+
+// diag is an error reported through Config.Error.
+type diag struct {
+	pos syntax.Pos
+	msg string
+}
+
+func (d *diag) Error() string {
+	return fmt.Sprintf("%s: %s", d.pos, d.msg)
+}
+
+// Load loads the program whose main package consists of the given files.
+//
+// Syntax and type errors are reported through conf.Error and the first of
+// them is returned. If the main package cannot be read or parsed, the
+// returned program is nil; otherwise it is complete but may be ill-typed
+// if err is non-nil.
+func Load(conf *Config, filenames []string) (*Program, error) {
+	if conf == nil {
+		conf = &Config{}
+	}
+	if conf.Sizes == nil {
+		conf.Sizes = types.DefaultSizes
+	}
+	if len(filenames) == 0 {
+		return nil, errors.New("no input file")
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(filenames[0]))
+	if err != nil {
+		return nil, err
+	}
+	root, modPath, err := findModule(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &loader{
+		conf: conf,
+		prog: &Program{
+			Info: &types2.Info{
+				Types:  make(map[syntax.Expr]types2.TypeAndValue),
+				Defs:   make(map[*syntax.Name]types.Object),
+				Uses:   make(map[*syntax.Name]types.Object),
+				Scopes: make(map[syntax.Node]*types.Scope),
+			},
+			ModulePath: modPath,
+			ModuleRoot: root,
+		},
+		pkgs: make(map[string]*Package),
+	}
+
+	mainPkg := &Package{Path: MainPath, Dir: dir}
+	if err := l.parse(mainPkg, filenames); err != nil {
+		return nil, err
+	}
+	if l.errors > 0 {
+		return nil, l.first
+	}
+	l.check(mainPkg)
+	l.prog.Main = mainPkg
+	return l.prog, l.first
+}
+
+// error reports a diagnostic through the configured handler.
+func (l *loader) error(pos syntax.Pos, msg string) {
+	l.errors++
+	if l.first == nil {
+		l.first = &diag{pos, msg}
+	}
+	if l.conf.Error != nil {
+		l.conf.Error(pos, msg)
+	}
+}
+
+// parse parses the given files into pkg. Syntax errors are reported;
+// the returned error is non-nil only if a file could not be read.
+func (l *loader) parse(pkg *Package, filenames []string) error {
+	for _, filename := range filenames {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		p := syntax.NewParser(filename, f, l.error)
+		if l.conf.NoASI {
+			p.SetASIEnabled(false)
+		}
+		pkg.Files = append(pkg.Files, p.Parse())
+		f.Close()
+	}
+	return nil
+}
+
+// check type-checks pkg, loading its imports first, and appends it to the
+// program's package list.
+func (l *loader) check(pkg *Package) {
+	l.pkgs[pkg.Path] = pkg
+	l.loading = append(l.loading, pkg.Path)
+
+	conf := &types2.Config{
+		Error:    types2.ErrorHandler(l.error),
+		Sizes:    l.conf.Sizes,
+		Importer: importer{l, pkg},
+	}
+	pkg.Types, _ = types2.CheckFiles(pkg.Files, conf, l.prog.Info)
+	pkg.Types.SetPath(pkg.Path)
+
+	l.loading = l.loading[:len(l.loading)-1]
+	l.prog.Packages = append(l.prog.Packages, pkg)
+}
+
+// importer resolves the imports of one package.
+type importer struct {
+	l   *loader
+	pkg *Package // importing package
+}
+
+func (imp importer) Import(importPath string) (*types.Package, error) {
+	dep, err := imp.l.load(importPath)
+	if err != nil {
+		return nil, err
+	}
+	imp.pkg.Imports = append(imp.pkg.Imports, dep)
+	return dep.Types, nil
+}
+
+// load returns the type-checked package with the given import path,
+// loading it if necessary.
+func (l *loader) load(importPath string) (*Package, error) {
+	for i, p := range l.loading {
+		if p == importPath {
+			cycle := append(l.loading[i:len(l.loading):len(l.loading)], importPath)
+			return nil, fmt.Errorf("import cycle not allowed: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if pkg := l.pkgs[importPath]; pkg != nil {
+		return pkg, nil
+	}
+
+	dir, err := l.resolve(importPath)
+	if err != nil {
+		return nil, err
+	}
+	filenames, err := packageFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &Package{Path: importPath, Dir: dir}
+	nerrors := l.errors
+	if err := l.parse(pkg, filenames); err != nil {
+		return nil, err
+	}
+	if l.errors > nerrors {
+		return nil, fmt.Errorf("package %s has syntax errors", importPath)
+	}
+	if name := pkg.Files[0].PkgName; name != nil && name.Value == "main" {
+		return nil, fmt.Errorf("%s is a program, not an importable package", importPath)
+	}
+
+	l.check(pkg)
+	return pkg, nil
+}
+
+// resolve maps an import path to a package directory.
+func (l *loader) resolve(importPath string) (string, error) {
+	if !validImportPath(importPath) {
+		return "", fmt.Errorf("invalid import path %q", importPath)
+	}
+
+	rel := importPath
+	if mod := l.prog.ModulePath; mod != "" {
+		switch {
+		case importPath == mod:
+			rel = "."
+		case strings.HasPrefix(importPath, mod+"/"):
+			rel = strings.TrimPrefix(importPath, mod+"/")
+		default:
+			return "", fmt.Errorf("package %s is not in module %s", importPath, mod)
+		}
+	}
+
+	dir := filepath.Join(l.prog.ModuleRoot, filepath.FromSlash(rel))
+	fi, err := os.Stat(dir)
+	if err != nil || !fi.IsDir() {
+		return "", fmt.Errorf("cannot find package %s in %s", importPath, dir)
+	}
+	return dir, nil
+}
+
+// validImportPath reports whether p is a clean, relative, slash-separated
+// path without "." or ".." elements. Paths must not contain '$', which
+// separates receiver types from method names in symbol names (see
+// ssa.LinkName).
+func validImportPath(p string) bool {
+	if p == MainPath || path.IsAbs(p) || path.Clean(p) != p || strings.ContainsAny(p, "\\$") {
+		return false
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == "." || elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// packageFiles returns the .yoru files of a package directory, in name order.
+func packageFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yoru"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .yoru files in %s", dir)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// findModule walks up from dir looking for a yoru.mod file. It returns the
+// module root and module path, or dir and an empty path if there is none.
+func findModule(dir string) (root, modPath string, err error) {
+	for d := dir; ; {
+		modFile := filepath.Join(d, ModFile)
+		if _, err := os.Stat(modFile); err == nil {
+			modPath, err := parseModFile(modFile)
+			return d, modPath, err
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir, "", nil
+		}
+		d = parent
+	}
+}
+
+// parseModFile returns the module path declared in a yoru.mod file.
+// Blank lines and "//" comments are ignored; the only directive is
+// "module <path>".
+func parseModFile(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var modPath string
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		if i := strings.Index(text, "//"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] != "module" || len(fields) != 2 || modPath != "" {
+			return "", fmt.Errorf("%s:%d: expected a single 'module <path>' directive", filename, line)
+		}
+		modPath = strings.Trim(fields[1], `"`)
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	if modPath == "" {
+		return "", fmt.Errorf("%s: missing module directive", filename)
+	}
+	return modPath, nil
+}
@@ -0,0 +1,224 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/syntax"
+)
+
+// writeTree creates the given files (path relative to dir → content).
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// load loads the main package in dir/main.yoru and returns the program
+// and the reported diagnostics.
+func load(t *testing.T, dir string) (*Program, []string) {
+	t.Helper()
+	var errs []string
+	conf := &Config{
+		Error: func(pos syntax.Pos, msg string) {
+			errs = append(errs, pos.String()+": "+msg)
+		},
+	}
+	prog, _ := Load(conf, []string{filepath.Join(dir, "main.yoru")})
+	return prog, errs
+}
+
+func TestLoadModule(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"yoru.mod": "// the module\nmodule example.com/app\n",
+		"cmd/main.yoru": `package main
+
+import "example.com/app/geo"
+import "example.com/app/util"
+
+func main() {
+	var p geo.Point = geo.Point{X: 1, Y: 2}
+	println(util.Twice(p.X))
+}
+`,
+		"geo/geo.yoru": `package geo
+
+import "example.com/app/util"
+
+type Point struct {
+	X int
+	Y int
+}
+
+func (p Point) Sum() int {
+	return util.Twice(p.X) + p.Y
+}
+`,
+		"util/util.yoru": `package util
+
+func Twice(x int) int {
+	return x * 2
+}
+`,
+	})
+
+	prog, errs := load(t, filepath.Join(dir, "cmd"))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors:\n%s", strings.Join(errs, "\n"))
+	}
+	if prog.ModulePath != "example.com/app" || prog.ModuleRoot != dir {
+		t.Errorf("module = %q in %q, want example.com/app in %q", prog.ModulePath, prog.ModuleRoot, dir)
+	}
+
+	var paths []string
+	for _, pkg := range prog.Packages {
+		paths = append(paths, pkg.Path)
+	}
+	want := "example.com/app/util example.com/app/geo main"
+	if got := strings.Join(paths, " "); got != want {
+		t.Errorf("package order = %s, want %s", got, want)
+	}
+	if prog.Main != prog.Packages[len(prog.Packages)-1] {
+		t.Errorf("Main is not the last package")
+	}
+	if len(prog.Main.Imports) != 2 {
+		t.Errorf("main imports %d packages, want 2", len(prog.Main.Imports))
+	}
+	if got := prog.Main.Types.Path(); got != MainPath {
+		t.Errorf("main package path = %q, want %q", got, MainPath)
+	}
+	if got := prog.Packages[0].Types.Path(); got != "example.com/app/util" {
+		t.Errorf("util package path = %q", got)
+	}
+}
+
+func TestLoadWithoutModFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"main.yoru":          "package main\n\nimport \"lib/math\"\n\nfunc main() {\n\tprintln(math.Sq(3))\n}\n",
+		"lib/math/math.yoru": "package math\n\nfunc Sq(x int) int {\n\treturn x * x\n}\n",
+	})
+
+	prog, errs := load(t, dir)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors:\n%s", strings.Join(errs, "\n"))
+	}
+	if prog.ModulePath != "" || prog.ModuleRoot != dir {
+		t.Errorf("module = %q in %q, want none in %q", prog.ModulePath, prog.ModuleRoot, dir)
+	}
+	if len(prog.Packages) != 2 || prog.Packages[0].Path != "lib/math" {
+		t.Errorf("unexpected packages %v", prog.Packages)
+	}
+}
+
+func TestLoadImportCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"yoru.mod":  "module m\n",
+		"main.yoru": "package main\n\nimport \"m/a\"\n\nfunc main() {\n\tprintln(a.F())\n}\n",
+		"a/a.yoru":  "package a\n\nimport \"m/b\"\n\nfunc F() int {\n\treturn b.G()\n}\n",
+		"b/b.yoru":  "package b\n\nimport \"m/c\"\n\nfunc G() int {\n\treturn c.H()\n}\n",
+		"c/c.yoru":  "package c\n\nimport \"m/a\"\n\nfunc H() int {\n\treturn 1\n}\n",
+	})
+
+	_, errs := load(t, dir)
+	text := strings.Join(errs, "\n")
+	want := "could not import m/a (import cycle not allowed: m/a -> m/b -> m/c -> m/a)"
+	if !strings.Contains(text, want) {
+		t.Errorf("missing error %q, got:\n%s", want, text)
+	}
+}
+
+func TestLoadImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"yoru.mod": "module m\n",
+		"main.yoru": `package main
+
+import "m/missing"
+import "other/pkg"
+import "m/../x"
+import "m/a$b"
+import "m/empty"
+import "m/prog"
+
+func main() {
+}
+`,
+		"empty/README":   "no sources\n",
+		"prog/prog.yoru": "package main\n\nfunc main() {\n}\n",
+	})
+
+	_, errs := load(t, dir)
+	text := strings.Join(errs, "\n")
+	for _, want := range []string{
+		"could not import m/missing (cannot find package m/missing in ",
+		"could not import other/pkg (package other/pkg is not in module m)",
+		`could not import m/../x (invalid import path "m/../x")`,
+		`could not import m/a$b (invalid import path "m/a$b")`,
+		"could not import m/empty (no .yoru files in ",
+		"could not import m/prog (m/prog is a program, not an importable package)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing error %q, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestLoadDependencyErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"yoru.mod":     "module m\n",
+		"main.yoru":    "package main\n\nimport \"m/dep\"\n\nfunc main() {\n\tprintln(dep.F())\n}\n",
+		"dep/dep.yoru": "package dep\n\nfunc F() int {\n\treturn true\n}\n",
+	})
+
+	prog, errs := load(t, dir)
+	if prog == nil {
+		t.Fatal("Load returned no program for a type error in a dependency")
+	}
+	text := strings.Join(errs, "\n")
+	if !strings.Contains(text, filepath.Join("dep", "dep.yoru")+":4:") {
+		t.Errorf("type error not reported in dependency file, got:\n%s", text)
+	}
+}
+
+func TestParseModFile(t *testing.T) {
+	tests := []struct {
+		src     string
+		want    string
+		wantErr string
+	}{
+		{"module example.com/x\n", "example.com/x", ""},
+		{"// comment\n\nmodule \"quoted/path\" // trailing\n", "quoted/path", ""},
+		{"\n", "", "missing module directive"},
+		{"module a\nmodule b\n", "", "expected a single 'module <path>' directive"},
+		{"require x\n", "", "expected a single 'module <path>' directive"},
+	}
+	for _, tt := range tests {
+		filename := filepath.Join(t.TempDir(), ModFile)
+		if err := os.WriteFile(filename, []byte(tt.src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseModFile(filename)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseModFile(%q) error = %v, want %q", tt.src, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseModFile(%q) = %q, %v; want %q", tt.src, got, err, tt.want)
+		}
+	}
+}
@@ -10,12 +10,27 @@ const (
 	// Memory allocation
 	FnAlloc = "rt_alloc"
 
+	// Slices
+	FnMakeSlice  = "rt_makeslice"
+	FnGrowSlice  = "rt_growslice"
+	FnSliceCopy  = "rt_slicecopy"
+	FnSliceCheck = "rt_slice_check"
+
+	// Maps
+	FnMakeMap   = "rt_makemap"
+	FnMapAccess = "rt_mapaccess"
+	FnMapAssign = "rt_mapassign"
+	FnMapDelete = "rt_mapdelete"
+	FnMapLen    = "rt_maplen"
+
 	// Garbage collection
-	FnCollect = "rt_collect"
+	FnCollect      = "rt_collect"
+	FnRegisterRoot = "rt_register_root"
 
 	// Error handling
 	FnPanic       = "rt_panic"
 	FnPanicString = "rt_panic_string"
+	FnPanicAssert = "rt_panic_assert"
 
 	// I/O functions
 	FnPrintI64    = "rt_print_i64"
@@ -26,6 +41,7 @@ const (
 
 	// Bounds checking
 	FnBoundsCheck = "rt_bounds_check"
+	FnShiftCheck  = "rt_shift_check"
 
 	// Statistics (debug)
 	FnGetStats   = "rt_get_stats"
@@ -40,10 +56,25 @@ const (
 	TypeDescString = "rt_type_string"
 )
 
+// Map key kinds passed to rt_makemap; they select how keys are hashed
+// and compared.
+const (
+	// MapKeyScalar keys (int, bool and ref) are hashed and compared by
+	// their bytes.
+	MapKeyScalar = 0
+
+	// MapKeyString keys are hashed and compared by their contents.
+	MapKeyString = 1
+)
+
 // User program entry point
 const (
 	// YoruMain is the name of the user's main function.
 	YoruMain = "yoru_main"
+
+	// YoruInit is the name of the generated program initialization
+	// function, called before YoruMain.
+	YoruInit = "yoru_init"
 )
 
 // LLVM intrinsics used by the runtime
@@ -70,12 +101,26 @@ func RuntimeFunctions() []FuncSignature {
 		// Memory allocation
 		{Name: FnAlloc, ReturnType: "ptr", ParamTypes: []string{"i64", "ptr"}},
 
+		// Slices
+		{Name: FnMakeSlice, ReturnType: "ptr", ParamTypes: []string{"ptr", "i64", "i64"}},
+		{Name: FnGrowSlice, ReturnType: "void", ParamTypes: []string{"ptr", "ptr", "i64"}},
+		{Name: FnSliceCopy, ReturnType: "i64", ParamTypes: []string{"ptr", "i64", "ptr", "i64", "i64"}},
+
+		// Maps
+		{Name: FnMakeMap, ReturnType: "ptr", ParamTypes: []string{"ptr", "ptr", "i64", "i64"}},
+		{Name: FnMapAccess, ReturnType: "i8", ParamTypes: []string{"ptr", "ptr", "ptr"}},
+		{Name: FnMapAssign, ReturnType: "void", ParamTypes: []string{"ptr", "ptr", "ptr"}},
+		{Name: FnMapDelete, ReturnType: "void", ParamTypes: []string{"ptr", "ptr"}},
+		{Name: FnMapLen, ReturnType: "i64", ParamTypes: []string{"ptr"}},
+
 		// Garbage collection
 		{Name: FnCollect, ReturnType: "void", ParamTypes: nil},
+		{Name: FnRegisterRoot, ReturnType: "void", ParamTypes: []string{"ptr"}},
 
 		// Error handling
 		{Name: FnPanic, ReturnType: "void", ParamTypes: []string{"ptr"}, NoReturn: true},
 		{Name: FnPanicString, ReturnType: "void", ParamTypes: []string{LLVMTypeString}, NoReturn: true},
+		{Name: FnPanicAssert, ReturnType: "void", ParamTypes: []string{"ptr", LLVMTypeString, LLVMTypeString}, NoReturn: true},
 
 		// I/O functions
 		{Name: FnPrintI64, ReturnType: "void", ParamTypes: []string{"i64"}},
@@ -85,6 +130,8 @@ func RuntimeFunctions() []FuncSignature {
 		{Name: FnPrintln, ReturnType: "void", ParamTypes: nil},
 
 		// Bounds checking
-		{Name: FnBoundsCheck, ReturnType: "void", ParamTypes: []string{"i64", "i64"}},
+		{Name: FnBoundsCheck, ReturnType: "void", ParamTypes: []string{"i64", "i64", LLVMTypeString}},
+		{Name: FnShiftCheck, ReturnType: "void", ParamTypes: []string{"i64"}},
+		{Name: FnSliceCheck, ReturnType: "void", ParamTypes: []string{"i64", "i64", "i64", LLVMTypeString}},
 	}
 }
@@ -0,0 +1,23 @@
+// Command hosttarget prints the LLVM triple of the supported target
+// matching the host, for the Makefile. Unlike rtabi.HostTarget, it fails
+// instead of falling back to the default target on other hosts.
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/you-not-fish/yoru/internal/rtabi"
+)
+
+func main() {
+	for _, t := range rtabi.Targets {
+		if t.GOOS == runtime.GOOS && t.GOARCH == runtime.GOARCH {
+			fmt.Println(t.Triple)
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "hosttarget: unsupported host %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	os.Exit(1)
+}
@@ -0,0 +1,116 @@
+package rtabi
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Target describes a code generation target.
+//
+// All supported targets are 64-bit little-endian, so the object header and
+// TypeDesc layouts below are shared; only the LLVM module configuration and
+// the scalar alignments used by types.Sizes vary per target.
+type Target struct {
+	// Name is the short name accepted by the -target flag.
+	Name string
+
+	// Triple is the LLVM target triple for code generation.
+	Triple string
+
+	// DataLayout is the LLVM data layout string matching the triple.
+	DataLayout string
+
+	// GOOS and GOARCH identify the host this target is native to.
+	GOOS, GOARCH string
+
+	// Scalar sizes and alignments in bytes.
+	PtrSize    int64
+	PtrAlign   int64
+	IntAlign   int64 // int64_t
+	FloatAlign int64 // double
+}
+
+// Supported targets.
+var (
+	// TargetDarwinARM64 is the original Phase 0 target.
+	TargetDarwinARM64 = &Target{
+		Name:       "arm64-apple-macosx",
+		Triple:     "arm64-apple-macosx26.0.0",
+		DataLayout: "e-m:o-i64:64-i128:128-n32:64-S128-Fn32",
+		GOOS:       "darwin",
+		GOARCH:     "arm64",
+		PtrSize:    8,
+		PtrAlign:   8,
+		IntAlign:   8,
+		FloatAlign: 8,
+	}
+
+	TargetLinuxAMD64 = &Target{
+		Name:       "x86_64-linux-gnu",
+		Triple:     "x86_64-unknown-linux-gnu",
+		DataLayout: "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-i128:128-f80:128-n8:16:32:64-S128",
+		GOOS:       "linux",
+		GOARCH:     "amd64",
+		PtrSize:    8,
+		PtrAlign:   8,
+		IntAlign:   8,
+		FloatAlign: 8,
+	}
+
+	TargetLinuxARM64 = &Target{
+		Name:       "aarch64-linux-gnu",
+		Triple:     "aarch64-unknown-linux-gnu",
+		DataLayout: "e-m:e-i8:8:32-i16:16:32-i64:64-i128:128-n32:64-S128-Fn32",
+		GOOS:       "linux",
+		GOARCH:     "arm64",
+		PtrSize:    8,
+		PtrAlign:   8,
+		IntAlign:   8,
+		FloatAlign: 8,
+	}
+)
+
+// Targets lists all supported targets.
+var Targets = []*Target{
+	TargetDarwinARM64,
+	TargetLinuxAMD64,
+	TargetLinuxARM64,
+}
+
+// DefaultTarget is used when the host is not a supported target.
+var DefaultTarget = TargetDarwinARM64
+
+// HostTarget returns the target matching the machine the compiler runs on,
+// or DefaultTarget if the host is not supported.
+func HostTarget() *Target {
+	for _, t := range Targets {
+		if t.GOOS == runtime.GOOS && t.GOARCH == runtime.GOARCH {
+			return t
+		}
+	}
+	return DefaultTarget
+}
+
+// LookupTarget returns the target with the given name or LLVM triple.
+// An empty name selects the host target.
+func LookupTarget(name string) (*Target, error) {
+	if name == "" {
+		return HostTarget(), nil
+	}
+	for _, t := range Targets {
+		if t.Name == name || t.Triple == name {
+			return t, nil
+		}
+	}
+	names := make([]string, len(Targets))
+	for i, t := range Targets {
+		names[i] = t.Name
+	}
+	return nil, fmt.Errorf("unknown target %q (supported: %s)", name, strings.Join(names, ", "))
+}
+
+// String returns the target name.
+func (t *Target) String() string {
+	return t.Name
+}
@@ -2,14 +2,7 @@
 // These values must be kept in sync with runtime/runtime.h.
 package rtabi
 
-// Target configuration (Phase 0 locked)
-const (
-	// TargetTriple is the LLVM target triple for code generation.
-	TargetTriple = "arm64-apple-macosx26.0.0"
-
-	// DataLayout is the LLVM data layout string matching the target.
-	DataLayout = "e-m:o-i64:64-i128:128-n32:64-S128-Fn32"
-)
+// Target-specific configuration (triple, data layout) lives in target.go.
 
 // Basic type sizes in bytes
 const (
@@ -63,6 +56,10 @@ const (
 
 	// GCMarkBit is the bit used for GC marking in next_mark.
 	GCMarkBit = 0
+
+	// GCArrayBit is the bit in next_mark that marks an array object, the
+	// backing store of a slice.
+	GCArrayBit = 1
 )
 
 // LLVM type names for code generation
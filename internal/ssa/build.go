@@ -16,23 +16,36 @@ type builder struct {
 	fn *Func  // current SSA function
 	b  *Block // current block (nil = unreachable)
 
-	vars map[types.Object]*Value // Object → alloca mapping
+	vars    map[types.Object]*Value // Object → alloca mapping
+	escapes map[types.Object]bool   // local arrays that are sliced, allocated on the heap
 
-	breakTarget    *Block // innermost loop exit
+	breakTarget    *Block // innermost loop or switch exit
 	continueTarget *Block // innermost loop header
 }
 
 // BuildFile builds SSA functions for all function declarations in the file.
 // It returns a list of SSA functions (one per FuncDecl with a body).
 func BuildFile(file *syntax.File, info *types2.Info, sizes *types.Sizes) []*Func {
+	return BuildFiles([]*syntax.File{file}, info, sizes)
+}
+
+// BuildFiles builds SSA functions for all function declarations in the
+// files of one package, in file order. The files must have been checked
+// together by types2.CheckFiles.
+func BuildFiles(files []*syntax.File, info *types2.Info, sizes *types.Sizes) []*Func {
 	var funcs []*Func
-	for _, decl := range file.Decls {
-		fd, ok := decl.(*syntax.FuncDecl)
-		if !ok || fd.Body == nil {
-			continue
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*syntax.FuncDecl)
+			if !ok || fd.Body == nil {
+				continue
+			}
+			if fd.Name.Value == "_" {
+				continue // cannot be called
+			}
+			fn := buildFunc(fd, info, sizes)
+			funcs = append(funcs, fn)
 		}
-		fn := buildFunc(fd, info, sizes)
-		funcs = append(funcs, fn)
 	}
 	return funcs
 }
@@ -51,6 +64,9 @@ func buildFunc(fd *syntax.FuncDecl, info *types2.Info, sizes *types.Sizes) *Func
 	sig := funcObj.Signature()
 
 	fn := NewFunc(fd.Name.Value, sig)
+	fn.LinkName = LinkName(funcObj)
+	fn.Sizes = sizes
+	fn.NoInline = fd.Pragma&syntax.NoInline != 0
 
 	b := &builder{
 		info:  info,
@@ -59,6 +75,7 @@ func buildFunc(fd *syntax.FuncDecl, info *types2.Info, sizes *types.Sizes) *Func
 		b:     fn.Entry,
 		vars:  make(map[types.Object]*Value),
 	}
+	b.escapes = b.slicedVars(fd.Body)
 
 	// Emit receiver as OpArg + OpAlloca + OpStore (if method).
 	if fd.Recv != nil && sig.Recv() != nil {
@@ -67,7 +84,7 @@ func buildFunc(fd *syntax.FuncDecl, info *types2.Info, sizes *types.Sizes) *Func
 		argVal.AuxInt = -1 // receiver distinguished from params
 		argVal.Aux = recv.Name()
 
-		alloca := b.entryAlloca(recv.Type(), recv.Name())
+		alloca := b.localVar(recv, recv.Name())
 		fn.NewValue(fn.Entry, OpStore, nil, alloca, argVal)
 
 		// Map the receiver object. The type checker inserts sig.Recv()
@@ -82,7 +99,7 @@ func buildFunc(fd *syntax.FuncDecl, info *types2.Info, sizes *types.Sizes) *Func
 		argVal.AuxInt = int64(i)
 		argVal.Aux = param.Name()
 
-		alloca := b.entryAlloca(param.Type(), param.Name())
+		alloca := b.localVar(param, param.Name())
 		fn.NewValue(fn.Entry, OpStore, nil, alloca, argVal)
 
 		// Map the parameter object. The type checker inserts sig.Param(i)
@@ -112,6 +129,60 @@ func (b *builder) entryAlloca(typ types.Type, name string) *Value {
 	return alloca
 }
 
+// localVar allocates the storage of the local variable obj: an alloca, or
+// a heap object if obj is an array whose elements a slice may reference
+// after the function returns.
+func (b *builder) localVar(obj types.Object, name string) *Value {
+	if !b.escapes[obj] {
+		return b.entryAlloca(obj.Type(), name)
+	}
+	v := b.fn.NewValue(b.b, OpNewAlloc, types.NewRef(obj.Type()))
+	v.Aux = obj.Type()
+	return v
+}
+
+// slicedVars returns the local variables of body whose storage is sliced:
+// the x in x[lo:hi] with x an array, or the variable holding such an array
+// in a struct field or array element.
+func (b *builder) slicedVars(body *syntax.BlockStmt) map[types.Object]bool {
+	sliced := make(map[types.Object]bool)
+	syntax.Inspect(body, func(n syntax.Node) bool {
+		e, ok := n.(*syntax.SliceExpr)
+		if !ok {
+			return true
+		}
+		if !isArray(b.exprType(e.X)) {
+			return true
+		}
+		// Walk from the sliced array to the variable holding it.
+		x := e.X
+		for {
+			switch y := x.(type) {
+			case *syntax.ParenExpr:
+				x = y.X
+				continue
+			case *syntax.SelectorExpr:
+				if !b.isQualified(y) && !isPointerOrRef(b.exprType(y.X)) {
+					x = y.X
+					continue
+				}
+			case *syntax.IndexExpr:
+				if isArray(b.exprType(y.X)) {
+					x = y.X
+					continue
+				}
+			case *syntax.Name:
+				if v, ok := b.info.Uses[y].(*types.Var); ok && !isGlobal(v) {
+					sliced[v] = true
+				}
+			}
+			break
+		}
+		return true
+	})
+	return sliced
+}
+
 // stmts lowers a list of statements.
 func (b *builder) stmts(list []syntax.Stmt) {
 	for _, s := range list {
@@ -151,6 +222,9 @@ func (b *builder) stmt(s syntax.Stmt) {
 	case *syntax.ForStmt:
 		b.forStmt(s)
 
+	case *syntax.TypeSwitchStmt:
+		b.typeSwitchStmt(s)
+
 	case *syntax.BranchStmt:
 		b.branchStmt(s)
 
@@ -179,12 +253,12 @@ func (b *builder) varDecl(d *syntax.VarDecl) {
 		return
 	}
 
-	alloca := b.entryAlloca(obj.Type(), d.Name.Value)
+	alloca := b.localVar(obj, d.Name.Value)
 	b.vars[obj] = alloca
 
 	if d.Value != nil {
 		// var x T = expr
-		val := b.expr(d.Value)
+		val := b.convert(b.expr(d.Value), obj.Type())
 		b.fn.NewValue(b.b, OpStore, nil, alloca, val)
 	} else {
 		// var x T (zero-initialized)
@@ -195,51 +269,122 @@ func (b *builder) varDecl(d *syntax.VarDecl) {
 }
 
 // assignStmt handles assignment (=) and short declaration (:=).
+// The addresses of the left-hand side are computed first, then all the
+// values, and only then are the values stored, so that a, b = b, a swaps.
+// A map element m[k] is assigned by storing the value to a temporary and
+// inserting it into m.
 func (b *builder) assignStmt(s *syntax.AssignStmt) {
-	if s.Op.IsDefine() {
-		// Short declaration: x := expr
-		// LHS[0] must be a Name.
-		for i, lhs := range s.LHS {
-			name, ok := lhs.(*syntax.Name)
-			if !ok {
-				continue
-			}
-			obj := b.info.Defs[name]
-			if obj == nil {
-				continue
-			}
+	ptrs := make([]*Value, len(s.LHS))
+	elems := make([]*mapElem, len(s.LHS))
+	for i, lhs := range s.LHS {
+		if e, ok := lhs.(*syntax.IndexExpr); ok && isMap(b.exprType(e.X)) {
+			elems[i] = b.mapElemLHS(e)
+			ptrs[i] = elems[i].val
+			continue
+		}
+		ptrs[i] = b.lhsAddr(s.Op, lhs)
+	}
+	vals := b.exprList(s.RHS, len(s.LHS))
+	for i, ptr := range ptrs {
+		if ptr != nil {
+			val := b.convert(vals[i], derefType(ptr.Type))
+			b.fn.NewValue(b.b, OpStore, nil, ptr, val)
+		}
+		if me := elems[i]; me != nil {
+			b.fn.NewValue(b.b, OpMapAssign, nil, me.m, me.key, me.val)
+		}
+	}
+}
 
-			alloca := b.entryAlloca(obj.Type(), name.Value)
-			b.vars[obj] = alloca
+// mapElem is the left-hand side m[k] of an assignment: the map, the
+// address of the key and the address of the temporary holding the value.
+type mapElem struct {
+	m, key, val *Value
+}
 
-			if i < len(s.RHS) {
-				val := b.expr(s.RHS[i])
-				b.fn.NewValue(b.b, OpStore, nil, alloca, val)
-			}
-		}
-		return
+// mapElemLHS evaluates the map and key of the assignment to m[k].
+func (b *builder) mapElemLHS(e *syntax.IndexExpr) *mapElem {
+	mt := b.exprType(e.X).Underlying().(*types.Map)
+	m := b.expr(e.X)
+	return &mapElem{
+		m:   m,
+		key: b.mapKey(mt, e.Index),
+		val: b.entryAlloca(mt.Elem(), ""),
 	}
+}
 
-	// Regular assignment: lhs = rhs
-	for i, lhs := range s.LHS {
-		if i >= len(s.RHS) {
-			break
+// lhsAddr returns the address an assignment stores to lhs, allocating
+// the variables a short declaration defines. It returns nil for the
+// blank identifier, whose value is discarded.
+func (b *builder) lhsAddr(op syntax.Token, lhs syntax.Expr) *Value {
+	if name, ok := lhs.(*syntax.Name); ok {
+		if name.Value == "_" {
+			return nil
+		}
+		if obj := b.info.Defs[name]; op.IsDefine() && obj != nil {
+			alloca := b.localVar(obj, name.Value)
+			b.vars[obj] = alloca
+			return alloca
 		}
-		ptr := b.addr(lhs)
-		val := b.expr(s.RHS[i])
-		b.fn.NewValue(b.b, OpStore, nil, ptr, val)
+		// A name redeclared by := is assigned like in a regular
+		// assignment.
+	}
+	return b.addr(lhs)
+}
+
+// exprList lowers the n values of the right-hand side of an assignment.
+// A single call of a function with several results is split into its
+// elements.
+func (b *builder) exprList(list []syntax.Expr, n int) []*Value {
+	if len(list) == 1 && n > 1 {
+		return b.splitTuple(b.expr(list[0]))
+	}
+
+	vals := make([]*Value, len(list))
+	for i, e := range list {
+		vals[i] = b.expr(e)
+	}
+	return vals
+}
+
+// splitTuple returns the elements of a value of tuple type.
+func (b *builder) splitTuple(tuple *Value) []*Value {
+	t := tuple.Type.(*types.Tuple)
+	vals := make([]*Value, t.Len())
+	for i := range vals {
+		vals[i] = b.fn.NewValue(b.b, OpExtractValue, t.At(i), tuple)
+		vals[i].AuxInt = int64(i)
 	}
+	return vals
 }
 
-// returnStmt handles: return [expr]
+// returnStmt handles: return [expr, ...]
+// Several results are returned as one aggregate of the result tuple.
 func (b *builder) returnStmt(s *syntax.ReturnStmt) {
-	if s.Result != nil {
-		val := b.expr(s.Result)
-		// b.b may have changed due to expr evaluation (e.g., short-circuit).
-		b.b.Kind = BlockReturn
+	var val *Value
+	result := b.fn.Sig.Result()
+	switch len(s.Results) {
+	case 0:
+	case 1:
+		// A single result, or a call returning all the results.
+		val = b.expr(s.Results[0])
+		if t, ok := result.(*types.Tuple); ok && !types.Identical(val.Type, t) {
+			// Some results are converted to interface types.
+			val = b.fn.NewValue(b.b, OpMakeAggregate, t, b.convertList(b.splitTuple(val), t)...)
+		} else {
+			val = b.convert(val, result)
+		}
+	default:
+		elems := make([]*Value, len(s.Results))
+		for i, r := range s.Results {
+			elems[i] = b.expr(r)
+		}
+		val = b.fn.NewValue(b.b, OpMakeAggregate, result, b.convertList(elems, result.(*types.Tuple))...)
+	}
+	// b.b may have changed due to expr evaluation (e.g., short-circuit).
+	b.b.Kind = BlockReturn
+	if val != nil {
 		b.b.SetControl(val)
-	} else {
-		b.b.Kind = BlockReturn
 	}
 	b.b = nil // subsequent code is unreachable
 }
@@ -339,6 +484,73 @@ func (b *builder) forStmt(s *syntax.ForStmt) {
 	b.b = bExit
 }
 
+// typeSwitchStmt handles: switch [v :=] x.(type) { case T1, T2: ... }
+// The operand is evaluated once and tested against the case types in
+// source order; the default clause is taken if no test succeeds. A clause
+// with a variable first asserts the operand to the variable's type. A
+// break in a clause exits the switch.
+func (b *builder) typeSwitchStmt(s *syntax.TypeSwitchStmt) {
+	x := b.expr(s.X)
+	bDone := b.fn.NewBlock(BlockPlain)
+
+	bodies := make([]*Block, len(s.Body))
+	var bDefault *Block
+	for i, clause := range s.Body {
+		bodies[i] = b.fn.NewBlock(BlockPlain)
+		if clause.Types == nil {
+			bDefault = bodies[i]
+			continue
+		}
+		for _, e := range clause.Types {
+			var cond *Value
+			if T := b.info.Types[e].Type; types.IsNil(T) {
+				cond = b.fn.NewValue(b.b, OpInterfaceIsNil, types.Typ[types.Bool], x)
+			} else {
+				cond = b.fn.NewValue(b.b, OpTypeTest, types.Typ[types.Bool], x)
+				cond.Aux = T
+			}
+			bNext := b.fn.NewBlock(BlockPlain)
+			b.b.Kind = BlockIf
+			b.b.SetControl(cond)
+			b.b.AddSucc(bodies[i])
+			b.b.AddSucc(bNext)
+			b.b = bNext
+		}
+	}
+	if bDefault != nil {
+		b.b.AddSucc(bDefault)
+	} else {
+		b.b.AddSucc(bDone)
+	}
+
+	savedBreak := b.breakTarget
+	b.breakTarget = bDone
+	for i, clause := range s.Body {
+		b.b = bodies[i]
+		if obj := b.info.Implicits[clause]; obj != nil {
+			alloca := b.localVar(obj, obj.Name())
+			b.vars[obj] = alloca
+			val := x
+			if !types.Identical(obj.Type(), x.Type) {
+				val = b.typeAssert(x, obj.Type(), clause.Pos())
+			}
+			b.fn.NewValue(b.b, OpStore, nil, alloca, val)
+		}
+		b.stmts(clause.Body)
+		if b.b != nil {
+			b.b.AddSucc(bDone)
+		}
+	}
+	b.breakTarget = savedBreak
+
+	if len(bDone.Preds) > 0 {
+		b.b = bDone
+	} else {
+		b.removeDead(bDone)
+		b.b = nil
+	}
+}
+
 // branchStmt handles break and continue.
 func (b *builder) branchStmt(s *syntax.BranchStmt) {
 	if s.Tok.IsBreak() {
@@ -37,6 +37,9 @@ func (b *builder) expr(e syntax.Expr) *Value {
 	case *syntax.IndexExpr:
 		return b.indexExpr(e)
 
+	case *syntax.SliceExpr:
+		return b.sliceExpr(e)
+
 	case *syntax.CompositeLit:
 		return b.compositeLitExpr(e)
 
@@ -46,6 +49,9 @@ func (b *builder) expr(e syntax.Expr) *Value {
 	case *syntax.NewExpr:
 		return b.newExpr(e)
 
+	case *syntax.AssertExpr:
+		return b.assertExpr(e)
+
 	default:
 		panic(fmt.Sprintf("ssa.builder.expr: unhandled %T", e))
 	}
@@ -146,7 +152,11 @@ func (b *builder) nameExpr(e *syntax.Name) *Value {
 
 	alloca, ok := b.vars[obj]
 	if !ok {
-		panic(fmt.Sprintf("ssa.nameExpr: no alloca for %q", e.Value))
+		v, isVar := obj.(*types.Var)
+		if !isVar || !isGlobal(v) {
+			panic(fmt.Sprintf("ssa.nameExpr: no alloca for %q", e.Value))
+		}
+		alloca = b.global(v)
 	}
 
 	// Load from the alloca.
@@ -154,6 +164,18 @@ func (b *builder) nameExpr(e *syntax.Name) *Value {
 	return b.fn.NewValue(b.b, OpLoad, loadTyp, alloca)
 }
 
+// global returns the address of the package-level variable v.
+func (b *builder) global(v *types.Var) *Value {
+	addr := b.fn.NewValue(b.b, OpGlobal, types.NewPointer(v.Type()))
+	addr.Aux = v
+	return addr
+}
+
+// isGlobal reports whether v is a package-level variable.
+func isGlobal(v *types.Var) bool {
+	return v.Pkg() != nil && v.Parent() == v.Pkg().Scope()
+}
+
 // operationExpr handles unary and binary operations.
 func (b *builder) operationExpr(e *syntax.Operation) *Value {
 	if e.Y == nil {
@@ -177,6 +199,10 @@ func (b *builder) unaryExpr(e *syntax.Operation) *Value {
 		}
 		return b.fn.NewValue(b.b, OpNeg64, xTyp, x)
 
+	case syntax.Xor: // ^ (bitwise complement)
+		x := b.expr(e.X)
+		return b.fn.NewValue(b.b, OpCom64, b.exprType(e), x)
+
 	case syntax.And: // & (address-of)
 		ptr := b.addr(e.X)
 		return ptr
@@ -186,7 +212,7 @@ func (b *builder) unaryExpr(e *syntax.Operation) *Value {
 		// Determine the element type.
 		xTyp := b.exprType(e.X)
 		if isRef(xTyp) {
-			b.nilCheck(x)
+			b.nilCheck(x, e.Pos())
 		}
 		elemTyp := derefType(xTyp)
 		return b.fn.NewValue(b.b, OpLoad, elemTyp, x)
@@ -203,6 +229,18 @@ func (b *builder) binaryExpr(e *syntax.Operation) *Value {
 		return b.shortCircuit(e)
 	}
 
+	// Interface, slice and map values are only compared to nil.
+	if isInterface(b.exprType(e.X)) || isInterface(b.exprType(e.Y)) {
+		return b.interfaceNilCompare(e)
+	}
+	if isSlice(b.exprType(e.X)) || isSlice(b.exprType(e.Y)) {
+		return b.sliceNilCompare(e)
+	}
+	if isMap(b.exprType(e.X)) || isMap(b.exprType(e.Y)) {
+		// A map is a pointer to its hash table.
+		return b.fn.NewValue(b.b, ptrBinOp(e.Op), types.Typ[types.Bool], b.expr(e.X), b.expr(e.Y))
+	}
+
 	x := b.expr(e.X)
 	y := b.expr(e.Y)
 
@@ -213,6 +251,74 @@ func (b *builder) binaryExpr(e *syntax.Operation) *Value {
 	return b.fn.NewValue(b.b, op, resTyp, x, y)
 }
 
+// interfaceNilCompare lowers the comparison of an interface value with nil.
+func (b *builder) interfaceNilCompare(e *syntax.Operation) *Value {
+	iface := e.X
+	if !isInterface(b.exprType(iface)) {
+		iface = e.Y
+	}
+	v := b.fn.NewValue(b.b, OpInterfaceIsNil, types.Typ[types.Bool], b.expr(iface))
+	if e.Op.String() == "!=" {
+		v = b.fn.NewValue(b.b, OpNot, types.Typ[types.Bool], v)
+	}
+	return v
+}
+
+// sliceNilCompare lowers the comparison of a slice with nil: a slice is
+// nil if its data pointer is.
+func (b *builder) sliceNilCompare(e *syntax.Operation) *Value {
+	x := e.X
+	if !isSlice(b.exprType(x)) {
+		x = e.Y
+	}
+	st := b.exprType(x).Underlying().(*types.Slice)
+	ptrTyp := types.NewPointer(st.Elem())
+	ptr := b.fn.NewValue(b.b, OpSlicePtr, ptrTyp, b.expr(x))
+	null := b.fn.NewValue(b.b, OpConstNil, ptrTyp)
+	return b.fn.NewValue(b.b, ptrBinOp(e.Op), types.Typ[types.Bool], ptr, null)
+}
+
+// assertExpr lowers a type assertion x.(T). The comma-ok form yields a
+// (T, bool) tuple holding the zero value and false if the dynamic type of
+// x is not T.
+func (b *builder) assertExpr(e *syntax.AssertExpr) *Value {
+	x := b.expr(e.X)
+	tup, ok := b.exprType(e).(*types.Tuple)
+	if !ok {
+		return b.typeAssert(x, b.exprType(e), e.Pos())
+	}
+	T := tup.At(0)
+	okVal := b.fn.NewValue(b.b, OpTypeTest, types.Typ[types.Bool], x)
+	okVal.Aux = T
+
+	tmp := b.entryAlloca(T, "")
+	zero := b.fn.NewValue(b.b, OpZero, nil, tmp)
+	zero.AuxInt = b.sizes.Sizeof(T)
+
+	bOk := b.fn.NewBlock(BlockPlain)
+	bDone := b.fn.NewBlock(BlockPlain)
+	b.b.Kind = BlockIf
+	b.b.SetControl(okVal)
+	b.b.AddSucc(bOk)
+	b.b.AddSucc(bDone)
+
+	b.b = bOk
+	b.fn.NewValue(b.b, OpStore, nil, tmp, b.typeAssert(x, T, e.Pos()))
+	b.b.AddSucc(bDone)
+
+	b.b = bDone
+	val := b.fn.NewValue(b.b, OpLoad, T, tmp)
+	return b.fn.NewValue(b.b, OpMakeAggregate, tup, val, okVal)
+}
+
+// typeAssert emits the assertion that the interface x holds a T.
+// pos is the position reported if the assertion fails.
+func (b *builder) typeAssert(x *Value, T types.Type, pos syntax.Pos) *Value {
+	v := b.fn.NewValuePos(b.b, OpTypeAssert, T, pos, x)
+	v.Aux = T
+	return v
+}
+
 // shortCircuit implements short-circuit evaluation for && and ||.
 func (b *builder) shortCircuit(e *syntax.Operation) *Value {
 	left := b.expr(e.X)
@@ -287,6 +393,18 @@ func intBinOp(tok syntax.Token) Op {
 		return OpDiv64
 	case "%":
 		return OpMod64
+	case "&":
+		return OpAnd64
+	case "|":
+		return OpOr64
+	case "^":
+		return OpXor64
+	case "&^":
+		return OpAndNot64
+	case "<<":
+		return OpLsh64
+	case ">>":
+		return OpRsh64
 	case "==":
 		return OpEq64
 	case "!=":
@@ -346,6 +464,10 @@ func ptrBinOp(tok syntax.Token) Op {
 func (b *builder) callExpr(e *syntax.CallExpr) *Value {
 	// Check for method call: e.Fun is SelectorExpr.
 	if sel, ok := e.Fun.(*syntax.SelectorExpr); ok {
+		if b.isQualified(sel) {
+			// pkg.F(args...) is a direct call of an imported function.
+			return b.staticCall(e, sel.Sel)
+		}
 		return b.methodCallExpr(e, sel)
 	}
 
@@ -358,37 +480,59 @@ func (b *builder) callExpr(e *syntax.CallExpr) *Value {
 	return b.regularCall(e)
 }
 
+// isQualified reports whether sel is a qualified identifier pkg.Name.
+func (b *builder) isQualified(sel *syntax.SelectorExpr) bool {
+	x, ok := sel.X.(*syntax.Name)
+	if !ok {
+		return false
+	}
+	_, ok = b.info.Uses[x].(*types.PkgName)
+	return ok
+}
+
 // regularCall handles a direct function call.
 func (b *builder) regularCall(e *syntax.CallExpr) *Value {
 	funName, ok := e.Fun.(*syntax.Name)
 	if !ok {
 		panic("ssa.regularCall: non-name function expression")
 	}
+	return b.staticCall(e, funName)
+}
 
+// staticCall emits a direct call of the function denoted by funName.
+func (b *builder) staticCall(e *syntax.CallExpr, funName *syntax.Name) *Value {
 	obj := b.info.Uses[funName]
 	funcObj, ok := obj.(*types.FuncObj)
 	if !ok {
-		panic(fmt.Sprintf("ssa.regularCall: expected *types.FuncObj, got %T", obj))
+		panic(fmt.Sprintf("ssa.staticCall: expected *types.FuncObj, got %T", obj))
 	}
 
 	// Evaluate arguments.
-	args := make([]*Value, len(e.Args))
-	for i, arg := range e.Args {
-		args[i] = b.expr(arg)
-	}
+	sig := funcObj.Signature()
+	args := b.callArgs(e, sig)
 
 	// Determine result type.
-	sig := funcObj.Signature()
 	var resTyp types.Type
 	if sig.Result() != nil {
 		resTyp = sig.Result()
 	}
 
-	v := b.fn.NewValue(b.b, OpStaticCall, resTyp, args...)
+	v := b.fn.NewValuePos(b.b, OpStaticCall, resTyp, e.Pos(), args...)
 	v.Aux = funcObj
 	return v
 }
 
+// callArgs evaluates the arguments of a call of a function with signature
+// sig and converts them to the parameter types. A single call of a
+// function with several results passes its results as the arguments.
+func (b *builder) callArgs(e *syntax.CallExpr, sig *types.Func) []*Value {
+	args := b.exprList(e.Args, sig.NumParams())
+	for i, arg := range args {
+		args[i] = b.convert(arg, sig.Param(i).Type())
+	}
+	return args
+}
+
 // methodCallExpr handles a method call: recv.Method(args...)
 func (b *builder) methodCallExpr(e *syntax.CallExpr, sel *syntax.SelectorExpr) *Value {
 	// Look up the method.
@@ -403,6 +547,11 @@ func (b *builder) methodCallExpr(e *syntax.CallExpr, sel *syntax.SelectorExpr) *
 
 	sig := funcObj.Signature()
 
+	// Methods of an interface value are called through its method table.
+	if it, ok := b.exprType(sel.X).Underlying().(*types.Interface); ok {
+		return b.interfaceCall(e, sel, it)
+	}
+
 	// Evaluate receiver.
 	recv := b.expr(sel.X)
 
@@ -417,32 +566,43 @@ func (b *builder) methodCallExpr(e *syntax.CallExpr, sel *syntax.SelectorExpr) *
 		} else if !isPointerOrRef(recvParamType) && isPointerOrRef(recvExprType) {
 			// Auto-dereference: pointer/ref → value.
 			if isRef(recvExprType) {
-				b.nilCheck(recv)
+				b.nilCheck(recv, sel.Pos())
 			}
 			recv = b.fn.NewValue(b.b, OpLoad, recvParamType, recv)
 		} else if isRef(recvExprType) {
 			// Ref receiver passed to ref/pointer receiver method — nil check.
-			b.nilCheck(recv)
+			b.nilCheck(recv, sel.Pos())
 		}
 	}
 
 	// Build args: receiver first, then call args.
-	args := make([]*Value, 0, 1+len(e.Args))
-	args = append(args, recv)
-	for _, arg := range e.Args {
-		args = append(args, b.expr(arg))
-	}
+	args := append([]*Value{recv}, b.callArgs(e, sig)...)
 
 	var resTyp types.Type
 	if sig.Result() != nil {
 		resTyp = sig.Result()
 	}
 
-	v := b.fn.NewValue(b.b, OpStaticCall, resTyp, args...)
+	v := b.fn.NewValuePos(b.b, OpStaticCall, resTyp, e.Pos(), args...)
 	v.Aux = funcObj
 	return v
 }
 
+// interfaceCall handles a call of a method of the interface type it:
+// iface.Method(args...)
+func (b *builder) interfaceCall(e *syntax.CallExpr, sel *syntax.SelectorExpr, it *types.Interface) *Value {
+	idx, method := it.LookupMethod(sel.Sel.Value)
+	sig := method.Signature()
+
+	// Build args: interface value first, then call args.
+	args := append([]*Value{b.expr(sel.X)}, b.callArgs(e, sig)...)
+
+	v := b.fn.NewValuePos(b.b, OpInterfaceCall, sig.Result(), e.Pos(), args...)
+	v.Aux = method
+	v.AuxInt = int64(idx)
+	return v
+}
+
 // builtinCall handles calls to builtin functions.
 func (b *builder) builtinCall(e *syntax.CallExpr) *Value {
 	funName, ok := e.Fun.(*syntax.Name)
@@ -477,6 +637,29 @@ func (b *builder) builtinCall(e *syntax.CallExpr) *Value {
 		}
 		return v
 
+	case types.BuiltinLen, types.BuiltinCap:
+		return b.lenCap(e, bi.Kind())
+
+	case types.BuiltinMake:
+		if isMap(b.exprType(e)) {
+			return b.makeMap(e)
+		}
+		return b.makeSlice(e)
+
+	case types.BuiltinAppend:
+		return b.appendSlice(e)
+
+	case types.BuiltinCopy:
+		dst := b.expr(e.Args[0])
+		src := b.expr(e.Args[1])
+		return b.fn.NewValue(b.b, OpSliceCopy, types.Typ[types.Int], dst, src)
+
+	case types.BuiltinDelete:
+		mt := b.exprType(e.Args[0]).Underlying().(*types.Map)
+		m := b.expr(e.Args[0])
+		b.fn.NewValue(b.b, OpMapDelete, nil, m, b.mapKey(mt, e.Args[1]))
+		return nil
+
 	case types.BuiltinPanic:
 		var arg *Value
 		if len(e.Args) > 0 {
@@ -496,8 +679,204 @@ func (b *builder) builtinCall(e *syntax.CallExpr) *Value {
 	}
 }
 
+// lenCap lowers len(x) or cap(x) of a string, slice or map. The length of an
+// array is a constant; the argument is only evaluated if it contains a
+// call, in which case types2 does not fold the length.
+func (b *builder) lenCap(e *syntax.CallExpr, kind types.BuiltinKind) *Value {
+	x := b.expr(e.Args[0])
+	xTyp := b.exprType(e.Args[0])
+	intTyp := types.Typ[types.Int]
+	switch t := xTyp.Underlying().(type) {
+	case *types.Basic:
+		return b.fn.NewValue(b.b, OpStringLen, intTyp, x)
+	case *types.Slice:
+		if kind == types.BuiltinLen {
+			return b.fn.NewValue(b.b, OpSliceLen, intTyp, x)
+		}
+		return b.fn.NewValue(b.b, OpSliceCap, intTyp, x)
+	case *types.Map:
+		return b.fn.NewValue(b.b, OpMapLen, intTyp, x)
+	case *types.Array:
+		return b.constInt(t.Len())
+	default:
+		return b.constInt(derefType(t).Underlying().(*types.Array).Len())
+	}
+}
+
+// makeSlice lowers make([]T, len) and make([]T, len, cap).
+func (b *builder) makeSlice(e *syntax.CallExpr) *Value {
+	typ := b.exprType(e)
+	n := b.expr(e.Args[1])
+	c := n
+	if len(e.Args) > 2 {
+		c = b.expr(e.Args[2])
+	}
+	v := b.fn.NewValuePos(b.b, OpNewSlice, typ, e.Pos(), n, c)
+	v.Aux = typ.Underlying().(*types.Slice).Elem()
+	return v
+}
+
+// makeMap lowers make(map[K]V) and make(map[K]V, hint).
+func (b *builder) makeMap(e *syntax.CallExpr) *Value {
+	typ := b.exprType(e)
+	var hint *Value
+	if len(e.Args) > 1 {
+		hint = b.expr(e.Args[1])
+	} else {
+		hint = b.constInt(0)
+	}
+	v := b.fn.NewValuePos(b.b, OpMakeMap, typ, e.Pos(), hint)
+	v.Aux = typ
+	return v
+}
+
+// mapKey stores the key k of a map of type mt to a temporary and returns
+// its address; the runtime map functions take keys and elements by
+// address.
+func (b *builder) mapKey(mt *types.Map, k syntax.Expr) *Value {
+	tmp := b.entryAlloca(mt.Key(), "")
+	b.fn.NewValue(b.b, OpStore, nil, tmp, b.convert(b.expr(k), mt.Key()))
+	return tmp
+}
+
+// mapIndex lowers the map lookup m[k], which yields the zero value if k
+// is not in m. The comma-ok form yields a (V, bool) tuple.
+func (b *builder) mapIndex(e *syntax.IndexExpr) *Value {
+	mt := b.exprType(e.X).Underlying().(*types.Map)
+	m := b.expr(e.X)
+	key := b.mapKey(mt, e.Index)
+	out := b.entryAlloca(mt.Elem(), "")
+	zero := b.fn.NewValue(b.b, OpZero, nil, out)
+	zero.AuxInt = b.sizes.Sizeof(mt.Elem())
+	found := b.fn.NewValue(b.b, OpMapAccess, types.Typ[types.Bool], m, key, out)
+	val := b.fn.NewValue(b.b, OpLoad, mt.Elem(), out)
+	if tup, ok := b.exprType(e).(*types.Tuple); ok {
+		return b.fn.NewValue(b.b, OpMakeAggregate, tup, val, found)
+	}
+	return val
+}
+
+// appendSlice lowers append(s, vals...). If the values do not fit in the
+// capacity of s, the backing array is first grown by the runtime, which
+// copies the elements of s to a larger array. The values are then stored
+// after the elements of s.
+func (b *builder) appendSlice(e *syntax.CallExpr) *Value {
+	typ := b.exprType(e)
+	elem := typ.Underlying().(*types.Slice).Elem()
+	s := b.expr(e.Args[0])
+	vals := make([]*Value, len(e.Args)-1)
+	for i, arg := range e.Args[1:] {
+		vals[i] = b.convert(b.expr(arg), elem)
+	}
+	if len(vals) == 0 {
+		return s
+	}
+
+	intTyp := types.Typ[types.Int]
+	n := b.fn.NewValue(b.b, OpSliceLen, intTyp, s)
+	newLen := b.fn.NewValue(b.b, OpAdd64, intTyp, n, b.constInt(int64(len(vals))))
+	c := b.fn.NewValue(b.b, OpSliceCap, intTyp, s)
+	full := b.fn.NewValue(b.b, OpGt64, types.Typ[types.Bool], newLen, c)
+
+	tmp := b.entryAlloca(typ, "")
+	b.fn.NewValue(b.b, OpStore, nil, tmp, s)
+
+	bGrow := b.fn.NewBlock(BlockPlain)
+	bDone := b.fn.NewBlock(BlockPlain)
+	b.b.Kind = BlockIf
+	b.b.SetControl(full)
+	b.b.AddSucc(bGrow)
+	b.b.AddSucc(bDone)
+
+	b.b = bGrow
+	grow := b.fn.NewValue(b.b, OpGrowSlice, nil, tmp, newLen)
+	grow.Aux = elem
+	b.b.AddSucc(bDone)
+
+	b.b = bDone
+	grown := b.fn.NewValue(b.b, OpLoad, typ, tmp)
+	res := b.fn.NewValue(b.b, OpSliceSlice, typ, grown, b.constInt(0), newLen)
+	for i, v := range vals {
+		idx := n
+		if i > 0 {
+			idx = b.fn.NewValue(b.b, OpAdd64, intTyp, n, b.constInt(int64(i)))
+		}
+		ptr := b.fn.NewValue(b.b, OpSliceIndexPtr, types.NewPointer(elem), res, idx)
+		b.fn.NewValue(b.b, OpStore, nil, ptr, v)
+	}
+	return res
+}
+
+// sliceExpr lowers a slice expression x[lo:hi]. An array operand is first
+// turned into a slice of all its elements. The bounds are checked against
+// the capacity, unless they are constants into an array, which types2 has
+// checked.
+func (b *builder) sliceExpr(e *syntax.SliceExpr) *Value {
+	typ := b.exprType(e)
+	xTyp := b.exprType(e.X)
+	var s *Value
+	checked := false
+	switch t := xTyp.Underlying().(type) {
+	case *types.Slice:
+		s = b.expr(e.X)
+	case *types.Array:
+		s = b.wholeSlice(b.addr(e.X), t, typ)
+		checked = true
+	case *types.Ref:
+		ptr := b.expr(e.X)
+		b.nilCheck(ptr, e.Pos())
+		s = b.wholeSlice(ptr, t.Elem().Underlying().(*types.Array), typ)
+		checked = true
+	default:
+		panic(fmt.Sprintf("ssa.sliceExpr: cannot slice %s", xTyp))
+	}
+	if e.Lo == nil && e.Hi == nil {
+		return s
+	}
+
+	intTyp := types.Typ[types.Int]
+	var lo *Value
+	if e.Lo != nil {
+		lo = b.expr(e.Lo)
+		checked = checked && lo.Op == OpConst64
+	} else {
+		lo = b.constInt(0)
+	}
+	var hi *Value
+	if e.Hi != nil {
+		hi = b.expr(e.Hi)
+		checked = checked && hi.Op == OpConst64
+	} else {
+		hi = b.fn.NewValue(b.b, OpSliceLen, intTyp, s)
+	}
+	if !checked {
+		c := b.fn.NewValue(b.b, OpSliceCap, intTyp, s)
+		b.fn.NewValuePos(b.b, OpSliceCheck, nil, e.Pos(), lo, hi, c)
+	}
+	return b.fn.NewValue(b.b, OpSliceSlice, typ, s, lo, hi)
+}
+
+// wholeSlice returns the slice of type typ of all elements of the array
+// of type arr at ptr.
+func (b *builder) wholeSlice(ptr *Value, arr *types.Array, typ types.Type) *Value {
+	n := b.constInt(arr.Len())
+	return b.fn.NewValue(b.b, OpSliceMake, typ, ptr, n, n)
+}
+
+// constInt returns an int constant.
+func (b *builder) constInt(n int64) *Value {
+	v := b.fn.NewValue(b.b, OpConst64, types.Typ[types.Int])
+	v.AuxInt = n
+	return v
+}
+
 // selectorExpr handles field access: x.field
 func (b *builder) selectorExpr(e *syntax.SelectorExpr) *Value {
+	// Qualified identifier: pkg.V
+	if b.isQualified(e) {
+		return b.nameExpr(e.Sel)
+	}
+
 	// Determine the struct type and field index.
 	xTyp := b.exprType(e.X)
 	st, fieldIdx := b.resolveField(xTyp, e.Sel.Value)
@@ -507,12 +886,22 @@ func (b *builder) selectorExpr(e *syntax.SelectorExpr) *Value {
 
 	fieldType := st.Field(fieldIdx).Type()
 
+	switch e.X.(type) {
+	case *syntax.CallExpr, *syntax.AssertExpr:
+		if !isPointerOrRef(xTyp) {
+			// X is a struct value without an address.
+			v := b.fn.NewValue(b.b, OpExtractValue, fieldType, b.expr(e.X))
+			v.AuxInt = int64(fieldIdx)
+			return v
+		}
+	}
+
 	var basePtr *Value
 	if isPointerOrRef(xTyp) {
 		// X is a pointer/ref — evaluate it as a pointer.
 		basePtr = b.expr(e.X)
 		if isRef(xTyp) {
-			b.nilCheck(basePtr)
+			b.nilCheck(basePtr, e.Pos())
 		}
 	} else {
 		// X is a struct value — take its address.
@@ -525,38 +914,57 @@ func (b *builder) selectorExpr(e *syntax.SelectorExpr) *Value {
 	return b.fn.NewValue(b.b, OpLoad, fieldType, fieldPtr)
 }
 
-// indexExpr handles array index: x[i]
+// indexExpr handles array, slice and map index: x[i]
 func (b *builder) indexExpr(e *syntax.IndexExpr) *Value {
+	if isMap(b.exprType(e.X)) {
+		return b.mapIndex(e)
+	}
+	elemPtr := b.indexAddr(e)
+	return b.fn.NewValue(b.b, OpLoad, derefType(elemPtr.Type), elemPtr)
+}
+
+// indexAddr returns the address of the array or slice element x[i]. The
+// index is bounds checked unless it is a constant into an array, which
+// types2 has already checked against the array length.
+func (b *builder) indexAddr(e *syntax.IndexExpr) *Value {
 	xTyp := b.exprType(e.X)
-	var elemType types.Type
+	var arr *types.Array
 	var basePtr *Value
 
 	switch t := xTyp.Underlying().(type) {
 	case *types.Array:
-		elemType = t.Elem()
+		arr = t
 		basePtr = b.addr(e.X)
 	case *types.Pointer:
-		if arr, ok := t.Elem().Underlying().(*types.Array); ok {
-			elemType = arr.Elem()
-			basePtr = b.expr(e.X)
-		} else {
-			panic("ssa.indexExpr: pointer to non-array")
+		a, ok := t.Elem().Underlying().(*types.Array)
+		if !ok {
+			panic("ssa.indexAddr: pointer to non-array")
 		}
+		arr = a
+		basePtr = b.expr(e.X)
 	case *types.Ref:
-		if arr, ok := t.Elem().Underlying().(*types.Array); ok {
-			elemType = arr.Elem()
-			basePtr = b.expr(e.X)
-			b.nilCheck(basePtr)
-		} else {
-			panic("ssa.indexExpr: ref to non-array")
+		a, ok := t.Elem().Underlying().(*types.Array)
+		if !ok {
+			panic("ssa.indexAddr: ref to non-array")
 		}
+		arr = a
+		basePtr = b.expr(e.X)
+		b.nilCheck(basePtr, e.Pos())
+	case *types.Slice:
+		s := b.expr(e.X)
+		idx := b.expr(e.Index)
+		n := b.fn.NewValue(b.b, OpSliceLen, types.Typ[types.Int], s)
+		b.fn.NewValuePos(b.b, OpBoundsCheck, nil, e.Index.Pos(), idx, n)
+		return b.fn.NewValue(b.b, OpSliceIndexPtr, types.NewPointer(t.Elem()), s, idx)
 	default:
-		panic(fmt.Sprintf("ssa.indexExpr: cannot index %s", xTyp))
+		panic(fmt.Sprintf("ssa.indexAddr: cannot index %s", xTyp))
 	}
 
 	idx := b.expr(e.Index)
-	elemPtr := b.fn.NewValue(b.b, OpArrayIndexPtr, types.NewPointer(elemType), basePtr, idx)
-	return b.fn.NewValue(b.b, OpLoad, elemType, elemPtr)
+	if idx.Op != OpConst64 {
+		b.boundsCheck(idx, arr.Len(), e.Index.Pos())
+	}
+	return b.fn.NewValue(b.b, OpArrayIndexPtr, types.NewPointer(arr.Elem()), basePtr, idx)
 }
 
 // compositeLitExpr handles struct literals: T{f: v, ...}
@@ -602,6 +1010,7 @@ func (b *builder) compositeLitExpr(e *syntax.CompositeLit) *Value {
 		}
 
 		fieldType := st.Field(fieldIdx).Type()
+		val = b.convert(val, fieldType)
 		fieldPtr := b.fn.NewValue(b.b, OpStructFieldPtr, types.NewPointer(fieldType), alloca)
 		fieldPtr.AuxInt = int64(fieldIdx)
 		b.fn.NewValue(b.b, OpStore, nil, fieldPtr, val)
@@ -638,11 +1047,20 @@ func (b *builder) addr(e syntax.Expr) *Value {
 		}
 		alloca, ok := b.vars[obj]
 		if !ok {
-			panic(fmt.Sprintf("ssa.addr: no alloca for %q", e.Value))
+			v, isVar := obj.(*types.Var)
+			if !isVar || !isGlobal(v) {
+				panic(fmt.Sprintf("ssa.addr: no alloca for %q", e.Value))
+			}
+			return b.global(v)
 		}
 		return alloca
 
 	case *syntax.SelectorExpr:
+		// Variable of an imported package: &pkg.V
+		if b.isQualified(e) {
+			return b.addr(e.Sel)
+		}
+
 		// Field address: &x.field
 		xTyp := b.exprType(e.X)
 		st, fieldIdx := b.resolveField(xTyp, e.Sel.Value)
@@ -655,7 +1073,7 @@ func (b *builder) addr(e syntax.Expr) *Value {
 		if isPointerOrRef(xTyp) {
 			basePtr = b.expr(e.X)
 			if isRef(xTyp) {
-				b.nilCheck(basePtr)
+				b.nilCheck(basePtr, e.Pos())
 			}
 		} else {
 			basePtr = b.addr(e.X)
@@ -666,30 +1084,15 @@ func (b *builder) addr(e syntax.Expr) *Value {
 		return fieldPtr
 
 	case *syntax.IndexExpr:
-		// Array element address: &x[i]
-		xTyp := b.exprType(e.X)
-		var elemType types.Type
-		var basePtr *Value
-
-		switch t := xTyp.Underlying().(type) {
-		case *types.Array:
-			elemType = t.Elem()
-			basePtr = b.addr(e.X)
-		case *types.Pointer:
-			if arr, ok := t.Elem().Underlying().(*types.Array); ok {
-				elemType = arr.Elem()
-				basePtr = b.expr(e.X)
-			}
-		case *types.Ref:
-			if arr, ok := t.Elem().Underlying().(*types.Array); ok {
-				elemType = arr.Elem()
-				basePtr = b.expr(e.X)
-				b.nilCheck(basePtr)
-			}
+		if isMap(b.exprType(e.X)) {
+			// A map element is not addressable; its fields and
+			// elements are read from a copy.
+			tmp := b.entryAlloca(b.exprType(e), "")
+			b.fn.NewValue(b.b, OpStore, nil, tmp, b.mapIndex(e))
+			return tmp
 		}
-
-		idx := b.expr(e.Index)
-		return b.fn.NewValue(b.b, OpArrayIndexPtr, types.NewPointer(elemType), basePtr, idx)
+		// Array element address: &x[i]
+		return b.indexAddr(e)
 
 	case *syntax.Operation:
 		// Dereference on LHS: *p = val → store to p
@@ -706,6 +1109,41 @@ func (b *builder) addr(e syntax.Expr) *Value {
 	}
 }
 
+// convert returns v converted to the type T it is assigned to. A value of
+// a concrete type assigned to an interface type becomes an interface value
+// whose data is the value's ref, or a copy of the value on the heap. An
+// untyped nil assigned to a slice becomes the nil slice. Other values are
+// returned unchanged.
+func (b *builder) convert(v *Value, T types.Type) *Value {
+	if isSlice(T) && v.Op == OpConstNil && types.IsNil(v.Type) {
+		return b.fn.NewValue(b.b, OpConstNil, T)
+	}
+	if !isInterface(T) || isInterface(v.Type) {
+		return v
+	}
+	if v.Op == OpConstNil && types.IsNil(v.Type) {
+		return b.fn.NewValue(b.b, OpConstNil, T)
+	}
+	data := v
+	if !isRef(v.Type) {
+		data = b.fn.NewValue(b.b, OpNewAlloc, types.NewRef(v.Type))
+		data.Aux = v.Type
+		b.fn.NewValue(b.b, OpStore, nil, data, v)
+	}
+	iface := b.fn.NewValue(b.b, OpMakeInterface, T, data)
+	iface.Aux = v.Type
+	return iface
+}
+
+// convertList converts each of vals to the corresponding element type of
+// the tuple t.
+func (b *builder) convertList(vals []*Value, t *types.Tuple) []*Value {
+	for i, v := range vals {
+		vals[i] = b.convert(v, t.At(i))
+	}
+	return vals
+}
+
 // exprType returns the concrete type of an expression.
 func (b *builder) exprType(e syntax.Expr) types.Type {
 	tv, ok := b.info.Types[e]
@@ -745,8 +1183,21 @@ func (b *builder) resolveField(typ types.Type, name string) (*types.Struct, int)
 }
 
 // nilCheck inserts an OpNilCheck for a ref T pointer before dereference.
-func (b *builder) nilCheck(ptr *Value) {
-	b.fn.NewValue(b.b, OpNilCheck, nil, ptr)
+// pos is the position of the dereferencing expression.
+func (b *builder) nilCheck(ptr *Value, pos syntax.Pos) {
+	b.fn.NewValuePos(b.b, OpNilCheck, nil, pos, ptr)
+}
+
+// boundsCheck inserts an OpBoundsCheck of idx against the array length n.
+// pos is the position reported if the check fails.
+func (b *builder) boundsCheck(idx *Value, n int64, pos syntax.Pos) {
+	b.fn.NewValuePos(b.b, OpBoundsCheck, nil, pos, idx, b.constInt(n))
+}
+
+// isInterface returns true if t is an interface type.
+func isInterface(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Interface)
+	return ok
 }
 
 // isRef returns true if t is a ref T type.
@@ -762,6 +1213,21 @@ func isFloat(t types.Type) bool {
 	return ok && (b.Kind() == types.Float || b.Kind() == types.UntypedFloat)
 }
 
+func isArray(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Array)
+	return ok
+}
+
+func isSlice(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Slice)
+	return ok
+}
+
+func isMap(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Map)
+	return ok
+}
+
 func isPointerOrRef(t types.Type) bool {
 	switch t.Underlying().(type) {
 	case *types.Pointer, *types.Ref:
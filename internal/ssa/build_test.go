@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/you-not-fish/yoru/internal/rtabi"
 	"github.com/you-not-fish/yoru/internal/syntax"
 	"github.com/you-not-fish/yoru/internal/types"
 	"github.com/you-not-fish/yoru/internal/types2"
@@ -15,6 +16,21 @@ import (
 func buildFromSource(t *testing.T, src string) []*Func {
 	t.Helper()
 
+	file, info := checkSource(t, src)
+	funcs := BuildFile(file, info, types.DefaultSizes)
+	for _, fn := range funcs {
+		if err := Verify(fn); err != nil {
+			t.Fatalf("Verify(%s) failed:\n%v\nSSA:\n%s", fn.Name, err, Sprint(fn))
+		}
+	}
+	return funcs
+}
+
+// checkSource parses and type-checks the given source.
+// It calls t.Fatal on any parse or type errors.
+func checkSource(t *testing.T, src string) (*syntax.File, *types2.Info) {
+	t.Helper()
+
 	r := strings.NewReader(src)
 	var parseErrs []string
 	parseErrh := func(pos syntax.Pos, msg string) {
@@ -47,14 +63,7 @@ func buildFromSource(t *testing.T, src string) []*Func {
 	if len(typeErrs) > 0 {
 		t.Fatalf("type errors:\n%s", strings.Join(typeErrs, "\n"))
 	}
-
-	funcs := BuildFile(file, info, types.DefaultSizes)
-	for _, fn := range funcs {
-		if err := Verify(fn); err != nil {
-			t.Fatalf("Verify(%s) failed:\n%v\nSSA:\n%s", fn.Name, err, Sprint(fn))
-		}
-	}
-	return funcs
+	return file, info
 }
 
 // getFunc returns the function with the given name from a list, or calls t.Fatal.
@@ -737,6 +746,42 @@ func f() {
 	}
 }
 
+func TestBuildBoundsCheck(t *testing.T) {
+	src := `package main
+func f(p *[4]int, i int) int {
+	var arr [5]int
+	arr[i] = 1
+	arr[2] = p[i+1]
+	return arr[3]
+}
+`
+	funcs := buildFromSource(t, src)
+	fn := getFunc(t, funcs, "f")
+
+	var checks []*Value
+	for _, b := range fn.Blocks {
+		for _, v := range b.Values {
+			if v.Op == OpBoundsCheck {
+				checks = append(checks, v)
+			}
+		}
+	}
+	// Only the two non-constant indexes are checked.
+	if len(checks) != 2 {
+		t.Fatalf("got %d OpBoundsCheck, want 2\nSSA:\n%s", len(checks), Sprint(fn))
+	}
+	wantLens := []int64{5, 4}
+	wantCols := []uint32{6, 13}
+	for i, c := range checks {
+		if n := c.Args[1]; n.Op != OpConst64 || n.AuxInt != wantLens[i] {
+			t.Errorf("check %d: length = %s, want Const64 [%d]", i, n.LongString(), wantLens[i])
+		}
+		if c.Pos.Line() == 0 || c.Pos.Col() != wantCols[i] {
+			t.Errorf("check %d: pos = %s, want column %d", i, c.Pos, wantCols[i])
+		}
+	}
+}
+
 func TestBuildAddressOf(t *testing.T) {
 	// &x is used within the same function (no escape).
 	src := `package main
@@ -877,6 +922,28 @@ func f(x int) int {
 	}
 }
 
+func TestBuildBitwise(t *testing.T) {
+	src := `package main
+func f(a int, b int, s int) int {
+	return (a & b) | (a ^ b) + (a &^ b) + ^a + a<<s + (b >> s)
+}
+`
+	funcs := buildFromSource(t, src)
+	fn := getFunc(t, funcs, "f")
+
+	seen := make(map[Op]bool)
+	for _, b := range fn.Blocks {
+		for _, v := range b.Values {
+			seen[v.Op] = true
+		}
+	}
+	for _, op := range []Op{OpAnd64, OpOr64, OpXor64, OpAndNot64, OpCom64, OpLsh64, OpRsh64} {
+		if !seen[op] {
+			t.Errorf("missing %s\nSSA:\n%s", op, Sprint(fn))
+		}
+	}
+}
+
 // --- Function calls ---
 
 func TestBuildFuncCall(t *testing.T) {
@@ -904,6 +971,321 @@ func f() int {
 	}
 }
 
+// --- Multiple results ---
+
+func TestBuildMultipleResults(t *testing.T) {
+	src := `package main
+func divmod(a int, b int) (int, int) {
+	return a / b, a % b
+}
+func f() int {
+	q, r := divmod(7, 2)
+	q, _ = divmod(q, r)
+	return q
+}
+`
+	funcs := buildFromSource(t, src)
+
+	divmod := getFunc(t, funcs, "divmod")
+	var ret *Block
+	for _, b := range divmod.Blocks {
+		if b.Kind == BlockReturn {
+			ret = b
+		}
+	}
+	if ret == nil || len(ret.Controls) == 0 || ret.Controls[0].Op != OpMakeAggregate ||
+		ret.Controls[0].Type.String() != "(int, int)" {
+		t.Errorf("divmod does not return a (int, int) aggregate\nSSA:\n%s", Sprint(divmod))
+	}
+
+	// The first call is split into both results, the second only into q
+	// and the discarded r.
+	fn := getFunc(t, funcs, "f")
+	var calls, extracts, stores int
+	for _, b := range fn.Blocks {
+		for _, v := range b.Values {
+			switch v.Op {
+			case OpStaticCall:
+				calls++
+				if _, ok := v.Type.(*types.Tuple); !ok {
+					t.Errorf("call %s has type %s, want a tuple", v.LongString(), v.Type)
+				}
+			case OpExtractValue:
+				extracts++
+			case OpStore:
+				stores++
+			}
+		}
+	}
+	if calls != 2 || extracts != 4 || stores != 3 {
+		t.Errorf("calls, extracts, stores = %d, %d, %d, want 2, 4, 3\nSSA:\n%s", calls, extracts, stores, Sprint(fn))
+	}
+}
+
+// --- Interfaces ---
+
+func TestBuildInterface(t *testing.T) {
+	src := `package main
+type Shape interface {
+	Name() string
+	Area() int
+}
+type Rect struct {
+	w int
+	h int
+}
+func (r Rect) Area() int {
+	return r.w * r.h
+}
+func (r Rect) Name() string {
+	return "rect"
+}
+func area(s Shape) int {
+	if s == nil {
+		return 0
+	}
+	return s.Area()
+}
+func f() int {
+	var r Rect
+	return area(r)
+}
+`
+	funcs := buildFromSource(t, src)
+
+	// Area is the first method in name order.
+	fn := getFunc(t, funcs, "area")
+	var isNil, calls int
+	for _, b := range fn.Blocks {
+		for _, v := range b.Values {
+			switch v.Op {
+			case OpInterfaceIsNil:
+				isNil++
+			case OpInterfaceCall:
+				calls++
+				if v.AuxInt != 0 || v.Type.String() != "int" {
+					t.Errorf("interface call %s, want method 0 returning int", v.LongString())
+				}
+			}
+		}
+	}
+	if isNil != 1 || calls != 1 {
+		t.Errorf("nil tests, calls = %d, %d, want 1, 1\nSSA:\n%s", isNil, calls, Sprint(fn))
+	}
+
+	// The Rect argument is boxed on the heap.
+	fn = getFunc(t, funcs, "f")
+	var mk *Value
+	for _, b := range fn.Blocks {
+		for _, v := range b.Values {
+			if v.Op == OpMakeInterface {
+				mk = v
+			}
+		}
+	}
+	if mk == nil || mk.Aux.(types.Type).String() != "Rect" || mk.Args[0].Op != OpNewAlloc {
+		t.Errorf("Rect is not boxed into a Shape\nSSA:\n%s", Sprint(fn))
+	}
+}
+
+func TestBuildTypeAssert(t *testing.T) {
+	src := `package main
+type Shape interface {
+	Area() int
+}
+type Rect struct {
+	w int
+}
+func (r Rect) Area() int {
+	return r.w
+}
+func width(s Shape) int {
+	return s.(Rect).w
+}
+func isRect(s Shape) bool {
+	_, ok := s.(Rect)
+	return ok
+}
+func kind(s Shape) int {
+	switch v := s.(type) {
+	case Rect:
+		return v.w
+	case nil:
+		return -1
+	default:
+		break
+	}
+	return 0
+}
+`
+	funcs := buildFromSource(t, src)
+	count := func(fn *Func) map[Op]int {
+		n := make(map[Op]int)
+		for _, b := range fn.Blocks {
+			for _, v := range b.Values {
+				n[v.Op]++
+				if (v.Op == OpTypeAssert || v.Op == OpTypeTest) && v.Aux.(types.Type).String() != "Rect" {
+					t.Errorf("%s asserts %v, want Rect", v.LongString(), v.Aux)
+				}
+			}
+		}
+		return n
+	}
+
+	fn := getFunc(t, funcs, "width")
+	if n := count(fn); n[OpTypeAssert] != 1 || n[OpTypeTest] != 0 {
+		t.Errorf("width: asserts, tests = %d, %d, want 1, 0\nSSA:\n%s", n[OpTypeAssert], n[OpTypeTest], Sprint(fn))
+	}
+
+	// The comma-ok form only asserts after a successful test.
+	fn = getFunc(t, funcs, "isRect")
+	if n := count(fn); n[OpTypeAssert] != 1 || n[OpTypeTest] != 1 || len(fn.Blocks) != 3 {
+		t.Errorf("isRect: asserts, tests, blocks = %d, %d, %d, want 1, 1, 3\nSSA:\n%s",
+			n[OpTypeAssert], n[OpTypeTest], len(fn.Blocks), Sprint(fn))
+	}
+
+	// One test per case type; the clause variable of the Rect clause is
+	// asserted, the one of the nil clause is the operand itself.
+	fn = getFunc(t, funcs, "kind")
+	if n := count(fn); n[OpTypeAssert] != 1 || n[OpTypeTest] != 1 || n[OpInterfaceIsNil] != 1 {
+		t.Errorf("kind: asserts, tests, nil tests = %d, %d, %d, want 1, 1, 1\nSSA:\n%s",
+			n[OpTypeAssert], n[OpTypeTest], n[OpInterfaceIsNil], Sprint(fn))
+	}
+	if err := Verify(fn); err != nil {
+		t.Errorf("kind: %v\nSSA:\n%s", err, Sprint(fn))
+	}
+}
+
+func TestBuildSlices(t *testing.T) {
+	src := `package main
+func sum(s []int) int {
+	total := 0
+	i := 0
+	for i < len(s) {
+		total = total + s[i]
+		i = i + 1
+	}
+	return total
+}
+func window(i int) []int {
+	var arr [8]int
+	return arr[i:i+2]
+}
+func head() []int {
+	var arr [8]int
+	return arr[:4]
+}
+func grow(s []int) []int {
+	return append(s, 1, 2)
+}
+func fresh(n int) bool {
+	s := make([]int, n)
+	return s == nil
+}
+`
+	funcs := buildFromSource(t, src)
+	count := func(fn *Func) map[Op]int {
+		n := make(map[Op]int)
+		for _, b := range fn.Blocks {
+			for _, v := range b.Values {
+				n[v.Op]++
+			}
+		}
+		if err := Verify(fn); err != nil {
+			t.Errorf("%s: %v\nSSA:\n%s", fn.Name, err, Sprint(fn))
+		}
+		return n
+	}
+
+	// Slice elements are bounds checked against the slice length.
+	fn := getFunc(t, funcs, "sum")
+	if n := count(fn); n[OpBoundsCheck] != 1 || n[OpSliceIndexPtr] != 1 || n[OpSliceLen] != 2 {
+		t.Errorf("sum: bounds checks, index ptrs, lens = %d, %d, %d, want 1, 1, 2\nSSA:\n%s",
+			n[OpBoundsCheck], n[OpSliceIndexPtr], n[OpSliceLen], Sprint(fn))
+	}
+
+	// A sliced local array lives on the heap; only the parameter has an
+	// alloca. Variable bounds are checked, constant bounds into an array
+	// are not.
+	fn = getFunc(t, funcs, "window")
+	if n := count(fn); n[OpNewAlloc] != 1 || n[OpAlloca] != 1 || n[OpSliceMake] != 1 || n[OpSliceCheck] != 1 {
+		t.Errorf("window: new allocs, allocas, makes, checks = %d, %d, %d, %d, want 1, 1, 1, 1\nSSA:\n%s",
+			n[OpNewAlloc], n[OpAlloca], n[OpSliceMake], n[OpSliceCheck], Sprint(fn))
+	}
+	fn = getFunc(t, funcs, "head")
+	if n := count(fn); n[OpSliceCheck] != 0 || n[OpSliceSlice] != 1 {
+		t.Errorf("head: checks, slices = %d, %d, want 0, 1\nSSA:\n%s", n[OpSliceCheck], n[OpSliceSlice], Sprint(fn))
+	}
+
+	// append grows the slice only if the values do not fit.
+	fn = getFunc(t, funcs, "grow")
+	if n := count(fn); n[OpGrowSlice] != 1 || n[OpSliceIndexPtr] != 2 || len(fn.Blocks) != 3 {
+		t.Errorf("grow: grows, stores, blocks = %d, %d, %d, want 1, 2, 3\nSSA:\n%s",
+			n[OpGrowSlice], n[OpSliceIndexPtr], len(fn.Blocks), Sprint(fn))
+	}
+
+	fn = getFunc(t, funcs, "fresh")
+	if n := count(fn); n[OpNewSlice] != 1 || n[OpSlicePtr] != 1 || n[OpEqPtr] != 1 {
+		t.Errorf("fresh: makes, ptrs, compares = %d, %d, %d, want 1, 1, 1\nSSA:\n%s",
+			n[OpNewSlice], n[OpSlicePtr], n[OpEqPtr], Sprint(fn))
+	}
+}
+
+func TestBuildMaps(t *testing.T) {
+	src := `package main
+type Point struct {
+	x int
+	y int
+}
+func count(m map[string]int, k string) {
+	m[k] = m[k] + 1
+}
+func lookup(m map[int]Point, k int) (int, bool) {
+	p, ok := m[k]
+	return p.x + m[k].y, ok
+}
+func fresh(n int) bool {
+	m := make(map[ref int]bool, n)
+	delete(m, nil)
+	return m == nil || len(m) > 0
+}
+`
+	funcs := buildFromSource(t, src)
+	count := func(fn *Func) map[Op]int {
+		n := make(map[Op]int)
+		for _, b := range fn.Blocks {
+			for _, v := range b.Values {
+				n[v.Op]++
+			}
+		}
+		if err := Verify(fn); err != nil {
+			t.Errorf("%s: %v\nSSA:\n%s", fn.Name, err, Sprint(fn))
+		}
+		return n
+	}
+
+	// The element is read, then written through a temporary.
+	fn := getFunc(t, funcs, "count")
+	if n := count(fn); n[OpMapAccess] != 1 || n[OpMapAssign] != 1 {
+		t.Errorf("count: accesses, assigns = %d, %d, want 1, 1\nSSA:\n%s",
+			n[OpMapAccess], n[OpMapAssign], Sprint(fn))
+	}
+
+	// The comma-ok lookup pairs the element with the found result; a
+	// field of an element is read from a copy.
+	fn = getFunc(t, funcs, "lookup")
+	if n := count(fn); n[OpMapAccess] != 2 || n[OpMakeAggregate] != 2 || n[OpStructFieldPtr] != 2 {
+		t.Errorf("lookup: accesses, aggregates, field ptrs = %d, %d, %d, want 2, 2, 2\nSSA:\n%s",
+			n[OpMapAccess], n[OpMakeAggregate], n[OpStructFieldPtr], Sprint(fn))
+	}
+
+	fn = getFunc(t, funcs, "fresh")
+	if n := count(fn); n[OpMakeMap] != 1 || n[OpMapDelete] != 1 || n[OpMapLen] != 1 || n[OpEqPtr] != 1 {
+		t.Errorf("fresh: makes, deletes, lens, compares = %d, %d, %d, %d, want 1, 1, 1, 1\nSSA:\n%s",
+			n[OpMakeMap], n[OpMapDelete], n[OpMapLen], n[OpEqPtr], Sprint(fn))
+	}
+}
+
 // --- Method calls ---
 
 func TestBuildMethodCall(t *testing.T) {
@@ -1145,3 +1527,124 @@ func add(a int, b int) int {
 		}
 	}
 }
+
+func TestBuildLinkNames(t *testing.T) {
+	funcs := buildFromSource(t, `package main
+
+type A struct {
+	x int
+}
+
+type B struct {
+	x int
+}
+
+func (a A) Get() int {
+	return a.x
+}
+
+func (b *B) Get() int {
+	return b.x
+}
+
+func helper() int {
+	return 0
+}
+
+func main() {
+}
+`)
+	want := map[string]string{
+		"helper": "main.helper",
+		"main":   rtabi.YoruMain,
+	}
+	var methods []string
+	for _, fn := range funcs {
+		if fn.Name == "Get" {
+			methods = append(methods, fn.LinkName)
+			continue
+		}
+		if fn.LinkName != want[fn.Name] {
+			t.Errorf("LinkName(%s) = %q, want %q", fn.Name, fn.LinkName, want[fn.Name])
+		}
+	}
+	if got := strings.Join(methods, " "); got != "main.A$Get main.B$Get" {
+		t.Errorf("method link names = %s, want main.A$Get main.B$Get", got)
+	}
+
+	// Functions of imported packages are qualified by the import path.
+	pkg := types.NewPackage("util")
+	pkg.SetPath("example.com/app/util")
+	obj := types.NewFuncObj(syntax.Pos{}, "Add")
+	obj.SetPkg(pkg)
+	obj.SetSignature(types.NewFunc(nil, nil, nil))
+	if got := LinkName(obj); got != "example.com/app/util.Add" {
+		t.Errorf("LinkName(util.Add) = %q", got)
+	}
+
+	// A function F of the package with import path T must not collide
+	// with the method T.F of the main package.
+	tpkg := types.NewPackage("T")
+	tpkg.SetPath("T")
+	f := types.NewFuncObj(syntax.Pos{}, "F")
+	f.SetPkg(tpkg)
+	f.SetSignature(types.NewFunc(nil, nil, nil))
+	if got := LinkName(f); got != "T.F" {
+		t.Errorf("LinkName(T.F) = %q, want T.F", got)
+	}
+}
+
+func TestBuildPackageGlobals(t *testing.T) {
+	file, info := checkSource(t, `package main
+
+var total int = base + twice(1)
+var base int = 40
+var name string = "yoru"
+var count int
+
+func twice(x int) int {
+	return x * 2
+}
+
+func main() {
+	count = total
+}
+`)
+	pkg := BuildPackage([]*syntax.File{file}, info, types.DefaultSizes)
+
+	var names []string
+	consts := make(map[string]string)
+	for _, g := range pkg.Globals {
+		names = append(names, g.LinkName)
+		if g.Const != nil {
+			consts[g.Var.Name()] = g.Const.ExactString()
+		}
+	}
+	if got := strings.Join(names, " "); got != "main.total main.base main.name main.count" {
+		t.Errorf("globals = %s, want main.total main.base main.name main.count", got)
+	}
+	if consts["base"] != "40" || consts["name"] != `"yoru"` || len(consts) != 2 {
+		t.Errorf("constant initializers = %v", consts)
+	}
+
+	if pkg.Init == nil {
+		t.Fatal("no init function for the dynamic initializer of total")
+	}
+	if err := Verify(pkg.Init); err != nil {
+		t.Fatalf("Verify(init) failed:\n%v", err)
+	}
+	if pkg.Init.LinkName != "main.init$" {
+		t.Errorf("init LinkName = %q", pkg.Init.LinkName)
+	}
+	init := Sprint(pkg.Init)
+	for _, s := range []string{"Global <*int> {base}", "StaticCall <int> {twice}", "Store"} {
+		if !strings.Contains(init, s) {
+			t.Errorf("init missing %q\ngot:\n%s", s, init)
+		}
+	}
+
+	main := Sprint(getFunc(t, pkg.Funcs, "main"))
+	if !strings.Contains(main, "Global <*int> {count}") {
+		t.Errorf("main does not address global count:\n%s", main)
+	}
+}
@@ -132,6 +132,17 @@ func ComputeDomFrontier(f *Func) map[*Block][]*Block {
 	return df
 }
 
+// Dominates reports whether a dominates b. Every block dominates itself.
+// ComputeDom must have been called first.
+func Dominates(a, b *Block) bool {
+	for x := b; x != nil; x = x.Idom {
+		if x == a {
+			return true
+		}
+	}
+	return false
+}
+
 // appendUnique appends b to list if not already present.
 func appendUnique(list []*Block, b *Block) []*Block {
 	for _, x := range list {
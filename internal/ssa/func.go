@@ -11,9 +11,22 @@ type Func struct {
 	// Name is the function name.
 	Name string
 
+	// LinkName is the symbol name used in the generated code (see
+	// LinkName). If empty, Name is used.
+	LinkName string
+
 	// Sig is the function signature from the type checker.
 	Sig *types.Func
 
+	// Sizes is the target layout the function was built for. Passes that
+	// create memory operations use it to size them; nil means
+	// types.DefaultSizes.
+	Sizes *types.Sizes
+
+	// NoInline is set for functions marked //yoru:noinline. The inliner
+	// never copies their bodies into callers.
+	NoInline bool
+
 	// Blocks is the list of basic blocks. Blocks[0] is always the entry block.
 	Blocks []*Block
 
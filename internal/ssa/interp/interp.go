@@ -0,0 +1,706 @@
+// Package interp executes SSA programs directly, without generating code.
+//
+// The interpreter runs the same []*ssa.Func the code generator lowers, at
+// any point of the pass pipeline, and prints like the runtime does. It lets
+// the end-to-end tests run on machines without clang, and serves as an
+// oracle checking that optimization passes preserve behavior.
+//
+// Memory is modelled with one slot per scalar component of each alloca,
+// heap object and package-level variable (see object). There is no
+// garbage collector: heap objects live as long as they are reachable from
+// Go's point of view.
+package interp
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/you-not-fish/yoru/internal/rtabi"
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// A Panic is the error returned by Run when the program panics. Msg is the
+// message the runtime would print after "panic: ".
+type Panic struct {
+	Msg string
+}
+
+func (p *Panic) Error() string {
+	return "panic: " + p.Msg
+}
+
+// throw panics the interpreted program with msg.
+func throw(msg string) {
+	panic(&Panic{Msg: msg})
+}
+
+// interpreter holds the state of a running program.
+type interpreter struct {
+	out     io.Writer
+	layout  *layout
+	funcs   map[string]*ssa.Func // by link name
+	globals map[*types.Var]*object
+}
+
+// Run runs the program made of pkgs, which must be in initialization
+// (dependency) order: it initializes the package-level variables, runs the
+// package initializers and calls main. Output of println goes to out. If
+// the program panics, the error is a *Panic; other errors report programs
+// the interpreter cannot run.
+func Run(pkgs []*ssa.Package, out io.Writer) (err error) {
+	in := &interpreter{
+		out:     out,
+		layout:  newLayout(),
+		funcs:   make(map[string]*ssa.Func),
+		globals: make(map[*types.Var]*object),
+	}
+	for _, pkg := range pkgs {
+		for _, fn := range pkg.Funcs {
+			name := linkName(fn)
+			if in.funcs[name] != nil {
+				return runtimeError(fmt.Sprintf("symbol %s is defined twice", name))
+			}
+			in.funcs[name] = fn
+		}
+		for _, gv := range pkg.Globals {
+			t := gv.Var.Type()
+			obj := in.layout.newObject(t)
+			if gv.Const != nil {
+				in.layout.store(pointer{obj, 0}, t, constValue(gv.Const, t))
+			}
+			in.globals[gv.Var] = obj
+		}
+	}
+
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case *Panic:
+			err = r
+		case runtimeError:
+			err = r
+		default:
+			panic(r)
+		}
+	}()
+
+	for _, pkg := range pkgs {
+		if pkg.Init != nil {
+			in.call(pkg.Init, nil)
+		}
+	}
+	main := in.funcs[rtabi.YoruMain]
+	if main == nil {
+		return runtimeError("function main is undeclared in the main package")
+	}
+	in.call(main, nil)
+	return nil
+}
+
+// linkName returns the symbol name of fn.
+func linkName(fn *ssa.Func) string {
+	if fn.LinkName != "" {
+		return fn.LinkName
+	}
+	return fn.Name
+}
+
+// runtimeError reports an SSA construct the interpreter cannot execute.
+type runtimeError string
+
+func (e runtimeError) Error() string {
+	return "interp: " + string(e)
+}
+
+// unsupported aborts the program with a runtimeError.
+func unsupported(format string, args ...interface{}) {
+	panic(runtimeError(fmt.Sprintf(format, args...)))
+}
+
+// frame is the activation record of a function call.
+type frame struct {
+	fn   *ssa.Func
+	args []value // receiver first, then parameters
+	env  map[*ssa.Value]value
+}
+
+// call calls fn with args and returns its result, or nil if it has none.
+func (in *interpreter) call(fn *ssa.Func, args []value) value {
+	fr := &frame{fn: fn, args: args, env: make(map[*ssa.Value]value)}
+	var prev *ssa.Block
+	b := fn.Entry
+	for {
+		in.enterBlock(fr, b, prev)
+		for _, v := range b.Values {
+			if v.Op == ssa.OpPhi {
+				continue
+			}
+			if r := in.eval(fr, v); r != nil {
+				fr.env[v] = r
+			}
+		}
+		switch b.Kind {
+		case ssa.BlockPlain:
+			if len(b.Succs) == 0 {
+				unsupported("%s: %s falls off the end of the function", fn.Name, b)
+			}
+			prev, b = b, b.Succs[0]
+		case ssa.BlockIf:
+			prev = b
+			if asBool(in.get(fr, b.Controls[0])) {
+				b = b.Succs[0]
+			} else {
+				b = b.Succs[1]
+			}
+		case ssa.BlockReturn:
+			if len(b.Controls) > 0 && b.Controls[0] != nil {
+				return in.get(fr, b.Controls[0])
+			}
+			return nil
+		case ssa.BlockExit:
+			unsupported("%s: reached exit block %s", fn.Name, b)
+		default:
+			unsupported("%s: unknown block kind %s", fn.Name, b.Kind)
+		}
+	}
+}
+
+// enterBlock evaluates the phis of b on the edge from prev. All phis read
+// their args before any of them is assigned.
+func (in *interpreter) enterBlock(fr *frame, b, prev *ssa.Block) {
+	if prev == nil {
+		return
+	}
+	idx := -1
+	for i, p := range b.Preds {
+		if p == prev {
+			idx = i
+			break
+		}
+	}
+	var phis []*ssa.Value
+	var vals []value
+	for _, v := range b.Values {
+		if v.Op != ssa.OpPhi {
+			continue
+		}
+		if idx < 0 || idx >= len(v.Args) {
+			unsupported("%s: phi %s has no arg for %s", fr.fn.Name, v, prev)
+		}
+		phis = append(phis, v)
+		vals = append(vals, in.get(fr, v.Args[idx]))
+	}
+	for i, v := range phis {
+		fr.env[v] = vals[i]
+	}
+}
+
+// get returns the value of v in fr. Constants are evaluated on use: a
+// pass may place them after their uses in the entry block.
+func (in *interpreter) get(fr *frame, v *ssa.Value) value {
+	switch v.Op {
+	case ssa.OpConst64:
+		return v.AuxInt
+	case ssa.OpConstFloat:
+		return v.AuxFloat
+	case ssa.OpConstBool:
+		return v.AuxInt != 0
+	case ssa.OpConstString:
+		s, _ := v.Aux.(string)
+		return s
+	case ssa.OpConstNil:
+		return zeroValue(v.Type)
+	case ssa.OpMakeAggregate:
+		if len(v.Args) == 0 {
+			return zeroValue(v.Type)
+		}
+	}
+	r, ok := fr.env[v]
+	if !ok {
+		unsupported("%s: %s used before it is defined", fr.fn.Name, v)
+	}
+	return r
+}
+
+// eval executes v and returns its result, or nil if it has none.
+func (in *interpreter) eval(fr *frame, v *ssa.Value) value {
+	arg := func(i int) value { return in.get(fr, v.Args[i]) }
+	x := func() int64 { return asInt(arg(0)) }
+	y := func() int64 { return asInt(arg(1)) }
+	fx := func() float64 { return asFloat(arg(0)) }
+	fy := func() float64 { return asFloat(arg(1)) }
+
+	switch v.Op {
+	case ssa.OpConst64, ssa.OpConstFloat, ssa.OpConstBool, ssa.OpConstString, ssa.OpConstNil:
+		return in.get(fr, v)
+
+	// Integer arithmetic
+	case ssa.OpAdd64:
+		return x() + y()
+	case ssa.OpSub64:
+		return x() - y()
+	case ssa.OpMul64:
+		return x() * y()
+	case ssa.OpDiv64:
+		d := y()
+		if d == 0 {
+			throw("integer divide by zero")
+		}
+		return x() / d
+	case ssa.OpMod64:
+		d := y()
+		if d == 0 {
+			throw("integer divide by zero")
+		}
+		return x() % d
+	case ssa.OpNeg64:
+		return -x()
+
+	// Integer bitwise operations and shifts
+	case ssa.OpAnd64:
+		return x() & y()
+	case ssa.OpOr64:
+		return x() | y()
+	case ssa.OpXor64:
+		return x() ^ y()
+	case ssa.OpAndNot64:
+		return x() &^ y()
+	case ssa.OpCom64:
+		return ^x()
+	case ssa.OpLsh64, ssa.OpRsh64:
+		s := y()
+		if s < 0 {
+			throw("negative shift amount")
+		}
+		if v.Op == ssa.OpLsh64 {
+			return x() << uint64(s)
+		}
+		return x() >> uint64(s)
+
+	// Float arithmetic
+	case ssa.OpAddF64:
+		return fx() + fy()
+	case ssa.OpSubF64:
+		return fx() - fy()
+	case ssa.OpMulF64:
+		return fx() * fy()
+	case ssa.OpDivF64:
+		return fx() / fy()
+	case ssa.OpNegF64:
+		return -fx()
+
+	// Comparisons
+	case ssa.OpEq64:
+		return x() == y()
+	case ssa.OpNeq64:
+		return x() != y()
+	case ssa.OpLt64:
+		return x() < y()
+	case ssa.OpLeq64:
+		return x() <= y()
+	case ssa.OpGt64:
+		return x() > y()
+	case ssa.OpGeq64:
+		return x() >= y()
+	case ssa.OpEqF64:
+		return fx() == fy()
+	case ssa.OpNeqF64:
+		return fx() != fy()
+	case ssa.OpLtF64:
+		return fx() < fy()
+	case ssa.OpLeqF64:
+		return fx() <= fy()
+	case ssa.OpGtF64:
+		return fx() > fy()
+	case ssa.OpGeqF64:
+		return fx() >= fy()
+	case ssa.OpEqPtr:
+		return asPointer(arg(0)) == asPointer(arg(1))
+	case ssa.OpNeqPtr:
+		return asPointer(arg(0)) != asPointer(arg(1))
+
+	// Boolean
+	case ssa.OpNot:
+		return !asBool(arg(0))
+	case ssa.OpAndBool:
+		return asBool(arg(0)) && asBool(arg(1))
+	case ssa.OpOrBool:
+		return asBool(arg(0)) || asBool(arg(1))
+
+	// Memory
+	case ssa.OpAlloca:
+		return pointer{in.layout.newObject(elemType(v.Type)), 0}
+	case ssa.OpLoad:
+		return in.layout.load(asPointer(arg(0)), v.Type)
+	case ssa.OpStore:
+		in.layout.store(asPointer(arg(0)), elemType(v.Args[0].Type), arg(1))
+		return nil
+	case ssa.OpZero:
+		in.layout.zero(asPointer(arg(0)), elemType(v.Args[0].Type))
+		return nil
+	case ssa.OpStructFieldPtr:
+		// Address computations do not fault: LICM may hoist them
+		// above the checks guarding their use.
+		p := asPointer(arg(0))
+		st := elemType(v.Args[0].Type).Underlying().(*types.Struct)
+		return pointer{p.obj, p.off + in.layout.fieldOffsets(st)[v.AuxInt]}
+	case ssa.OpArrayIndexPtr:
+		p := asPointer(arg(0))
+		arr := elemType(v.Args[0].Type).Underlying().(*types.Array)
+		return pointer{p.obj, p.off + int(y())*in.layout.numSlots(arr.Elem())}
+
+	// Aggregate values
+	case ssa.OpExtractValue:
+		return arg(0).([]value)[v.AuxInt]
+	case ssa.OpMakeAggregate:
+		if len(v.Args) == 0 {
+			return zeroValue(v.Type)
+		}
+		elems := make([]value, len(v.Args))
+		for i := range v.Args {
+			elems[i] = arg(i)
+		}
+		return elems
+
+	// Conversion
+	case ssa.OpIntToFloat:
+		return float64(x())
+	case ssa.OpFloatToInt:
+		f := fx()
+		if math.IsNaN(f) {
+			return int64(0)
+		}
+		return int64(f)
+
+	// Calls
+	case ssa.OpStaticCall:
+		obj, _ := v.Aux.(*types.FuncObj)
+		if obj == nil {
+			unsupported("%s: call %s has no callee", fr.fn.Name, v)
+		}
+		callee := in.funcs[ssa.LinkName(obj)]
+		if callee == nil {
+			unsupported("%s: call to %s, which has no body", fr.fn.Name, ssa.LinkName(obj))
+		}
+		args := make([]value, len(v.Args))
+		for i := range v.Args {
+			args[i] = arg(i)
+		}
+		return in.call(callee, args)
+
+	// Interfaces
+	case ssa.OpMakeInterface:
+		t, ok := v.Aux.(types.Type)
+		if !ok {
+			unsupported("%s: %s without dynamic type", fr.fn.Name, v)
+		}
+		return iface{dyn: t, data: asPointer(arg(0))}
+	case ssa.OpInterfaceCall:
+		args := make([]value, len(v.Args))
+		for i := 1; i < len(v.Args); i++ {
+			args[i] = arg(i)
+		}
+		callee, recv := in.method(fr, v, arg(0).(iface))
+		args[0] = recv
+		return in.call(callee, args)
+	case ssa.OpInterfaceIsNil:
+		return arg(0).(iface).dyn == nil
+	case ssa.OpTypeAssert:
+		return in.typeAssert(fr, v, arg(0).(iface))
+	case ssa.OpTypeTest:
+		i := arg(0).(iface)
+		if types.IsInterface(assertedType(fr, v)) {
+			return i.dyn != nil
+		}
+		return i.dyn != nil && types.Identical(i.dyn, assertedType(fr, v))
+
+	case ssa.OpNewAlloc:
+		t, ok := v.Aux.(types.Type)
+		if !ok {
+			unsupported("%s: %s without element type", fr.fn.Name, v)
+		}
+		return pointer{in.layout.newObject(t), 0}
+
+	// Slices
+	case ssa.OpNewSlice:
+		n, c := x(), y()
+		elem := sliceElem(fr, v)
+		limit := maxElems(fr, elem)
+		if n < 0 || n > limit {
+			throw("makeslice: len out of range")
+		}
+		if c < n || c > limit {
+			throw("makeslice: cap out of range")
+		}
+		return slice{in.newArray(elem, c), n, c}
+	case ssa.OpSliceMake:
+		return slice{asPointer(arg(0)), y(), asInt(arg(2))}
+	case ssa.OpSliceLen:
+		return asSlice(arg(0)).len
+	case ssa.OpSliceCap:
+		return asSlice(arg(0)).cap
+	case ssa.OpSlicePtr:
+		return asSlice(arg(0)).data
+	case ssa.OpSliceIndexPtr:
+		s := asSlice(arg(0))
+		n := in.layout.numSlots(elemType(v.Type))
+		return pointer{s.data.obj, s.data.off + int(y())*n}
+	case ssa.OpSliceSlice:
+		s := asSlice(arg(0))
+		lo, hi := y(), asInt(arg(2))
+		n := in.layout.numSlots(v.Type.Underlying().(*types.Slice).Elem())
+		return slice{pointer{s.data.obj, s.data.off + int(lo)*n}, hi - lo, s.cap - lo}
+	case ssa.OpSliceCheck:
+		lo, hi, c := x(), y(), asInt(arg(2))
+		var msg string
+		if hi < 0 || hi > c {
+			msg = fmt.Sprintf("slice bounds out of range [:%d] with capacity %d", hi, c)
+		} else if lo < 0 || lo > hi {
+			msg = fmt.Sprintf("slice bounds out of range [%d:%d]", lo, hi)
+		} else {
+			return nil
+		}
+		if v.Pos.IsValid() {
+			msg = v.Pos.String() + ": " + msg
+		}
+		throw(msg)
+	case ssa.OpGrowSlice:
+		in.growSlice(fr, v, asPointer(arg(0)), y())
+		return nil
+	case ssa.OpSliceCopy:
+		dst, src := asSlice(arg(0)), asSlice(arg(1))
+		n := min(dst.len, src.len)
+		if n > 0 {
+			k := int64(in.layout.numSlots(v.Args[0].Type.Underlying().(*types.Slice).Elem()))
+			copy(dst.data.obj.slots[dst.data.off:int64(dst.data.off)+n*k], src.data.obj.slots[src.data.off:int64(src.data.off)+n*k])
+		}
+		return n
+
+	// Maps
+	case ssa.OpMakeMap:
+		if x() < 0 {
+			throw("makemap: size out of range")
+		}
+		return pointer{&object{slots: []value{make(map[value]value)}}, 0}
+	case ssa.OpMapAccess:
+		elem, ok := mapEntries(asPointer(arg(0)))[in.mapKey(fr, v)]
+		if ok {
+			p := asPointer(arg(2))
+			in.layout.store(p, elemType(v.Args[2].Type), elem)
+		}
+		return ok
+	case ssa.OpMapAssign:
+		m := mapEntries(asPointer(arg(0)))
+		if m == nil {
+			throw("assignment to entry in nil map")
+		}
+		p := asPointer(arg(2))
+		m[in.mapKey(fr, v)] = in.layout.load(p, elemType(v.Args[2].Type))
+		return nil
+	case ssa.OpMapDelete:
+		delete(mapEntries(asPointer(arg(0))), in.mapKey(fr, v))
+		return nil
+	case ssa.OpMapLen:
+		return int64(len(mapEntries(asPointer(arg(0)))))
+
+	// SSA
+	case ssa.OpCopy:
+		return arg(0)
+	case ssa.OpArg:
+		i := int(v.AuxInt)
+		if fr.fn.Sig != nil && fr.fn.Sig.Recv() != nil {
+			i++ // the receiver, AuxInt -1, comes first
+		}
+		return fr.args[i]
+
+	// Address
+	case ssa.OpAddr:
+		return arg(0)
+	case ssa.OpGlobal:
+		gv, _ := v.Aux.(*types.Var)
+		obj := in.globals[gv]
+		if obj == nil {
+			unsupported("%s: unknown global %s", fr.fn.Name, v)
+		}
+		return pointer{obj, 0}
+
+	// Builtins
+	case ssa.OpPrintln:
+		for i := range v.Args {
+			if i > 0 {
+				io.WriteString(in.out, " ")
+			}
+			io.WriteString(in.out, formatValue(arg(i)))
+		}
+		io.WriteString(in.out, "\n")
+		return nil
+	case ssa.OpPanic:
+		throw(asString(arg(0)))
+	case ssa.OpNilCheck:
+		checkNil(asPointer(arg(0)))
+		return nil
+	case ssa.OpBoundsCheck:
+		i, n := x(), y()
+		if i < 0 || i >= n {
+			msg := fmt.Sprintf("index out of range [%d] with length %d", i, n)
+			if v.Pos.IsValid() {
+				msg = v.Pos.String() + ": " + msg
+			}
+			throw(msg)
+		}
+		return nil
+
+	// Strings
+	case ssa.OpStringLen:
+		return int64(len(asString(arg(0))))
+	}
+	unsupported("%s: cannot execute %s", fr.fn.Name, v.LongString())
+	return nil
+}
+
+// newArray returns a pointer to a new array of n zero elements of type elem.
+func (in *interpreter) newArray(elem types.Type, n int64) pointer {
+	k := in.layout.numSlots(elem)
+	if k > 0 && n > maxSlots/int64(k) {
+		throw("out of memory")
+	}
+	obj := &object{slots: make([]value, int(n)*k)}
+	for i := 0; i < int(n); i++ {
+		in.layout.zero(pointer{obj, i * k}, elem)
+	}
+	return pointer{obj, 0}
+}
+
+// growSlice grows the slice at p for the GrowSlice v to hold at least
+// newLen elements, like rt_growslice: the capacity at least doubles, and
+// is at least 4.
+func (in *interpreter) growSlice(fr *frame, v *ssa.Value, p pointer, newLen int64) {
+	t := elemType(v.Args[0].Type)
+	s := asSlice(in.layout.load(p, t))
+	if newLen <= s.cap {
+		return
+	}
+	newCap := max(newLen, 2*s.cap, 4)
+	elem := sliceElem(fr, v)
+	if newCap > maxElems(fr, elem) {
+		throw("growslice: len out of range")
+	}
+	data := in.newArray(elem, newCap)
+	if s.len > 0 {
+		k := int64(in.layout.numSlots(elem))
+		copy(data.obj.slots, s.data.obj.slots[s.data.off:int64(s.data.off)+s.len*k])
+	}
+	in.layout.store(p, t, slice{data, s.len, newCap})
+}
+
+// maxSlots bounds the number of slots of one array, so that a length the
+// runtime would accept but could not allocate panics like a failed
+// allocation instead of exhausting the interpreter's memory.
+const maxSlots = 1 << 28
+
+// maxElems returns the largest length of an array of elem, like max_elems
+// in the runtime: its size in bytes must fit in an int.
+func maxElems(fr *frame, elem types.Type) int64 {
+	sizes := fr.fn.Sizes
+	if sizes == nil {
+		sizes = types.DefaultSizes
+	}
+	size := sizes.Sizeof(elem)
+	if size == 0 {
+		return math.MaxInt64
+	}
+	return math.MaxInt64 / size
+}
+
+// sliceElem returns the element type of the NewSlice or GrowSlice v.
+func sliceElem(fr *frame, v *ssa.Value) types.Type {
+	T, ok := v.Aux.(types.Type)
+	if !ok {
+		unsupported("%s: %s without element type", fr.fn.Name, v)
+	}
+	return T
+}
+
+// mapEntries returns the entries of the map m, or nil for the nil map.
+func mapEntries(m pointer) map[value]value {
+	if m.obj == nil {
+		return nil
+	}
+	return m.obj.slots[0].(map[value]value)
+}
+
+// mapKey loads the key of the map operation v from its address, Args[1].
+// Keys are ints, bools, strings or pointers, which Go compares like the
+// runtime does.
+func (in *interpreter) mapKey(fr *frame, v *ssa.Value) value {
+	return in.layout.load(asPointer(in.get(fr, v.Args[1])), elemType(v.Args[1].Type))
+}
+
+// method returns the method the interface call v dispatches to on the
+// value held by i, and the receiver to pass to it: the data ref for a
+// pointer receiver, or the value it refers to.
+func (in *interpreter) method(fr *frame, v *ssa.Value, i iface) (*ssa.Func, value) {
+	if i.dyn == nil {
+		throw("nil pointer dereference")
+	}
+	im, _ := v.Aux.(*types.FuncObj)
+	if im == nil {
+		unsupported("%s: interface call %s has no method", fr.fn.Name, v)
+	}
+	m := types.MethodSet(i.dyn, im.Name())
+	if m == nil {
+		unsupported("%s: %s has no method %s", fr.fn.Name, i.dyn, im.Name())
+	}
+	callee := in.funcs[ssa.LinkName(m)]
+	if callee == nil {
+		unsupported("%s: call to %s, which has no body", fr.fn.Name, ssa.LinkName(m))
+	}
+	if types.HasPointerRecv(m) {
+		return callee, i.data
+	}
+	return callee, in.layout.load(i.data, m.Signature().Recv().Type())
+}
+
+// typeAssert returns the value of type T held by i for the type assertion
+// v, or i itself if T is an interface type. It panics like the runtime if
+// i does not hold a T.
+func (in *interpreter) typeAssert(fr *frame, v *ssa.Value, i iface) value {
+	T := assertedType(fr, v)
+	if i.dyn != nil && types.IsInterface(T) {
+		return i
+	}
+	if i.dyn == nil || !types.Identical(i.dyn, T) {
+		have := "nil"
+		if i.dyn != nil {
+			have = i.dyn.String()
+		}
+		throw(fmt.Sprintf("interface conversion: %s is %s, not %s", v.Args[0].Type, have, T))
+	}
+	if _, ok := T.Underlying().(*types.Ref); ok {
+		return i.data
+	}
+	return in.layout.load(i.data, T)
+}
+
+// assertedType returns the type a TypeAssert or TypeTest v tests for.
+func assertedType(fr *frame, v *ssa.Value) types.Type {
+	T, ok := v.Aux.(types.Type)
+	if !ok {
+		unsupported("%s: %s without asserted type", fr.fn.Name, v)
+	}
+	return T
+}
+
+// elemType returns the element type of the pointer or ref type t.
+func elemType(t types.Type) types.Type {
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		return u.Elem()
+	case *types.Ref:
+		return u.Elem()
+	}
+	unsupported("%s is not a pointer type", t)
+	return nil
+}
@@ -0,0 +1,302 @@
+package interp
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/loader"
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/syntax"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// buildProgram builds the unoptimized SSA of a single-file program.
+func buildProgram(t *testing.T, src string) []*ssa.Package {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "test.yoru")
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var errs []string
+	conf := &loader.Config{
+		Sizes: types.DefaultSizes,
+		Error: func(pos syntax.Pos, msg string) {
+			errs = append(errs, pos.String()+": "+msg)
+		},
+	}
+	prog, err := loader.Load(conf, []string{file})
+	if len(errs) > 0 {
+		t.Fatalf("errors:\n%s", strings.Join(errs, "\n"))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkgs []*ssa.Package
+	for _, pkg := range prog.Packages {
+		pkgs = append(pkgs, ssa.BuildPackage(pkg.Files, prog.Info, types.DefaultSizes))
+	}
+	return pkgs
+}
+
+// runProgram runs src and returns its output and error.
+func runProgram(t *testing.T, src string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := Run(buildProgram(t, src), &out)
+	return out.String(), err
+}
+
+func TestRun(t *testing.T) {
+	src := `package main
+
+type Point struct {
+	x int
+	y int
+}
+
+type Line struct {
+	from Point
+	to   Point
+}
+
+var origin Point
+var scale int = 3
+var label string = "len"
+
+func (p Point) Add(q Point) Point {
+	var r Point
+	r.x = p.x + q.x
+	r.y = p.y + q.y
+	return r
+}
+
+func (p *Point) Move(dx int) {
+	p.x = p.x + dx
+}
+
+func fact(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return n * fact(n - 1)
+}
+
+func main() {
+	var l Line
+	l.to.x = 2
+	l.to.y = 5
+	var m Line = l
+	m.to.Move(10)
+	var sum Point = m.to.Add(l.to)
+	println(l.to.x, m.to.x, sum.x, sum.y, origin.x)
+
+	var a [3]int
+	var i int = 0
+	for i < 3 {
+		a[i] = fact(i + 3) * scale
+		i = i + 1
+	}
+	println(label, a[0], a[1], a[2])
+
+	var r ref Point = new(Point)
+	r.y = 7
+	var s ref Point = r
+	s.y = s.y + 1
+	println(r.y, r == s, 7 / 2, -7 % 3, 1 << 3, -16 >> 2)
+	println(1.5, 2.0 / 3.0, 1e6, true, !true)
+}
+`
+	out, err := runProgram(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2 12 14 10 0\nlen 18 72 360\n8 true 3 -1 8 -4\n1.5 0.666667 1e+06 true false\n"
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestRunPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"panic", `panic("boom")`, "boom"},
+		{"nil", `var p ref Node
+	println(p.val)`, "nil pointer dereference"},
+		{"bounds", `var a [2]int
+	var i int = 2
+	println(a[i])`, "index out of range [2] with length 2"},
+		{"shift", `var s int = -1
+	println(1 << s)`, "negative shift amount"},
+		{"divide", `var d int = 0
+	println(1 / d)`, "integer divide by zero"},
+		{"slice index", `s := make([]int, 2, 4)
+	var i int = 2
+	println(s[i])`, "index out of range [2] with length 2"},
+		{"slice bounds", `s := make([]int, 2, 4)
+	var hi int = 5
+	println(len(s[1:hi]))`, "slice bounds out of range [:5] with capacity 4"},
+		{"makeslice", `var n int = -1
+	println(len(make([]int, n)))`, "makeslice: len out of range"},
+		{"makeslice huge len", `var n int = 1 << 62
+	println(len(make([]int, n)))`, "makeslice: len out of range"},
+		{"makeslice huge cap", `var n int = 1 << 62
+	println(len(make([]int, 1, n)))`, "makeslice: cap out of range"},
+		{"makeslice out of memory", `var n int = 1 << 40
+	println(len(make([]int, n)))`, "out of memory"},
+		{"nil map", `var m map[int]int
+	m[1] = 2`, "assignment to entry in nil map"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package main\n\ntype Node struct {\n\tval int\n}\n\nfunc main() {\n\tprintln(\"before\")\n\t" + tt.body + "\n}\n"
+			out, err := runProgram(t, src)
+			var p *Panic
+			if !errors.As(err, &p) {
+				t.Fatalf("err = %v, want a panic", err)
+			}
+			if !strings.HasSuffix(p.Msg, tt.want) {
+				t.Errorf("panic message %q, want suffix %q", p.Msg, tt.want)
+			}
+			if out != "before\n" {
+				t.Errorf("output %q, want %q", out, "before\n")
+			}
+		})
+	}
+}
+
+func TestRunSlices(t *testing.T) {
+	src := `package main
+
+type Point struct {
+	x int
+	y int
+}
+
+func sum(s []int) int {
+	total := 0
+	i := 0
+	for i < len(s) {
+		total = total + s[i]
+		i = i + 1
+	}
+	return total
+}
+
+func main() {
+	var arr [5]int
+	i := 0
+	for i < 5 {
+		arr[i] = i * 10
+		i = i + 1
+	}
+	s := arr[1:4]
+	s[0] = 11
+	println(arr[1], len(s), cap(s), sum(s))
+
+	var t []int
+	println(t == nil, len(t))
+	i = 0
+	for i < 6 {
+		t = append(t, i)
+		i = i + 1
+	}
+	println(t == nil, len(t), cap(t), sum(t))
+
+	u := t[2:]
+	u = append(u, 100)
+	println(len(u), t[5], u[4], sum(t[:3]))
+
+	ps := make([]Point, 2, 3)
+	ps[1].y = 9
+	qs := append(ps, Point{x: 1, y: 2})
+	qs[1].y = 10
+	println(ps[1].y, qs[2].x, len(qs), cap(qs))
+
+	dst := make([]int, 3)
+	println(copy(dst, t[3:]), dst[0], dst[2], copy(t, t[1:]), t[0], t[4])
+}
+`
+	out, err := runProgram(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "11 3 4 61\ntrue 0\nfalse 6 8 15\n5 5 100 3\n10 1 3 3\n3 3 5 5 1 5\n"
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestRunMaps(t *testing.T) {
+	src := `package main
+
+type Point struct {
+	x int
+	y int
+}
+
+func main() {
+	m := make(map[string]int)
+	m["a"] = 1
+	m["b"] = 2
+	m["a"] = m["a"] + 10
+	v, ok := m["c"]
+	println(len(m), m["a"], m["b"], v, ok)
+	delete(m, "a")
+	delete(m, "z")
+	v, ok = m["b"]
+	println(len(m), m["a"], v, ok)
+
+	ps := make(map[int]Point, 8)
+	ps[1] = Point{x: 3, y: 4}
+	q := ps[1]
+	q.x = 5
+	println(ps[1].x, q.x, ps[2].y)
+
+	keys := make(map[ref int]bool)
+	k := new(int)
+	keys[k] = true
+	println(keys[k], keys[new(int)], keys[nil])
+
+	var nilMap map[bool]int
+	println(nilMap == nil, len(nilMap), nilMap[true])
+}
+`
+	out, err := runProgram(t, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2 11 2 0 false\n1 0 2 true\n3 5 0\ntrue false false\ntrue 0 0\n"
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		f    float64
+		want string
+	}{
+		{0, "0"},
+		{math.Copysign(0, -1), "-0"},
+		{3.14159265, "3.14159"},
+		{100000, "100000"},
+		{1234567, "1.23457e+06"},
+		{1e-5, "1e-05"},
+		{math.Inf(1), "inf"},
+		{math.Inf(-1), "-inf"},
+		{math.NaN(), "nan"},
+	}
+	for _, tt := range tests {
+		if got := formatFloat(tt.f); got != tt.want {
+			t.Errorf("formatFloat(%v) = %q, want %q", tt.f, got, tt.want)
+		}
+	}
+}
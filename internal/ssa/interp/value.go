@@ -0,0 +1,281 @@
+package interp
+
+import (
+	"go/constant"
+	"math"
+	"strconv"
+
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// A value is the run-time representation of an SSA value:
+//
+//	int                 int64
+//	float               float64
+//	bool                bool
+//	string              string
+//	*T, ref T           pointer
+//	struct, array       []value, one per field or element
+//	interface           iface
+//	slice               slice
+//	map                 pointer to an object whose only slot holds a
+//	                    map[value]value of the entries
+type value interface{}
+
+// An iface is an interface value: the dynamic type of the value it holds,
+// T for a copy of a T value or ref T, and the ref to the value. The zero
+// iface is the nil interface.
+type iface struct {
+	dyn  types.Type
+	data pointer
+}
+
+// A slice is a slice value: a pointer to its first element, its length
+// and its capacity. The zero slice is the nil slice.
+type slice struct {
+	data     pointer
+	len, cap int64
+}
+
+// An object is a block of memory: an alloca, a heap object from new(), or
+// a package-level variable. Memory is modelled as one slot per scalar
+// (non-aggregate) component of the object's type, in layout order.
+type object struct {
+	slots []value
+}
+
+// A pointer addresses a slot of an object. The zero pointer is nil.
+type pointer struct {
+	obj *object
+	off int
+}
+
+// layout caches the number of scalar slots of each type, and the slot
+// offset of each struct field.
+type layout struct {
+	slots   map[types.Type]int
+	offsets map[*types.Struct][]int
+}
+
+func newLayout() *layout {
+	return &layout{
+		slots:   make(map[types.Type]int),
+		offsets: make(map[*types.Struct][]int),
+	}
+}
+
+// numSlots returns the number of scalar slots of a value of type t.
+func (l *layout) numSlots(t types.Type) int {
+	if n, ok := l.slots[t]; ok {
+		return n
+	}
+	n := 1
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		offs := l.fieldOffsets(u)
+		n = offs[len(offs)-1]
+	case *types.Array:
+		n = int(u.Len()) * l.numSlots(u.Elem())
+	}
+	l.slots[t] = n
+	return n
+}
+
+// fieldOffsets returns the slot offset of each field of s, followed by the
+// total number of slots.
+func (l *layout) fieldOffsets(s *types.Struct) []int {
+	if offs, ok := l.offsets[s]; ok {
+		return offs
+	}
+	offs := make([]int, s.NumFields()+1)
+	for i, f := range s.Fields() {
+		offs[i+1] = offs[i] + l.numSlots(f.Type())
+	}
+	l.offsets[s] = offs
+	return offs
+}
+
+// newObject returns an object holding the zero value of type t.
+func (l *layout) newObject(t types.Type) *object {
+	obj := &object{slots: make([]value, l.numSlots(t))}
+	l.zero(pointer{obj, 0}, t)
+	return obj
+}
+
+// zeroValue returns the zero value of type t.
+func zeroValue(t types.Type) value {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case isKind(u, types.Int, types.UntypedInt):
+			return int64(0)
+		case isKind(u, types.Float, types.UntypedFloat):
+			return float64(0)
+		case isKind(u, types.Bool, types.UntypedBool):
+			return false
+		case isKind(u, types.String, types.UntypedString):
+			return ""
+		}
+	case *types.Pointer, *types.Ref, *types.Map:
+		return pointer{}
+	case *types.Interface:
+		return iface{}
+	case *types.Slice:
+		return slice{}
+	case *types.Struct:
+		fields := make([]value, u.NumFields())
+		for i, f := range u.Fields() {
+			fields[i] = zeroValue(f.Type())
+		}
+		return fields
+	case *types.Array:
+		elems := make([]value, u.Len())
+		for i := range elems {
+			elems[i] = zeroValue(u.Elem())
+		}
+		return elems
+	}
+	return pointer{}
+}
+
+// isKind reports whether b is one of kinds.
+func isKind(b *types.Basic, kinds ...types.BasicKind) bool {
+	for _, k := range kinds {
+		if b.Kind() == k {
+			return true
+		}
+	}
+	return false
+}
+
+// load reads a value of type t from p.
+func (l *layout) load(p pointer, t types.Type) value {
+	checkNil(p)
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		offs := l.fieldOffsets(u)
+		fields := make([]value, u.NumFields())
+		for i, f := range u.Fields() {
+			fields[i] = l.load(pointer{p.obj, p.off + offs[i]}, f.Type())
+		}
+		return fields
+	case *types.Array:
+		n := l.numSlots(u.Elem())
+		elems := make([]value, u.Len())
+		for i := range elems {
+			elems[i] = l.load(pointer{p.obj, p.off + i*n}, u.Elem())
+		}
+		return elems
+	}
+	checkRange(p)
+	return p.obj.slots[p.off]
+}
+
+// store writes v, a value of type t, to p.
+func (l *layout) store(p pointer, t types.Type, v value) {
+	checkNil(p)
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		offs := l.fieldOffsets(u)
+		for i, f := range u.Fields() {
+			l.store(pointer{p.obj, p.off + offs[i]}, f.Type(), v.([]value)[i])
+		}
+		return
+	case *types.Array:
+		n := l.numSlots(u.Elem())
+		for i, elem := range v.([]value) {
+			l.store(pointer{p.obj, p.off + i*n}, u.Elem(), elem)
+		}
+		return
+	}
+	checkRange(p)
+	p.obj.slots[p.off] = v
+}
+
+// zero writes the zero value of type t to p.
+func (l *layout) zero(p pointer, t types.Type) {
+	l.store(p, t, zeroValue(t))
+}
+
+// checkNil panics if p is nil. Compiled code faults instead, but the
+// compiler guards every dereference of a ref with a nil check.
+func checkNil(p pointer) {
+	if p.obj == nil {
+		throw("nil pointer dereference")
+	}
+}
+
+// checkRange aborts the program if p is outside its object. Compiled code
+// would corrupt memory, but the compiler guards every variable index with
+// a bounds check.
+func checkRange(p pointer) {
+	if p.off < 0 || p.off >= len(p.obj.slots) {
+		unsupported("access to slot %d of an object of %d slots", p.off, len(p.obj.slots))
+	}
+}
+
+// constValue converts the constant c to a value of type t.
+func constValue(c constant.Value, t types.Type) value {
+	b, _ := t.Underlying().(*types.Basic)
+	switch {
+	case b == nil:
+		return zeroValue(t)
+	case isKind(b, types.Int, types.UntypedInt):
+		// go/constant may represent integer-valued results as rationals.
+		if c.Kind() == constant.Float {
+			f, _ := constant.Float64Val(c)
+			return int64(f)
+		}
+		n, _ := constant.Int64Val(c)
+		return n
+	case isKind(b, types.Float, types.UntypedFloat):
+		f, _ := constant.Float64Val(constant.ToFloat(c))
+		return f
+	case isKind(b, types.Bool, types.UntypedBool):
+		return constant.BoolVal(c)
+	case isKind(b, types.String, types.UntypedString):
+		return constant.StringVal(c)
+	}
+	return zeroValue(t)
+}
+
+// formatValue formats v the way the runtime prints it. Values the runtime
+// cannot print format as "".
+func formatValue(v value) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return formatFloat(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+// formatFloat formats f like C's printf("%g").
+func formatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		if math.Signbit(f) {
+			return "-nan"
+		}
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
+
+// asInt, asFloat, asBool, asString, asPointer and asSlice convert an interpreter
+// value to its Go representation.
+func asInt(v value) int64       { return v.(int64) }
+func asFloat(v value) float64   { return v.(float64) }
+func asBool(v value) bool       { return v.(bool) }
+func asString(v value) string   { return v.(string) }
+func asPointer(v value) pointer { return v.(pointer) }
+func asSlice(v value) slice     { return v.(slice) }
@@ -0,0 +1,75 @@
+package ssa
+
+import (
+	"github.com/you-not-fish/yoru/internal/rtabi"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// LinkName returns the symbol name of a function in the generated code.
+//
+// Functions are qualified with the import path of their package ("main" for
+// the main package), so equal names in different packages do not collide
+// with each other or with runtime symbols; only main itself becomes
+// rtabi.YoruMain. Methods are additionally qualified with their receiver
+// type name, separated by '$', which cannot occur in an import path or an
+// identifier:
+//
+//	main.F              → main.F
+//	main.(T).M          → main.T$M
+//	example/util.F      → example/util.F
+//	example/util.(T).M  → example/util.T$M
+func LinkName(fn *types.FuncObj) string {
+	if fn.Name() == "main" && fn.Signature().Recv() == nil && isMainPkg(fn.Pkg()) {
+		return rtabi.YoruMain
+	}
+
+	name := fn.Name()
+	if recv := fn.Signature().Recv(); recv != nil {
+		if tn := recvTypeName(recv.Type()); tn != "" {
+			name = tn + "$" + name
+		}
+	}
+	return qualify(fn.Pkg(), name)
+}
+
+// GlobalLinkName returns the symbol name of a package-level variable,
+// qualified like a function name.
+func GlobalLinkName(v *types.Var) string {
+	return qualify(v.Pkg(), v.Name())
+}
+
+// InitLinkName returns the symbol name of the function initializing the
+// package-level variables of pkg. The '$' cannot occur in Yoru identifiers,
+// so the name never collides with a user declaration.
+func InitLinkName(pkg *types.Package) string {
+	return qualify(pkg, "init$")
+}
+
+// qualify qualifies name with the import path of pkg.
+func qualify(pkg *types.Package, name string) string {
+	if isMainPkg(pkg) {
+		return "main." + name
+	}
+	return pkg.Path() + "." + name
+}
+
+// isMainPkg reports whether pkg is the main package (or a package checked
+// without an import path).
+func isMainPkg(pkg *types.Package) bool {
+	return pkg == nil || pkg.Path() == "" || pkg.Path() == "main"
+}
+
+// recvTypeName returns the name of the named type T of a receiver of
+// type T, *T or ref T.
+func recvTypeName(t types.Type) string {
+	switch p := t.(type) {
+	case *types.Pointer:
+		t = p.Elem()
+	case *types.Ref:
+		t = p.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj().Name()
+	}
+	return ""
+}
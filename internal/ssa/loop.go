@@ -0,0 +1,249 @@
+package ssa
+
+import "sort"
+
+// Loop is a natural loop: a header block together with the blocks that
+// reach one of its back edges without passing through the header. Back
+// edges sharing a header form a single loop.
+type Loop struct {
+	// Header is the single entry block of the loop.
+	Header *Block
+
+	// Blocks lists the blocks of the loop, including the header and the
+	// blocks of nested loops, in f.Blocks order.
+	Blocks []*Block
+
+	// Exits lists the blocks outside the loop with a predecessor inside
+	// it, in f.Blocks order.
+	Exits []*Block
+
+	// Parent is the innermost loop containing this one, or nil.
+	Parent *Loop
+
+	// Children lists the loops directly nested in this one.
+	Children []*Loop
+
+	// Depth is the nesting depth: 1 for an outermost loop.
+	Depth int
+
+	contains map[*Block]bool
+}
+
+// Contains reports whether b is in the loop.
+func (l *Loop) Contains(b *Block) bool {
+	return l.contains[b]
+}
+
+// Preheader returns the loop's preheader, or nil if it has none. The
+// preheader is the only predecessor of the header outside the loop, and
+// has the header as its only successor.
+func (l *Loop) Preheader() *Block {
+	var pre *Block
+	for _, p := range l.Header.Preds {
+		if l.contains[p] {
+			continue
+		}
+		if pre != nil && pre != p {
+			return nil
+		}
+		pre = p
+	}
+	if pre == nil || len(pre.Succs) != 1 {
+		return nil
+	}
+	return pre
+}
+
+// LoopNest describes the natural loops of a function.
+type LoopNest struct {
+	// Loops lists all loops, each before the loops nested in it.
+	Loops []*Loop
+
+	innermost map[*Block]*Loop
+}
+
+// ComputeLoopNest finds the natural loops of f from the back edges of its
+// dominator tree. ComputeDom must have been called first. Cycles with
+// more than one entry (irreducible control flow) are not loops.
+func ComputeLoopNest(f *Func) *LoopNest {
+	ln := &LoopNest{innermost: make(map[*Block]*Loop)}
+
+	rpo := ReversePostOrder(f)
+	reachable := make(map[*Block]bool, len(rpo))
+	for _, b := range rpo {
+		reachable[b] = true
+	}
+
+	// An edge b -> h is a back edge if h dominates b.
+	byHeader := make(map[*Block]*Loop)
+	for _, b := range rpo {
+		for _, h := range b.Succs {
+			if !Dominates(h, b) {
+				continue
+			}
+			l := byHeader[h]
+			if l == nil {
+				l = &Loop{Header: h, contains: map[*Block]bool{h: true}}
+				byHeader[h] = l
+				ln.Loops = append(ln.Loops, l)
+			}
+			// Walk backwards from the latch up to the header.
+			work := []*Block{b}
+			for len(work) > 0 {
+				x := work[len(work)-1]
+				work = work[:len(work)-1]
+				if l.contains[x] || !reachable[x] {
+					continue
+				}
+				l.contains[x] = true
+				work = append(work, x.Preds...)
+			}
+		}
+	}
+
+	order := make(map[*Block]int, len(f.Blocks))
+	for i, b := range f.Blocks {
+		order[b] = i
+	}
+	for _, l := range ln.Loops {
+		for b := range l.contains {
+			l.Blocks = append(l.Blocks, b)
+		}
+		sortBlocks(l.Blocks, order)
+	}
+
+	// A loop's parent is the smallest other loop containing its header.
+	// Sorting by size puts every loop after the loops containing it.
+	sort.SliceStable(ln.Loops, func(i, j int) bool {
+		return len(ln.Loops[i].Blocks) > len(ln.Loops[j].Blocks)
+	})
+	for i, l := range ln.Loops {
+		for j := i - 1; j >= 0; j-- {
+			if ln.Loops[j].contains[l.Header] {
+				l.Parent = ln.Loops[j]
+				break
+			}
+		}
+		l.Depth = 1
+		if l.Parent != nil {
+			l.Parent.Children = append(l.Parent.Children, l)
+			l.Depth = l.Parent.Depth + 1
+		}
+		for _, b := range l.Blocks {
+			ln.innermost[b] = l
+		}
+	}
+
+	for _, l := range ln.Loops {
+		seen := make(map[*Block]bool)
+		for _, b := range l.Blocks {
+			for _, s := range b.Succs {
+				if !l.contains[s] && !seen[s] {
+					seen[s] = true
+					l.Exits = append(l.Exits, s)
+				}
+			}
+		}
+		sortBlocks(l.Exits, order)
+	}
+	return ln
+}
+
+// Loop returns the innermost loop containing b, or nil.
+func (ln *LoopNest) Loop(b *Block) *Loop {
+	return ln.innermost[b]
+}
+
+// Depth returns the loop nesting depth of b: 0 outside any loop.
+func (ln *LoopNest) Depth(b *Block) int {
+	if l := ln.innermost[b]; l != nil {
+		return l.Depth
+	}
+	return 0
+}
+
+// InsertPreheader returns the preheader of l, creating one if needed, or
+// nil if the header has no predecessor outside the loop. A new preheader
+// takes over the edges entering the header from outside the loop; header
+// phis get a single argument for it, merged by a new phi in the preheader
+// if several outside edges carried different values. The preheader joins
+// the loops enclosing l. The dominator tree must be recomputed afterwards.
+func (ln *LoopNest) InsertPreheader(l *Loop) *Block {
+	if pre := l.Preheader(); pre != nil {
+		return pre
+	}
+	f := l.Header.Func
+	h := l.Header
+
+	var outside, inside []int
+	for i, p := range h.Preds {
+		if l.contains[p] {
+			inside = append(inside, i)
+		} else {
+			outside = append(outside, i)
+		}
+	}
+	if len(outside) == 0 {
+		return nil
+	}
+
+	pre := f.NewBlock(BlockPlain)
+	for _, i := range outside {
+		p := h.Preds[i]
+		for k, s := range p.Succs {
+			if s == h {
+				p.Succs[k] = pre
+			}
+		}
+		pre.Preds = append(pre.Preds, p)
+	}
+	pre.Succs = []*Block{h}
+
+	// The header's preds become the preheader followed by the latches.
+	preds := []*Block{pre}
+	for _, i := range inside {
+		preds = append(preds, h.Preds[i])
+	}
+	for _, v := range h.Values {
+		if v.Op != OpPhi {
+			continue
+		}
+		in := v.Args[outside[0]]
+		for _, i := range outside[1:] {
+			if v.Args[i] != in {
+				in = nil
+				break
+			}
+		}
+		if in == nil {
+			in = f.NewValue(pre, OpPhi, v.Type)
+			for _, i := range outside {
+				in.AddArg(v.Args[i])
+			}
+		} else {
+			in.Uses++
+		}
+		args := []*Value{in}
+		for _, i := range inside {
+			args = append(args, v.Args[i])
+		}
+		v.SetArgs(args)
+	}
+	h.Preds = preds
+
+	for p := l.Parent; p != nil; p = p.Parent {
+		p.contains[pre] = true
+		p.Blocks = append(p.Blocks, pre)
+	}
+	if l.Parent != nil {
+		ln.innermost[pre] = l.Parent
+	}
+	return pre
+}
+
+// sortBlocks sorts blocks by their position in order.
+func sortBlocks(blocks []*Block, order map[*Block]int) {
+	sort.Slice(blocks, func(i, j int) bool {
+		return order[blocks[i]] < order[blocks[j]]
+	})
+}
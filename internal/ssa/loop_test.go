@@ -0,0 +1,239 @@
+package ssa
+
+import (
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// makeLoopCond adds a bool constant to b and makes it an If block on it.
+func makeLoopCond(f *Func, b *Block) {
+	cond := f.NewValue(b, OpConstBool, types.Typ[types.Bool])
+	cond.AuxInt = 1
+	b.Kind = BlockIf
+	b.SetControl(cond)
+}
+
+// TestLoopNestNone verifies that acyclic code has no loops.
+func TestLoopNestNone(t *testing.T) {
+	f := NewFunc("f", makeSig())
+	b0 := f.Entry
+	b1 := f.NewBlock(BlockReturn)
+	b0.AddSucc(b1)
+
+	ComputeDom(f)
+	ln := ComputeLoopNest(f)
+
+	if len(ln.Loops) != 0 {
+		t.Errorf("len(Loops) = %d, want 0", len(ln.Loops))
+	}
+	if ln.Loop(b1) != nil || ln.Depth(b1) != 0 {
+		t.Errorf("b1 in loop %v at depth %d, want none", ln.Loop(b1), ln.Depth(b1))
+	}
+}
+
+// TestLoopNestSimple verifies a single loop:
+//
+//	b0 → b1 ⇄ b2
+//	     ↓
+//	     b3
+func TestLoopNestSimple(t *testing.T) {
+	f := NewFunc("f", makeSig())
+	b0 := f.Entry
+	b1 := f.NewBlock(BlockPlain)
+	b2 := f.NewBlock(BlockPlain)
+	b3 := f.NewBlock(BlockReturn)
+
+	b0.AddSucc(b1)
+	makeLoopCond(f, b1)
+	b1.AddSucc(b2)
+	b1.AddSucc(b3)
+	b2.AddSucc(b1)
+
+	ComputeDom(f)
+	ln := ComputeLoopNest(f)
+
+	if len(ln.Loops) != 1 {
+		t.Fatalf("len(Loops) = %d, want 1", len(ln.Loops))
+	}
+	l := ln.Loops[0]
+	if l.Header != b1 {
+		t.Errorf("Header = %v, want %v", l.Header, b1)
+	}
+	if len(l.Blocks) != 2 || l.Blocks[0] != b1 || l.Blocks[1] != b2 {
+		t.Errorf("Blocks = %v, want [b1 b2]", l.Blocks)
+	}
+	if len(l.Exits) != 1 || l.Exits[0] != b3 {
+		t.Errorf("Exits = %v, want [b3]", l.Exits)
+	}
+	if l.Depth != 1 || l.Parent != nil {
+		t.Errorf("Depth = %d, Parent = %v; want 1, nil", l.Depth, l.Parent)
+	}
+	if l.Contains(b0) || l.Contains(b3) || !l.Contains(b2) {
+		t.Errorf("Contains is wrong for b0, b2 or b3")
+	}
+	if pre := l.Preheader(); pre != b0 {
+		t.Errorf("Preheader = %v, want %v", pre, b0)
+	}
+	if pre := ln.InsertPreheader(l); pre != b0 {
+		t.Errorf("InsertPreheader = %v, want existing %v", pre, b0)
+	}
+}
+
+// TestLoopNestNested verifies two nested loops:
+//
+//	b0 → b1 → b2 ⇄ b3
+//	     ↑    ↓
+//	     └─── b4
+//	b1 → b5
+func TestLoopNestNested(t *testing.T) {
+	f := NewFunc("f", makeSig())
+	b0 := f.Entry
+	b1 := f.NewBlock(BlockPlain)
+	b2 := f.NewBlock(BlockPlain)
+	b3 := f.NewBlock(BlockPlain)
+	b4 := f.NewBlock(BlockPlain)
+	b5 := f.NewBlock(BlockReturn)
+
+	b0.AddSucc(b1)
+	makeLoopCond(f, b1)
+	b1.AddSucc(b2)
+	b1.AddSucc(b5)
+	makeLoopCond(f, b2)
+	b2.AddSucc(b3)
+	b2.AddSucc(b4)
+	b3.AddSucc(b2)
+	b4.AddSucc(b1)
+
+	ComputeDom(f)
+	ln := ComputeLoopNest(f)
+
+	if len(ln.Loops) != 2 {
+		t.Fatalf("len(Loops) = %d, want 2", len(ln.Loops))
+	}
+	outer, inner := ln.Loops[0], ln.Loops[1]
+	if outer.Header != b1 || inner.Header != b2 {
+		t.Fatalf("headers = %v, %v; want b1, b2", outer.Header, inner.Header)
+	}
+	if len(outer.Blocks) != 4 || len(inner.Blocks) != 2 {
+		t.Errorf("sizes = %d, %d; want 4, 2", len(outer.Blocks), len(inner.Blocks))
+	}
+	if inner.Parent != outer || len(outer.Children) != 1 || outer.Children[0] != inner {
+		t.Errorf("inner loop is not nested in the outer loop")
+	}
+	if outer.Depth != 1 || inner.Depth != 2 {
+		t.Errorf("depths = %d, %d; want 1, 2", outer.Depth, inner.Depth)
+	}
+	if len(inner.Exits) != 1 || inner.Exits[0] != b4 {
+		t.Errorf("inner Exits = %v, want [b4]", inner.Exits)
+	}
+	if len(outer.Exits) != 1 || outer.Exits[0] != b5 {
+		t.Errorf("outer Exits = %v, want [b5]", outer.Exits)
+	}
+
+	for _, tc := range []struct {
+		b     *Block
+		loop  *Loop
+		depth int
+	}{
+		{b0, nil, 0},
+		{b1, outer, 1},
+		{b2, inner, 2},
+		{b3, inner, 2},
+		{b4, outer, 1},
+		{b5, nil, 0},
+	} {
+		if got := ln.Loop(tc.b); got != tc.loop {
+			t.Errorf("Loop(%v) = %v, want %v", tc.b, got, tc.loop)
+		}
+		if got := ln.Depth(tc.b); got != tc.depth {
+			t.Errorf("Depth(%v) = %d, want %d", tc.b, got, tc.depth)
+		}
+	}
+
+	// The inner header is entered from b1, which also exits the outer
+	// loop, so it has no preheader yet.
+	if pre := inner.Preheader(); pre != nil {
+		t.Errorf("inner Preheader = %v, want nil", pre)
+	}
+	pre := ln.InsertPreheader(inner)
+	if pre == nil || !outer.Contains(pre) || ln.Loop(pre) != outer {
+		t.Fatalf("InsertPreheader = %v, want a new block in the outer loop", pre)
+	}
+	if inner.Preheader() != pre {
+		t.Errorf("inner Preheader = %v, want %v", inner.Preheader(), pre)
+	}
+	if b1.Succs[0] != pre || len(pre.Succs) != 1 || pre.Succs[0] != b2 {
+		t.Errorf("preheader is not between b1 and b2")
+	}
+
+	ComputeDom(f)
+	if err := Verify(f); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+// TestInsertPreheaderPhis verifies that header phis are split between a
+// new preheader and the latch:
+//
+//	b0 ─→ b2 ⇄ b3
+//	b1 ─↗ ↓
+//	      b4
+func TestInsertPreheaderPhis(t *testing.T) {
+	f := NewFunc("f", makeSig())
+	b0 := f.Entry
+	b1 := f.NewBlock(BlockPlain)
+	b2 := f.NewBlock(BlockPlain)
+	b3 := f.NewBlock(BlockPlain)
+	b4 := f.NewBlock(BlockReturn)
+
+	i64 := types.Typ[types.Int]
+	x := f.NewValue(b0, OpConst64, i64)
+	x.AuxInt = 1
+	y := f.NewValue(b0, OpConst64, i64)
+	y.AuxInt = 2
+
+	makeLoopCond(f, b0)
+	b0.AddSucc(b1)
+	b0.AddSucc(b2)
+	b1.AddSucc(b2)
+	makeLoopCond(f, b2)
+	b2.AddSucc(b3)
+	b2.AddSucc(b4)
+	b3.AddSucc(b2)
+
+	// b2's preds are b0, b1 and b3.
+	same := f.NewValue(b2, OpPhi, i64, x, x, y)
+	diff := f.NewValue(b2, OpPhi, i64, x, y, y)
+
+	ComputeDom(f)
+	ln := ComputeLoopNest(f)
+	l := ln.Loops[0]
+	pre := ln.InsertPreheader(l)
+	if pre == nil {
+		t.Fatal("InsertPreheader returned nil")
+	}
+
+	if len(b2.Preds) != 2 || b2.Preds[0] != pre || b2.Preds[1] != b3 {
+		t.Fatalf("header Preds = %v, want [%v b3]", b2.Preds, pre)
+	}
+	if len(pre.Preds) != 2 || pre.Preds[0] != b0 || pre.Preds[1] != b1 {
+		t.Errorf("preheader Preds = %v, want [b0 b1]", pre.Preds)
+	}
+	if len(same.Args) != 2 || same.Args[0] != x || same.Args[1] != y {
+		t.Errorf("same = %v, want phi of x and y", same.Args)
+	}
+	merged := diff.Args[0]
+	if merged.Op != OpPhi || merged.Block != pre || len(merged.Args) != 2 ||
+		merged.Args[0] != x || merged.Args[1] != y {
+		t.Errorf("diff.Args[0] = %v, want a phi of x and y in the preheader", merged.LongString())
+	}
+	if diff.Args[1] != y {
+		t.Errorf("diff.Args[1] = %v, want y", diff.Args[1])
+	}
+
+	ComputeDom(f)
+	if err := Verify(f); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
@@ -23,6 +23,15 @@ const (
 	OpMod64 // int % int
 	OpNeg64 // -int (unary)
 
+	// Integer bitwise operations and shifts
+	OpAnd64    // int & int
+	OpOr64     // int | int
+	OpXor64    // int ^ int
+	OpAndNot64 // int &^ int
+	OpCom64    // ^int (unary bitwise complement)
+	OpLsh64    // int << int; counts >= 64 give 0, negative counts panic
+	OpRsh64    // int >> int (arithmetic); counts >= 64 give 0 or -1, negative counts panic
+
 	// Float arithmetic
 	OpAddF64 // float + float
 	OpSubF64 // float - float
@@ -65,6 +74,10 @@ const (
 	OpStructFieldPtr // &s.field; Args[0] = struct ptr; AuxInt = field index
 	OpArrayIndexPtr  // &a[i]; Args[0] = array ptr, Args[1] = index
 
+	// Aggregate values
+	OpExtractValue  // field or element of a struct or array value; Args[0] = aggregate; AuxInt = index
+	OpMakeAggregate // struct or array value; Args = fields or elements in order, or none for the zero value
+
 	// Conversion
 	OpIntToFloat // int → float
 	OpFloatToInt // float → int
@@ -73,16 +86,43 @@ const (
 	OpStaticCall // direct function call; Aux = *types.FuncObj; Args = arguments
 	OpCall       // indirect call; Args[0] = func ptr, Args[1:] = arguments
 
+	// Interfaces
+	OpMakeInterface  // interface value; Args[0] = data ref; Aux = dynamic type (T for a boxed T, or ref T)
+	OpInterfaceCall  // dynamic method call; Args[0] = interface, Args[1:] = arguments; Aux = *types.FuncObj of the interface method; AuxInt = method index
+	OpInterfaceIsNil // interface == nil; Args[0] = interface
+	OpTypeAssert     // x.(T); Args[0] = interface; Aux = T; panics unless the dynamic type is T
+	OpTypeTest       // dynamic type of Args[0] is T; Aux = T; false for the nil interface
+
 	// Heap allocation
 	OpNewAlloc // new(T) → ref T; calls rt_alloc; Aux = TypeDesc info
 
+	// Slices
+	OpNewSlice      // make([]T, len, cap); Args[0] = len, Args[1] = cap; Aux = element type T; calls rt_makeslice
+	OpSliceMake     // slice of a whole array; Args[0] = array ptr, Args[1] = len, Args[2] = cap
+	OpSliceLen      // len(s); Args[0] = slice
+	OpSliceCap      // cap(s); Args[0] = slice
+	OpSlicePtr      // data pointer of a slice; Args[0] = slice; Type = *T
+	OpSliceIndexPtr // &s[i]; Args[0] = slice, Args[1] = index (unchecked)
+	OpSliceSlice    // s[lo:hi]; Args[0] = slice, Args[1] = lo, Args[2] = hi (unchecked)
+	OpSliceCheck    // slice bounds check; Args[0] = lo, Args[1] = hi, Args[2] = cap; Pos = slice position; panics unless 0 <= lo <= hi <= cap
+	OpGrowSlice     // grow *Args[0] in place to a capacity of at least Args[1]; Aux = element type; calls rt_growslice; void
+	OpSliceCopy     // copy(dst, src); Args[0] = dst, Args[1] = src; result = number of elements copied
+
+	// Maps
+	OpMakeMap   // make(map[K]V, hint); Args[0] = hint; Aux = map type; calls rt_makemap
+	OpMapAccess // m[k]; Args[0] = map, Args[1] = key ptr, Args[2] = element ptr; stores the element if present; result = found
+	OpMapAssign // m[k] = v; Args[0] = map, Args[1] = key ptr, Args[2] = element ptr; panics if the map is nil; void
+	OpMapDelete // delete(m, k); Args[0] = map, Args[1] = key ptr; void
+	OpMapLen    // len(m); Args[0] = map
+
 	// SSA-specific
 	OpPhi  // φ function; Args = one per predecessor
 	OpCopy // value copy (identity)
 	OpArg  // function argument; AuxInt = param index; Aux = param name
 
 	// Address
-	OpAddr // address of local (&x → ptr to alloca); Args[0] = alloca
+	OpAddr   // address of local (&x → ptr to alloca); Args[0] = alloca
+	OpGlobal // address of package-level variable; Aux = *types.Var; Type = *T
 
 	// Builtins
 	OpPrintln // println(...); Args = values to print; void
@@ -91,6 +131,9 @@ const (
 	// Nil check
 	OpNilCheck // nil check; Args[0] = pointer; panics if nil
 
+	// Bounds check
+	OpBoundsCheck // bounds check; Args[0] = index, Args[1] = length; Pos = index position; panics if out of range
+
 	// String operations
 	OpStringLen // string length; Args[0] = string
 	OpStringPtr // string data pointer; Args[0] = string
@@ -100,9 +143,11 @@ const (
 
 // OpInfo holds metadata about an SSA operation.
 type OpInfo struct {
-	Name   string // human-readable name
-	IsPure bool   // true if the op has no side effects and can be CSE'd/DCE'd
-	IsVoid bool   // true if the op produces no value (Store, Println, etc.)
+	Name          string // human-readable name
+	IsPure        bool   // true if the op has no side effects and can be CSE'd/DCE'd
+	MayTrap       bool   // true if the pure op can panic, so it must not be DCE'd
+	IsVoid        bool   // true if the op produces no value (Store, Println, etc.)
+	IsCommutative bool   // true if the two args of the op can be swapped
 }
 
 // opInfoTable maps each Op to its OpInfo.
@@ -117,45 +162,55 @@ var opInfoTable = [opCount]OpInfo{
 	OpConstString: {Name: "ConstString", IsPure: true},
 	OpConstNil:    {Name: "ConstNil", IsPure: true},
 
-	// Integer arithmetic — all pure
-	OpAdd64: {Name: "Add64", IsPure: true},
+	// Integer arithmetic — all pure; division panics for a zero divisor
+	OpAdd64: {Name: "Add64", IsPure: true, IsCommutative: true},
 	OpSub64: {Name: "Sub64", IsPure: true},
-	OpMul64: {Name: "Mul64", IsPure: true},
-	OpDiv64: {Name: "Div64", IsPure: true},
-	OpMod64: {Name: "Mod64", IsPure: true},
+	OpMul64: {Name: "Mul64", IsPure: true, IsCommutative: true},
+	OpDiv64: {Name: "Div64", IsPure: true, MayTrap: true},
+	OpMod64: {Name: "Mod64", IsPure: true, MayTrap: true},
 	OpNeg64: {Name: "Neg64", IsPure: true},
 
+	// Integer bitwise operations and shifts — all pure; shifts panic for a
+	// negative count
+	OpAnd64:    {Name: "And64", IsPure: true, IsCommutative: true},
+	OpOr64:     {Name: "Or64", IsPure: true, IsCommutative: true},
+	OpXor64:    {Name: "Xor64", IsPure: true, IsCommutative: true},
+	OpAndNot64: {Name: "AndNot64", IsPure: true},
+	OpCom64:    {Name: "Com64", IsPure: true},
+	OpLsh64:    {Name: "Lsh64", IsPure: true, MayTrap: true},
+	OpRsh64:    {Name: "Rsh64", IsPure: true, MayTrap: true},
+
 	// Float arithmetic — all pure
-	OpAddF64: {Name: "AddF64", IsPure: true},
+	OpAddF64: {Name: "AddF64", IsPure: true, IsCommutative: true},
 	OpSubF64: {Name: "SubF64", IsPure: true},
-	OpMulF64: {Name: "MulF64", IsPure: true},
+	OpMulF64: {Name: "MulF64", IsPure: true, IsCommutative: true},
 	OpDivF64: {Name: "DivF64", IsPure: true},
 	OpNegF64: {Name: "NegF64", IsPure: true},
 
 	// Integer comparison — all pure
-	OpEq64:  {Name: "Eq64", IsPure: true},
-	OpNeq64: {Name: "Neq64", IsPure: true},
+	OpEq64:  {Name: "Eq64", IsPure: true, IsCommutative: true},
+	OpNeq64: {Name: "Neq64", IsPure: true, IsCommutative: true},
 	OpLt64:  {Name: "Lt64", IsPure: true},
 	OpLeq64: {Name: "Leq64", IsPure: true},
 	OpGt64:  {Name: "Gt64", IsPure: true},
 	OpGeq64: {Name: "Geq64", IsPure: true},
 
 	// Float comparison — all pure
-	OpEqF64:  {Name: "EqF64", IsPure: true},
-	OpNeqF64: {Name: "NeqF64", IsPure: true},
+	OpEqF64:  {Name: "EqF64", IsPure: true, IsCommutative: true},
+	OpNeqF64: {Name: "NeqF64", IsPure: true, IsCommutative: true},
 	OpLtF64:  {Name: "LtF64", IsPure: true},
 	OpLeqF64: {Name: "LeqF64", IsPure: true},
 	OpGtF64:  {Name: "GtF64", IsPure: true},
 	OpGeqF64: {Name: "GeqF64", IsPure: true},
 
 	// Pointer comparison — pure
-	OpEqPtr:  {Name: "EqPtr", IsPure: true},
-	OpNeqPtr: {Name: "NeqPtr", IsPure: true},
+	OpEqPtr:  {Name: "EqPtr", IsPure: true, IsCommutative: true},
+	OpNeqPtr: {Name: "NeqPtr", IsPure: true, IsCommutative: true},
 
 	// Boolean — pure
 	OpNot:     {Name: "Not", IsPure: true},
-	OpAndBool: {Name: "AndBool", IsPure: true},
-	OpOrBool:  {Name: "OrBool", IsPure: true},
+	OpAndBool: {Name: "AndBool", IsPure: true, IsCommutative: true},
+	OpOrBool:  {Name: "OrBool", IsPure: true, IsCommutative: true},
 
 	// Memory — NOT pure (side effects)
 	OpAlloca: {Name: "Alloca"},
@@ -167,6 +222,10 @@ var opInfoTable = [opCount]OpInfo{
 	OpStructFieldPtr: {Name: "StructFieldPtr", IsPure: true},
 	OpArrayIndexPtr:  {Name: "ArrayIndexPtr", IsPure: true},
 
+	// Aggregate values — pure
+	OpExtractValue:  {Name: "ExtractValue", IsPure: true},
+	OpMakeAggregate: {Name: "MakeAggregate", IsPure: true},
+
 	// Conversion — pure
 	OpIntToFloat: {Name: "IntToFloat", IsPure: true},
 	OpFloatToInt: {Name: "FloatToInt", IsPure: true},
@@ -175,16 +234,44 @@ var opInfoTable = [opCount]OpInfo{
 	OpStaticCall: {Name: "StaticCall"},
 	OpCall:       {Name: "Call"},
 
+	// Interfaces — InterfaceCall and TypeAssert are NOT pure
+	OpMakeInterface:  {Name: "MakeInterface", IsPure: true},
+	OpInterfaceCall:  {Name: "InterfaceCall"},
+	OpInterfaceIsNil: {Name: "InterfaceIsNil", IsPure: true},
+	OpTypeAssert:     {Name: "TypeAssert"},
+	OpTypeTest:       {Name: "TypeTest", IsPure: true},
+
 	// Heap allocation — NOT pure
 	OpNewAlloc: {Name: "NewAlloc"},
 
+	// Slices — header accessors and address arithmetic are pure;
+	// allocation, checks and copies are not
+	OpNewSlice:      {Name: "NewSlice"},
+	OpSliceMake:     {Name: "SliceMake", IsPure: true},
+	OpSliceLen:      {Name: "SliceLen", IsPure: true},
+	OpSliceCap:      {Name: "SliceCap", IsPure: true},
+	OpSlicePtr:      {Name: "SlicePtr", IsPure: true},
+	OpSliceIndexPtr: {Name: "SliceIndexPtr", IsPure: true},
+	OpSliceSlice:    {Name: "SliceSlice", IsPure: true},
+	OpSliceCheck:    {Name: "SliceCheck", IsVoid: true},
+	OpGrowSlice:     {Name: "GrowSlice", IsVoid: true},
+	OpSliceCopy:     {Name: "SliceCopy"},
+
+	// Maps — all read or write the hash table, so none is pure
+	OpMakeMap:   {Name: "MakeMap"},
+	OpMapAccess: {Name: "MapAccess"},
+	OpMapAssign: {Name: "MapAssign", IsVoid: true},
+	OpMapDelete: {Name: "MapDelete", IsVoid: true},
+	OpMapLen:    {Name: "MapLen"},
+
 	// SSA — Phi and Copy are pure; Arg is pure
 	OpPhi:  {Name: "Phi", IsPure: true},
 	OpCopy: {Name: "Copy", IsPure: true},
 	OpArg:  {Name: "Arg", IsPure: true},
 
 	// Address — pure (just computes pointer)
-	OpAddr: {Name: "Addr", IsPure: true},
+	OpAddr:   {Name: "Addr", IsPure: true},
+	OpGlobal: {Name: "Global", IsPure: true},
 
 	// Builtins — NOT pure (side effects)
 	OpPrintln: {Name: "Println", IsVoid: true},
@@ -193,6 +280,9 @@ var opInfoTable = [opCount]OpInfo{
 	// Nil check — NOT pure (may panic), void (no result value)
 	OpNilCheck: {Name: "NilCheck", IsVoid: true},
 
+	// Bounds check — NOT pure (may panic), void (no result value)
+	OpBoundsCheck: {Name: "BoundsCheck", IsVoid: true},
+
 	// String — pure
 	OpStringLen: {Name: "StringLen", IsPure: true},
 	OpStringPtr: {Name: "StringPtr", IsPure: true},
@@ -222,6 +312,22 @@ func (o Op) IsPure() bool {
 	return false
 }
 
+// MayTrap returns true if this op can panic at run time.
+func (o Op) MayTrap() bool {
+	if o >= 0 && int(o) < len(opInfoTable) {
+		return opInfoTable[o].MayTrap
+	}
+	return false
+}
+
+// IsCommutative returns true if the two args of this op can be swapped.
+func (o Op) IsCommutative() bool {
+	if o >= 0 && int(o) < len(opInfoTable) {
+		return opInfoTable[o].IsCommutative
+	}
+	return false
+}
+
 // IsVoid returns true if this op produces no value.
 func (o Op) IsVoid() bool {
 	if o >= 0 && int(o) < len(opInfoTable) {
@@ -0,0 +1,125 @@
+package ssa
+
+import (
+	"go/constant"
+
+	"github.com/you-not-fish/yoru/internal/syntax"
+	"github.com/you-not-fish/yoru/internal/types"
+	"github.com/you-not-fish/yoru/internal/types2"
+)
+
+// Package is the SSA form of one package: its functions and its
+// package-level variables.
+type Package struct {
+	// Funcs lists the functions and methods with bodies, in source order.
+	Funcs []*Func
+
+	// Globals lists the package-level variables, in declaration order.
+	// Blank variables have no storage and are not listed.
+	Globals []*Global
+
+	// Init stores the non-constant initializers of Globals in
+	// initialization order. It is nil if no variable needs code to
+	// initialize it.
+	Init *Func
+}
+
+// Global is a package-level variable.
+type Global struct {
+	// Var is the variable object from the type checker.
+	Var *types.Var
+
+	// LinkName is the symbol name in the generated code (see GlobalLinkName).
+	LinkName string
+
+	// Const is the constant initializer, or nil if the variable starts
+	// as the zero value (possibly to be assigned by Package.Init).
+	Const constant.Value
+}
+
+// BuildPackage builds the SSA form of a package from its files, which must
+// have been checked together by types2.CheckFiles.
+func BuildPackage(files []*syntax.File, info *types2.Info, sizes *types.Sizes) *Package {
+	pkg := &Package{Funcs: BuildFiles(files, info, sizes)}
+
+	globals := make(map[*types.Var]*Global)
+	blanks := make(map[*types.Var]bool)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			vd, ok := decl.(*syntax.VarDecl)
+			if !ok {
+				continue
+			}
+			v, ok := info.Defs[vd.Name].(*types.Var)
+			if !ok || v.Type() == nil {
+				continue
+			}
+			if v.Name() == "_" {
+				blanks[v] = true
+				continue
+			}
+			g := &Global{Var: v, LinkName: GlobalLinkName(v)}
+			pkg.Globals = append(pkg.Globals, g)
+			globals[v] = g
+		}
+	}
+
+	// Constant initializers become static data; the rest are evaluated by
+	// the init function, in the order computed by the type checker.
+	var dynamic []*types2.Initializer
+	for _, init := range info.InitOrder {
+		if blanks[init.Lhs] {
+			// Evaluated only for its side effects.
+			if !info.Types[init.Rhs].IsConstant() {
+				dynamic = append(dynamic, init)
+			}
+			continue
+		}
+		g := globals[init.Lhs]
+		if g == nil {
+			continue // another package
+		}
+		// A constant assigned to an interface is boxed at run time.
+		if tv := info.Types[init.Rhs]; tv.IsConstant() && (tv.Value == nil || !types.IsInterface(init.Lhs.Type())) {
+			g.Const = tv.Value // nil for the nil constant: zero value
+			continue
+		}
+		dynamic = append(dynamic, init)
+	}
+	if len(dynamic) > 0 {
+		pkg.Init = buildInit(dynamic, info, sizes)
+	}
+	return pkg
+}
+
+// buildInit builds the function storing the given initializers into
+// their variables. The value of a blank variable is discarded.
+func buildInit(inits []*types2.Initializer, info *types2.Info, sizes *types.Sizes) *Func {
+	fn := NewFunc("init", types.NewFunc(nil, nil, nil))
+	fn.Sizes = sizes
+	fn.LinkName = InitLinkName(inits[0].Lhs.Pkg())
+
+	b := &builder{
+		info:  info,
+		sizes: sizes,
+		fn:    fn,
+		b:     fn.Entry,
+		vars:  make(map[types.Object]*Value),
+	}
+	for _, init := range inits {
+		val := b.expr(init.Rhs)
+		if b.b == nil {
+			break // initializer always panics
+		}
+		if init.Lhs.Name() == "_" {
+			continue
+		}
+		val = b.convert(val, init.Lhs.Type())
+		addr := b.global(init.Lhs)
+		b.fn.NewValue(b.b, OpStore, nil, addr, val)
+	}
+	if b.b != nil {
+		b.b.Kind = BlockReturn
+	}
+	return fn
+}
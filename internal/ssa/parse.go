@@ -0,0 +1,750 @@
+package ssa
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/you-not-fish/yoru/internal/syntax"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// Parse reads functions in the format written by Fprint and returns them
+// in input order. The functions may be preceded or followed by
+// declarations of the named types and package-level variables they use,
+// and of the functions they call but do not define, as written by
+// FprintDecls:
+//
+//	type Point struct{x int; y int}
+//	var origin Point
+//	func abs(x int) int
+//
+//	func dist(p *Point) int:
+//	  b0: (entry)
+//	    ...
+//
+// Types, variables and functions are resolved by name, and values and
+// blocks may be used before they are defined. A block header may omit its
+// "<- preds" list, in which case the predecessors are the blocks branching
+// to it, in block order. Lines starting with "//" are comments.
+//
+// Value and block IDs are kept, so printing a parsed function reproduces
+// its input. Void values, whose IDs are not printed, get fresh IDs.
+// Positions are not part of the format.
+func Parse(filename string, src io.Reader) (funcs []*Func, err error) {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		named:   make(map[string]*types.Named),
+		globals: make(map[string]*types.Var),
+		funcs:   make(map[string]*types.FuncObj),
+	}
+	for i, text := range strings.Split(string(data), "\n") {
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, "//") {
+			continue
+		}
+		p.lines = append(p.lines, line{no: i + 1, text: text})
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+			funcs, err = nil, fmt.Errorf("%s:%d: %s", filename, e.line, e.msg)
+		}
+	}()
+	return p.parse(), nil
+}
+
+// A line is a non-blank, non-comment line of the input.
+type line struct {
+	no   int // 1-based line number
+	text string
+}
+
+// A parseError aborts parsing with an error at a line.
+type parseError struct {
+	line int
+	msg  string
+}
+
+// A funcBody is a function definition: its object and the lines of its
+// blocks.
+type funcBody struct {
+	obj    *types.FuncObj
+	header line
+	lines  []line
+}
+
+// parser holds the state of Parse. The current line is scanned by the
+// token methods (ident, consume, ...) from position pos.
+type parser struct {
+	lines []line
+
+	named   map[string]*types.Named
+	globals map[string]*types.Var
+	funcs   map[string]*types.FuncObj // by funcName
+
+	line line // current line
+	pos  int  // scan position in line.text
+}
+
+func (p *parser) errorf(format string, args ...interface{}) {
+	panic(parseError{line: p.line.no, msg: fmt.Sprintf(format, args...)})
+}
+
+// parse parses the declarations, then the function bodies, so that
+// declarations may follow their uses.
+func (p *parser) parse() []*Func {
+	// Named types may refer to each other, so all are declared before
+	// any is resolved.
+	for _, l := range p.lines {
+		if p.setLine(l); p.keyword("type") {
+			name := p.ident()
+			if p.named[name] != nil {
+				p.errorf("type %s redeclared", name)
+			}
+			p.named[name] = types.NewNamed(types.NewTypeName(syntax.Pos{}, name, nil), nil)
+		}
+	}
+
+	var bodies []*funcBody
+	var body *funcBody // function whose blocks are being read, or nil
+	for _, l := range p.lines {
+		p.setLine(l)
+		switch {
+		case p.keyword("type"):
+			named := p.named[p.ident()]
+			u := p.parseType().Underlying()
+			if u == nil {
+				p.errorf("invalid underlying type for %s", named)
+			}
+			named.SetUnderlying(u)
+			p.expectEnd()
+			body = nil
+		case p.keyword("var"):
+			name := p.ident()
+			if p.globals[name] != nil {
+				p.errorf("var %s redeclared", name)
+			}
+			p.globals[name] = types.NewVar(syntax.Pos{}, name, p.parseType())
+			p.expectEnd()
+			body = nil
+		case p.keyword("func"):
+			obj, hasBody := p.funcHeader()
+			name := funcName(obj)
+			if p.funcs[name] != nil {
+				p.errorf("func %s redeclared", name)
+			}
+			p.funcs[name] = obj
+			body = nil
+			if hasBody {
+				body = &funcBody{obj: obj, header: l}
+				bodies = append(bodies, body)
+			}
+		default:
+			if body == nil {
+				p.errorf("unexpected %q outside a function body", l.text)
+			}
+			body.lines = append(body.lines, l)
+		}
+	}
+
+	funcs := make([]*Func, len(bodies))
+	for i, body := range bodies {
+		funcs[i] = p.parseFunc(body)
+	}
+	return funcs
+}
+
+// funcHeader parses the rest of a function header,
+//
+//	[(recv T)] name(params) [result][:]
+//
+// and reports whether it is followed by a body.
+func (p *parser) funcHeader() (obj *types.FuncObj, hasBody bool) {
+	var recv *types.Var
+	if p.consume("(") {
+		name := p.ident()
+		recv = types.NewVar(syntax.Pos{}, name, p.parseType())
+		p.expect(")")
+	}
+	obj = types.NewFuncObj(syntax.Pos{}, p.ident())
+	obj.SetSignature(p.signature(recv))
+	hasBody = p.consume(":")
+	p.expectEnd()
+
+	// Methods are added to their type, for interface calls.
+	if recv != nil {
+		base := recv.Type()
+		if ptr, ok := base.(*types.Pointer); ok {
+			base = ptr.Elem()
+		}
+		if named, ok := base.(*types.Named); ok {
+			named.AddMethod(obj)
+		}
+	}
+	return obj, hasBody
+}
+
+// signature parses the parameters and result of a function or interface
+// method,
+//
+//	(params) [result]
+//
+// where the result ends the line, or is followed by ":", ";" or "}".
+func (p *parser) signature(recv *types.Var) *types.Func {
+	p.expect("(")
+	var params []*types.Var
+	for !p.consume(")") {
+		if len(params) > 0 {
+			p.expect(",")
+		}
+		name := p.ident()
+		params = append(params, types.NewVar(syntax.Pos{}, name, p.parseType()))
+	}
+	var result types.Type
+	if !p.atEnd() && !p.peek(":") && !p.peek(";") && !p.peek("}") {
+		result = p.parseType()
+	}
+	return types.NewFunc(recv, params, result)
+}
+
+// funcParser holds the state of parsing one function body. Uses of values
+// and blocks are resolved once the whole body has been read.
+type funcParser struct {
+	*parser
+	fn *Func
+
+	values  map[ID]*Value
+	blocks  map[ID]*Block
+	defined map[*Block]bool
+	refLine map[*Block]int // line of the first reference to each block
+	uses    []use
+
+	// preds holds the predecessor lists of the block headers that have
+	// one, and the line to report a mismatch at.
+	preds    map[*Block][]*Block
+	predLine map[*Block]int
+}
+
+// A use is a reference to a value from an arg or a block control.
+type use struct {
+	line int
+	id   ID
+	v    *Value // using value, or nil for a control of b
+	b    *Block
+}
+
+// parseFunc builds the function obj from the lines of its body.
+func (p *parser) parseFunc(body *funcBody) *Func {
+	fp := &funcParser{
+		parser:   p,
+		fn:       &Func{Name: body.obj.Name(), LinkName: LinkName(body.obj), Sig: body.obj.Signature()},
+		values:   make(map[ID]*Value),
+		blocks:   make(map[ID]*Block),
+		defined:  make(map[*Block]bool),
+		refLine:  make(map[*Block]int),
+		preds:    make(map[*Block][]*Block),
+		predLine: make(map[*Block]int),
+	}
+	fn := fp.fn
+
+	var b *Block       // current block
+	terminated := true // whether b has its terminator
+	var void []*Value
+	for _, l := range body.lines {
+		p.setLine(l)
+		if id, ok := fp.blockHeader(); ok {
+			if !terminated {
+				p.errorf("%s has no terminator", b)
+			}
+			b, terminated = fp.block(id), false
+			if fp.defined[b] {
+				p.errorf("%s redefined", b)
+			}
+			fp.defined[b] = true
+			fn.Blocks = append(fn.Blocks, b)
+			continue
+		}
+		if b == nil {
+			p.errorf("%q outside a block", l.text)
+		}
+		if terminated {
+			p.errorf("%q after the terminator of %s", l.text, b)
+		}
+		if fp.terminator(b) {
+			terminated = true
+			continue
+		}
+		if v := fp.value(b); v.Op.IsVoid() {
+			void = append(void, v)
+		}
+	}
+	if b == nil {
+		p.setLine(body.header)
+		p.errorf("func %s has no blocks", fn.Name)
+	}
+	if !terminated {
+		p.errorf("%s has no terminator", b)
+	}
+	fn.Entry = fn.Blocks[0]
+	var undefined *Block
+	for _, b := range fp.blocks {
+		if !fp.defined[b] && (undefined == nil || fp.refLine[b] < fp.refLine[undefined]) {
+			undefined = b
+		}
+	}
+	if undefined != nil {
+		p.line.no = fp.refLine[undefined]
+		p.errorf("undefined block %s", undefined)
+	}
+
+	for _, u := range fp.uses {
+		arg := fp.values[u.id]
+		if arg == nil {
+			p.line.no = u.line
+			p.errorf("undefined value v%d", u.id)
+		}
+		if u.v != nil {
+			u.v.AddArg(arg)
+		} else {
+			u.b.AddControl(arg)
+		}
+	}
+	fp.linkPreds()
+
+	// IDs continue after the largest printed one.
+	for id := range fp.values {
+		fn.nextValueID = max(fn.nextValueID, id+1)
+	}
+	for _, v := range void {
+		v.ID = fn.nextValueID
+		fn.nextValueID++
+	}
+	for id := range fp.blocks {
+		fn.nextBlockID = max(fn.nextBlockID, id+1)
+	}
+	return fn
+}
+
+// blockHeader parses a block header, "bN: [(entry)] [<- preds]", and
+// returns the block ID. It reports false if the line is not a header.
+func (fp *funcParser) blockHeader() (ID, bool) {
+	text := fp.line.text
+	i := strings.IndexByte(text, ':')
+	if i < 0 {
+		return 0, false
+	}
+	id, ok := parseRef(text[:i], 'b')
+	if !ok {
+		return 0, false
+	}
+	fp.pos = i + 1
+	b := fp.block(id)
+	if fp.consume("(entry)") && len(fp.fn.Blocks) > 0 {
+		fp.errorf("entry block %s is not the first block", b)
+	}
+	if fp.consume("<-") {
+		preds := []*Block{}
+		for !fp.atEnd() {
+			preds = append(preds, fp.blockRef())
+		}
+		fp.preds[b] = preds
+		fp.predLine[b] = fp.line.no
+	}
+	fp.expectEnd()
+	return id, true
+}
+
+// terminator parses a block terminator into b, reporting false if the
+// line is not one:
+//
+//	Plain [-> bN]
+//	If vN -> bT bF
+//	Return [vN]
+//	Exit
+func (fp *funcParser) terminator(b *Block) bool {
+	switch {
+	case fp.keyword("Plain"):
+		b.Kind = BlockPlain
+		if fp.consume("->") {
+			b.Succs = append(b.Succs, fp.blockRef())
+		}
+	case fp.keyword("If"):
+		b.Kind = BlockIf
+		fp.control(b)
+		fp.expect("->")
+		b.Succs = append(b.Succs, fp.blockRef(), fp.blockRef())
+	case fp.keyword("Return"):
+		b.Kind = BlockReturn
+		if !fp.atEnd() {
+			fp.control(b)
+		}
+	case fp.keyword("Exit"):
+		b.Kind = BlockExit
+	default:
+		return false
+	}
+	fp.expectEnd()
+	return true
+}
+
+// control parses a control value of b.
+func (fp *funcParser) control(b *Block) {
+	fp.uses = append(fp.uses, use{line: fp.line.no, id: fp.valueRef(), b: b})
+}
+
+// value parses a value into b:
+//
+//	[vN =] Op [<type>] [[auxint]] [{aux}] args...
+//
+// Void ops have no "vN =".
+func (fp *funcParser) value(b *Block) *Value {
+	name := fp.ident()
+	id := ID(-1)
+	if fp.consume("=") {
+		var ok bool
+		if id, ok = parseRef(name, 'v'); !ok {
+			fp.errorf("expected value, found %q", name)
+		}
+		name = fp.ident()
+	}
+	op := lookupOp(name)
+	if op == OpInvalid {
+		fp.errorf("unknown op %s", name)
+	}
+	if op.IsVoid() != (id < 0) {
+		if op.IsVoid() {
+			fp.errorf("%s has no result", op)
+		}
+		fp.errorf("missing result of %s", op)
+	}
+	v := &Value{ID: id, Op: op, Block: b}
+	if id >= 0 {
+		if fp.values[id] != nil {
+			fp.errorf("v%d redefined", id)
+		}
+		fp.values[id] = v
+	}
+	b.Values = append(b.Values, v)
+
+	if fp.consume("<") {
+		v.Type = fp.parseType()
+		fp.expect(">")
+	}
+	if fp.consume("[") {
+		text := fp.until(']')
+		var err error
+		if op == OpConstFloat {
+			v.AuxFloat, err = strconv.ParseFloat(text, 64)
+		} else {
+			v.AuxInt, err = strconv.ParseInt(text, 10, 64)
+		}
+		if err != nil {
+			fp.errorf("invalid aux value [%s] of %s", text, op)
+		}
+		fp.expect("]")
+	}
+	if fp.consume("{") {
+		v.Aux = fp.aux(op)
+		fp.expect("}")
+	}
+	for !fp.atEnd() {
+		fp.uses = append(fp.uses, use{line: fp.line.no, id: fp.valueRef(), v: v})
+	}
+	return v
+}
+
+// aux parses the text between the braces of an aux value of op.
+func (fp *funcParser) aux(op Op) interface{} {
+	switch op {
+	case OpConstString:
+		rest := fp.line.text[fp.pos:]
+		q, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			fp.errorf("invalid string constant %s", rest)
+		}
+		fp.pos += len(q)
+		s, _ := strconv.Unquote(q)
+		return s
+	case OpNewAlloc, OpMakeInterface, OpTypeAssert, OpTypeTest, OpNewSlice, OpGrowSlice, OpMakeMap:
+		return fp.parseType()
+	case OpInterfaceCall:
+		// The method is resolved by name on the dynamic type.
+		return types.NewFuncObj(syntax.Pos{}, fp.ident())
+	case OpStaticCall:
+		name := fp.ident()
+		obj := fp.funcs[name]
+		if obj == nil {
+			fp.errorf("undefined func %s", name)
+		}
+		return obj
+	case OpGlobal:
+		name := fp.ident()
+		obj := fp.globals[name]
+		if obj == nil {
+			fp.errorf("undefined var %s", name)
+		}
+		return obj
+	}
+	return fp.until('}')
+}
+
+// linkPreds sets the predecessors of each block, checking the listed ones
+// against the successors of the other blocks.
+func (fp *funcParser) linkPreds() {
+	branches := make(map[*Block][]*Block)
+	for _, b := range fp.fn.Blocks {
+		for _, s := range b.Succs {
+			branches[s] = append(branches[s], b)
+		}
+	}
+	for _, b := range fp.fn.Blocks {
+		b.Preds = branches[b]
+		listed, ok := fp.preds[b]
+		if !ok {
+			continue
+		}
+		if !samePreds(listed, b.Preds) {
+			fp.line.no = fp.predLine[b]
+			fp.errorf("predecessors of %s do not match the blocks branching to it", b)
+		}
+		b.Preds = listed
+	}
+}
+
+// samePreds reports whether x and y hold the same blocks, in any order.
+func samePreds(x, y []*Block) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	count := make(map[*Block]int)
+	for _, b := range x {
+		count[b]++
+	}
+	for _, b := range y {
+		if count[b] == 0 {
+			return false
+		}
+		count[b]--
+	}
+	return true
+}
+
+// block returns the block with the given ID, creating it on first use.
+func (fp *funcParser) block(id ID) *Block {
+	b := fp.blocks[id]
+	if b == nil {
+		b = &Block{ID: id, Func: fp.fn}
+		fp.blocks[id] = b
+		fp.refLine[b] = fp.line.no
+	}
+	return b
+}
+
+// blockRef parses a block reference, "bN".
+func (fp *funcParser) blockRef() *Block {
+	name := fp.ident()
+	id, ok := parseRef(name, 'b')
+	if !ok {
+		fp.errorf("expected block, found %q", name)
+	}
+	return fp.block(id)
+}
+
+// valueRef parses a value reference, "vN".
+func (fp *funcParser) valueRef() ID {
+	name := fp.ident()
+	id, ok := parseRef(name, 'v')
+	if !ok {
+		fp.errorf("expected value, found %q", name)
+	}
+	return id
+}
+
+// parseRef parses a value or block name, such as "v12" for prefix 'v'.
+func parseRef(name string, prefix byte) (ID, bool) {
+	if len(name) < 2 || name[0] != prefix {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(name[1:], 10, 31)
+	if err != nil {
+		return 0, false
+	}
+	return ID(n), true
+}
+
+// lookupOp returns the op with the given name, or OpInvalid.
+func lookupOp(name string) Op {
+	for op := OpInvalid + 1; op < opCount; op++ {
+		if opInfoTable[op].Name == name {
+			return op
+		}
+	}
+	return OpInvalid
+}
+
+// parseType parses a type as printed by types.Type.String:
+//
+//	int  *T  ref T  [N]T  []T  struct{f T; g U}  Name
+func (p *parser) parseType() types.Type {
+	switch {
+	case p.consume("*"):
+		return types.NewPointer(p.parseType())
+	case p.consume("[]"):
+		return types.NewSlice(p.parseType())
+	case p.consume("["):
+		n, err := strconv.ParseInt(p.until(']'), 10, 64)
+		if err != nil || n < 0 {
+			p.errorf("invalid array length")
+		}
+		p.expect("]")
+		return types.NewArray(n, p.parseType())
+	case p.consume("("):
+		var elems []types.Type
+		for !p.consume(")") {
+			if len(elems) > 0 {
+				p.expect(",")
+			}
+			elems = append(elems, p.parseType())
+		}
+		return types.NewTuple(elems...)
+	}
+
+	name := p.ident()
+	switch name {
+	case "ref":
+		return types.NewRef(p.parseType())
+	case "map":
+		p.expect("[")
+		key := p.parseType()
+		p.expect("]")
+		return types.NewMap(key, p.parseType())
+	case "struct":
+		p.expect("{")
+		var fields []*types.Var
+		for !p.consume("}") {
+			if len(fields) > 0 {
+				p.expect(";")
+			}
+			fname := p.ident()
+			fields = append(fields, types.NewField(syntax.Pos{}, fname, p.parseType()))
+		}
+		return types.NewStruct(fields)
+	case "interface":
+		p.expect("{")
+		var methods []*types.FuncObj
+		for !p.consume("}") {
+			if len(methods) > 0 {
+				p.expect(";")
+			}
+			m := types.NewFuncObj(syntax.Pos{}, p.ident())
+			m.SetSignature(p.signature(nil))
+			methods = append(methods, m)
+		}
+		return types.NewInterface(methods)
+	case "untyped":
+		name += " " + p.ident()
+	}
+	for _, t := range types.Typ {
+		if t != nil && t.String() == name {
+			return t
+		}
+	}
+	if t := p.named[name]; t != nil {
+		return t
+	}
+	p.errorf("undefined type %s", name)
+	return nil
+}
+
+// setLine makes l the current line.
+func (p *parser) setLine(l line) {
+	p.line, p.pos = l, 0
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.line.text) && p.line.text[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// atEnd reports whether the rest of the line is blank.
+func (p *parser) atEnd() bool {
+	p.skipSpace()
+	return p.pos == len(p.line.text)
+}
+
+// peek reports whether the rest of the line starts with tok.
+func (p *parser) peek(tok string) bool {
+	p.skipSpace()
+	return strings.HasPrefix(p.line.text[p.pos:], tok)
+}
+
+// consume skips tok if the rest of the line starts with it.
+func (p *parser) consume(tok string) bool {
+	if !p.peek(tok) {
+		return false
+	}
+	p.pos += len(tok)
+	return true
+}
+
+// keyword skips the word kw, reporting whether it is next.
+func (p *parser) keyword(kw string) bool {
+	start := p.pos
+	if p.consume(kw) && (p.pos == len(p.line.text) || !isNameByte(p.line.text[p.pos])) {
+		return true
+	}
+	p.pos = start
+	return false
+}
+
+func (p *parser) expect(tok string) {
+	if !p.consume(tok) {
+		p.errorf("expected %q, found %q", tok, p.line.text[p.pos:])
+	}
+}
+
+func (p *parser) expectEnd() {
+	if !p.atEnd() {
+		p.errorf("unexpected %q", p.line.text[p.pos:])
+	}
+}
+
+// ident parses a name. Names may contain dots and dollar signs, as in
+// "Point.Move" or "init$".
+func (p *parser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.line.text) && isNameByte(p.line.text[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.errorf("expected name, found %q", p.line.text[p.pos:])
+	}
+	return p.line.text[start:p.pos]
+}
+
+// until returns the text up to the next c, which is not consumed.
+func (p *parser) until(c byte) string {
+	rest := p.line.text[p.pos:]
+	i := strings.IndexByte(rest, c)
+	if i < 0 {
+		p.errorf("missing %q", c)
+	}
+	p.pos += i
+	return rest[:i]
+}
+
+func isNameByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '_' || c == '.' || c == '$'
+}
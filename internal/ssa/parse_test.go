@@ -0,0 +1,339 @@
+package ssa
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// TestParseRoundTrip checks that parsing the printed declarations and SSA
+// of built functions and printing them again reproduces the text, with the
+// same use counts.
+func TestParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		decls string // declarations written by FprintDecls
+		src   string
+	}{
+		{
+			name: "control flow",
+			src: `package main
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+func main() {
+	var s int = 0
+	var i int = 0
+	for i < 10 && s >= 0 {
+		s = s + fib(i) / 3 << 1
+		i = i + 1
+	}
+	println(s)
+}
+`,
+		},
+		{
+			name: "constants",
+			src: `package main
+func main() {
+	var f float = 0.1 + 1e300
+	var s string = "a \"quoted\"} {string}\n"
+	var b bool = true
+	println(f / 3.0, s, b)
+	panic("done")
+}
+`,
+		},
+		{
+			name:  "types",
+			decls: "type Point struct{x int; y int}\ntype Node struct{val int; next ref Node}\nvar origin Point\n",
+			src: `package main
+type Point struct {
+	x int
+	y int
+}
+type Node struct {
+	val  int
+	next ref Node
+}
+var origin Point
+func (p *Point) Move(dx int) {
+	p.x = p.x + dx
+}
+func (p Point) Sum() int {
+	return p.x + p.y
+}
+func push(n ref Node, v int) ref Node {
+	m := new(Node)
+	m.val = v
+	m.next = n
+	return m
+}
+func main() {
+	var a [4]Point
+	var i int = 2
+	a[i].Move(3)
+	origin.Move(a[i].Sum())
+	var n ref Node = push(nil, 1)
+	println(n.next == nil, origin.x)
+}
+`,
+		},
+		{
+			name: "multiple results",
+			src: `package main
+func divmod(a int, b int) (int, int) {
+	return a / b, a % b
+}
+func forward(a int) (int, int) {
+	return divmod(a, 3)
+}
+func main() {
+	q, r := forward(7)
+	q, r = r, q
+	_, r = divmod(q, 2)
+	println(q, r)
+}
+`,
+		},
+		{
+			name:  "interfaces",
+			decls: "type Rect struct{w int; h int}\ntype Shape interface{Area() int; Scale(k int)}\n",
+			src: `package main
+type Shape interface {
+	Area() int
+	Scale(k int)
+}
+type Rect struct {
+	w int
+	h int
+}
+func (r *Rect) Area() int {
+	return r.w * r.h
+}
+func (r *Rect) Scale(k int) {
+	r.w = r.w * k
+}
+func use(s Shape) int {
+	if s == nil {
+		return 0
+	}
+	s.Scale(2)
+	return s.Area()
+}
+func main() {
+	var r ref Rect = new(Rect)
+	println(use(r), use(nil))
+}
+`,
+		},
+		{
+			name:  "type assertions",
+			decls: "type Rect struct{w int}\ntype Shape interface{Area() int}\n",
+			src: `package main
+type Shape interface {
+	Area() int
+}
+type Rect struct {
+	w int
+}
+func (r Rect) Area() int {
+	return r.w
+}
+func kind(s Shape) int {
+	switch v := s.(type) {
+	case Rect:
+		return v.w
+	case nil:
+		return -1
+	}
+	r, ok := s.(ref Rect)
+	if ok {
+		return r.w
+	}
+	return s.(Rect).w
+}
+`,
+		},
+		{
+			name: "slices",
+			src: `package main
+func f(n int) int {
+	var arr [4]int
+	s := make([]int, n, 8)
+	s = append(s, arr[1:3][0])
+	t := s[1:]
+	copy(t, arr[:])
+	if s == nil {
+		return 0
+	}
+	return len(t) + cap(s) + t[0]
+}
+`,
+		},
+		{
+			name: "maps",
+			src: `package main
+func f(k string) int {
+	m := make(map[string]int, 4)
+	m[k] = 1
+	v, ok := m["x"]
+	delete(m, k)
+	if ok && m != nil {
+		return v
+	}
+	return len(m) + m[k]
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			funcs := buildFromSource(t, tt.src)
+			var texts []string
+			for _, fn := range funcs {
+				texts = append(texts, Sprint(fn))
+			}
+			want := strings.Join(texts, "\n")
+			var decls strings.Builder
+			FprintDecls(&decls, funcs)
+			if tt.decls != "" {
+				tt.decls += "\n"
+			}
+			if decls.String() != tt.decls {
+				t.Errorf("FprintDecls:\n%s\nwant:\n%s", decls.String(), tt.decls)
+			}
+			parsed, err := Parse("test.ssa", strings.NewReader(decls.String()+want))
+			if err != nil {
+				t.Fatalf("Parse: %v\ninput:\n%s%s", err, decls.String(), want)
+			}
+			var redecls strings.Builder
+			FprintDecls(&redecls, parsed)
+			if redecls.String() != decls.String() {
+				t.Errorf("FprintDecls after Parse:\n%s\nwant:\n%s", redecls.String(), decls.String())
+			}
+			if len(parsed) != len(funcs) {
+				t.Fatalf("Parse returned %d funcs, want %d", len(parsed), len(funcs))
+			}
+			for i, got := range parsed {
+				fn := funcs[i]
+				if err := Verify(got); err != nil {
+					t.Errorf("Verify(%s) after Parse: %v", got.Name, err)
+				}
+				if s := Sprint(got); s != texts[i] {
+					t.Errorf("round trip mismatch\ngot:\n%s\nwant:\n%s", s, texts[i])
+				}
+				if got.LinkName != fn.LinkName {
+					t.Errorf("LinkName = %q, want %q", got.LinkName, fn.LinkName)
+				}
+				uses := make(map[ID]int32)
+				for _, b := range fn.Blocks {
+					for _, v := range b.Values {
+						uses[v.ID] = v.Uses
+					}
+				}
+				for _, b := range got.Blocks {
+					for _, v := range b.Values {
+						if !v.Op.IsVoid() && v.Uses != uses[v.ID] {
+							t.Errorf("%s: %s.Uses = %d, want %d", got.Name, v, v.Uses, uses[v.ID])
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseForwardRefs(t *testing.T) {
+	src := `
+// A loop written by hand: v5 and b2 are used before they are defined,
+// and b2 and b3 leave their predecessors to the parser.
+func count(n int) int:
+  b0: (entry)
+    v0 = Arg <int> {n}
+    v1 = Const64 <int> [0]
+    Plain -> b1
+  b1: <- b2 b0
+    v2 = Phi <int> v5 v1
+    v3 = Lt64 <bool> v2 v0
+    If v3 -> b2 b3
+  b2:
+    v4 = Const64 <int> [1]
+    v5 = Add64 <int> v2 v4
+    v6 = StaticCall <bool> {check} v5
+    Plain -> b1
+  b3:
+    Return v2
+
+func check(n int) bool
+`
+	funcs, err := Parse("test.ssa", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(funcs) != 1 {
+		t.Fatalf("Parse returned %d funcs, want 1", len(funcs))
+	}
+	f := funcs[0]
+	if err := Verify(f); err != nil {
+		t.Fatalf("Verify: %v\nSSA:\n%s", err, Sprint(f))
+	}
+
+	b0, b1, b2, b3 := f.Blocks[0], f.Blocks[1], f.Blocks[2], f.Blocks[3]
+	if f.Entry != b0 || len(b1.Preds) != 2 || b1.Preds[0] != b2 || b1.Preds[1] != b0 {
+		t.Errorf("preds of b1 = %v, want [b2 b0]", b1.Preds)
+	}
+	if len(b2.Preds) != 1 || b2.Preds[0] != b1 || len(b3.Preds) != 1 || b3.Preds[0] != b1 {
+		t.Errorf("preds of b2, b3 = %v, %v, want [b1], [b1]", b2.Preds, b3.Preds)
+	}
+	phi := b1.Values[0]
+	if phi.Args[0] != b2.Values[1] || phi.Args[1] != b0.Values[1] {
+		t.Errorf("phi args = %v, want [v5 v1]", phi.Args)
+	}
+	if phi.Uses != 3 {
+		t.Errorf("phi.Uses = %d, want 3", phi.Uses)
+	}
+	call := b2.Values[2]
+	if obj, ok := call.Aux.(*types.FuncObj); !ok || obj.Name() != "check" || obj.Signature().NumParams() != 1 {
+		t.Errorf("call Aux = %v, want func check", call.Aux)
+	}
+
+	// New values get fresh IDs.
+	v := f.NewValue(b3, OpConst64, types.Typ[types.Int])
+	b := f.NewBlock(BlockExit)
+	if v.ID != 7 || b.ID != 4 {
+		t.Errorf("new IDs = %s, %s, want v7, b4", v, b)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"v0 = Const64 <int> [1]", "test.ssa:1: unexpected \"v0 = Const64 <int> [1]\" outside a function body"},
+		{"func f():\n  b0:\n    Return\n  b1:\n    Plain -> b2", "test.ssa:5: undefined block b2"},
+		{"func f() int:\n  b0:\n    Return v3", "test.ssa:3: undefined value v3"},
+		{"func f():\n  b0:\n    v0 = Frob <int>\n    Return", "test.ssa:3: unknown op Frob"},
+		{"func f():\n  b0:\n    v0 = Alloca <*Point>\n    Return", "test.ssa:3: undefined type Point"},
+		{"func f():\n  b0:\n    v0 = StaticCall {g}\n    Return", "test.ssa:3: undefined func g"},
+		{"func f():\n  b0:\n    v0 = Store v1 v2\n    Return", "test.ssa:3: Store has no result"},
+		{"func f():\n  b0:\n    v0 = Const64 <int> [1]\n    v0 = Const64 <int> [2]\n    Return", "test.ssa:4: v0 redefined"},
+		{"func f():\n  b0:\n    v0 = Const64 <int> [1]\n  b1:\n    Return", "test.ssa:4: b0 has no terminator"},
+		{"func f():\n  b0:\n    Return\n    Exit", "test.ssa:4: \"Exit\" after the terminator of b0"},
+		{"func f():\n  b0:\n    Plain -> b1\n  b1: <- b0 b0\n    Return", "test.ssa:4: predecessors of b1 do not match the blocks branching to it"},
+		{"func f():\n  b0:\n    v0 = ConstString <string> {hi}\n    Return", "test.ssa:3: invalid string constant hi}"},
+		{"func f()\nfunc f():\n  b0:\n    Return", "test.ssa:2: func f redeclared"},
+		{"func f():", "test.ssa:1: func f has no blocks"},
+	}
+	for _, tt := range tests {
+		_, err := Parse("test.ssa", strings.NewReader(tt.src))
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("Parse(%q) error = %v, want %s", tt.src, err, tt.err)
+		}
+	}
+}
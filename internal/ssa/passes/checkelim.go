@@ -0,0 +1,191 @@
+package passes
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+)
+
+// CheckElim removes nil and bounds checks that cannot fail.
+//
+// A nil check is removed if its pointer was returned by new(), or was
+// checked by another nil check that dominates it: execution only gets past
+// that check if the pointer is not nil.
+//
+// A bounds check of i against a constant length is removed if the check
+// is dominated by the body of a loop whose header tests i < n or i <= n,
+// with n small enough, and i is an induction variable of that loop that
+// starts at a non-negative constant and only grows by non-negative
+// constant steps.
+func CheckElim(f *ssa.Func) {
+	elimChecks(f, nil)
+}
+
+// CheckElimReport returns a CheckElim pass that calls report for each check
+// it removes, with the reason it cannot fail.
+func CheckElimReport(report func(check *ssa.Value, reason string)) func(*ssa.Func) {
+	return func(f *ssa.Func) {
+		elimChecks(f, report)
+	}
+}
+
+func elimChecks(f *ssa.Func, report func(check *ssa.Value, reason string)) {
+	ssa.ComputeDom(f)
+	var ln *ssa.LoopNest // computed on the first bounds check
+
+	// nonNil maps the pointers known not to be nil in the current block to
+	// the value proving it, a nil check or a new().
+	nonNil := make(map[*ssa.Value]*ssa.Value)
+	dead := make(map[*ssa.Value]bool)
+
+	var visit func(b *ssa.Block)
+	visit = func(b *ssa.Block) {
+		var added []*ssa.Value
+		for _, v := range b.Values {
+			var reason string
+			switch v.Op {
+			case ssa.OpNewAlloc:
+				nonNil[v] = v
+				added = append(added, v)
+				continue
+			case ssa.OpNilCheck:
+				p := v.Args[0]
+				proof, ok := nonNil[p]
+				if !ok {
+					nonNil[p] = v
+					added = append(added, p)
+					continue
+				}
+				if proof.Op == ssa.OpNewAlloc {
+					reason = "pointer comes from new()"
+				} else {
+					reason = "pointer checked by " + describeNilCheck(proof)
+				}
+			case ssa.OpBoundsCheck:
+				if ln == nil {
+					ln = ssa.ComputeLoopNest(f)
+				}
+				reason = provenInBounds(ln, v)
+				if reason == "" {
+					continue
+				}
+			default:
+				continue
+			}
+			dead[v] = true
+			if report != nil {
+				report(v, reason)
+			}
+		}
+
+		for _, c := range b.Dominees {
+			visit(c)
+		}
+
+		// Facts established in b only hold in its dominator subtree.
+		for _, p := range added {
+			delete(nonNil, p)
+		}
+	}
+	visit(f.Entry)
+
+	if len(dead) == 0 {
+		return
+	}
+	for _, b := range f.Blocks {
+		live := b.Values[:0]
+		for _, v := range b.Values {
+			if dead[v] {
+				dropValue(v)
+				continue
+			}
+			live = append(live, v)
+		}
+		b.Values = live
+	}
+}
+
+// describeNilCheck returns a description of the nil check v for reports.
+func describeNilCheck(v *ssa.Value) string {
+	if v.Pos.IsValid() {
+		return fmt.Sprintf("nil check at %s", v.Pos)
+	}
+	return "nil check " + v.String()
+}
+
+// provenInBounds returns the reason the bounds check v cannot fail, or ""
+// if it may fail. The index must be a phi in the header of a loop that is
+// only entered while index < n, where n does not exceed the length, and
+// whose back edges only add non-negative constants to it.
+func provenInBounds(ln *ssa.LoopNest, v *ssa.Value) string {
+	idx, length := v.Args[0], v.Args[1]
+	if idx.Op != ssa.OpPhi || length.Op != ssa.OpConst64 {
+		return ""
+	}
+	h := idx.Block
+	l := ln.Loop(h)
+	if l == nil || l.Header != h || h.Kind != ssa.BlockIf || len(h.Controls) != 1 {
+		return ""
+	}
+
+	// The header must branch into the loop on the condition and out of it
+	// otherwise, and the check must only run once the condition holds.
+	cond := h.Controls[0]
+	body := h.Succs[0]
+	if !l.Contains(body) || l.Contains(h.Succs[1]) || len(body.Preds) != 1 || !ssa.Dominates(body, v.Block) {
+		return ""
+	}
+	if len(cond.Args) != 2 || cond.Args[0] != idx || cond.Args[1].Op != ssa.OpConst64 {
+		return ""
+	}
+	n := cond.Args[1].AuxInt
+	switch cond.Op {
+	case ssa.OpLt64:
+	case ssa.OpLeq64:
+		// idx <= n is idx < n+1.
+		if n == math.MaxInt64 {
+			return ""
+		}
+		n++
+	default:
+		return ""
+	}
+	if n > length.AuxInt {
+		return ""
+	}
+
+	// On entry idx is a non-negative constant; around the loop it is at
+	// most n-1 plus a step that must not overflow.
+	for i, arg := range idx.Args {
+		if !l.Contains(h.Preds[i]) {
+			if arg.Op != ssa.OpConst64 || arg.AuxInt < 0 {
+				return ""
+			}
+			continue
+		}
+		if arg == idx {
+			continue
+		}
+		step := inductionStep(idx, arg)
+		if step < 0 || step > math.MaxInt64-n {
+			return ""
+		}
+	}
+	return fmt.Sprintf("index in [0, %d) by loop condition", n)
+}
+
+// inductionStep returns c if v is idx + c for a constant c, or -1.
+func inductionStep(idx, v *ssa.Value) int64 {
+	if v.Op != ssa.OpAdd64 {
+		return -1
+	}
+	x, c := v.Args[0], v.Args[1]
+	if x != idx {
+		x, c = c, x
+	}
+	if x != idx || c.Op != ssa.OpConst64 {
+		return -1
+	}
+	return c.AuxInt
+}
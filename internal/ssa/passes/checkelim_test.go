@@ -0,0 +1,58 @@
+package passes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+)
+
+// TestCheckElimGolden compares the SSA before and after checkelim, run
+// after mem2reg, with the golden files in testdata/checkelim.
+func TestCheckElimGolden(t *testing.T) {
+	runPassGolden(t, Pass{Name: "checkelim", Fn: CheckElim})
+}
+
+// TestCheckElimReport checks that every removed check is reported with
+// its position and reason.
+func TestCheckElimReport(t *testing.T) {
+	src := `package main
+type Rect struct {
+	width int
+}
+func f(p ref Rect) int {
+	var a [4]int
+	var i int = 0
+	for i < 4 {
+		a[i] = p.width
+		p.width = i
+		i = i + 1
+	}
+	return a[0]
+}
+`
+	funcs := buildAndRun(t, src)
+	fn := getFunc(t, funcs, "f")
+	var reports []string
+	report := func(check *ssa.Value, reason string) {
+		reports = append(reports, check.Pos.String()+" "+check.Op.String()+": "+reason)
+	}
+	if err := Run(fn, []Pass{{Name: "checkelim", Fn: CheckElimReport(report)}}, Config{Verify: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkUses(t, fn)
+
+	want := []string{
+		"test.yoru:9:5 BoundsCheck: index in [0, 4) by loop condition",
+		"test.yoru:10:3 NilCheck: pointer checked by nil check at test.yoru:9:10",
+	}
+	if got := strings.Join(reports, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("reports:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+	if n := countOp(fn, ssa.OpNilCheck); n != 1 {
+		t.Errorf("%d nil checks left, want 1", n)
+	}
+	if n := countOp(fn, ssa.OpBoundsCheck); n != 0 {
+		t.Errorf("%d bounds checks left, want 0", n)
+	}
+}
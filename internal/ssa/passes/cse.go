@@ -0,0 +1,136 @@
+package passes
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// CSE eliminates common subexpressions using dominator-based global value
+// numbering. The dominator tree is walked in preorder with a scoped table
+// of the pure values available at each block; a pure value equal to one
+// in the table (same Op, Type, Aux, AuxInt, AuxFloat and args) is replaced
+// by it. Impure values — calls, memory operations, prints, panics,
+// allocations and checks — are never merged.
+func CSE(f *ssa.Func) {
+	ssa.ComputeDom(f)
+
+	table := make(map[cseKey][]*ssa.Value)
+	dead := make(map[*ssa.Value]bool)
+
+	var visit func(b *ssa.Block)
+	visit = func(b *ssa.Block) {
+		var pushed []cseKey
+		for _, v := range b.Values {
+			if !v.IsPure() {
+				continue
+			}
+			k := makeCSEKey(v)
+			if w := lookupCSE(table[k], v); w != nil {
+				f.ReplaceUses(v, w)
+				dead[v] = true
+				continue
+			}
+			table[k] = append(table[k], v)
+			pushed = append(pushed, k)
+		}
+
+		for _, c := range b.Dominees {
+			visit(c)
+		}
+
+		// Values of b are not available outside its dominator subtree.
+		for i := len(pushed) - 1; i >= 0; i-- {
+			k := pushed[i]
+			table[k] = table[k][:len(table[k])-1]
+		}
+	}
+	visit(f.Entry)
+
+	if len(dead) == 0 {
+		return
+	}
+	for _, b := range f.Blocks {
+		var live []*ssa.Value
+		for _, v := range b.Values {
+			if dead[v] {
+				for _, arg := range v.Args {
+					arg.Uses--
+				}
+				continue
+			}
+			live = append(live, v)
+		}
+		b.Values = live
+	}
+}
+
+// cseKey is the hash key of a pure value. Values with equal keys are
+// candidates for merging; types and Aux are compared separately.
+type cseKey struct {
+	op       ssa.Op
+	auxInt   int64
+	auxFloat uint64
+	args     string
+	// block is set for phis, whose args are only meaningful relative to
+	// the predecessors of their own block.
+	block *ssa.Block
+}
+
+// makeCSEKey returns the hash key of v. The args of commutative ops are
+// ordered by ID so that x+y and y+x get the same key.
+func makeCSEKey(v *ssa.Value) cseKey {
+	k := cseKey{
+		op:       v.Op,
+		auxInt:   v.AuxInt,
+		auxFloat: math.Float64bits(v.AuxFloat),
+	}
+	if v.Op == ssa.OpPhi {
+		k.block = v.Block
+	}
+	args := v.Args
+	if v.Op.IsCommutative() && len(args) == 2 && args[1].ID < args[0].ID {
+		args = []*ssa.Value{args[1], args[0]}
+	}
+	var sb strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(strconv.Itoa(int(arg.ID)))
+	}
+	k.args = sb.String()
+	return k
+}
+
+// lookupCSE returns the value among candidates equivalent to v, or nil.
+func lookupCSE(candidates []*ssa.Value, v *ssa.Value) *ssa.Value {
+	for _, w := range candidates {
+		if sameType(w.Type, v.Type) && sameAux(w.Aux, v.Aux) {
+			return w
+		}
+	}
+	return nil
+}
+
+// sameType reports whether x and y are identical types. Distinct *T values
+// are created for the same pointer type, so types are compared
+// structurally.
+func sameType(x, y types.Type) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return types.Identical(x, y)
+}
+
+// sameAux reports whether two Aux values are equal.
+func sameAux(x, y interface{}) bool {
+	if tx, ok := x.(types.Type); ok {
+		ty, ok := y.(types.Type)
+		return ok && types.Identical(tx, ty)
+	}
+	return x == y
+}
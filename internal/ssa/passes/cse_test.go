@@ -0,0 +1,118 @@
+package passes
+
+import (
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// TestCSEGolden compares the SSA before and after cse, run after mem2reg,
+// with the golden files in testdata/cse.
+func TestCSEGolden(t *testing.T) {
+	runPassGolden(t, Pass{Name: "cse", Fn: CSE})
+}
+
+// TestCSEKeepsEffects checks that identical impure values are not merged.
+func TestCSEKeepsEffects(t *testing.T) {
+	src := `package main
+type Node struct {
+	val int
+}
+func h(x int) int {
+	return x
+}
+func f(p ref Node, x int) int {
+	var a int = h(x) + h(x)
+	var b int = p.val + p.val
+	var n ref Node = new(Node)
+	var m ref Node = new(Node)
+	println(x)
+	println(x)
+	if n == m {
+		panic("same")
+	}
+	return a + b
+}
+`
+	funcs := buildAndRun(t, src)
+	fn := getFunc(t, funcs, "f")
+	want := map[ssa.Op]int{
+		ssa.OpStaticCall: countOp(fn, ssa.OpStaticCall),
+		ssa.OpLoad:       countOp(fn, ssa.OpLoad),
+		ssa.OpNilCheck:   countOp(fn, ssa.OpNilCheck),
+		ssa.OpNewAlloc:   countOp(fn, ssa.OpNewAlloc),
+		ssa.OpPrintln:    countOp(fn, ssa.OpPrintln),
+		ssa.OpPanic:      countOp(fn, ssa.OpPanic),
+	}
+	CSE(fn)
+	if err := ssa.Verify(fn); err != nil {
+		t.Fatalf("Verify after cse: %v\nSSA:\n%s", err, ssa.Sprint(fn))
+	}
+	checkUses(t, fn)
+	for op, n := range want {
+		if got := countOp(fn, op); got != n {
+			t.Errorf("got %d %s after cse, want %d\nSSA:\n%s", got, op, n, ssa.Sprint(fn))
+		}
+	}
+	// Both nil checks guard the same ref, but their StructFieldPtrs merge.
+	if n := countOp(fn, ssa.OpStructFieldPtr); n != 1 {
+		t.Errorf("got %d StructFieldPtr after cse, want 1\nSSA:\n%s", n, ssa.Sprint(fn))
+	}
+}
+
+// TestCSEPhisInDifferentBlocks checks that phis with the same args are
+// only merged within one block: b3 dominates b6, but the phis in b6 select
+// on different edges than the one in b3.
+//
+//	b0 -> b1, b2 -> b3 -> b4, b5 -> b6
+func TestCSEPhisInDifferentBlocks(t *testing.T) {
+	intT := types.Typ[types.Int]
+	boolT := types.Typ[types.Bool]
+	f := ssa.NewFunc("f", types.NewFunc(nil, nil, intT))
+	b0 := f.Entry
+	b0.Kind = ssa.BlockIf
+	b1 := f.NewBlock(ssa.BlockPlain)
+	b2 := f.NewBlock(ssa.BlockPlain)
+	b3 := f.NewBlock(ssa.BlockIf)
+	b4 := f.NewBlock(ssa.BlockPlain)
+	b5 := f.NewBlock(ssa.BlockPlain)
+	b6 := f.NewBlock(ssa.BlockReturn)
+	b0.AddSucc(b1)
+	b0.AddSucc(b2)
+	b1.AddSucc(b3)
+	b2.AddSucc(b3)
+	b3.AddSucc(b4)
+	b3.AddSucc(b5)
+	b4.AddSucc(b6)
+	b5.AddSucc(b6)
+
+	c0 := f.NewValue(b0, ssa.OpArg, boolT)
+	b0.SetControl(c0)
+	c3 := f.NewValue(b0, ssa.OpArg, boolT)
+	c3.AuxInt = 1
+	one := f.NewValue(b0, ssa.OpConst64, intT)
+	one.AuxInt = 1
+	two := f.NewValue(b0, ssa.OpConst64, intT)
+	two.AuxInt = 2
+	b3.SetControl(c3)
+
+	p3 := f.NewValue(b3, ssa.OpPhi, intT, one, two)
+	p6 := f.NewValue(b6, ssa.OpPhi, intT, one, two)
+	q6 := f.NewValue(b6, ssa.OpPhi, intT, one, two)
+	sum := f.NewValue(b6, ssa.OpAdd64, intT, p3, p6)
+	sum2 := f.NewValue(b6, ssa.OpAdd64, intT, sum, q6)
+	b6.SetControl(sum2)
+
+	CSE(f)
+	if err := ssa.Verify(f); err != nil {
+		t.Fatalf("Verify after cse: %v\nSSA:\n%s", err, ssa.Sprint(f))
+	}
+	checkUses(t, f)
+	if n := countOp(f, ssa.OpPhi); n != 2 {
+		t.Errorf("got %d phis after cse, want 2\nSSA:\n%s", n, ssa.Sprint(f))
+	}
+	if sum.Args[1] != p6 || sum2.Args[1] != p6 {
+		t.Errorf("b6 phis not merged with each other:\n%s", ssa.Sprint(f))
+	}
+}
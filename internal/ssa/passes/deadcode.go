@@ -0,0 +1,117 @@
+package passes
+
+import "github.com/you-not-fish/yoru/internal/ssa"
+
+// DeadCode removes blocks unreachable from the entry block and pure values
+// whose results are never used. Values with side effects (stores, calls,
+// checks that may panic) and divisions or shifts that may panic are
+// always kept, along with everything they and the block terminators
+// depend on.
+func DeadCode(f *ssa.Func) {
+	if removeUnreachable(f) {
+		ssa.ComputeDom(f)
+	}
+	removeDeadValues(f)
+}
+
+// removeUnreachable deletes the blocks not reachable from f.Entry and the
+// edges leading out of them, dropping the matching phi arguments in the
+// surviving successors. It reports whether any block was removed.
+func removeUnreachable(f *ssa.Func) bool {
+	reachable := make(map[*ssa.Block]bool, len(f.Blocks))
+	for _, b := range ssa.ReversePostOrder(f) {
+		reachable[b] = true
+	}
+	if len(reachable) == len(f.Blocks) {
+		return false
+	}
+
+	var live []*ssa.Block
+	for _, b := range f.Blocks {
+		if reachable[b] {
+			live = append(live, b)
+			continue
+		}
+		for _, s := range b.Succs {
+			if reachable[s] {
+				removePred(s, b)
+			}
+		}
+		for _, v := range b.Values {
+			for _, arg := range v.Args {
+				arg.Uses--
+			}
+		}
+		for _, c := range b.Controls {
+			if c != nil {
+				c.Uses--
+			}
+		}
+	}
+	f.Blocks = live
+	return true
+}
+
+// removePred removes every edge from pred to b, along with the
+// corresponding arguments of b's phis.
+func removePred(b, pred *ssa.Block) {
+	for i := len(b.Preds) - 1; i >= 0; i-- {
+		if b.Preds[i] != pred {
+			continue
+		}
+		b.Preds = append(b.Preds[:i], b.Preds[i+1:]...)
+		for _, v := range b.Values {
+			if v.Op != ssa.OpPhi || i >= len(v.Args) {
+				continue
+			}
+			v.Args[i].Uses--
+			v.Args = append(v.Args[:i], v.Args[i+1:]...)
+		}
+	}
+}
+
+// removeDeadValues deletes the pure values that no impure value, value
+// that may trap, or block control transitively depends on. Marking from
+// the roots, rather than testing Uses == 0, also removes dead cycles such
+// as a loop phi that only feeds its own increment.
+func removeDeadValues(f *ssa.Func) {
+	live := make(map[*ssa.Value]bool)
+	var work []*ssa.Value
+	mark := func(v *ssa.Value) {
+		if v != nil && !live[v] {
+			live[v] = true
+			work = append(work, v)
+		}
+	}
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			if !v.IsPure() || v.MayTrap() {
+				mark(v)
+			}
+		}
+		for _, c := range b.Controls {
+			mark(c)
+		}
+	}
+	for len(work) > 0 {
+		v := work[len(work)-1]
+		work = work[:len(work)-1]
+		for _, arg := range v.Args {
+			mark(arg)
+		}
+	}
+
+	for _, b := range f.Blocks {
+		var kept []*ssa.Value
+		for _, v := range b.Values {
+			if live[v] {
+				kept = append(kept, v)
+				continue
+			}
+			for _, arg := range v.Args {
+				arg.Uses--
+			}
+		}
+		b.Values = kept
+	}
+}
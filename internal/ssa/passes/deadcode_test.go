@@ -0,0 +1,102 @@
+package passes
+
+import (
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// TestDeadCodeGolden compares the SSA before and after deadcode, run
+// after mem2reg, with the golden files in testdata/deadcode.
+func TestDeadCodeGolden(t *testing.T) {
+	runPassGolden(t, Pass{Name: "deadcode", Fn: DeadCode})
+}
+
+// TestDeadCodeUnreachableBlocks builds a diamond whose else edge has been
+// folded away, plus a self-looping block nothing reaches, and checks that
+// deadcode removes both and fixes up the join block's phi.
+//
+//	b0 -> b1 -> b3 <- b2 (unreachable)
+//	            ^
+//	            b4 (unreachable, loops to itself)
+func TestDeadCodeUnreachableBlocks(t *testing.T) {
+	intT := types.Typ[types.Int]
+	f := ssa.NewFunc("f", types.NewFunc(nil, nil, intT))
+	b0 := f.Entry
+	b1 := f.NewBlock(ssa.BlockPlain)
+	b2 := f.NewBlock(ssa.BlockPlain)
+	b3 := f.NewBlock(ssa.BlockReturn)
+	b4 := f.NewBlock(ssa.BlockIf)
+
+	b0.AddSucc(b1)
+	b1.AddSucc(b3)
+	b2.AddSucc(b3)
+	b4.AddSucc(b4)
+	b4.AddSucc(b3)
+
+	one := f.NewValue(b1, ssa.OpConst64, intT)
+	one.AuxInt = 1
+	two := f.NewValue(b2, ssa.OpConst64, intT)
+	two.AuxInt = 2
+	cond := f.NewValue(b4, ssa.OpConstBool, types.Typ[types.Bool])
+	cond.AuxInt = 1
+	b4.SetControl(cond)
+	three := f.NewValue(b4, ssa.OpConst64, intT)
+	three.AuxInt = 3
+	phi := f.NewValue(b3, ssa.OpPhi, intT, one, two, three)
+	b3.SetControl(phi)
+
+	if err := Run(f, []Pass{{Name: "deadcode", Fn: DeadCode}}, Config{Verify: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkUses(t, f)
+
+	if len(f.Blocks) != 3 {
+		t.Fatalf("got %d blocks, want 3\nSSA:\n%s", len(f.Blocks), ssa.Sprint(f))
+	}
+	if len(b3.Preds) != 1 || b3.Preds[0] != b1 {
+		t.Errorf("b3 preds = %v, want [b1]", b3.Preds)
+	}
+	if len(phi.Args) != 1 || phi.Args[0] != one {
+		t.Errorf("phi args = %v, want [%s]", phi.Args, one)
+	}
+	if err := ssa.VerifyDom(f); err != nil {
+		t.Errorf("VerifyDom: %v", err)
+	}
+}
+
+// TestDeadCodeKeepsEffects checks that impure values survive even when
+// their results are unused, while the pure values feeding only dead code
+// are removed.
+func TestDeadCodeKeepsEffects(t *testing.T) {
+	src := `package main
+func h() int {
+	return 1
+}
+func f(p ref [3]int, i int) int {
+	var unused int = i * 2 + 1
+	h()
+	var x int = p[i]
+	return i
+}
+`
+	funcs := buildAndRun(t, src)
+	fn := getFunc(t, funcs, "f")
+	DeadCode(fn)
+	if err := ssa.Verify(fn); err != nil {
+		t.Fatalf("Verify after deadcode: %v\nSSA:\n%s", err, ssa.Sprint(fn))
+	}
+	checkUses(t, fn)
+
+	for _, op := range []ssa.Op{ssa.OpStaticCall, ssa.OpNilCheck, ssa.OpBoundsCheck, ssa.OpLoad} {
+		if countOp(fn, op) != 1 {
+			t.Errorf("got %d %s, want 1\nSSA:\n%s", countOp(fn, op), op, ssa.Sprint(fn))
+		}
+	}
+	for _, op := range []ssa.Op{ssa.OpMul64, ssa.OpAdd64} {
+		if n := countOp(fn, op); n != 0 {
+			t.Errorf("got %d %s, want 0\nSSA:\n%s", n, op, ssa.Sprint(fn))
+		}
+	}
+}
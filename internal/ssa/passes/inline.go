@@ -0,0 +1,310 @@
+package passes
+
+import (
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// inlineBudget is the largest callee, in values that generate code, that
+// is inlined.
+const inlineBudget = 40
+
+// Inliner copies the bodies of small functions into their callers. It is
+// built over the whole program, since a call may target any function;
+// Inline is the per-function pass.
+//
+// A call is inlined if its callee is a known function that is not marked
+// //yoru:noinline, is not recursive (directly or through other functions),
+// returns normally on some path, and costs at most inlineBudget. Callers
+// should run the pipeline in BottomUp order, so that callees are inlined
+// into and optimized before their own size is measured.
+type Inliner struct {
+	funcs     map[string]*ssa.Func // by link name
+	recursive map[*ssa.Func]bool
+}
+
+// NewInliner returns an Inliner for the program made of funcs.
+func NewInliner(funcs []*ssa.Func) *Inliner {
+	in := &Inliner{
+		funcs:     make(map[string]*ssa.Func, len(funcs)),
+		recursive: make(map[*ssa.Func]bool),
+	}
+	for _, f := range funcs {
+		in.funcs[f.LinkName] = f
+	}
+	for _, scc := range callGraphSCCs(funcs, in.callee) {
+		if len(scc) > 1 {
+			for _, f := range scc {
+				in.recursive[f] = true
+			}
+			continue
+		}
+		f := scc[0]
+		forEachCall(f, func(call *ssa.Value) {
+			if in.callee(call) == f {
+				in.recursive[f] = true
+			}
+		})
+	}
+	return in
+}
+
+// BottomUp returns funcs ordered so that every function comes after the
+// functions it calls, except within cycles of mutually recursive
+// functions.
+func BottomUp(funcs []*ssa.Func) []*ssa.Func {
+	in := NewInliner(funcs)
+	var order []*ssa.Func
+	for _, scc := range callGraphSCCs(funcs, in.callee) {
+		order = append(order, scc...)
+	}
+	return order
+}
+
+// callee returns the function called by the OpStaticCall call, or nil if
+// it is not part of the program.
+func (in *Inliner) callee(call *ssa.Value) *ssa.Func {
+	obj, ok := call.Aux.(*types.FuncObj)
+	if !ok {
+		return nil
+	}
+	return in.funcs[ssa.LinkName(obj)]
+}
+
+// forEachCall calls fn for each static call in f.
+func forEachCall(f *ssa.Func, fn func(call *ssa.Value)) {
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			if v.Op == ssa.OpStaticCall {
+				fn(v)
+			}
+		}
+	}
+}
+
+// callGraphSCCs returns the strongly connected components of the static
+// call graph of funcs, callees first (Tarjan's algorithm).
+func callGraphSCCs(funcs []*ssa.Func, callee func(*ssa.Value) *ssa.Func) [][]*ssa.Func {
+	index := make(map[*ssa.Func]int, len(funcs))
+	low := make(map[*ssa.Func]int, len(funcs))
+	onStack := make(map[*ssa.Func]bool)
+	var stack []*ssa.Func
+	var sccs [][]*ssa.Func
+
+	var visit func(f *ssa.Func)
+	visit = func(f *ssa.Func) {
+		index[f] = len(index)
+		low[f] = index[f]
+		stack = append(stack, f)
+		onStack[f] = true
+
+		forEachCall(f, func(call *ssa.Value) {
+			g := callee(call)
+			if g == nil {
+				return
+			}
+			if _, seen := index[g]; !seen {
+				visit(g)
+				low[f] = min(low[f], low[g])
+			} else if onStack[g] {
+				low[f] = min(low[f], index[g])
+			}
+		})
+
+		if low[f] != index[f] {
+			return
+		}
+		var scc []*ssa.Func
+		for {
+			g := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[g] = false
+			scc = append(scc, g)
+			if g == f {
+				break
+			}
+		}
+		sccs = append(sccs, scc)
+	}
+	for _, f := range funcs {
+		if _, seen := index[f]; !seen {
+			visit(f)
+		}
+	}
+	return sccs
+}
+
+// Inline inlines the eligible calls in f, including calls exposed by
+// inlining.
+func (in *Inliner) Inline(f *ssa.Func) {
+	changed := false
+	// Inlining splits the calling block; the rest of it and the copied
+	// blocks are appended to f.Blocks and scanned in turn.
+	for i := 0; i < len(f.Blocks); i++ {
+		b := f.Blocks[i]
+		for j, v := range b.Values {
+			if v.Op != ssa.OpStaticCall {
+				continue
+			}
+			if callee := in.callee(v); callee != nil && in.canInline(f, callee) {
+				inlineCall(f, b, j, callee)
+				changed = true
+				break
+			}
+		}
+	}
+	if changed {
+		ssa.ComputeDom(f)
+	}
+}
+
+// canInline reports whether calls from caller to callee may be inlined.
+func (in *Inliner) canInline(caller, callee *ssa.Func) bool {
+	if callee == caller || callee.NoInline || in.recursive[callee] {
+		return false
+	}
+	// The caller jumps to the copy of the entry block, which must not
+	// have phis to extend.
+	if len(callee.Entry.Preds) > 0 {
+		return false
+	}
+	returns := false
+	for _, b := range callee.Blocks {
+		if b.Kind == ssa.BlockReturn {
+			returns = true
+		}
+	}
+	return returns && inlineCost(callee) <= inlineBudget
+}
+
+// inlineCost returns the number of values of f that generate code:
+// everything but constants and arguments.
+func inlineCost(f *ssa.Func) int {
+	n := 0
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			switch v.Op {
+			case ssa.OpConst64, ssa.OpConstFloat, ssa.OpConstBool, ssa.OpConstNil, ssa.OpArg:
+				continue
+			}
+			n++
+		}
+	}
+	return n
+}
+
+// inlineCall replaces the call b.Values[j] with a copy of callee's body.
+// b is split after the call: b jumps to the copy of callee's entry, and
+// each return becomes a jump to a new block holding the rest of b, where
+// a phi merges the returned values. Copied values keep their source
+// positions; those without one get the call's. Allocas go to f's entry
+// block.
+func inlineCall(f *ssa.Func, b *ssa.Block, j int, callee *ssa.Func) {
+	call := b.Values[j]
+
+	// Move the rest of b to cont. Values without args stay in b, where
+	// they still dominate everything: mem2reg appends the zero values it
+	// creates to the end of the entry block, possibly after their uses.
+	cont := f.NewBlock(b.Kind)
+	rest := b.Values[j+1:]
+	b.Values = b.Values[:j:j]
+	for _, v := range rest {
+		if len(v.Args) == 0 && v.IsPure() {
+			b.Values = append(b.Values, v)
+			continue
+		}
+		v.Block = cont
+		cont.Values = append(cont.Values, v)
+	}
+	cont.Controls, b.Controls = b.Controls, nil
+	cont.Succs, b.Succs = b.Succs, nil
+	for _, s := range cont.Succs {
+		for i, p := range s.Preds {
+			if p == b {
+				s.Preds[i] = cont
+			}
+		}
+	}
+	b.Kind = ssa.BlockPlain
+
+	// Arguments map to the call's operands; the receiver comes first.
+	vmap := make(map[*ssa.Value]*ssa.Value)
+	argBase := int64(0)
+	if callee.Sig != nil && callee.Sig.Recv() != nil {
+		argBase = 1
+	}
+	bmap := make(map[*ssa.Block]*ssa.Block, len(callee.Blocks))
+	for _, cb := range callee.Blocks {
+		kind := cb.Kind
+		if kind == ssa.BlockReturn {
+			kind = ssa.BlockPlain
+		}
+		bmap[cb] = f.NewBlock(kind)
+	}
+	for _, cb := range callee.Blocks {
+		nb := bmap[cb]
+		for _, cv := range cb.Values {
+			if cv.Op == ssa.OpArg {
+				vmap[cv] = call.Args[argBase+cv.AuxInt]
+				continue
+			}
+			dst := nb
+			if cv.Op == ssa.OpAlloca {
+				dst = f.Entry
+			}
+			pos := cv.Pos
+			if !pos.IsValid() {
+				pos = call.Pos
+			}
+			nv := f.NewValuePos(dst, cv.Op, cv.Type, pos)
+			nv.AuxInt = cv.AuxInt
+			nv.AuxFloat = cv.AuxFloat
+			nv.Aux = cv.Aux
+			vmap[cv] = nv
+		}
+	}
+
+	// Wire up args, controls and edges.
+	var rets []*ssa.Value
+	for _, cb := range callee.Blocks {
+		nb := bmap[cb]
+		for _, cv := range cb.Values {
+			if cv.Op == ssa.OpArg {
+				continue
+			}
+			nv := vmap[cv]
+			for _, arg := range cv.Args {
+				nv.AddArg(vmap[arg])
+			}
+		}
+		for _, p := range cb.Preds {
+			nb.Preds = append(nb.Preds, bmap[p])
+		}
+		for _, s := range cb.Succs {
+			nb.Succs = append(nb.Succs, bmap[s])
+		}
+		if cb.Kind == ssa.BlockReturn {
+			if len(cb.Controls) > 0 && cb.Controls[0] != nil {
+				rets = append(rets, vmap[cb.Controls[0]])
+			}
+			nb.AddSucc(cont)
+			continue
+		}
+		for _, c := range cb.Controls {
+			nb.AddControl(vmap[c])
+		}
+	}
+	b.AddSucc(bmap[callee.Entry])
+
+	// The call's result is the returned value, merged by a phi if the
+	// callee returns in several places.
+	if call.Type != nil && len(rets) > 0 {
+		result := rets[0]
+		if len(rets) > 1 {
+			result = f.NewValueAtFront(cont, ssa.OpPhi, call.Type, rets...)
+			result.Pos = call.Pos
+		}
+		f.ReplaceUses(call, result)
+	}
+	dropValue(call)
+}
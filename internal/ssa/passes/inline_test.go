@@ -0,0 +1,140 @@
+package passes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+)
+
+// TestInlineGolden compares the SSA before and after inline, run after
+// mem2reg in BottomUp order, with the golden files in testdata/inline.
+func TestInlineGolden(t *testing.T) {
+	runGolden(t, "inline", func(funcs []*ssa.Func) ([]*ssa.Func, Pass) {
+		return BottomUp(funcs), Pass{Name: "inline", Fn: NewInliner(funcs).Inline}
+	})
+}
+
+// inlineAll runs the inliner over the functions of src in BottomUp order.
+func inlineAll(t *testing.T, src string) []*ssa.Func {
+	t.Helper()
+	funcs := buildAndRun(t, src)
+	in := NewInliner(funcs)
+	for _, fn := range BottomUp(funcs) {
+		if err := Run(fn, []Pass{{Name: "inline", Fn: in.Inline}}, Config{Verify: true}); err != nil {
+			t.Fatal(err)
+		}
+		checkUses(t, fn)
+		if err := ssa.VerifyDom(fn); err != nil {
+			t.Fatalf("VerifyDom(%s): %v", fn.Name, err)
+		}
+	}
+	return funcs
+}
+
+// TestInlineChain checks that calls exposed by inlining are inlined too,
+// leaving no calls in the outermost caller.
+func TestInlineChain(t *testing.T) {
+	src := `package main
+func a(x int) int {
+	return x + 1
+}
+func b(x int) int {
+	return a(x) * 2
+}
+func c(x int) int {
+	return b(a(x))
+}
+`
+	fn := getFunc(t, inlineAll(t, src), "c")
+	if n := countOp(fn, ssa.OpStaticCall); n != 0 {
+		t.Errorf("got %d calls, want 0\nSSA:\n%s", n, ssa.Sprint(fn))
+	}
+}
+
+// TestInlineKeepsCalls checks that recursive functions, functions marked
+// //yoru:noinline and functions over the budget are still called.
+func TestInlineKeepsCalls(t *testing.T) {
+	var big strings.Builder
+	big.WriteString("func big(x int) int {\n")
+	for i := 0; i < inlineBudget; i++ {
+		big.WriteString("\tx = x * 3 + 1\n")
+	}
+	big.WriteString("\treturn x\n}\n")
+
+	src := `package main
+//yoru:noinline
+func small(x int) int {
+	return x
+}
+func rec(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return rec(n - 1)
+}
+` + big.String() + `
+func f(n int) int {
+	return small(n) + rec(n) + big(n)
+}
+`
+	funcs := inlineAll(t, src)
+	fn := getFunc(t, funcs, "f")
+	if n := countOp(fn, ssa.OpStaticCall); n != 3 {
+		t.Errorf("got %d calls, want 3\nSSA:\n%s", n, ssa.Sprint(fn))
+	}
+	if len(fn.Blocks) != 1 {
+		t.Errorf("got %d blocks, want 1\nSSA:\n%s", len(fn.Blocks), ssa.Sprint(fn))
+	}
+}
+
+// TestInlinePositions checks that inlined values keep the callee's source
+// positions.
+func TestInlinePositions(t *testing.T) {
+	src := `package main
+func get(a [3]int, i int) int {
+	return a[i]
+}
+func f(i int) int {
+	var a [3]int
+	return get(a, i)
+}
+`
+	fn := getFunc(t, inlineAll(t, src), "f")
+	var found bool
+	for _, b := range fn.Blocks {
+		for _, v := range b.Values {
+			if v.Op == ssa.OpBoundsCheck {
+				found = true
+				if got := v.Pos.String(); got != "test.yoru:3:11" {
+					t.Errorf("BoundsCheck pos = %s, want test.yoru:3:11", got)
+				}
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("no BoundsCheck in f\nSSA:\n%s", ssa.Sprint(fn))
+	}
+}
+
+// TestBottomUp checks that callees come before their callers.
+func TestBottomUp(t *testing.T) {
+	src := `package main
+func top() int {
+	return mid() + leaf()
+}
+func mid() int {
+	return leaf()
+}
+func leaf() int {
+	return 1
+}
+`
+	var names []string
+	for _, fn := range BottomUp(buildAndRun(t, src)) {
+		names = append(names, fn.Name)
+	}
+	if got := strings.Join(names, " "); got != "leaf mid top" {
+		t.Errorf("BottomUp order = %s, want leaf mid top", got)
+	}
+}
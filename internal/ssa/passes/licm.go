@@ -0,0 +1,83 @@
+package passes
+
+import "github.com/you-not-fish/yoru/internal/ssa"
+
+// LICM performs loop-invariant code motion. Loops are processed innermost
+// first; a value is moved to the loop's preheader, created if needed, when
+// all its args are defined outside the loop. Hoisted values run even if
+// the loop body does not, so only values that cannot fault are moved:
+//
+//   - pure values other than phis that cannot panic (see
+//     ssa.Value.MayTrap): divisions need a non-zero constant divisor and
+//     shifts a non-negative constant count;
+//   - nil checks in the loop header that no other side effect or value
+//     that may panic precedes, since the header runs whenever the
+//     preheader does.
+//
+// Moving a value out of a loop may let the enclosing loop move it further.
+func LICM(f *ssa.Func) {
+	ssa.ComputeDom(f)
+	ln := ssa.ComputeLoopNest(f)
+	if len(ln.Loops) == 0 {
+		return
+	}
+
+	// ln.Loops lists outer loops first.
+	for i := len(ln.Loops) - 1; i >= 0; i-- {
+		hoistInvariants(f, ln, ln.Loops[i])
+	}
+	ssa.ComputeDom(f)
+}
+
+// hoistInvariants moves the invariant values of l to its preheader.
+func hoistInvariants(f *ssa.Func, ln *ssa.LoopNest, l *ssa.Loop) {
+	var pre *ssa.Block
+	hoist := func(v *ssa.Value) bool {
+		if pre == nil {
+			pre = ln.InsertPreheader(l)
+			if pre == nil {
+				return false
+			}
+		}
+		v.Block = pre
+		pre.Values = append(pre.Values, v)
+		return true
+	}
+
+	// Visiting blocks in reverse postorder sees every value's args first.
+	for _, b := range ssa.ReversePostOrder(f) {
+		if !l.Contains(b) {
+			continue
+		}
+		sideEffect := false
+		kept := b.Values[:0]
+		for _, v := range b.Values {
+			movable := canSpeculate(v) ||
+				v.Op == ssa.OpNilCheck && b == l.Header && !sideEffect
+			if movable && isInvariant(l, v) && hoist(v) {
+				continue
+			}
+			if !v.IsPure() || v.MayTrap() {
+				sideEffect = true
+			}
+			kept = append(kept, v)
+		}
+		b.Values = kept
+	}
+}
+
+// isInvariant reports whether all args of v are defined outside l.
+func isInvariant(l *ssa.Loop, v *ssa.Value) bool {
+	for _, arg := range v.Args {
+		if l.Contains(arg.Block) {
+			return false
+		}
+	}
+	return true
+}
+
+// canSpeculate reports whether v may be executed on paths where it was
+// not: it is pure, not a phi, and cannot fault.
+func canSpeculate(v *ssa.Value) bool {
+	return v.Op != ssa.OpPhi && v.IsPure() && !v.MayTrap()
+}
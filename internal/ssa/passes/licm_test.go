@@ -0,0 +1,55 @@
+package passes
+
+import (
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+)
+
+// TestLICMGolden compares the SSA before and after licm, run after
+// mem2reg, with the golden files in testdata/licm.
+func TestLICMGolden(t *testing.T) {
+	runPassGolden(t, Pass{Name: "licm", Fn: LICM})
+}
+
+// TestLICMKeepsFaults checks that values that may fault stay in the loop
+// when they would run on paths that did not run them before.
+func TestLICMKeepsFaults(t *testing.T) {
+	src := `package main
+type Counter struct {
+	limit int
+}
+func next(i int) int {
+	println(i)
+	return i + 1
+}
+func f(c ref Counter, x int, d int) int {
+	var i int = 0
+	for next(i) < c.limit {
+		i = i + x / d
+	}
+	return i
+}
+`
+	funcs := buildAndRun(t, src)
+	fn := getFunc(t, funcs, "f")
+	if err := Run(fn, []Pass{{Name: "licm", Fn: LICM}}, Config{Verify: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkUses(t, fn)
+
+	ssa.ComputeDom(fn)
+	ln := ssa.ComputeLoopNest(fn)
+	for _, op := range []ssa.Op{ssa.OpNilCheck, ssa.OpDiv64} {
+		if countOp(fn, op) == 0 {
+			t.Errorf("no %s in f", op)
+		}
+		for _, b := range fn.Blocks {
+			for _, v := range b.Values {
+				if v.Op == op && ln.Depth(b) != 1 {
+					t.Errorf("%s hoisted out of the loop:\n%s", v.LongString(), ssa.Sprint(fn))
+				}
+			}
+		}
+	}
+}
@@ -7,7 +7,9 @@ import (
 
 // Mem2Reg promotes stack allocas to SSA registers by inserting phi nodes
 // and renaming variables. Only "simple" allocas (used only by load/store/zero)
-// are promoted; allocas whose address escapes are left intact.
+// are promoted; allocas whose address escapes are left intact. Aggregates
+// reached through field or element addresses are left to SROA, which
+// splits them and promotes the pieces.
 func Mem2Reg(f *ssa.Func) {
 	// Ensure dominance tree is available.
 	ssa.ComputeDom(f)
@@ -307,10 +309,13 @@ func makeZero(f *ssa.Func, t types.Type) *ssa.Value {
 			v.Aux = ""
 			return v
 		}
-	case *types.Pointer, *types.Ref:
+	case *types.Pointer, *types.Ref, *types.Interface, *types.Slice, *types.Map:
 		return f.NewValue(f.Entry, ssa.OpConstNil, t)
+	case *types.Struct, *types.Array:
+		// An aggregate loaded and stored only whole; a MakeAggregate
+		// without args is its zero value.
+		return f.NewValue(f.Entry, ssa.OpMakeAggregate, t)
 	}
-	// For struct/array types, this shouldn't happen since they aren't promotable.
 	// Fallback: create a zero int (should not be reached for valid programs).
 	v := f.NewValue(f.Entry, ssa.OpConst64, t)
 	v.AuxInt = 0
@@ -521,3 +521,7 @@ func f(x int) int {
 		t.Errorf("allocas after pass runner = %d, want 0", n)
 	}
 }
+
+func TestMem2RegGolden(t *testing.T) {
+	runPassGolden(t, Pass{Name: "mem2reg", Fn: Mem2Reg})
+}
@@ -3,6 +3,7 @@ package passes
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/you-not-fish/yoru/internal/ssa"
 )
@@ -19,6 +20,45 @@ type Config struct {
 	DumpAfter  string // dump SSA after this pass ("*" for all)
 	Verify     bool   // verify SSA before/after each pass
 	DumpFunc   string // restrict dumps to this function name
+
+	// Record, if not nil, is called after each pass with its wall time
+	// and the function's stats before and after it.
+	Record func(PassRun)
+}
+
+// Stats counts the contents of a function.
+type Stats struct {
+	Blocks int
+	Values int
+	Phis   int
+	Calls  int
+}
+
+// CountStats returns the stats of f.
+func CountStats(f *ssa.Func) Stats {
+	st := Stats{Blocks: len(f.Blocks)}
+	for _, b := range f.Blocks {
+		st.Values += len(b.Values)
+		for _, v := range b.Values {
+			switch v.Op {
+			case ssa.OpPhi:
+				st.Phis++
+			case ssa.OpStaticCall, ssa.OpCall, ssa.OpInterfaceCall:
+				st.Calls++
+			}
+		}
+	}
+	return st
+}
+
+// A PassRun records one run of a pass on a function.
+type PassRun struct {
+	Pass   string
+	Func   string
+	Start  time.Time
+	Time   time.Duration // excluding verification
+	Before Stats
+	After  Stats
 }
 
 // Run executes the given passes on f in order.
@@ -36,7 +76,13 @@ func Run(f *ssa.Func, passes []Pass, cfg Config) error {
 			}
 		}
 
+		var before Stats
+		if cfg.Record != nil {
+			before = CountStats(f)
+		}
+		start := time.Now()
 		p.Fn(f)
+		elapsed := time.Since(start)
 
 		if cfg.Verify {
 			if err := ssa.Verify(f); err != nil {
@@ -49,6 +95,17 @@ func Run(f *ssa.Func, passes []Pass, cfg Config) error {
 			ssa.Fprint(os.Stderr, f)
 			fmt.Fprintln(os.Stderr)
 		}
+
+		if cfg.Record != nil {
+			cfg.Record(PassRun{
+				Pass:   p.Name,
+				Func:   f.Name,
+				Start:  start,
+				Time:   elapsed,
+				Before: before,
+				After:  CountStats(f),
+			})
+		}
 	}
 	return nil
 }
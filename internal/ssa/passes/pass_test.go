@@ -1,6 +1,11 @@
 package passes
 
 import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/you-not-fish/yoru/internal/ssa"
@@ -67,3 +72,113 @@ func TestRunMultiplePasses(t *testing.T) {
 		t.Errorf("pass order = %v, want [first second]", order)
 	}
 }
+
+var update = flag.Bool("update", false, "update golden files")
+
+// checkUses fails the test if a value's Uses count does not match the
+// number of args and controls referring to it.
+func checkUses(t *testing.T, f *ssa.Func) {
+	t.Helper()
+	uses := make(map[*ssa.Value]int32)
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			for _, arg := range v.Args {
+				uses[arg]++
+			}
+		}
+		for _, c := range b.Controls {
+			if c != nil {
+				uses[c]++
+			}
+		}
+	}
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			if v.Uses != uses[v] {
+				t.Errorf("%s: %s.Uses = %d, want %d\nSSA:\n%s", f.Name, v, v.Uses, uses[v], ssa.Sprint(f))
+			}
+		}
+	}
+}
+
+// runPassGolden runs p after mem2reg on each .yoru file in
+// testdata/<p.Name> and compares the SSA before and after the pass with the
+// file's .golden file. Each .ssa file there is parsed with ssa.Parse
+// instead, and its golden file holds the SSA after the pass. Run with
+// -update to rewrite the golden files.
+func runPassGolden(t *testing.T, p Pass) {
+	t.Helper()
+	runGolden(t, p.Name, func(funcs []*ssa.Func) ([]*ssa.Func, Pass) {
+		return funcs, p
+	})
+}
+
+// runGolden is runPassGolden for passes that need the whole program.
+// setup receives the functions of each file and returns the order to run
+// them in and the pass to run.
+func runGolden(t *testing.T, name string, setup func([]*ssa.Func) ([]*ssa.Func, Pass)) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", name, "*.yoru"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssaFiles, err := filepath.Glob(filepath.Join("testdata", name, "*.ssa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, ssaFiles...)
+	if len(files) == 0 {
+		t.Fatalf("no test files in testdata/%s", name)
+	}
+	for _, file := range files {
+		ext := filepath.Ext(file)
+		t.Run(strings.TrimSuffix(filepath.Base(file), ext), func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var funcs []*ssa.Func
+			if ext == ".ssa" {
+				funcs, err = ssa.Parse(file, bytes.NewReader(src))
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				funcs = buildAndRun(t, string(src))
+			}
+			funcs, p := setup(funcs)
+			var buf bytes.Buffer
+			for _, fn := range funcs {
+				if ext != ".ssa" {
+					buf.WriteString("--- before " + p.Name + " ---\n")
+					ssa.Fprint(&buf, fn)
+				}
+				if err := Run(fn, []Pass{p}, Config{Verify: true}); err != nil {
+					t.Fatal(err)
+				}
+				checkUses(t, fn)
+				if ext != ".ssa" {
+					buf.WriteString("--- after " + p.Name + " ---\n")
+				} else if buf.Len() > 0 {
+					buf.WriteString("\n")
+				}
+				ssa.Fprint(&buf, fn)
+			}
+
+			golden := strings.TrimSuffix(file, ext) + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("SSA mismatch for %s\ngot:\n%s\nwant:\n%s", file, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package passes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+)
+
+// Context holds what passes need beyond the function they run on.
+type Context struct {
+	// Funcs is the whole program. The inliner copies bodies between them.
+	Funcs []*ssa.Func
+
+	// CheckElimReport, if not nil, is called for each check removed by
+	// checkelim, with the reason it cannot fail.
+	CheckElimReport func(check *ssa.Value, reason string)
+}
+
+// A registeredPass is a pass that can be named in a pipeline. new returns
+// its function for a context.
+type registeredPass struct {
+	name string
+	new  func(ctx *Context) func(*ssa.Func)
+}
+
+// registry lists the passes in the order of the -O2 pipeline.
+var registry = []registeredPass{
+	{"inline", func(ctx *Context) func(*ssa.Func) {
+		return NewInliner(ctx.Funcs).Inline
+	}},
+	{"mem2reg", fixed(Mem2Reg)},
+	{"sroa", fixed(SROA)},
+	{"sccp", fixed(SCCP)},
+	{"cse", fixed(CSE)},
+	{"licm", fixed(LICM)},
+	{"checkelim", func(ctx *Context) func(*ssa.Func) {
+		if ctx.CheckElimReport != nil {
+			return CheckElimReport(ctx.CheckElimReport)
+		}
+		return CheckElim
+	}},
+	{"deadcode", fixed(DeadCode)},
+}
+
+// aliases maps alternative names of passes to their registered names.
+var aliases = map[string]string{
+	"dce": "deadcode",
+}
+
+// presets lists the passes run at each optimization level.
+var presets = [][]string{
+	0: nil,
+	1: {"mem2reg", "sroa", "sccp", "deadcode"},
+	2: {"inline", "mem2reg", "sroa", "sccp", "cse", "licm", "checkelim", "deadcode"},
+}
+
+// MaxOptLevel is the highest optimization level. It is the default.
+const MaxOptLevel = 2
+
+func fixed(fn func(*ssa.Func)) func(*Context) func(*ssa.Func) {
+	return func(*Context) func(*ssa.Func) { return fn }
+}
+
+// Names returns the names of the registered passes, in -O2 pipeline order.
+func Names() []string {
+	names := make([]string, len(registry))
+	for i, p := range registry {
+		names[i] = p.name
+	}
+	return names
+}
+
+// Preset returns the names of the passes run at optimization level, which
+// must be between 0 and MaxOptLevel.
+func Preset(level int) []string {
+	return presets[level]
+}
+
+// NewPipeline returns the named passes, in order, set up for ctx. A pass
+// may be named more than once.
+func NewPipeline(names []string, ctx *Context) ([]Pass, error) {
+	pipeline := make([]Pass, 0, len(names))
+	for _, name := range names {
+		p := lookup(name)
+		if p == nil {
+			return nil, fmt.Errorf("unknown pass %q (valid: %s)", name, strings.Join(Names(), ", "))
+		}
+		pipeline = append(pipeline, Pass{Name: p.name, Fn: p.new(ctx)})
+	}
+	return pipeline, nil
+}
+
+// lookup returns the registered pass with the given name or alias, or nil.
+func lookup(name string) *registeredPass {
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	for i := range registry {
+		if registry[i].name == name {
+			return &registry[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package passes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/syntax"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+func TestNewPipeline(t *testing.T) {
+	pipeline, err := NewPipeline([]string{"mem2reg", "dce", "cse", "mem2reg"}, &Context{})
+	if err != nil {
+		t.Fatalf("NewPipeline: %v", err)
+	}
+	var names []string
+	for _, p := range pipeline {
+		if p.Fn == nil {
+			t.Errorf("pass %s has no function", p.Name)
+		}
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ","); got != "mem2reg,deadcode,cse,mem2reg" {
+		t.Errorf("pipeline = %s, want mem2reg,deadcode,cse,mem2reg", got)
+	}
+
+	_, err = NewPipeline([]string{"mem2reg", "nope"}, &Context{})
+	if err == nil || !strings.HasPrefix(err.Error(), `unknown pass "nope" (valid: inline, mem2reg,`) {
+		t.Errorf("NewPipeline with unknown pass: err = %v", err)
+	}
+}
+
+func TestPresets(t *testing.T) {
+	if p := Preset(0); len(p) != 0 {
+		t.Errorf("Preset(0) = %v, want no passes", p)
+	}
+	if got, want := strings.Join(Preset(MaxOptLevel), ","), strings.Join(Names(), ","); got != want {
+		t.Errorf("Preset(%d) = %s, want all passes %s", MaxOptLevel, got, want)
+	}
+	for level := 0; level <= MaxOptLevel; level++ {
+		if _, err := NewPipeline(Preset(level), &Context{}); err != nil {
+			t.Errorf("Preset(%d): %v", level, err)
+		}
+	}
+}
+
+func TestRunRecord(t *testing.T) {
+	src := `package main
+func main() {
+	var x int = 1
+	if x > 0 {
+		x = x + 1
+	}
+	println(x)
+}
+`
+	f := buildFromSource(t, src)[0]
+	before := CountStats(f)
+	pipeline, err := NewPipeline([]string{"mem2reg", "dce"}, &Context{Funcs: []*ssa.Func{f}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var runs []PassRun
+	if err := Run(f, pipeline, Config{Verify: true, Record: func(r PassRun) { runs = append(runs, r) }}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(runs) != 2 || runs[0].Pass != "mem2reg" || runs[1].Pass != "deadcode" {
+		t.Fatalf("recorded runs = %+v, want mem2reg, deadcode", runs)
+	}
+	if runs[0].Func != "main" || runs[0].Before != before || runs[1].Before != runs[0].After {
+		t.Errorf("recorded runs = %+v, want main starting from %+v", runs, before)
+	}
+	if after := CountStats(f); runs[1].After != after {
+		t.Errorf("last After = %+v, want %+v", runs[1].After, after)
+	}
+	if runs[0].After.Phis == 0 || runs[0].After.Values >= before.Values {
+		t.Errorf("mem2reg stats %+v -> %+v, want fewer values and a phi", before, runs[0].After)
+	}
+	if runs[1].Start.Before(runs[0].Start) {
+		t.Errorf("deadcode started before mem2reg")
+	}
+}
+
+func TestCountStats(t *testing.T) {
+	f := ssa.NewFunc("f", types.NewFunc(nil, nil, nil))
+	callee := types.NewFuncObj(syntax.Pos{}, "g")
+	b := f.NewBlock(ssa.BlockReturn)
+	f.Entry.Kind = ssa.BlockPlain
+	f.Entry.AddSucc(b)
+	f.NewValue(f.Entry, ssa.OpStaticCall, nil).Aux = callee
+	f.NewValue(b, ssa.OpConst64, types.Typ[types.Int])
+
+	if got, want := CountStats(f), (Stats{Blocks: 2, Values: 2, Calls: 1}); got != want {
+		t.Errorf("CountStats = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,485 @@
+package passes
+
+import (
+	"math"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+)
+
+// SCCP performs sparse conditional constant propagation (Wegman and
+// Zadeck). Values are evaluated over a three-level lattice — undefined,
+// constant, overdefined — while only following the CFG edges that can be
+// taken, so constants flowing through phis are folded and branches on
+// constant conditions prune the arms they never take.
+//
+// Afterwards, pure integer, float and bool values found constant are
+// rewritten in place into Const64, ConstFloat or ConstBool, every BlockIf
+// whose condition is constant becomes a BlockPlain, and blocks left
+// unreachable are removed. Operations that would panic or trap at run
+// time, such as integer division by zero, are never folded.
+func SCCP(f *ssa.Func) {
+	s := &sccp{
+		f:        f,
+		lattice:  make(map[*ssa.Value]latticeVal),
+		execEdge: make(map[sccpEdge]bool),
+		execBlk:  make(map[*ssa.Block]bool),
+		users:    make(map[*ssa.Value][]*ssa.Value),
+		ctrlUser: make(map[*ssa.Value][]*ssa.Block),
+	}
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			for _, arg := range v.Args {
+				s.users[arg] = append(s.users[arg], v)
+			}
+		}
+		for _, c := range b.Controls {
+			if c != nil {
+				s.ctrlUser[c] = append(s.ctrlUser[c], b)
+			}
+		}
+	}
+	s.solve()
+	if s.rewrite() {
+		if removeUnreachable(f) {
+			ssa.ComputeDom(f)
+		}
+	}
+}
+
+// latticeState is the position of a value in the SCCP lattice.
+type latticeState int
+
+const (
+	latticeUndef latticeState = iota // not yet known; may still be any constant
+	latticeConst                     // a single known constant
+	latticeOver                      // not a constant
+)
+
+// latticeVal is a lattice element. For constants, op is OpConst64,
+// OpConstFloat or OpConstBool and the value is in auxInt or auxFloat.
+type latticeVal struct {
+	state    latticeState
+	op       ssa.Op
+	auxInt   int64
+	auxFloat float64
+}
+
+var overdefined = latticeVal{state: latticeOver}
+
+func intConst(n int64) latticeVal {
+	return latticeVal{state: latticeConst, op: ssa.OpConst64, auxInt: n}
+}
+
+func floatConst(x float64) latticeVal {
+	return latticeVal{state: latticeConst, op: ssa.OpConstFloat, auxFloat: x}
+}
+
+func boolConst(b bool) latticeVal {
+	l := latticeVal{state: latticeConst, op: ssa.OpConstBool}
+	if b {
+		l.auxInt = 1
+	}
+	return l
+}
+
+// equal reports whether two lattice elements are the same. Floats are
+// compared by bits, so a NaN constant equals itself.
+func (l latticeVal) equal(m latticeVal) bool {
+	if l.state != m.state {
+		return false
+	}
+	if l.state != latticeConst {
+		return true
+	}
+	return l.op == m.op && l.auxInt == m.auxInt &&
+		math.Float64bits(l.auxFloat) == math.Float64bits(m.auxFloat)
+}
+
+// meet returns the greatest lower bound of l and m.
+func (l latticeVal) meet(m latticeVal) latticeVal {
+	switch {
+	case l.state == latticeUndef:
+		return m
+	case m.state == latticeUndef:
+		return l
+	case l.equal(m):
+		return l
+	}
+	return overdefined
+}
+
+// sccpEdge is the CFG edge from b to b.Succs[i].
+type sccpEdge struct {
+	b *ssa.Block
+	i int
+}
+
+type sccp struct {
+	f        *ssa.Func
+	lattice  map[*ssa.Value]latticeVal
+	execEdge map[sccpEdge]bool
+	execBlk  map[*ssa.Block]bool
+	users    map[*ssa.Value][]*ssa.Value
+	ctrlUser map[*ssa.Value][]*ssa.Block
+
+	flowWork []sccpEdge
+	ssaWork  []*ssa.Value
+}
+
+// solve runs the propagation to a fixed point.
+func (s *sccp) solve() {
+	s.visitBlock(s.f.Entry)
+	for len(s.flowWork) > 0 || len(s.ssaWork) > 0 {
+		for len(s.flowWork) > 0 {
+			e := s.flowWork[len(s.flowWork)-1]
+			s.flowWork = s.flowWork[:len(s.flowWork)-1]
+			succ := e.b.Succs[e.i]
+			if s.execBlk[succ] {
+				// Only the phis see the new edge.
+				for _, v := range succ.Values {
+					if v.Op == ssa.OpPhi {
+						s.visitValue(v)
+					}
+				}
+				continue
+			}
+			s.visitBlock(succ)
+		}
+		for len(s.ssaWork) > 0 {
+			v := s.ssaWork[len(s.ssaWork)-1]
+			s.ssaWork = s.ssaWork[:len(s.ssaWork)-1]
+			for _, u := range s.users[v] {
+				if s.execBlk[u.Block] {
+					s.visitValue(u)
+				}
+			}
+			for _, b := range s.ctrlUser[v] {
+				if s.execBlk[b] {
+					s.visitTerminator(b)
+				}
+			}
+		}
+	}
+}
+
+// visitBlock marks b executable and evaluates its values and terminator.
+func (s *sccp) visitBlock(b *ssa.Block) {
+	s.execBlk[b] = true
+	for _, v := range b.Values {
+		s.visitValue(v)
+	}
+	s.visitTerminator(b)
+}
+
+// visitValue re-evaluates v, queueing its users if its lattice value
+// changed.
+func (s *sccp) visitValue(v *ssa.Value) {
+	old := s.lattice[v]
+	if old.state == latticeOver {
+		return
+	}
+	var l latticeVal
+	if v.Op == ssa.OpPhi {
+		for i, arg := range v.Args {
+			if s.edgeExecutable(v.Block, i) {
+				l = l.meet(s.lattice[arg])
+			}
+		}
+	} else {
+		l = s.eval(v)
+	}
+	if l.equal(old) {
+		return
+	}
+	s.lattice[v] = l
+	s.ssaWork = append(s.ssaWork, v)
+}
+
+// edgeExecutable reports whether the edge from b.Preds[i] to b is
+// executable.
+func (s *sccp) edgeExecutable(b *ssa.Block, i int) bool {
+	pred := b.Preds[i]
+	// Match the i-th pred to an edge of pred. If pred reaches b over more
+	// than one edge, the k-th occurrence in Preds pairs with the k-th
+	// occurrence in Succs.
+	k := 0
+	for j := 0; j < i; j++ {
+		if b.Preds[j] == pred {
+			k++
+		}
+	}
+	for j, succ := range pred.Succs {
+		if succ != b {
+			continue
+		}
+		if k == 0 {
+			return s.execEdge[sccpEdge{pred, j}]
+		}
+		k--
+	}
+	return false
+}
+
+// visitTerminator marks the outgoing edges of b that can be taken.
+func (s *sccp) visitTerminator(b *ssa.Block) {
+	switch b.Kind {
+	case ssa.BlockPlain:
+		s.markEdge(sccpEdge{b, 0})
+	case ssa.BlockIf:
+		c := s.lattice[b.Controls[0]]
+		switch c.state {
+		case latticeConst:
+			if c.auxInt != 0 {
+				s.markEdge(sccpEdge{b, 0})
+			} else {
+				s.markEdge(sccpEdge{b, 1})
+			}
+		case latticeOver:
+			s.markEdge(sccpEdge{b, 0})
+			s.markEdge(sccpEdge{b, 1})
+		}
+	}
+}
+
+func (s *sccp) markEdge(e sccpEdge) {
+	if e.i >= len(e.b.Succs) || s.execEdge[e] {
+		return
+	}
+	s.execEdge[e] = true
+	s.flowWork = append(s.flowWork, e)
+}
+
+// eval computes the lattice value of a non-phi value from its args.
+func (s *sccp) eval(v *ssa.Value) latticeVal {
+	switch v.Op {
+	case ssa.OpConst64:
+		return intConst(v.AuxInt)
+	case ssa.OpConstFloat:
+		return floatConst(v.AuxFloat)
+	case ssa.OpConstBool:
+		return boolConst(v.AuxInt != 0)
+	case ssa.OpCopy:
+		return s.lattice[v.Args[0]]
+	}
+	if !v.IsPure() || len(v.Args) == 0 || len(v.Args) > 2 {
+		return overdefined
+	}
+
+	args := make([]latticeVal, len(v.Args))
+	for i, arg := range v.Args {
+		args[i] = s.lattice[arg]
+		if args[i].state == latticeOver {
+			return overdefined
+		}
+	}
+	for _, a := range args {
+		if a.state == latticeUndef {
+			return latticeVal{}
+		}
+	}
+	if len(args) == 1 {
+		return foldUnary(v.Op, args[0])
+	}
+	return foldBinary(v.Op, args[0], args[1])
+}
+
+// foldUnary folds a unary op on a constant.
+func foldUnary(op ssa.Op, x latticeVal) latticeVal {
+	switch op {
+	case ssa.OpNeg64:
+		return intConst(-x.auxInt)
+	case ssa.OpCom64:
+		return intConst(^x.auxInt)
+	case ssa.OpNegF64:
+		return floatConst(-x.auxFloat)
+	case ssa.OpNot:
+		return boolConst(x.auxInt == 0)
+	case ssa.OpIntToFloat:
+		return floatConst(float64(x.auxInt))
+	case ssa.OpFloatToInt:
+		// Out-of-range conversions are target dependent; leave them.
+		if f := x.auxFloat; f >= -(1<<63) && f < 1<<63 {
+			return intConst(int64(f))
+		}
+	}
+	return overdefined
+}
+
+// foldBinary folds a binary op on two constants.
+func foldBinary(op ssa.Op, x, y latticeVal) latticeVal {
+	a, b := x.auxInt, y.auxInt
+	fa, fb := x.auxFloat, y.auxFloat
+	switch op {
+	case ssa.OpAdd64:
+		return intConst(a + b)
+	case ssa.OpSub64:
+		return intConst(a - b)
+	case ssa.OpMul64:
+		return intConst(a * b)
+	case ssa.OpDiv64, ssa.OpMod64:
+		// Division by zero panics at run time, so it is left for the
+		// program to hit. MinInt64 / -1 wraps like in Go.
+		if b == 0 {
+			return overdefined
+		}
+		if op == ssa.OpDiv64 {
+			return intConst(a / b)
+		}
+		return intConst(a % b)
+	case ssa.OpAnd64:
+		return intConst(a & b)
+	case ssa.OpOr64:
+		return intConst(a | b)
+	case ssa.OpXor64:
+		return intConst(a ^ b)
+	case ssa.OpAndNot64:
+		return intConst(a &^ b)
+	case ssa.OpLsh64, ssa.OpRsh64:
+		// Negative counts panic at run time.
+		if b < 0 {
+			return overdefined
+		}
+		if op == ssa.OpLsh64 {
+			return intConst(a << uint64(b))
+		}
+		return intConst(a >> uint64(b))
+
+	case ssa.OpAddF64:
+		return floatConst(fa + fb)
+	case ssa.OpSubF64:
+		return floatConst(fa - fb)
+	case ssa.OpMulF64:
+		return floatConst(fa * fb)
+	case ssa.OpDivF64:
+		return floatConst(fa / fb)
+
+	case ssa.OpEq64:
+		return boolConst(a == b)
+	case ssa.OpNeq64:
+		return boolConst(a != b)
+	case ssa.OpLt64:
+		return boolConst(a < b)
+	case ssa.OpLeq64:
+		return boolConst(a <= b)
+	case ssa.OpGt64:
+		return boolConst(a > b)
+	case ssa.OpGeq64:
+		return boolConst(a >= b)
+
+	case ssa.OpEqF64:
+		return boolConst(fa == fb)
+	case ssa.OpNeqF64:
+		return boolConst(fa != fb)
+	case ssa.OpLtF64:
+		return boolConst(fa < fb)
+	case ssa.OpLeqF64:
+		return boolConst(fa <= fb)
+	case ssa.OpGtF64:
+		return boolConst(fa > fb)
+	case ssa.OpGeqF64:
+		return boolConst(fa >= fb)
+
+	case ssa.OpAndBool:
+		return boolConst(a != 0 && b != 0)
+	case ssa.OpOrBool:
+		return boolConst(a != 0 || b != 0)
+	}
+	return overdefined
+}
+
+// rewrite applies the solution: constant values become constant ops and
+// branches on constants become plain jumps. It reports whether any branch
+// was removed, which may leave blocks unreachable.
+func (s *sccp) rewrite() bool {
+	for _, b := range s.f.Blocks {
+		if !s.execBlk[b] {
+			continue
+		}
+		for _, v := range b.Values {
+			l := s.lattice[v]
+			if l.state != latticeConst || v.Op == l.op {
+				continue
+			}
+			for _, arg := range v.Args {
+				arg.Uses--
+			}
+			v.Args = nil
+			v.Aux = nil
+			v.Op = l.op
+			v.AuxInt = l.auxInt
+			v.AuxFloat = l.auxFloat
+		}
+	}
+	// Folded phis are now constants; keep the remaining phis at the top
+	// of their blocks.
+	for _, b := range s.f.Blocks {
+		sortPhisFirst(b)
+	}
+
+	pruned := false
+	for _, b := range s.f.Blocks {
+		if b.Kind != ssa.BlockIf || !s.execBlk[b] {
+			continue
+		}
+		c := s.lattice[b.Controls[0]]
+		if c.state != latticeConst {
+			continue
+		}
+		dead := 1
+		if c.auxInt == 0 {
+			dead = 0
+		}
+		removeSucc(b, dead)
+		b.Controls[0].Uses--
+		b.Controls = nil
+		b.Kind = ssa.BlockPlain
+		pruned = true
+	}
+	return pruned
+}
+
+// sortPhisFirst moves the phis of b ahead of its other values, keeping
+// the relative order of each group.
+func sortPhisFirst(b *ssa.Block) {
+	var phis, rest []*ssa.Value
+	for _, v := range b.Values {
+		if v.Op == ssa.OpPhi {
+			phis = append(phis, v)
+		} else {
+			rest = append(rest, v)
+		}
+	}
+	b.Values = append(phis, rest...)
+}
+
+// removeSucc removes the edge from b to b.Succs[i], along with the
+// matching phi arguments of the successor.
+func removeSucc(b *ssa.Block, i int) {
+	succ := b.Succs[i]
+	// The k-th edge from b to succ pairs with the k-th occurrence of b in
+	// succ.Preds.
+	k := 0
+	for j := 0; j < i; j++ {
+		if b.Succs[j] == succ {
+			k++
+		}
+	}
+	b.Succs = append(b.Succs[:i], b.Succs[i+1:]...)
+	for j, p := range succ.Preds {
+		if p != b {
+			continue
+		}
+		if k > 0 {
+			k--
+			continue
+		}
+		succ.Preds = append(succ.Preds[:j], succ.Preds[j+1:]...)
+		for _, v := range succ.Values {
+			if v.Op == ssa.OpPhi {
+				v.Args[j].Uses--
+				v.Args = append(v.Args[:j], v.Args[j+1:]...)
+			}
+		}
+		return
+	}
+}
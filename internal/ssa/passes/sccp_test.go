@@ -0,0 +1,133 @@
+package passes
+
+import (
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// TestSCCPGolden compares the SSA before and after sccp, run after
+// mem2reg, with the golden files in testdata/sccp.
+func TestSCCPGolden(t *testing.T) {
+	runPassGolden(t, Pass{Name: "sccp", Fn: SCCP})
+}
+
+// TestSCCPFoldsBranch checks that a branch on a constant becomes a plain
+// jump, the untaken arm is removed, and the join phi folds.
+func TestSCCPFoldsBranch(t *testing.T) {
+	src := `package main
+func f(n int) int {
+	var r int = 0
+	var on bool = 2 > 1
+	if on {
+		r = 5
+	} else {
+		r = n
+	}
+	return r
+}
+`
+	funcs := buildAndRun(t, src)
+	fn := getFunc(t, funcs, "f")
+	if err := Run(fn, []Pass{{Name: "sccp", Fn: SCCP}}, Config{Verify: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkUses(t, fn)
+	for _, b := range fn.Blocks {
+		if b.Kind == ssa.BlockIf {
+			t.Errorf("%s is still a BlockIf\nSSA:\n%s", b, ssa.Sprint(fn))
+		}
+	}
+	if n := countOp(fn, ssa.OpPhi); n != 0 {
+		t.Errorf("got %d phis, want 0\nSSA:\n%s", n, ssa.Sprint(fn))
+	}
+	ret := fn.Blocks[len(fn.Blocks)-1].Controls[0]
+	if ret.Op != ssa.OpConst64 || ret.AuxInt != 5 {
+		t.Errorf("return value = %s, want Const64 [5]\nSSA:\n%s", ret.LongString(), ssa.Sprint(fn))
+	}
+	if err := ssa.VerifyDom(fn); err != nil {
+		t.Errorf("VerifyDom: %v", err)
+	}
+}
+
+// TestSCCPNoFoldTraps checks that operations that trap or panic at run
+// time keep their op even with constant operands, while the wrapping
+// MinInt64 / -1 is folded like in Go.
+func TestSCCPNoFoldTraps(t *testing.T) {
+	intT := types.Typ[types.Int]
+	f := ssa.NewFunc("f", types.NewFunc(nil, nil, intT))
+	b := f.Entry
+	b.Kind = ssa.BlockReturn
+	konst := func(n int64) *ssa.Value {
+		v := f.NewValue(b, ssa.OpConst64, intT)
+		v.AuxInt = n
+		return v
+	}
+	minInt := konst(-1 << 63)
+	div := f.NewValue(b, ssa.OpDiv64, intT, konst(7), konst(0))
+	mod := f.NewValue(b, ssa.OpMod64, intT, konst(7), konst(0))
+	ovf := f.NewValue(b, ssa.OpDiv64, intT, minInt, konst(-1))
+	shl := f.NewValue(b, ssa.OpLsh64, intT, konst(1), konst(-1))
+	ok := f.NewValue(b, ssa.OpDiv64, intT, konst(7), konst(-2))
+	sum := f.NewValue(b, ssa.OpAdd64, intT, div, mod)
+	sum = f.NewValue(b, ssa.OpAdd64, intT, sum, ovf)
+	sum = f.NewValue(b, ssa.OpAdd64, intT, sum, shl)
+	sum = f.NewValue(b, ssa.OpAdd64, intT, sum, ok)
+	b.SetControl(sum)
+
+	SCCP(f)
+	checkUses(t, f)
+	for _, v := range []*ssa.Value{div, mod, shl} {
+		if v.Op == ssa.OpConst64 {
+			t.Errorf("%s was folded to %d", v, v.AuxInt)
+		}
+	}
+	if ok.Op != ssa.OpConst64 || ok.AuxInt != -3 {
+		t.Errorf("7 / -2 = %s, want Const64 [-3]", ok.LongString())
+	}
+	if ovf.Op != ssa.OpConst64 || ovf.AuxInt != -1<<63 {
+		t.Errorf("MinInt64 / -1 = %s, want Const64 [%d]", ovf.LongString(), int64(-1<<63))
+	}
+	if sum.Op != ssa.OpAdd64 {
+		t.Errorf("sum depending on trapping ops was folded: %s", sum.LongString())
+	}
+}
+
+// TestSCCPLoopPhi checks that a loop-carried value that stays constant is
+// folded, while the induction variable is not.
+func TestSCCPLoopPhi(t *testing.T) {
+	src := `package main
+func f(n int) int {
+	var k int = 7
+	var i int = 0
+	for i < n {
+		k = k * 1
+		i = i + 1
+	}
+	return k + i
+}
+`
+	funcs := buildAndRun(t, src)
+	fn := getFunc(t, funcs, "f")
+	SCCP(fn)
+	if err := ssa.Verify(fn); err != nil {
+		t.Fatalf("Verify after sccp: %v\nSSA:\n%s", err, ssa.Sprint(fn))
+	}
+	checkUses(t, fn)
+	phis := 0
+	for _, b := range fn.Blocks {
+		for _, v := range b.Values {
+			if v.Op == ssa.OpPhi {
+				phis++
+			}
+			if v.Op == ssa.OpMul64 {
+				t.Errorf("k * 1 not folded: %s", v.LongString())
+			}
+		}
+	}
+	// Only the phi for i survives.
+	if phis != 1 {
+		t.Errorf("got %d phis, want 1\nSSA:\n%s", phis, ssa.Sprint(fn))
+	}
+}
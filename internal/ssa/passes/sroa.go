@@ -0,0 +1,284 @@
+package passes
+
+import (
+	"fmt"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// maxSROAPieces is the largest number of fields or elements an alloca is
+// split into. Bigger aggregates stay in memory.
+const maxSROAPieces = 16
+
+// SROA performs scalar replacement of aggregates. A struct or array alloca
+// whose every use is a field address, a constant-indexed element address,
+// or a whole load, store or zero is split into one alloca per field or
+// element: the field and element addresses become the new allocas, and
+// whole loads and stores become one per piece, joined with MakeAggregate
+// and split with ExtractValue. Splitting repeats for nested aggregates,
+// then mem2reg promotes the pieces to registers.
+func SROA(f *ssa.Func) {
+	sizes := f.Sizes
+	if sizes == nil {
+		sizes = types.DefaultSizes
+	}
+
+	split := false
+	for {
+		pieces := findSplittable(f)
+		if len(pieces) == 0 {
+			break
+		}
+		splitAllocas(f, sizes, pieces)
+		split = true
+	}
+	if !split {
+		return
+	}
+
+	Mem2Reg(f)
+	simplifyExtracts(f)
+}
+
+// aggregatePieces returns the field or element types of the aggregate t
+// and the suffixes naming them, or nil if t is not an aggregate.
+func aggregatePieces(t types.Type) ([]types.Type, []string) {
+	switch t := t.Underlying().(type) {
+	case *types.Struct:
+		elems := make([]types.Type, t.NumFields())
+		names := make([]string, t.NumFields())
+		for i, field := range t.Fields() {
+			elems[i] = field.Type()
+			names[i] = "." + field.Name()
+		}
+		return elems, names
+	case *types.Array:
+		if t.Len() > maxSROAPieces {
+			return nil, nil
+		}
+		elems := make([]types.Type, t.Len())
+		names := make([]string, t.Len())
+		for i := range elems {
+			elems[i] = t.Elem()
+			names[i] = fmt.Sprintf("[%d]", i)
+		}
+		return elems, names
+	}
+	return nil, nil
+}
+
+// findSplittable returns the allocas SROA can split, mapped to nil. An
+// alloca is splittable if it holds a non-empty aggregate of at most
+// maxSROAPieces fields or elements and its address is only used to reach
+// a field, a constant-indexed element, or to load, store or zero it whole.
+func findSplittable(f *ssa.Func) map[*ssa.Value][]*ssa.Value {
+	candidates := make(map[*ssa.Value][]*ssa.Value)
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			if v.Op != ssa.OpAlloca {
+				continue
+			}
+			elems, _ := aggregatePieces(v.Type.(*types.Pointer).Elem())
+			if len(elems) > 0 && len(elems) <= maxSROAPieces {
+				candidates[v] = nil
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			for i, arg := range v.Args {
+				if _, ok := candidates[arg]; !ok {
+					continue
+				}
+				if !splittableUse(v, i) {
+					delete(candidates, arg)
+				}
+			}
+		}
+		for _, c := range b.Controls {
+			delete(candidates, c)
+		}
+	}
+	return candidates
+}
+
+// splittableUse reports whether v's use of the alloca in Args[i] allows
+// the alloca to be split.
+func splittableUse(v *ssa.Value, i int) bool {
+	switch v.Op {
+	case ssa.OpStructFieldPtr, ssa.OpLoad, ssa.OpZero:
+		return i == 0
+	case ssa.OpArrayIndexPtr:
+		if i != 0 || v.Args[1].Op != ssa.OpConst64 {
+			return false
+		}
+		n := v.Args[0].Type.(*types.Pointer).Elem().Underlying().(*types.Array).Len()
+		return v.Args[1].AuxInt >= 0 && v.Args[1].AuxInt < n
+	case ssa.OpStore:
+		// Storing the alloca's own address would let it escape.
+		return i == 0 && v.Args[1] != v.Args[0]
+	}
+	return false
+}
+
+// splitAllocas replaces each alloca in allocas with one alloca per field
+// or element and rewrites its uses accordingly.
+func splitAllocas(f *ssa.Func, sizes *types.Sizes, allocas map[*ssa.Value][]*ssa.Value) {
+	// Create the pieces right after each alloca.
+	for _, b := range f.Blocks {
+		old := b.Values
+		b.Values = make([]*ssa.Value, 0, len(old))
+		for _, v := range old {
+			b.Values = append(b.Values, v)
+			if _, ok := allocas[v]; !ok {
+				continue
+			}
+			name, _ := v.Aux.(string)
+			elems, suffixes := aggregatePieces(v.Type.(*types.Pointer).Elem())
+			pieces := make([]*ssa.Value, len(elems))
+			for i, elem := range elems {
+				p := f.NewValuePos(b, ssa.OpAlloca, types.NewPointer(elem), v.Pos)
+				if name != "" {
+					p.Aux = name + suffixes[i]
+				}
+				pieces[i] = p
+			}
+			allocas[v] = pieces
+		}
+	}
+
+	// Field and element addresses become the corresponding piece.
+	repl := make(map[*ssa.Value]*ssa.Value)
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			if len(v.Args) == 0 {
+				continue
+			}
+			pieces, ok := allocas[v.Args[0]]
+			if !ok {
+				continue
+			}
+			switch v.Op {
+			case ssa.OpStructFieldPtr:
+				repl[v] = pieces[v.AuxInt]
+			case ssa.OpArrayIndexPtr:
+				repl[v] = pieces[v.Args[1].AuxInt]
+			}
+		}
+	}
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			for i, arg := range v.Args {
+				if p, ok := repl[arg]; ok {
+					v.ReplaceArg(i, p)
+				}
+			}
+		}
+		for i, c := range b.Controls {
+			if p, ok := repl[c]; ok {
+				c.Uses--
+				b.Controls[i] = p
+				p.Uses++
+			}
+		}
+	}
+
+	// Expand whole loads, stores and zeros, and drop the replaced
+	// addresses and the split allocas.
+	for _, b := range f.Blocks {
+		old := b.Values
+		b.Values = make([]*ssa.Value, 0, len(old))
+		for _, v := range old {
+			if _, ok := repl[v]; ok {
+				dropValue(v)
+				continue
+			}
+			if _, ok := allocas[v]; ok {
+				continue
+			}
+			if len(v.Args) == 0 {
+				b.Values = append(b.Values, v)
+				continue
+			}
+			pieces, ok := allocas[v.Args[0]]
+			if !ok {
+				b.Values = append(b.Values, v)
+				continue
+			}
+			switch v.Op {
+			case ssa.OpLoad:
+				loads := make([]*ssa.Value, len(pieces))
+				for i, p := range pieces {
+					loads[i] = f.NewValuePos(b, ssa.OpLoad, p.Type.(*types.Pointer).Elem(), v.Pos, p)
+				}
+				v.Op = ssa.OpMakeAggregate
+				v.SetArgs(loads)
+				b.Values = append(b.Values, v)
+			case ssa.OpStore:
+				for i, p := range pieces {
+					elem := f.NewValuePos(b, ssa.OpExtractValue, p.Type.(*types.Pointer).Elem(), v.Pos, v.Args[1])
+					elem.AuxInt = int64(i)
+					f.NewValuePos(b, ssa.OpStore, nil, v.Pos, p, elem)
+				}
+				dropValue(v)
+			case ssa.OpZero:
+				for _, p := range pieces {
+					z := f.NewValuePos(b, ssa.OpZero, nil, v.Pos, p)
+					z.AuxInt = sizes.Sizeof(p.Type.(*types.Pointer).Elem())
+				}
+				dropValue(v)
+			}
+		}
+	}
+}
+
+// dropValue releases the uses held by a value being removed.
+func dropValue(v *ssa.Value) {
+	for _, arg := range v.Args {
+		arg.Uses--
+	}
+}
+
+// simplifyExtracts replaces ExtractValue of a MakeAggregate with the
+// corresponding arg, or with a zero constant if the aggregate is the zero
+// value, then removes the aggregate values left unused.
+func simplifyExtracts(f *ssa.Func) {
+	for changed := true; changed; {
+		changed = false
+		for _, b := range f.Blocks {
+			for _, v := range b.Values {
+				if v.Op != ssa.OpExtractValue || v.Uses == 0 || v.Args[0].Op != ssa.OpMakeAggregate {
+					continue
+				}
+				agg := v.Args[0]
+				if len(agg.Args) == 0 {
+					f.ReplaceUses(v, makeZero(f, v.Type))
+				} else {
+					f.ReplaceUses(v, agg.Args[v.AuxInt])
+				}
+				changed = true
+			}
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, b := range f.Blocks {
+			live := b.Values[:0]
+			for _, v := range b.Values {
+				if v.Uses == 0 && (v.Op == ssa.OpExtractValue || v.Op == ssa.OpMakeAggregate) {
+					dropValue(v)
+					changed = true
+					continue
+				}
+				live = append(live, v)
+			}
+			b.Values = live
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package passes
+
+import (
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+)
+
+// TestSROAGolden compares the SSA before and after sroa, run after
+// mem2reg, with the golden files in testdata/sroa.
+func TestSROAGolden(t *testing.T) {
+	runPassGolden(t, Pass{Name: "sroa", Fn: SROA})
+}
+
+// TestSROAMethodValue checks that a value-receiver method reading nested
+// fields, and a caller building the receiver field by field, are left
+// with no memory operations.
+func TestSROAMethodValue(t *testing.T) {
+	src := `package main
+type Point struct {
+	x int
+	y int
+}
+type Rectangle struct {
+	min Point
+	max Point
+}
+func (r Rectangle) Area() int {
+	return (r.max.x - r.min.x) * (r.max.y - r.min.y)
+}
+func main() {
+	var r Rectangle
+	r.min.x = 1
+	r.min.y = 2
+	r.max = Point{x: 4, y: 6}
+	var s Rectangle = r
+	s.min.x = 0
+	println(r.Area(), s.Area())
+}
+`
+	funcs := buildAndRun(t, src)
+	for _, name := range []string{"Area", "main"} {
+		fn := getFunc(t, funcs, name)
+		if err := Run(fn, []Pass{{Name: "sroa", Fn: SROA}}, Config{Verify: true}); err != nil {
+			t.Fatal(err)
+		}
+		checkUses(t, fn)
+		for _, op := range []ssa.Op{ssa.OpAlloca, ssa.OpLoad, ssa.OpStore, ssa.OpZero, ssa.OpStructFieldPtr} {
+			if n := countOp(fn, op); n != 0 {
+				t.Errorf("%s: got %d %s, want 0\nSSA:\n%s", name, n, op, ssa.Sprint(fn))
+			}
+		}
+	}
+}
+
+// TestSROAKeepsEscaping checks that aggregates indexed with a variable or
+// whose address is passed to a call are not split.
+func TestSROAKeepsEscaping(t *testing.T) {
+	src := `package main
+type Point struct {
+	x int
+	y int
+}
+func (p *Point) Scale(factor int) {
+	p.x = p.x * factor
+}
+func f(i int) int {
+	var a [2]int
+	var p Point
+	p.Scale(2)
+	return a[i] + p.x
+}
+`
+	funcs := buildAndRun(t, src)
+	fn := getFunc(t, funcs, "f")
+	if err := Run(fn, []Pass{{Name: "sroa", Fn: SROA}}, Config{Verify: true}); err != nil {
+		t.Fatal(err)
+	}
+	checkUses(t, fn)
+	if n := countOp(fn, ssa.OpAlloca); n != 2 {
+		t.Errorf("got %d allocas, want 2\nSSA:\n%s", n, ssa.Sprint(fn))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/you-not-fish/yoru/internal/types"
@@ -19,31 +20,164 @@ import (
 //	    v2 = Const64 <int> [42]
 //	    v3 = Add64 <int> v1 v2
 //	    Return v3
+//
+// Methods are printed with their receiver, as in "func (p *Point) Move(dx
+// int):". Parse reads the format back.
 func Fprint(w io.Writer, f *Func) {
-	// Function header
-	fmt.Fprintf(w, "func %s", f.Name)
-	if f.Sig != nil {
+	fprintFuncHeader(w, f.Name, f.Sig)
+	fmt.Fprintf(w, ":\n")
+
+	// Blocks
+	for _, b := range f.Blocks {
+		fprintBlock(w, b, f)
+	}
+}
+
+// fprintFuncHeader writes a function header without its trailing ":".
+func fprintFuncHeader(w io.Writer, name string, sig *types.Func) {
+	fmt.Fprintf(w, "func ")
+	if sig != nil && sig.Recv() != nil {
+		recv := sig.Recv()
+		fmt.Fprintf(w, "(%s %s) ", recv.Name(), recv.Type())
+	}
+	fmt.Fprintf(w, "%s", name)
+	if sig != nil {
 		fmt.Fprintf(w, "(")
-		for i := 0; i < f.Sig.NumParams(); i++ {
+		for i := 0; i < sig.NumParams(); i++ {
 			if i > 0 {
 				fmt.Fprintf(w, ", ")
 			}
-			p := f.Sig.Param(i)
+			p := sig.Param(i)
 			fmt.Fprintf(w, "%s %s", p.Name(), p.Type())
 		}
 		fmt.Fprintf(w, ")")
-		if f.Sig.Result() != nil {
-			fmt.Fprintf(w, " %s", f.Sig.Result())
+		if sig.Result() != nil {
+			fmt.Fprintf(w, " %s", sig.Result())
 		}
 	}
-	fmt.Fprintf(w, ":\n")
+}
 
-	// Blocks
-	for _, b := range f.Blocks {
-		fprintBlock(w, b, f)
+// FprintDecls writes the declarations Parse needs to read back the
+// printed funcs: the named types they use, the package-level variables
+// they access and the functions they call but that are not among funcs,
+// in order of first use:
+//
+//	type Point struct{x int; y int}
+//	var origin Point
+//	func abs(x int) int
+//
+// A blank line follows the declarations, if there are any.
+func FprintDecls(w io.Writer, funcs []*Func) {
+	d := &declSet{seen: make(map[string]bool)}
+	defined := make(map[string]bool)
+	for _, f := range funcs {
+		name := f.Name
+		if f.Sig != nil && f.Sig.Recv() != nil {
+			if tn := recvTypeName(f.Sig.Recv().Type()); tn != "" {
+				name = tn + "." + name
+			}
+		}
+		defined[name] = true
+	}
+	for _, f := range funcs {
+		d.sig(f.Sig)
+		for _, b := range f.Blocks {
+			for _, v := range b.Values {
+				d.typ(v.Type)
+				switch a := v.Aux.(type) {
+				case types.Type:
+					d.typ(a)
+				case *types.Var:
+					if v.Op == OpGlobal && !d.seen["var "+a.Name()] {
+						d.seen["var "+a.Name()] = true
+						d.typ(a.Type())
+						d.vars = append(d.vars, a)
+					}
+				case *types.FuncObj:
+					if v.Op == OpStaticCall && !defined[funcName(a)] && !d.seen["func "+funcName(a)] {
+						d.seen["func "+funcName(a)] = true
+						d.sig(a.Signature())
+						d.funcs = append(d.funcs, a)
+					}
+				}
+			}
+		}
+	}
+
+	for _, n := range d.named {
+		fmt.Fprintf(w, "type %s %s\n", n, n.Underlying())
+	}
+	for _, v := range d.vars {
+		fmt.Fprintf(w, "var %s %s\n", v.Name(), v.Type())
+	}
+	for _, fn := range d.funcs {
+		fprintFuncHeader(w, fn.Name(), fn.Signature())
+		fmt.Fprintln(w)
+	}
+	if len(d.named)+len(d.vars)+len(d.funcs) > 0 {
+		fmt.Fprintln(w)
+	}
+}
+
+// declSet collects the declarations written by FprintDecls. seen holds
+// the names already collected, prefixed by their kind.
+type declSet struct {
+	seen  map[string]bool
+	named []*types.Named
+	vars  []*types.Var
+	funcs []*types.FuncObj
+}
+
+// typ collects the named types used by t.
+func (d *declSet) typ(t types.Type) {
+	switch t := t.(type) {
+	case *types.Named:
+		if d.seen["type "+t.String()] {
+			return
+		}
+		d.seen["type "+t.String()] = true
+		d.named = append(d.named, t)
+		d.typ(t.Underlying())
+	case *types.Pointer:
+		d.typ(t.Elem())
+	case *types.Ref:
+		d.typ(t.Elem())
+	case *types.Array:
+		d.typ(t.Elem())
+	case *types.Slice:
+		d.typ(t.Elem())
+	case *types.Map:
+		d.typ(t.Key())
+		d.typ(t.Elem())
+	case *types.Tuple:
+		for _, elem := range t.Types() {
+			d.typ(elem)
+		}
+	case *types.Struct:
+		for _, f := range t.Fields() {
+			d.typ(f.Type())
+		}
+	case *types.Interface:
+		for _, m := range t.Methods() {
+			d.sig(m.Signature())
+		}
 	}
 }
 
+// sig collects the named types used by a signature.
+func (d *declSet) sig(sig *types.Func) {
+	if sig == nil {
+		return
+	}
+	if sig.Recv() != nil {
+		d.typ(sig.Recv().Type())
+	}
+	for _, p := range sig.Params() {
+		d.typ(p.Type())
+	}
+	d.typ(sig.Result())
+}
+
 // fprintBlock writes a single block to w.
 func fprintBlock(w io.Writer, b *Block, f *Func) {
 	// Block header
@@ -93,7 +227,7 @@ func formatValue(v *Value) string {
 	switch v.Op {
 	case OpConst64, OpConstBool:
 		fmt.Fprintf(&sb, " [%d]", v.AuxInt)
-	case OpZero, OpStructFieldPtr:
+	case OpZero, OpStructFieldPtr, OpExtractValue:
 		fmt.Fprintf(&sb, " [%d]", v.AuxInt)
 	default:
 		// Show AuxInt for other ops only if non-zero
@@ -105,11 +239,14 @@ func formatValue(v *Value) string {
 	// AuxFloat
 	switch v.Op {
 	case OpConstFloat:
-		fmt.Fprintf(&sb, " [%g]", v.AuxFloat)
+		// Shortest representation that parses back to the same value.
+		fmt.Fprintf(&sb, " [%s]", strconv.FormatFloat(v.AuxFloat, 'g', -1, 64))
 	}
 
 	// Aux
-	if v.Aux != nil {
+	if s, ok := v.Aux.(string); ok && v.Op == OpConstString {
+		fmt.Fprintf(&sb, " {%s}", strconv.Quote(s))
+	} else if v.Aux != nil {
 		fmt.Fprintf(&sb, " {%s}", formatAux(v.Aux))
 	}
 
@@ -157,6 +294,8 @@ func Sprint(f *Func) string {
 func formatAux(aux interface{}) string {
 	switch a := aux.(type) {
 	case *types.FuncObj:
+		return funcName(a)
+	case *types.Var:
 		return a.Name()
 	case types.Type:
 		return a.String()
@@ -167,6 +306,17 @@ func formatAux(aux interface{}) string {
 	}
 }
 
+// funcName returns the name of fn as printed in calls: methods are
+// qualified with their receiver type name, as in "Point.Move".
+func funcName(fn *types.FuncObj) string {
+	if sig := fn.Signature(); sig != nil && sig.Recv() != nil {
+		if tn := recvTypeName(sig.Recv().Type()); tn != "" {
+			return tn + "." + fn.Name()
+		}
+	}
+	return fn.Name()
+}
+
 // Print writes the SSA representation of a function to stdout.
 func Print(f *Func) {
 	Fprint(os.Stdout, f)
@@ -354,6 +354,7 @@ func TestOpIsPure(t *testing.T) {
 		OpEqPtr, OpNeqPtr,
 		OpNot, OpAndBool, OpOrBool,
 		OpStructFieldPtr, OpArrayIndexPtr,
+		OpExtractValue, OpMakeAggregate,
 		OpIntToFloat, OpFloatToInt,
 		OpPhi, OpCopy, OpArg,
 		OpAddr,
@@ -371,7 +372,7 @@ func TestOpIsPure(t *testing.T) {
 		OpStaticCall, OpCall,
 		OpNewAlloc,
 		OpPrintln, OpPanic,
-		OpNilCheck,
+		OpNilCheck, OpBoundsCheck,
 	}
 
 	for _, op := range impureOps {
@@ -382,7 +383,7 @@ func TestOpIsPure(t *testing.T) {
 }
 
 func TestOpIsVoid(t *testing.T) {
-	voidOps := []Op{OpStore, OpZero, OpPrintln, OpPanic, OpNilCheck}
+	voidOps := []Op{OpStore, OpZero, OpPrintln, OpPanic, OpNilCheck, OpBoundsCheck}
 	for _, op := range voidOps {
 		if !op.IsVoid() {
 			t.Errorf("Op %s should be void", op)
@@ -400,6 +401,31 @@ func TestOpIsVoid(t *testing.T) {
 	}
 }
 
+func TestOpIsCommutative(t *testing.T) {
+	commutativeOps := []Op{
+		OpAdd64, OpMul64, OpAnd64, OpOr64, OpXor64,
+		OpAddF64, OpMulF64,
+		OpEq64, OpNeq64, OpEqF64, OpNeqF64, OpEqPtr, OpNeqPtr,
+		OpAndBool, OpOrBool,
+	}
+	for _, op := range commutativeOps {
+		if !op.IsCommutative() {
+			t.Errorf("Op %s should be commutative", op)
+		}
+	}
+
+	nonCommutativeOps := []Op{
+		OpSub64, OpDiv64, OpMod64, OpAndNot64, OpLsh64, OpRsh64,
+		OpSubF64, OpDivF64, OpLt64, OpGeq64, OpLtF64,
+		OpStore, OpArrayIndexPtr,
+	}
+	for _, op := range nonCommutativeOps {
+		if op.IsCommutative() {
+			t.Errorf("Op %s should NOT be commutative", op)
+		}
+	}
+}
+
 func TestOpString(t *testing.T) {
 	tests := []struct {
 		op   Op
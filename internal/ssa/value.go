@@ -103,3 +103,23 @@ func (v *Value) ReplaceArg(i int, new *Value) {
 func (v *Value) IsPure() bool {
 	return v.Op.IsPure()
 }
+
+// MayTrap returns true if this value can panic at run time: a division
+// whose divisor is not a non-zero constant, or a shift whose count is not
+// a non-negative constant.
+func (v *Value) MayTrap() bool {
+	if !v.Op.MayTrap() {
+		return false
+	}
+	d := v.Args[1]
+	if d.Op != OpConst64 {
+		return true
+	}
+	switch v.Op {
+	case OpDiv64, OpMod64:
+		return d.AuxInt == 0
+	case OpLsh64, OpRsh64:
+		return d.AuxInt < 0
+	}
+	return true
+}
@@ -65,8 +65,8 @@ func Verify(f *Func) error {
 			}
 
 			// 5. Non-void values must have non-nil Type
-			// Exception: StaticCall/Call may have nil Type for void-returning functions.
-			if !v.Op.IsVoid() && v.Type == nil && v.Op != OpStaticCall && v.Op != OpCall {
+			// Exception: calls may have nil Type for void-returning functions.
+			if !v.Op.IsVoid() && v.Type == nil && v.Op != OpStaticCall && v.Op != OpCall && v.Op != OpInterfaceCall {
 				add("func %s, %s, %s (%s): non-void value has nil Type",
 					f.Name, b, v, v.Op)
 			}
@@ -70,8 +70,8 @@ func toJSON(node Node) interface{} {
 			m["recv"] = toJSON(n.Recv)
 		}
 		m["params"] = mapSlice(n.Params, func(f *Field) interface{} { return toJSON(f) })
-		if n.Result != nil {
-			m["result"] = toJSON(n.Result)
+		if len(n.Results) > 0 {
+			m["results"] = mapSliceExpr(n.Results, toJSON)
 		}
 		if n.Body != nil {
 			m["body"] = toJSON(n.Body)
@@ -119,13 +119,36 @@ func toJSON(node Node) interface{} {
 		}
 		return m
 
+	case *TypeSwitchStmt:
+		m := map[string]interface{}{
+			"type": "TypeSwitchStmt",
+			"pos":  n.pos.String(),
+			"x":    toJSON(n.X),
+			"body": mapSlice(n.Body, func(c *CaseClause) interface{} { return toJSON(c) }),
+		}
+		if n.Lhs != nil {
+			m["lhs"] = n.Lhs.Value
+		}
+		return m
+
+	case *CaseClause:
+		m := map[string]interface{}{
+			"type": "CaseClause",
+			"pos":  n.pos.String(),
+			"body": mapSliceStmt(n.Body, toJSON),
+		}
+		if n.Types != nil {
+			m["types"] = mapSliceExpr(n.Types, toJSON)
+		}
+		return m
+
 	case *ReturnStmt:
 		m := map[string]interface{}{
 			"type": "ReturnStmt",
 			"pos":  n.pos.String(),
 		}
-		if n.Result != nil {
-			m["result"] = toJSON(n.Result)
+		if len(n.Results) > 0 {
+			m["results"] = mapSliceExpr(n.Results, toJSON)
 		}
 		return m
 
@@ -208,6 +231,20 @@ func toJSON(node Node) interface{} {
 			"index": toJSON(n.Index),
 		}
 
+	case *SliceExpr:
+		m := map[string]interface{}{
+			"type": "SliceExpr",
+			"pos":  n.pos.String(),
+			"x":    toJSON(n.X),
+		}
+		if n.Lo != nil {
+			m["lo"] = toJSON(n.Lo)
+		}
+		if n.Hi != nil {
+			m["hi"] = toJSON(n.Hi)
+		}
+		return m
+
 	case *SelectorExpr:
 		return map[string]interface{}{
 			"type": "SelectorExpr",
@@ -216,6 +253,17 @@ func toJSON(node Node) interface{} {
 			"sel":  n.Sel.Value,
 		}
 
+	case *AssertExpr:
+		m := map[string]interface{}{
+			"type": "AssertExpr",
+			"pos":  n.pos.String(),
+			"x":    toJSON(n.X),
+		}
+		if n.Type != nil {
+			m["asserttype"] = toJSON(n.Type)
+		}
+		return m
+
 	case *ParenExpr:
 		return map[string]interface{}{
 			"type": "ParenExpr",
@@ -254,6 +302,21 @@ func toJSON(node Node) interface{} {
 			"elem": toJSON(n.Elem),
 		}
 
+	case *SliceType:
+		return map[string]interface{}{
+			"type": "SliceType",
+			"pos":  n.pos.String(),
+			"elem": toJSON(n.Elem),
+		}
+
+	case *MapType:
+		return map[string]interface{}{
+			"type":  "MapType",
+			"pos":   n.pos.String(),
+			"key":   toJSON(n.Key),
+			"value": toJSON(n.Value),
+		}
+
 	case *PointerType:
 		return map[string]interface{}{
 			"type": "PointerType",
@@ -275,6 +338,25 @@ func toJSON(node Node) interface{} {
 			"fields": mapSlice(n.Fields, func(f *Field) interface{} { return toJSON(f) }),
 		}
 
+	case *InterfaceType:
+		return map[string]interface{}{
+			"type":    "InterfaceType",
+			"pos":     n.pos.String(),
+			"methods": mapSlice(n.Methods, func(m *MethodSpec) interface{} { return toJSON(m) }),
+		}
+
+	case *MethodSpec:
+		m := map[string]interface{}{
+			"type":   "MethodSpec",
+			"pos":    n.pos.String(),
+			"name":   n.Name.Value,
+			"params": mapSlice(n.Params, func(f *Field) interface{} { return toJSON(f) }),
+		}
+		if len(n.Results) > 0 {
+			m["results"] = mapSliceExpr(n.Results, toJSON)
+		}
+		return m
+
 	default:
 		return map[string]interface{}{
 			"type": "Unknown",
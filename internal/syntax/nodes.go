@@ -96,15 +96,30 @@ type VarDecl struct {
 
 // FuncDecl represents a function or method declaration.
 // func (Recv) Name(Params) Result { Body }
+// func (Recv) Name(Params) (Result1, Result2, ...) { Body }
 type FuncDecl struct {
 	decl
-	Recv   *Field     // receiver (nil for functions)
-	Name   *Name      // function name
-	Params []*Field   // parameter list
-	Result Expr       // return type (nil for void)
-	Body   *BlockStmt // function body
+	Pragma  PragmaFlag // directives preceding the declaration
+	Recv    *Field     // receiver (nil for functions)
+	Name    *Name      // function name
+	Params  []*Field   // parameter list
+	Results []Expr     // result types (nil for void)
+	Body    *BlockStmt // function body
 }
 
+// PragmaFlag is a set of //yoru: directives. A directive is a line
+// comment starting in column 1, such as
+//
+//	//yoru:noinline
+//
+// and applies to the function declaration that directly follows it; a
+// directive anywhere else is an error.
+type PragmaFlag uint
+
+const (
+	NoInline PragmaFlag = 1 << iota // //yoru:noinline: never inline the function
+)
+
 // Field represents a named field in a struct, parameter list, or receiver.
 type Field struct {
 	node
@@ -152,6 +167,14 @@ type IndexExpr struct {
 	Index Expr // index expression
 }
 
+// SliceExpr represents a slice expression: X[Lo:Hi]
+type SliceExpr struct {
+	expr
+	X  Expr // sliced expression (array, ref to array, or slice)
+	Lo Expr // low bound (nil if absent)
+	Hi Expr // high bound (nil if absent)
+}
+
 // SelectorExpr represents a selector expression: X.Sel
 type SelectorExpr struct {
 	expr
@@ -159,6 +182,15 @@ type SelectorExpr struct {
 	Sel *Name // field/method name
 }
 
+// AssertExpr represents a type assertion: X.(Type)
+// Type is nil for X.(type), which is only valid as the guard of a type
+// switch.
+type AssertExpr struct {
+	expr
+	X    Expr // interface operand
+	Type Expr // asserted type (nil for X.(type))
+}
+
 // ParenExpr represents a parenthesized expression: (X)
 type ParenExpr struct {
 	expr
@@ -196,6 +228,19 @@ type ArrayType struct {
 	Elem Expr // element type
 }
 
+// SliceType represents a slice type: []Elem
+type SliceType struct {
+	expr
+	Elem Expr // element type
+}
+
+// MapType represents a map type: map[Key]Value
+type MapType struct {
+	expr
+	Key   Expr // key type
+	Value Expr // value type
+}
+
 // PointerType represents a pointer type: *Base
 type PointerType struct {
 	expr
@@ -214,6 +259,21 @@ type StructType struct {
 	Fields []*Field // field declarations
 }
 
+// InterfaceType represents an interface type: interface { Methods... }
+type InterfaceType struct {
+	expr
+	Methods []*MethodSpec // method declarations
+}
+
+// MethodSpec represents a method in an interface type.
+// Name(Params) Result or Name(Params) (Result1, Result2, ...)
+type MethodSpec struct {
+	node
+	Name    *Name    // method name
+	Params  []*Field // parameter list
+	Results []Expr   // result types (nil for void)
+}
+
 // ----------------------------------------------------------------------------
 // Statements
 
@@ -259,10 +319,27 @@ type ForStmt struct {
 	Body *BlockStmt // loop body
 }
 
-// ReturnStmt represents a return statement: return [Result]
+// TypeSwitchStmt represents a type switch: switch [Lhs :=] X.(type) { Body }
+// Yoru has no expression switch.
+type TypeSwitchStmt struct {
+	stmt
+	Lhs  *Name         // variable bound in each clause (nil if absent)
+	X    Expr          // interface operand
+	Body []*CaseClause // case and default clauses
+}
+
+// CaseClause represents a clause of a type switch: case Types: Body or
+// default: Body. The name nil may appear among the types.
+type CaseClause struct {
+	node
+	Types []Expr // types (nil for default)
+	Body  []Stmt // statements
+}
+
+// ReturnStmt represents a return statement: return [Results]
 type ReturnStmt struct {
 	stmt
-	Result Expr // return value (nil for bare return)
+	Results []Expr // return values (nil for bare return)
 }
 
 // BranchStmt represents a break or continue statement.
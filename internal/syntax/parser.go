@@ -144,6 +144,7 @@ func (p *Parser) advance() {
 		_Func:     true,
 		_If:       true,
 		_For:      true,
+		_Switch:   true,
 		_Return:   true,
 		_Break:    true,
 		_Continue: true,
@@ -201,6 +202,7 @@ func (p *Parser) Parse() *File {
 			f.Decls = append(f.Decls, d)
 		}
 	}
+	p.scanner.ClearPragma()
 
 	return f
 }
@@ -252,14 +254,22 @@ func (p *Parser) importDecl() *ImportDecl {
 
 // decl parses a top-level declaration.
 func (p *Parser) decl() Decl {
+	// Directives apply only to a function declaration directly following
+	// them; anywhere else they are reported as misplaced.
 	switch p.tok {
 	case _Type:
+		p.scanner.ClearPragma()
 		return p.typeDecl()
 	case _Var:
+		p.scanner.ClearPragma()
 		return p.varDecl()
 	case _Func:
-		return p.funcDecl()
+		pragma := p.scanner.TakePragma()
+		d := p.funcDecl()
+		d.Pragma = pragma
+		return d
 	default:
+		p.scanner.ClearPragma()
 		p.syntaxError("expected declaration")
 		p.advance()
 		return nil
@@ -300,12 +310,18 @@ func (p *Parser) type_() Expr {
 	case _Ref: // ref T
 		return p.refType()
 
-	case _Lbrack: // [N]T
+	case _Lbrack: // [N]T or []T
 		return p.arrayType()
 
+	case _Map: // map[K]V
+		return p.mapType()
+
 	case _Struct:
 		return p.structType()
 
+	case _Interface:
+		return p.interfaceType()
+
 	default:
 		p.syntaxError("expected type")
 		n := &Name{Value: "_"}
@@ -314,9 +330,15 @@ func (p *Parser) type_() Expr {
 	}
 }
 
-// typeName parses a type name (identifier).
+// typeName parses a type name: an identifier or a qualified
+// identifier pkg.Name.
 func (p *Parser) typeName() Expr {
-	return p.name()
+	n := p.name()
+	if p.tok != _Dot {
+		return n
+	}
+	p.next()
+	return p.selectorExpr(n)
 }
 
 // pointerType parses *Base
@@ -337,17 +359,36 @@ func (p *Parser) refType() Expr {
 	return rt
 }
 
-// arrayType parses [N]Elem
+// arrayType parses [N]Elem or the slice type []Elem
 func (p *Parser) arrayType() Expr {
-	at := &ArrayType{}
-	at.pos = p.pos
+	pos := p.pos
 	p.want(_Lbrack)
+	if p.got(_Rbrack) {
+		st := &SliceType{}
+		st.pos = pos
+		st.Elem = p.type_()
+		return st
+	}
+	at := &ArrayType{}
+	at.pos = pos
 	at.Len = p.expr()
 	p.want(_Rbrack)
 	at.Elem = p.type_()
 	return at
 }
 
+// mapType parses map[Key]Value
+func (p *Parser) mapType() Expr {
+	mt := &MapType{}
+	mt.pos = p.pos
+	p.want(_Map)
+	p.want(_Lbrack)
+	mt.Key = p.type_()
+	p.want(_Rbrack)
+	mt.Value = p.type_()
+	return mt
+}
+
 // structType parses struct { Fields... }
 func (p *Parser) structType() Expr {
 	st := &StructType{}
@@ -374,6 +415,37 @@ func (p *Parser) fieldDecl() *Field {
 	return f
 }
 
+// interfaceType parses interface { Methods... }
+func (p *Parser) interfaceType() Expr {
+	it := &InterfaceType{}
+	it.pos = p.pos
+
+	p.want(_Interface)
+	p.want(_Lbrace)
+
+	for p.tok != _Rbrace && p.tok != _EOF {
+		it.Methods = append(it.Methods, p.methodSpec())
+	}
+
+	p.want(_Rbrace)
+	return it
+}
+
+// methodSpec parses an interface method: Name(Params) Results
+func (p *Parser) methodSpec() *MethodSpec {
+	m := &MethodSpec{}
+	m.pos = p.pos
+	m.Name = p.name()
+	m.Params = p.paramList()
+	if p.tok != _Semi && p.tok != _Rbrace {
+		m.Results = p.resultList()
+	}
+	if p.tok != _Rbrace {
+		p.want(_Semi) // ASI handles newline
+	}
+	return m
+}
+
 // ----------------------------------------------------------------------------
 // Variable declarations
 
@@ -402,7 +474,7 @@ func (p *Parser) varDecl() *VarDecl {
 // ----------------------------------------------------------------------------
 // Function declarations
 
-// funcDecl parses: func (recv) Name(params) result { body }
+// funcDecl parses: func (recv) Name(params) results { body }
 func (p *Parser) funcDecl() *FuncDecl {
 	d := &FuncDecl{}
 	d.pos = p.pos
@@ -417,9 +489,9 @@ func (p *Parser) funcDecl() *FuncDecl {
 	d.Name = p.name()
 	d.Params = p.paramList()
 
-	// Optional result type
+	// Optional result type or parenthesized list of result types
 	if p.tok != _Lbrace {
-		d.Result = p.type_()
+		d.Results = p.resultList()
 	}
 
 	p.fnest++
@@ -429,6 +501,19 @@ func (p *Parser) funcDecl() *FuncDecl {
 	return d
 }
 
+// resultList parses a function result: Type or (Type1, Type2, ...)
+func (p *Parser) resultList() []Expr {
+	if !p.got(_Lparen) {
+		return []Expr{p.type_()}
+	}
+	results := []Expr{p.type_()}
+	for p.got(_Comma) {
+		results = append(results, p.type_())
+	}
+	p.want(_Rparen)
+	return results
+}
+
 // receiver parses (name Type)
 func (p *Parser) receiver() *Field {
 	f := &Field{}
@@ -489,6 +574,9 @@ func (p *Parser) stmt() Stmt {
 	case _For:
 		return p.forStmt()
 
+	case _Switch:
+		return p.switchStmt()
+
 	case _Return:
 		return p.returnStmt()
 
@@ -517,30 +605,34 @@ func (p *Parser) stmt() Stmt {
 // simpleStmt parses an expression statement or assignment.
 func (p *Parser) simpleStmt() Stmt {
 	pos := p.pos
-	x := p.expr()
+	lhs := p.exprList()
 
 	switch p.tok {
 	case _Assign, _Define:
 		// Assignment or short declaration
-		return p.assignStmt(pos, x)
+		return p.assignStmt(pos, lhs)
 
 	default:
 		// Expression statement
-		s := &ExprStmt{X: x}
+		if len(lhs) > 1 {
+			p.syntaxError("expected := or = or comma")
+		}
+		s := &ExprStmt{X: lhs[0]}
 		s.pos = pos
 		p.want(_Semi)
 		return s
 	}
 }
 
-// assignStmt parses LHS op RHS where op is = or :=
-func (p *Parser) assignStmt(pos Pos, lhs Expr) Stmt {
-	s := &AssignStmt{Op: p.tok, LHS: []Expr{lhs}}
+// assignStmt parses LHS op RHS where op is = or := and both sides are
+// comma-separated lists.
+func (p *Parser) assignStmt(pos Pos, lhs []Expr) Stmt {
+	s := &AssignStmt{Op: p.tok, LHS: lhs}
 	s.pos = pos
 
 	p.next() // consume = or :=
 
-	s.RHS = []Expr{p.expr()}
+	s.RHS = p.exprList()
 	p.want(_Semi)
 
 	return s
@@ -608,16 +700,95 @@ func (p *Parser) forStmt() Stmt {
 	return s
 }
 
-// returnStmt parses: return [expr]
+// switchStmt parses a type switch:
+//
+//	switch [name :=] x.(type) { case T1, T2: stmts... default: stmts... }
+func (p *Parser) switchStmt() Stmt {
+	s := &TypeSwitchStmt{}
+	s.pos = p.pos
+
+	p.want(_Switch)
+	old := p.noBrace
+	p.noBrace = true
+	x := p.expr()
+	if p.tok == _Define {
+		if name, ok := x.(*Name); ok {
+			s.Lhs = name
+		} else {
+			p.syntaxError("expected identifier on left side of :=")
+		}
+		p.next()
+		x = p.expr()
+	}
+	p.noBrace = old
+	if a, ok := x.(*AssertExpr); ok && a.Type == nil {
+		s.X = a.X
+	} else {
+		p.syntaxErrorAt(x.Pos(), "switch requires a type switch guard x.(type); use if/else for other conditions")
+		s.X = x
+	}
+
+	p.want(_Lbrace)
+	hasDefault := false
+	for p.tok != _Rbrace && p.tok != _EOF {
+		c := p.caseClause()
+		if c == nil {
+			// Skip to the next clause.
+			for p.tok != _Case && p.tok != _Default && p.tok != _Rbrace && p.tok != _EOF {
+				p.next()
+			}
+			continue
+		}
+		if c.Types == nil {
+			if hasDefault {
+				p.syntaxErrorAt(c.Pos(), "multiple defaults in switch")
+			}
+			hasDefault = true
+		}
+		s.Body = append(s.Body, c)
+	}
+	p.want(_Rbrace)
+
+	return s
+}
+
+// caseClause parses: case T1, T2: stmts... or default: stmts...
+// It returns nil if the current token starts neither.
+func (p *Parser) caseClause() *CaseClause {
+	c := &CaseClause{}
+	c.pos = p.pos
+
+	switch p.tok {
+	case _Case:
+		p.next()
+		c.Types = append(c.Types, p.type_())
+		for p.got(_Comma) {
+			c.Types = append(c.Types, p.type_())
+		}
+	case _Default:
+		p.next()
+	default:
+		p.syntaxError("expected case or default or }")
+		return nil
+	}
+	p.want(_Colon)
+
+	for p.tok != _Case && p.tok != _Default && p.tok != _Rbrace && p.tok != _EOF {
+		c.Body = append(c.Body, p.stmt())
+	}
+	return c
+}
+
+// returnStmt parses: return [expr {, expr}]
 func (p *Parser) returnStmt() Stmt {
 	s := &ReturnStmt{}
 	s.pos = p.pos
 
 	p.want(_Return)
 
-	// Optional return value (check for statement terminators)
+	// Optional return values (check for statement terminators)
 	if p.tok != _Semi && p.tok != _Rbrace && p.tok != _EOF {
-		s.Result = p.expr()
+		s.Results = p.exprList()
 	}
 
 	p.want(_Semi)
@@ -682,6 +853,13 @@ func (p *Parser) unaryExpr() Expr {
 		op.X = p.unaryExpr()
 		return op
 
+	case _Xor: // ^ (bitwise complement)
+		op := &Operation{Op: p.tok}
+		op.pos = p.pos
+		p.next()
+		op.X = p.unaryExpr()
+		return op
+
 	case _Mul: // * (dereference)
 		op := &Operation{Op: p.tok}
 		op.pos = p.pos
@@ -711,11 +889,22 @@ func (p *Parser) primaryExpr() Expr {
 		case _Lparen: // function call
 			x = p.callExpr(x)
 
-		case _Lbrack: // index expression
+		case _Lbrack: // index or slice expression
 			x = p.indexExpr(x)
 
-		case _Dot: // selector expression
+		case _Dot: // selector expression or type assertion
+			p.next()
+			if p.tok == _Lparen {
+				x = p.assertExpr(x)
+				continue
+			}
 			x = p.selectorExpr(x)
+			// Check for qualified composite literal: pkg.T{...}
+			if sel := x.(*SelectorExpr); !p.noBrace && p.tok == _Lbrace {
+				if _, ok := sel.X.(*Name); ok {
+					x = p.compositeLit(x)
+				}
+			}
 
 		default:
 			return x
@@ -762,6 +951,12 @@ func (p *Parser) operand() Expr {
 	case _New: // new(Type)
 		return p.newExpr()
 
+	case _Lbrack: // array or slice type, as in make([]T, n)
+		return p.arrayType()
+
+	case _Map: // map type, as in make(map[K]V)
+		return p.mapType()
+
 	default:
 		p.syntaxError("expected operand")
 		n := &Name{Value: "_"} // error recovery
@@ -784,29 +979,59 @@ func (p *Parser) callExpr(fun Expr) Expr {
 	return call
 }
 
-// indexExpr parses X[Index]
+// indexExpr parses X[Index] or the slice expression X[Lo:Hi], where
+// both bounds are optional.
 func (p *Parser) indexExpr(x Expr) Expr {
-	idx := &IndexExpr{X: x}
-	idx.pos = x.Pos()
-
+	pos := x.Pos()
 	p.want(_Lbrack)
-	idx.Index = p.expr()
+
+	var index Expr
+	if p.tok != _Colon {
+		index = p.expr()
+		if p.tok != _Colon {
+			p.want(_Rbrack)
+			idx := &IndexExpr{X: x, Index: index}
+			idx.pos = pos
+			return idx
+		}
+	}
+
+	s := &SliceExpr{X: x, Lo: index}
+	s.pos = pos
+	p.want(_Colon)
+	if p.tok != _Rbrack {
+		s.Hi = p.expr()
+	}
 	p.want(_Rbrack)
 
-	return idx
+	return s
 }
 
-// selectorExpr parses X.Sel
+// selectorExpr parses Sel of X.Sel; the dot has been consumed.
 func (p *Parser) selectorExpr(x Expr) Expr {
 	sel := &SelectorExpr{X: x}
 	sel.pos = x.Pos()
 
-	p.want(_Dot)
 	sel.Sel = p.name()
 
 	return sel
 }
 
+// assertExpr parses (Type) or (type) of X.(Type); the dot has been
+// consumed.
+func (p *Parser) assertExpr(x Expr) Expr {
+	a := &AssertExpr{X: x}
+	a.pos = x.Pos()
+
+	p.want(_Lparen)
+	if !p.got(_Type) {
+		a.Type = p.type_()
+	}
+	p.want(_Rparen)
+
+	return a
+}
+
 // newExpr parses new(Type)
 func (p *Parser) newExpr() Expr {
 	n := &NewExpr{}
@@ -2,6 +2,7 @@ package syntax
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -208,6 +209,235 @@ func TestParseTypeDecl(t *testing.T) {
 	}
 }
 
+func TestParseQualifiedNames(t *testing.T) {
+	src := `package main
+import "example/geo"
+func f(p *geo.Point) geo.Point {
+	var q ref geo.Point = new(geo.Point)
+	return geo.Point{X: 1}
+}`
+	f, errs := parseFileWithErrors(t, src)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	fd := f.Decls[0].(*FuncDecl)
+	param := fd.Params[0].Type.(*PointerType)
+	if sel, ok := param.Base.(*SelectorExpr); !ok || sel.X.(*Name).Value != "geo" || sel.Sel.Value != "Point" {
+		t.Errorf("param type = %T, want geo.Point selector", param.Base)
+	}
+	if _, ok := fd.Results[0].(*SelectorExpr); !ok {
+		t.Errorf("result type = %T, want *SelectorExpr", fd.Results[0])
+	}
+
+	ret := fd.Body.Stmts[1].(*ReturnStmt)
+	lit, ok := ret.Results[0].(*CompositeLit)
+	if !ok {
+		t.Fatalf("return value = %T, want *CompositeLit", ret.Results[0])
+	}
+	if _, ok := lit.Type.(*SelectorExpr); !ok {
+		t.Errorf("composite literal type = %T, want *SelectorExpr", lit.Type)
+	}
+}
+
+func TestParseInterfaceType(t *testing.T) {
+	src := `package main
+type Shape interface {
+	Area() int
+	Scale(k int, s string)
+	Bounds() (int, int)
+}
+type Any interface{}
+`
+	f := parseFile(t, src)
+	if len(f.Decls) != 2 {
+		t.Fatalf("got %d decls, want 2", len(f.Decls))
+	}
+	it, ok := f.Decls[0].(*TypeDecl).Type.(*InterfaceType)
+	if !ok {
+		t.Fatalf("type is %T, want *InterfaceType", f.Decls[0].(*TypeDecl).Type)
+	}
+	want := []string{"Area() int", "Scale(k int, s string)", "Bounds() (int, int)"}
+	if len(it.Methods) != len(want) {
+		t.Fatalf("got %d methods, want %d", len(it.Methods), len(want))
+	}
+	for i, m := range it.Methods {
+		if got := methodString(m); got != want[i] {
+			t.Errorf("method[%d] = %q, want %q", i, got, want[i])
+		}
+	}
+	empty, ok := f.Decls[1].(*TypeDecl).Type.(*InterfaceType)
+	if !ok || len(empty.Methods) != 0 {
+		t.Errorf("Any = %#v, want empty interface", f.Decls[1].(*TypeDecl).Type)
+	}
+}
+
+func TestParseSlices(t *testing.T) {
+	src := `package main
+var s []int
+func f(a [4]int, m [][]string) {
+	t := make([]int, 3, 8)
+	u := a[1:3]
+	v := s[:]
+	w := t[2:]
+	x := m[0][:1]
+	t[0] = len(u) + cap(v)
+}
+`
+	f := parseFile(t, src)
+	vd := f.Decls[0].(*VarDecl)
+	if typeString(vd.Type) != "[]int" {
+		t.Errorf("var s type = %s, want []int", typeString(vd.Type))
+	}
+	fd := f.Decls[1].(*FuncDecl)
+	if typeString(fd.Params[1].Type) != "[][]string" {
+		t.Errorf("param m type = %s, want [][]string", typeString(fd.Params[1].Type))
+	}
+
+	body := fd.Body.Stmts
+	call := body[0].(*AssignStmt).RHS[0].(*CallExpr)
+	if st, ok := call.Args[0].(*SliceType); !ok || typeString(st.Elem) != "int" || len(call.Args) != 3 {
+		t.Errorf("make args = %#v, want []int, 3, 8", call.Args)
+	}
+
+	tests := []struct {
+		x, lo, hi string
+	}{
+		{"a", "1", "3"},
+		{"s", "", ""},
+		{"t", "2", ""},
+		{"<*syntax.IndexExpr>", "", "1"},
+	}
+	for i, want := range tests {
+		se, ok := body[i+1].(*AssignStmt).RHS[0].(*SliceExpr)
+		if !ok {
+			t.Errorf("statement %d: got %T, want *SliceExpr", i+1, body[i+1].(*AssignStmt).RHS[0])
+			continue
+		}
+		lo, hi := "", ""
+		if se.Lo != nil {
+			lo = exprString(se.Lo)
+		}
+		if se.Hi != nil {
+			hi = exprString(se.Hi)
+		}
+		if exprString(se.X) != want.x || lo != want.lo || hi != want.hi {
+			t.Errorf("statement %d: %s[%s:%s], want %s[%s:%s]", i+1, exprString(se.X), lo, hi, want.x, want.lo, want.hi)
+		}
+	}
+	if _, ok := body[5].(*AssignStmt).LHS[0].(*IndexExpr); !ok {
+		t.Errorf("t[0] = %T, want *IndexExpr", body[5].(*AssignStmt).LHS[0])
+	}
+}
+
+func TestParseMaps(t *testing.T) {
+	src := `package main
+
+var ages map[string]int
+
+func f(m map[int][]ref Node) {
+	n := make(map[string]int, 8)
+	n["a"] = 1
+	v, ok := n["a"]
+	delete(n, "a")
+}
+`
+	f := parseFile(t, src)
+	vd := f.Decls[0].(*VarDecl)
+	if typeString(vd.Type) != "map[string]int" {
+		t.Errorf("var ages type = %s, want map[string]int", typeString(vd.Type))
+	}
+	fd := f.Decls[1].(*FuncDecl)
+	if typeString(fd.Params[0].Type) != "map[int][]ref Node" {
+		t.Errorf("param m type = %s, want map[int][]ref Node", typeString(fd.Params[0].Type))
+	}
+
+	body := fd.Body.Stmts
+	call := body[0].(*AssignStmt).RHS[0].(*CallExpr)
+	mt, ok := call.Args[0].(*MapType)
+	if !ok || typeString(mt.Key) != "string" || typeString(mt.Value) != "int" || len(call.Args) != 2 {
+		t.Errorf("make args = %#v, want map[string]int, 8", call.Args)
+	}
+	if _, ok := body[1].(*AssignStmt).LHS[0].(*IndexExpr); !ok {
+		t.Errorf("n[\"a\"] = %T, want *IndexExpr", body[1].(*AssignStmt).LHS[0])
+	}
+	if as := body[2].(*AssignStmt); len(as.LHS) != 2 || len(as.RHS) != 1 {
+		t.Errorf("comma-ok lookup = %d := %d, want 2 := 1", len(as.LHS), len(as.RHS))
+	}
+}
+
+func TestParseTypeAssertions(t *testing.T) {
+	src := `package main
+func f(s Shape) {
+	r := s.(Rect)
+	c, ok := s.(ref Circle)
+	switch v := s.(type) {
+	case Rect, *Rect:
+		println(1)
+	case nil:
+	default:
+		println(2)
+		return
+	}
+	switch s.(type) {
+	}
+}
+`
+	f := parseFile(t, src)
+	// Each switch is followed by the empty statement of the semicolon
+	// inserted after its closing brace.
+	body := f.Decls[0].(*FuncDecl).Body.Stmts
+	if len(body) != 6 {
+		t.Fatalf("got %d statements, want 6", len(body))
+	}
+
+	a, ok := body[0].(*AssignStmt).RHS[0].(*AssertExpr)
+	if !ok || typeString(a.Type) != "Rect" {
+		t.Errorf("s.(Rect) = %#v, want an AssertExpr of Rect", body[0].(*AssignStmt).RHS[0])
+	}
+	a, ok = body[1].(*AssignStmt).RHS[0].(*AssertExpr)
+	if !ok || typeString(a.Type) != "ref Circle" {
+		t.Errorf("s.(ref Circle) = %#v, want an AssertExpr of ref Circle", body[1].(*AssignStmt).RHS[0])
+	}
+
+	sw, ok := body[2].(*TypeSwitchStmt)
+	if !ok {
+		t.Fatalf("statement is %T, want *TypeSwitchStmt", body[2])
+	}
+	if sw.Lhs == nil || sw.Lhs.Value != "v" || exprString(sw.X) != "s" {
+		t.Errorf("switch guard = %v := %s, want v := s", sw.Lhs, exprString(sw.X))
+	}
+	wantCases := []struct {
+		types []string
+		stmts int
+	}{
+		{[]string{"Rect", "*Rect"}, 1},
+		{[]string{"nil"}, 0},
+		{nil, 2},
+	}
+	if len(sw.Body) != len(wantCases) {
+		t.Fatalf("got %d clauses, want %d", len(sw.Body), len(wantCases))
+	}
+	for i, want := range wantCases {
+		c := sw.Body[i]
+		var types []string
+		for _, typ := range c.Types {
+			types = append(types, typeString(typ))
+		}
+		if strings.Join(types, ", ") != strings.Join(want.types, ", ") || (c.Types == nil) != (want.types == nil) {
+			t.Errorf("clause %d types = %v, want %v", i, types, want.types)
+		}
+		if len(c.Body) != want.stmts {
+			t.Errorf("clause %d has %d statements, want %d", i, len(c.Body), want.stmts)
+		}
+	}
+
+	sw, ok = body[4].(*TypeSwitchStmt)
+	if !ok || sw.Lhs != nil || len(sw.Body) != 0 {
+		t.Errorf("empty switch = %#v, want a TypeSwitchStmt without clauses", body[4])
+	}
+}
+
 func TestParseStructFields(t *testing.T) {
 	src := `package main
 type Person struct {
@@ -427,8 +657,8 @@ func TestParseFuncDecl(t *testing.T) {
 			if len(fd.Params) != tt.wantParams {
 				t.Errorf("Params count = %d, want %d", len(fd.Params), tt.wantParams)
 			}
-			if (fd.Result != nil) != tt.wantResult {
-				t.Errorf("Result set = %v, want %v", fd.Result != nil, tt.wantResult)
+			if (len(fd.Results) > 0) != tt.wantResult {
+				t.Errorf("Results set = %v, want %v", len(fd.Results) > 0, tt.wantResult)
 			}
 		})
 	}
@@ -699,6 +929,8 @@ func exprTypeName(e Expr) string {
 		return "RefType"
 	case *StructType:
 		return "StructType"
+	case *InterfaceType:
+		return "InterfaceType"
 	default:
 		return "Unknown"
 	}
@@ -719,6 +951,12 @@ func TestParsePrecedence(t *testing.T) {
 		// Or has lowest precedence
 		{"a && b || c && d", "Op{||,Op{&&,a,b},Op{&&,c,d}}"},
 
+		// Bitwise operators follow Go's precedence
+		{"a | b & c", "Op{|,a,Op{&,b,c}}"},
+		{"a ^ b << 2", "Op{^,a,Op{<<,b,2}}"},
+		{"a &^ b == c", "Op{==,Op{&^,a,b},c}"},
+		{"^a & b", "Op{&,Op{^,a},b}"},
+
 		// Left associativity
 		{"a + b + c", "Op{+,Op{+,a,b},c}"},
 		{"a * b * c", "Op{*,Op{*,a,b},c}"},
@@ -847,12 +1085,17 @@ func TestParseErrors(t *testing.T) {
 		{"unexpected_op", "package main\nfunc f() { x = + }", "expected operand"},
 
 		// Type errors ([]int is slice syntax, not supported; parser expects array length)
-		{"bad_array_type", "package main\ntype T []int", "expected operand"},
+		{"bad_array_type", "package main\ntype T [)int", "expected operand"},
+		{"bad_slice_expr", "package main\nfunc f() { x := a[1:2:3] }", "expected ]"},
 
 		// Statement errors
 		{"bad_if", "package main\nfunc f() { if { } }", "expected"},
 		{"bad_return", "package main\nfunc f() { return + }", "expected operand"},
 		{"missing_for_cond", "package main\nfunc f() { for { break } }", "expected for condition"},
+		{"expr_switch", "package main\nfunc f() { switch x { default: } }", "switch requires a type switch guard"},
+		{"switch_bad_lhs", "package main\nfunc f() { switch a.b := x.(type) { } }", "expected identifier on left side of :="},
+		{"switch_two_defaults", "package main\nfunc f() { switch x.(type) { default: default: } }", "multiple defaults in switch"},
+		{"switch_bad_clause", "package main\nfunc f() { switch x.(type) { x = 1 } }", "expected case or default or }"},
 	}
 
 	for _, tt := range tests {
@@ -1214,6 +1457,8 @@ func TestParseMoreErrors(t *testing.T) {
 		{"missing_func_body", "package main\nfunc f()", "expected {"},
 		{"bad_param", "package main\nfunc f(int) {}", "expected type"},
 		{"unclosed_params", "package main\nfunc f(x int {}", "expected )"},
+		{"unclosed_results", "package main\nfunc f() (int, bool {}", "expected )"},
+		{"bad_result_type", "package main\nfunc f() (int, ) {}", "expected type"},
 
 		// Expression errors
 		{"unclosed_paren", "package main\nfunc f() { x = (1 + 2 }", "expected )"},
@@ -1226,6 +1471,7 @@ func TestParseMoreErrors(t *testing.T) {
 		{"missing_for_condition", "package main\nfunc f() { for { break } }", "expected for condition"},
 		{"bad_var_init", "package main\nfunc f() { var x int = }", "expected operand"},
 		{"unclosed_block", "package main\nfunc f() { { x = 1 }", "expected }"},
+		{"expr_list_stmt", "package main\nfunc f() { a, b\n}", "expected := or = or comma"},
 
 		// Composite literal errors
 		{"unclosed_composite", "package main\nfunc f() { x := T{ }", "expected"},
@@ -1258,3 +1504,109 @@ func TestParseMoreErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMultipleValues(t *testing.T) {
+	src := `package main
+func divmod(a int, b int) (int, int) {
+	q, r := a / b, a % b
+	a, _ = f()
+	return q, r
+}`
+	f, errs := parseFileWithErrors(t, src)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	fd := f.Decls[0].(*FuncDecl)
+	if len(fd.Results) != 2 || typeString(fd.Results[0]) != "int" || typeString(fd.Results[1]) != "int" {
+		t.Errorf("results = %s, want (int, int)", resultString(fd.Results))
+	}
+
+	def := fd.Body.Stmts[0].(*AssignStmt)
+	if !def.Op.IsDefine() || len(def.LHS) != 2 || len(def.RHS) != 2 {
+		t.Errorf("q, r := ...: Op = %s, %d = %d", def.Op, len(def.LHS), len(def.RHS))
+	}
+	if op, ok := def.RHS[1].(*Operation); !ok || !op.Op.IsRem() {
+		t.Errorf("second value = %T, want a %% b", def.RHS[1])
+	}
+
+	assign := fd.Body.Stmts[1].(*AssignStmt)
+	if len(assign.LHS) != 2 || len(assign.RHS) != 1 {
+		t.Errorf("a, _ = f(): %d = %d", len(assign.LHS), len(assign.RHS))
+	}
+	if blank, ok := assign.LHS[1].(*Name); !ok || blank.Value != "_" {
+		t.Errorf("second LHS = %T, want _", assign.LHS[1])
+	}
+
+	ret := fd.Body.Stmts[2].(*ReturnStmt)
+	if len(ret.Results) != 2 {
+		t.Fatalf("return has %d values, want 2", len(ret.Results))
+	}
+	if ret.Results[1].(*Name).Value != "r" {
+		t.Errorf("second return value = %s, want r", exprString(ret.Results[1]))
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	src := `package main
+
+//yoru:noinline
+func small() int {
+	return 1
+}
+
+// A comment, not a directive.
+func plain() int {
+	return small()
+}
+
+  //yoru:noinline
+func indented() {}
+`
+	f, errs := parseFileWithErrors(t, src)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	want := map[string]PragmaFlag{"small": NoInline, "plain": 0, "indented": 0}
+	for _, d := range f.Decls {
+		fd, ok := d.(*FuncDecl)
+		if !ok {
+			continue
+		}
+		if fd.Pragma != want[fd.Name.Value] {
+			t.Errorf("%s: Pragma = %d, want %d", fd.Name.Value, fd.Pragma, want[fd.Name.Value])
+		}
+	}
+
+	_, errs = parseFileWithErrors(t, "package main\n//yoru:nosuch\nfunc f() {}\n")
+	if len(errs) != 1 || !strings.Contains(errs[0], "test.yoru:2:1: unknown directive //yoru:nosuch") {
+		t.Errorf("errors = %v, want unknown directive at 2:1", errs)
+	}
+}
+
+func TestParseMisplacedDirectives(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		line int
+	}{
+		{"in body", "package main\nfunc f() {\n//yoru:noinline\n}\nfunc g() {}\n", 3},
+		{"above var", "package main\n//yoru:noinline\nvar x int\nfunc g() {}\n", 2},
+		{"above type", "package main\n//yoru:noinline\ntype T int\nfunc g() {}\n", 2},
+		{"at end", "package main\nfunc g() {}\n//yoru:noinline\n", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, errs := parseFileWithErrors(t, tt.src)
+			want := fmt.Sprintf("test.yoru:%d:1: misplaced //yoru: directive", tt.line)
+			if len(errs) != 1 || !strings.Contains(errs[0], want) {
+				t.Errorf("errors = %v, want %q", errs, want)
+			}
+			for _, d := range f.Decls {
+				if fd, ok := d.(*FuncDecl); ok && fd.Pragma != 0 {
+					t.Errorf("%s: Pragma = %d, want 0", fd.Name.Value, fd.Pragma)
+				}
+			}
+		})
+	}
+}
@@ -93,8 +93,8 @@ func (p *printer) print(node Node) {
 			}
 			p.indent--
 		}
-		if n.Result != nil {
-			p.printf("Result: %s\n", typeString(n.Result))
+		if len(n.Results) > 0 {
+			p.printf("Result: %s\n", resultString(n.Results))
 		}
 		if n.Body != nil {
 			p.printf("Body:\n")
@@ -146,13 +146,44 @@ func (p *printer) print(node Node) {
 		p.indent--
 		p.indent--
 
+	case *TypeSwitchStmt:
+		p.printf("TypeSwitchStmt %s\n", n.pos)
+		p.indent++
+		if n.Lhs != nil {
+			p.printf("Lhs: %s\n", n.Lhs.Value)
+		}
+		p.printf("X:\n")
+		p.indent++
+		p.print(n.X)
+		p.indent--
+		for _, c := range n.Body {
+			p.print(c)
+		}
+		p.indent--
+
+	case *CaseClause:
+		if n.Types == nil {
+			p.printf("Default %s\n", n.pos)
+		} else {
+			types := make([]string, len(n.Types))
+			for i, t := range n.Types {
+				types[i] = typeString(t)
+			}
+			p.printf("Case %s %s\n", n.pos, strings.Join(types, ", "))
+		}
+		p.indent++
+		for _, s := range n.Body {
+			p.print(s)
+		}
+		p.indent--
+
 	case *ReturnStmt:
 		p.printf("ReturnStmt %s\n", n.pos)
-		if n.Result != nil {
-			p.indent++
-			p.print(n.Result)
-			p.indent--
+		p.indent++
+		for _, r := range n.Results {
+			p.print(r)
 		}
+		p.indent--
 
 	case *BranchStmt:
 		p.printf("BranchStmt %s %s\n", n.pos, n.Tok)
@@ -245,6 +276,27 @@ func (p *printer) print(node Node) {
 		p.indent--
 		p.indent--
 
+	case *SliceExpr:
+		p.printf("SliceExpr %s\n", n.pos)
+		p.indent++
+		p.printf("X:\n")
+		p.indent++
+		p.print(n.X)
+		p.indent--
+		if n.Lo != nil {
+			p.printf("Lo:\n")
+			p.indent++
+			p.print(n.Lo)
+			p.indent--
+		}
+		if n.Hi != nil {
+			p.printf("Hi:\n")
+			p.indent++
+			p.print(n.Hi)
+			p.indent--
+		}
+		p.indent--
+
 	case *SelectorExpr:
 		p.printf("SelectorExpr %s\n", n.pos)
 		p.indent++
@@ -255,6 +307,20 @@ func (p *printer) print(node Node) {
 		p.printf("Sel: %s\n", n.Sel.Value)
 		p.indent--
 
+	case *AssertExpr:
+		p.printf("AssertExpr %s\n", n.pos)
+		p.indent++
+		p.printf("X:\n")
+		p.indent++
+		p.print(n.X)
+		p.indent--
+		if n.Type == nil {
+			p.printf("Type: type\n")
+		} else {
+			p.printf("Type: %s\n", typeString(n.Type))
+		}
+		p.indent--
+
 	case *ParenExpr:
 		p.printf("ParenExpr %s\n", n.pos)
 		p.indent++
@@ -304,6 +370,19 @@ func (p *printer) print(node Node) {
 		p.printf("Elem: %s\n", typeString(n.Elem))
 		p.indent--
 
+	case *SliceType:
+		p.printf("SliceType %s\n", n.pos)
+		p.indent++
+		p.printf("Elem: %s\n", typeString(n.Elem))
+		p.indent--
+
+	case *MapType:
+		p.printf("MapType %s\n", n.pos)
+		p.indent++
+		p.printf("Key: %s\n", typeString(n.Key))
+		p.printf("Value: %s\n", typeString(n.Value))
+		p.indent--
+
 	case *PointerType:
 		p.printf("PointerType %s\n", n.pos)
 		p.indent++
@@ -324,6 +403,14 @@ func (p *printer) print(node Node) {
 		}
 		p.indent--
 
+	case *InterfaceType:
+		p.printf("InterfaceType %s\n", n.pos)
+		p.indent++
+		for _, m := range n.Methods {
+			p.printf("Method: %s\n", methodString(m))
+		}
+		p.indent--
+
 	case *Field:
 		p.printf("Field %s\n", n.pos)
 		p.indent++
@@ -352,13 +439,45 @@ func typeString(e Expr) string {
 		return "ref " + typeString(t.Base)
 	case *ArrayType:
 		return "[" + exprString(t.Len) + "]" + typeString(t.Elem)
+	case *SliceType:
+		return "[]" + typeString(t.Elem)
+	case *MapType:
+		return "map[" + typeString(t.Key) + "]" + typeString(t.Value)
 	case *StructType:
 		return "struct{...}"
+	case *InterfaceType:
+		return "interface{...}"
 	default:
 		return fmt.Sprintf("<%T>", e)
 	}
 }
 
+// resultString returns a string representation of a result type list:
+// the single type, or the parenthesized list of types.
+func resultString(results []Expr) string {
+	if len(results) == 1 {
+		return typeString(results[0])
+	}
+	list := make([]string, len(results))
+	for i, r := range results {
+		list[i] = typeString(r)
+	}
+	return "(" + strings.Join(list, ", ") + ")"
+}
+
+// methodString returns a string representation of an interface method.
+func methodString(m *MethodSpec) string {
+	params := make([]string, len(m.Params))
+	for i, f := range m.Params {
+		params[i] = f.Name.Value + " " + typeString(f.Type)
+	}
+	s := m.Name.Value + "(" + strings.Join(params, ", ") + ")"
+	if len(m.Results) > 0 {
+		s += " " + resultString(m.Results)
+	}
+	return s
+}
+
 // exprString returns a simple string representation of an expression.
 func exprString(e Expr) string {
 	if e == nil {
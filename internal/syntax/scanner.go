@@ -24,6 +24,11 @@ type Scanner struct {
 
 	// Literal accumulation
 	litBuf strings.Builder
+
+	// Directives seen since the last TakePragma or ClearPragma
+	pragma     PragmaFlag
+	pragmaPos  Pos // position of the first of them
+	pragmaNext Pos // position of the token following them, once scanned
 }
 
 // NewScanner creates a new Scanner for the given source.
@@ -102,6 +107,11 @@ redo:
 
 	// 7. Set nlsemi flag for next token
 	s.nlsemi = s.shouldInsertSemi()
+
+	// 8. Note the token following pending directives
+	if s.pragma != 0 && !s.pragmaNext.IsValid() {
+		s.pragmaNext = s.tokPos
+	}
 }
 
 // Token returns the current token type.
@@ -424,11 +434,16 @@ func (s *Scanner) scanOperator() bool {
 		s.tok = _Rem
 		s.lit = "%"
 	case '&':
-		if s.ch == '&' {
+		switch s.ch {
+		case '&':
 			s.nextch()
 			s.tok = _AndAnd
 			s.lit = "&&"
-		} else {
+		case '^':
+			s.nextch()
+			s.tok = _AndNot
+			s.lit = "&^"
+		default:
 			s.tok = _And
 			s.lit = "&"
 		}
@@ -532,10 +547,68 @@ func (s *Scanner) scanOperator() bool {
 }
 
 // skipLineComment skips a line comment (from // to end of line).
+// A comment starting in column 1 is checked for a //yoru: directive.
 func (s *Scanner) skipLineComment() {
 	// Already consumed the second /
 	s.nextch()
+	directive := s.tokPos.Col() == 1
+	s.litBuf.Reset()
 	for s.ch != '\n' && s.ch >= 0 {
+		if directive {
+			s.litBuf.WriteRune(s.ch)
+		}
 		s.nextch()
 	}
+	if directive {
+		s.directive(s.litBuf.String())
+	}
+}
+
+// directive records the //yoru: directive in the comment text, if any.
+// Unknown directives are reported as errors.
+func (s *Scanner) directive(text string) {
+	name, ok := strings.CutPrefix(text, "yoru:")
+	if !ok {
+		return
+	}
+	name = strings.TrimSpace(name)
+	switch name {
+	case "noinline":
+		// Directives already followed by a token apply to nothing: the
+		// parser takes them only before a function declaration.
+		if s.pragmaNext.IsValid() {
+			s.ClearPragma()
+		}
+		if s.pragma == 0 {
+			s.pragmaPos = s.tokPos
+		}
+		s.pragma |= NoInline
+	default:
+		if s.errh != nil {
+			s.errh(s.tokPos.Line(), s.tokPos.Col(), fmt.Sprintf("unknown directive //yoru:%s", name))
+		}
+	}
+}
+
+// TakePragma returns the directives directly preceding the current token
+// and clears them. Directives followed by another token are reported as
+// misplaced instead.
+func (s *Scanner) TakePragma() PragmaFlag {
+	if s.pragmaNext != s.tokPos {
+		s.ClearPragma()
+	}
+	p := s.pragma
+	s.pragma, s.pragmaNext = 0, Pos{}
+	return p
+}
+
+// ClearPragma reports the directives seen since the previous TakePragma
+// or ClearPragma as misplaced and clears them. The parser calls it where
+// no directive may apply, such as before a declaration other than a
+// function.
+func (s *Scanner) ClearPragma() {
+	if s.pragma != 0 && s.errh != nil {
+		s.errh(s.pragmaPos.Line(), s.pragmaPos.Col(), "misplaced //yoru: directive")
+	}
+	s.pragma, s.pragmaNext = 0, Pos{}
 }
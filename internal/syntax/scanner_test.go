@@ -84,6 +84,7 @@ func TestScanTokens(t *testing.T) {
 		{"op_geq", ">=", []Token{_Geq}, []string{">="}},
 		{"op_shl", "<<", []Token{_Shl}, []string{"<<"}},
 		{"op_shr", ">>", []Token{_Shr}, []string{">>"}},
+		{"op_andnot", "&^", []Token{_AndNot}, []string{"&^"}},
 		{"op_define", ":=", []Token{_Define}, []string{":="}},
 
 		// Delimiters (ASI for ), ], })
@@ -99,18 +100,23 @@ func TestScanTokens(t *testing.T) {
 
 		// Keywords (ASI for break, continue, return)
 		{"kw_break", "break", []Token{_Break, _Semi}, []string{"break", "EOF"}},
+		{"kw_case", "case", []Token{_Case}, []string{"case"}},
 		{"kw_continue", "continue", []Token{_Continue, _Semi}, []string{"continue", "EOF"}},
+		{"kw_default", "default", []Token{_Default}, []string{"default"}},
 		{"kw_else", "else", []Token{_Else}, []string{"else"}},
 		{"kw_for", "for", []Token{_For}, []string{"for"}},
 		{"kw_func", "func", []Token{_Func}, []string{"func"}},
 		{"kw_if", "if", []Token{_If}, []string{"if"}},
 		{"kw_import", "import", []Token{_Import}, []string{"import"}},
+		{"kw_interface", "interface", []Token{_Interface}, []string{"interface"}},
+		{"kw_map", "map", []Token{_Map}, []string{"map"}},
 		{"kw_new", "new", []Token{_New}, []string{"new"}},
 		{"kw_package", "package", []Token{_Package}, []string{"package"}},
 		{"kw_panic", "panic", []Token{_Panic}, []string{"panic"}},
 		{"kw_ref", "ref", []Token{_Ref}, []string{"ref"}},
 		{"kw_return", "return", []Token{_Return, _Semi}, []string{"return", "EOF"}},
 		{"kw_struct", "struct", []Token{_Struct}, []string{"struct"}},
+		{"kw_switch", "switch", []Token{_Switch}, []string{"switch"}},
 		{"kw_type", "type", []Token{_Type}, []string{"type"}},
 		{"kw_var", "var", []Token{_Var}, []string{"var"}},
 
@@ -39,12 +39,13 @@ const (
 	_Xor // ^
 
 	// Arithmetic operators (multiplicative)
-	_Mul // *
-	_Div // /
-	_Rem // %
-	_And // &
-	_Shl // <<
-	_Shr // >>
+	_Mul    // *
+	_Div    // /
+	_Rem    // %
+	_And    // &
+	_Shl    // <<
+	_Shr    // >>
+	_AndNot // &^
 
 	// Unary operators
 	_Not // !
@@ -63,18 +64,23 @@ const (
 
 	// Keywords
 	_Break
+	_Case
 	_Continue
+	_Default
 	_Else
 	_For
 	_Func
 	_If
 	_Import
+	_Interface
+	_Map
 	_New
 	_Package
 	_Panic
 	_Ref
 	_Return
 	_Struct
+	_Switch
 	_Type
 	_Var
 
@@ -107,12 +113,13 @@ var tokenNames = [...]string{
 	_Or:  "|",
 	_Xor: "^",
 
-	_Mul: "*",
-	_Div: "/",
-	_Rem: "%",
-	_And: "&",
-	_Shl: "<<",
-	_Shr: ">>",
+	_Mul:    "*",
+	_Div:    "/",
+	_Rem:    "%",
+	_And:    "&",
+	_Shl:    "<<",
+	_Shr:    ">>",
+	_AndNot: "&^",
 
 	_Not: "!",
 
@@ -127,21 +134,26 @@ var tokenNames = [...]string{
 	_Colon:  ":",
 	_Dot:    ".",
 
-	_Break:    "break",
-	_Continue: "continue",
-	_Else:     "else",
-	_For:      "for",
-	_Func:     "func",
-	_If:       "if",
-	_Import:   "import",
-	_New:      "new",
-	_Package:  "package",
-	_Panic:    "panic",
-	_Ref:      "ref",
-	_Return:   "return",
-	_Struct:   "struct",
-	_Type:     "type",
-	_Var:      "var",
+	_Break:     "break",
+	_Case:      "case",
+	_Continue:  "continue",
+	_Default:   "default",
+	_Else:      "else",
+	_For:       "for",
+	_Func:      "func",
+	_If:        "if",
+	_Import:    "import",
+	_Interface: "interface",
+	_Map:       "map",
+	_New:       "new",
+	_Package:   "package",
+	_Panic:     "panic",
+	_Ref:       "ref",
+	_Return:    "return",
+	_Struct:    "struct",
+	_Switch:    "switch",
+	_Type:      "type",
+	_Var:       "var",
 }
 
 // String returns the string representation of the token.
@@ -160,7 +172,7 @@ func (t Token) String() string {
 //	2: &&
 //	3: == != < <= > >=
 //	4: + - | ^
-//	5: * / % & << >>
+//	5: * / % & << >> &^
 func (t Token) Precedence() int {
 	switch t {
 	case _OrOr:
@@ -171,7 +183,7 @@ func (t Token) Precedence() int {
 		return 3
 	case _Add, _Sub, _Or, _Xor:
 		return 4
-	case _Mul, _Div, _Rem, _And, _Shl, _Shr:
+	case _Mul, _Div, _Rem, _And, _Shl, _Shr, _AndNot:
 		return 5
 	}
 	return 0
@@ -236,6 +248,20 @@ func (t Token) IsRem() bool {
 	return t == _Rem
 }
 
+// IsShift reports whether t is << or >>.
+func (t Token) IsShift() bool {
+	return t == _Shl || t == _Shr
+}
+
+// IsBitwise reports whether t is a bitwise operator: & | ^ &^.
+func (t Token) IsBitwise() bool {
+	switch t {
+	case _And, _Or, _Xor, _AndNot:
+		return true
+	}
+	return false
+}
+
 // IsBreak reports whether t is break.
 func (t Token) IsBreak() bool {
 	return t == _Break
@@ -252,6 +278,7 @@ const (
 	Sub Token = _Sub // -
 	And Token = _And // &
 	Mul Token = _Mul // *
+	Xor Token = _Xor // ^
 )
 
 // LitKind represents the kind of a literal token.
@@ -282,21 +309,26 @@ func (k LitKind) String() string {
 // Note: Pre-declared identifiers (int, float, bool, string, true, false, nil, println)
 // are NOT keywords - they are scanned as _Name and bound in the Universe during Phase 3.
 var keywords = map[string]Token{
-	"break":    _Break,
-	"continue": _Continue,
-	"else":     _Else,
-	"for":      _For,
-	"func":     _Func,
-	"if":       _If,
-	"import":   _Import,
-	"new":      _New,
-	"package":  _Package,
-	"panic":    _Panic,
-	"ref":      _Ref,
-	"return":   _Return,
-	"struct":   _Struct,
-	"type":     _Type,
-	"var":      _Var,
+	"break":     _Break,
+	"case":      _Case,
+	"continue":  _Continue,
+	"default":   _Default,
+	"else":      _Else,
+	"for":       _For,
+	"func":      _Func,
+	"if":        _If,
+	"import":    _Import,
+	"interface": _Interface,
+	"map":       _Map,
+	"new":       _New,
+	"package":   _Package,
+	"panic":     _Panic,
+	"ref":       _Ref,
+	"return":    _Return,
+	"struct":    _Struct,
+	"switch":    _Switch,
+	"type":      _Type,
+	"var":       _Var,
 }
 
 // LookupKeyword returns the token for the given identifier string.
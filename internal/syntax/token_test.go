@@ -39,6 +39,7 @@ func TestTokenString(t *testing.T) {
 		{_And, "&"},
 		{_Shl, "<<"},
 		{_Shr, ">>"},
+		{_AndNot, "&^"},
 		{_Not, "!"},
 
 		// Delimiters
@@ -55,18 +56,23 @@ func TestTokenString(t *testing.T) {
 
 		// Keywords
 		{_Break, "break"},
+		{_Case, "case"},
 		{_Continue, "continue"},
+		{_Default, "default"},
 		{_Else, "else"},
 		{_For, "for"},
 		{_Func, "func"},
 		{_If, "if"},
 		{_Import, "import"},
+		{_Interface, "interface"},
+		{_Map, "map"},
 		{_New, "new"},
 		{_Package, "package"},
 		{_Panic, "panic"},
 		{_Ref, "ref"},
 		{_Return, "return"},
 		{_Struct, "struct"},
+		{_Switch, "switch"},
 		{_Type, "type"},
 		{_Var, "var"},
 	}
@@ -128,6 +134,7 @@ func TestTokenPrecedence(t *testing.T) {
 		{_And, 5},
 		{_Shl, 5},
 		{_Shr, 5},
+		{_AndNot, 5},
 	}
 
 	for _, tt := range tests {
@@ -141,8 +148,9 @@ func TestTokenPrecedence(t *testing.T) {
 
 func TestTokenIsKeyword(t *testing.T) {
 	keywords := []Token{
-		_Break, _Continue, _Else, _For, _Func, _If, _Import,
-		_New, _Package, _Panic, _Ref, _Return, _Struct, _Type, _Var,
+		_Break, _Case, _Continue, _Default, _Else, _For, _Func, _If, _Import,
+		_Interface, _Map, _New, _Package, _Panic, _Ref, _Return, _Struct,
+		_Switch, _Type, _Var,
 	}
 
 	nonKeywords := []Token{
@@ -251,18 +259,23 @@ func TestLookupKeyword(t *testing.T) {
 		want  Token
 	}{
 		{"break", _Break},
+		{"case", _Case},
 		{"continue", _Continue},
+		{"default", _Default},
 		{"else", _Else},
 		{"for", _For},
 		{"func", _Func},
 		{"if", _If},
 		{"import", _Import},
+		{"interface", _Interface},
+		{"map", _Map},
 		{"new", _New},
 		{"package", _Package},
 		{"panic", _Panic},
 		{"ref", _Ref},
 		{"return", _Return},
 		{"struct", _Struct},
+		{"switch", _Switch},
 		{"type", _Type},
 		{"var", _Var},
 	}
@@ -295,8 +308,8 @@ func TestLookupKeywordNonKeyword(t *testing.T) {
 }
 
 func TestKeywordCount(t *testing.T) {
-	// Verify we have exactly 15 keywords
-	expectedCount := 15
+	// Verify we have exactly 20 keywords
+	expectedCount := 20
 	count := 0
 	for tok := _Break; tok <= _Var; tok++ {
 		count++
@@ -47,8 +47,8 @@ func Walk(node Node, v Visitor) {
 		for _, p := range n.Params {
 			Walk(p, v)
 		}
-		if n.Result != nil {
-			Walk(n.Result, v)
+		for _, r := range n.Results {
+			Walk(r, v)
 		}
 		if n.Body != nil {
 			Walk(n.Body, v)
@@ -78,9 +78,26 @@ func Walk(node Node, v Visitor) {
 		}
 		Walk(n.Body, v)
 
+	case *TypeSwitchStmt:
+		if n.Lhs != nil {
+			Walk(n.Lhs, v)
+		}
+		Walk(n.X, v)
+		for _, c := range n.Body {
+			Walk(c, v)
+		}
+
+	case *CaseClause:
+		for _, t := range n.Types {
+			Walk(t, v)
+		}
+		for _, s := range n.Body {
+			Walk(s, v)
+		}
+
 	case *ReturnStmt:
-		if n.Result != nil {
-			Walk(n.Result, v)
+		for _, r := range n.Results {
+			Walk(r, v)
 		}
 
 	case *AssignStmt:
@@ -113,10 +130,25 @@ func Walk(node Node, v Visitor) {
 		Walk(n.X, v)
 		Walk(n.Index, v)
 
+	case *SliceExpr:
+		Walk(n.X, v)
+		if n.Lo != nil {
+			Walk(n.Lo, v)
+		}
+		if n.Hi != nil {
+			Walk(n.Hi, v)
+		}
+
 	case *SelectorExpr:
 		Walk(n.X, v)
 		Walk(n.Sel, v)
 
+	case *AssertExpr:
+		Walk(n.X, v)
+		if n.Type != nil {
+			Walk(n.Type, v)
+		}
+
 	case *ParenExpr:
 		Walk(n.X, v)
 
@@ -139,6 +171,13 @@ func Walk(node Node, v Visitor) {
 		Walk(n.Len, v)
 		Walk(n.Elem, v)
 
+	case *SliceType:
+		Walk(n.Elem, v)
+
+	case *MapType:
+		Walk(n.Key, v)
+		Walk(n.Value, v)
+
 	case *PointerType:
 		Walk(n.Base, v)
 
@@ -150,6 +189,20 @@ func Walk(node Node, v Visitor) {
 			Walk(f, v)
 		}
 
+	case *InterfaceType:
+		for _, m := range n.Methods {
+			Walk(m, v)
+		}
+
+	case *MethodSpec:
+		Walk(n.Name, v)
+		for _, p := range n.Params {
+			Walk(p, v)
+		}
+		for _, r := range n.Results {
+			Walk(r, v)
+		}
+
 	// Leaf nodes: Name, BasicLit, EmptyStmt, BranchStmt
 	// No children to visit
 	}
@@ -3,6 +3,8 @@ package types
 import (
 	"fmt"
 	"strings"
+
+	"github.com/you-not-fish/yoru/internal/rtabi"
 )
 
 // Array represents an array type [N]Elem.
@@ -37,13 +39,74 @@ func (a *Array) String() string {
 	return fmt.Sprintf("[%d]%s", a.len, a.elem)
 }
 
+// Slice represents a slice type []Elem. A slice value is a header
+// { data, len, cap } referring to a GC-managed backing array.
+type Slice struct {
+	typ
+	elem Type
+}
+
+// NewSlice creates a new slice type with the given element type.
+func NewSlice(elem Type) *Slice {
+	return &Slice{elem: elem}
+}
+
+// Elem returns the slice element type.
+func (s *Slice) Elem() Type {
+	return s.elem
+}
+
+// Underlying implements Type.
+func (s *Slice) Underlying() Type {
+	return s
+}
+
+// String implements Type.
+func (s *Slice) String() string {
+	return "[]" + s.elem.String()
+}
+
+// Map represents a map type map[Key]Elem. A map value is a ref to a
+// GC-managed hash table, or nil.
+type Map struct {
+	typ
+	key  Type
+	elem Type
+}
+
+// NewMap creates a new map type with the given key and element types.
+func NewMap(key, elem Type) *Map {
+	return &Map{key: key, elem: elem}
+}
+
+// Key returns the map key type.
+func (m *Map) Key() Type {
+	return m.key
+}
+
+// Elem returns the map element type.
+func (m *Map) Elem() Type {
+	return m.elem
+}
+
+// Underlying implements Type.
+func (m *Map) Underlying() Type {
+	return m
+}
+
+// String implements Type.
+func (m *Map) String() string {
+	return "map[" + m.key.String() + "]" + m.elem.String()
+}
+
 // Struct represents a struct type.
 type Struct struct {
 	typ
-	fields  []*Var  // field declarations
-	size    int64   // computed size (0 if not yet computed)
-	align   int64   // computed alignment (0 if not yet computed)
-	offsets []int64 // field offsets (nil if not yet computed)
+	fields  []*Var        // field declarations
+	target  *rtabi.Target // target of the computed layout (nil if not yet computed)
+	size    int64         // computed size (0 if not yet computed)
+	align   int64         // computed alignment (0 if not yet computed)
+	offsets []int64       // field offsets (nil if not yet computed)
 }
 
 // NewStruct creates a new struct type with the given fields.
@@ -67,39 +130,44 @@ func (s *Struct) Fields() []*Var {
 }
 
 // Size returns the struct size in bytes.
-// Must be called after layout is computed.
+// Must be called after layout is computed; the result is for the target
+// of the most recent layout.
 func (s *Struct) Size() int64 {
 	return s.size
 }
 
 // Align returns the struct alignment in bytes.
-// Must be called after layout is computed.
+// Must be called after layout is computed; the result is for the target
+// of the most recent layout.
 func (s *Struct) Align() int64 {
 	return s.align
 }
 
 // Offset returns the offset of field i in bytes.
-// Must be called after layout is computed.
+// Must be called after layout is computed; the result is for the target
+// of the most recent layout.
 func (s *Struct) Offset(i int) int64 {
 	return s.offsets[i]
 }
 
 // Offsets returns all field offsets.
-// Must be called after layout is computed.
+// Must be called after layout is computed; the result is for the target
+// of the most recent layout.
 func (s *Struct) Offsets() []int64 {
 	return s.offsets
 }
 
-// SetLayout sets the computed layout information.
-func (s *Struct) SetLayout(size, align int64, offsets []int64) {
+// SetLayout sets the layout information computed for target.
+func (s *Struct) SetLayout(target *rtabi.Target, size, align int64, offsets []int64) {
+	s.target = target
 	s.size = size
 	s.align = align
 	s.offsets = offsets
 }
 
-// LayoutDone reports whether layout has been computed.
-func (s *Struct) LayoutDone() bool {
-	return s.offsets != nil
+// LayoutDone reports whether layout has been computed for target.
+func (s *Struct) LayoutDone(target *rtabi.Target) bool {
+	return s.target == target && s.offsets != nil
 }
 
 // Underlying implements Type.
@@ -181,7 +249,7 @@ type Func struct {
 	typ
 	recv   *Var   // receiver (nil for non-method functions)
 	params []*Var // parameters
-	result Type   // return type (nil for void functions)
+	result Type   // return type (nil for void functions, *Tuple for several results)
 }
 
 // NewFunc creates a new function type.
@@ -209,7 +277,8 @@ func (f *Func) Param(i int) *Var {
 	return f.params[i]
 }
 
-// Result returns the result type, or nil for void functions.
+// Result returns the result type, or nil for void functions. A function
+// with several results returns a *Tuple.
 func (f *Func) Result() Type {
 	return f.result
 }
@@ -246,3 +315,50 @@ func (f *Func) String() string {
 	}
 	return buf.String()
 }
+
+// Tuple represents the ordered list of result types of a function with
+// several results, such as (int, bool). A tuple is the type of a call of
+// such a function; it is not a type of variables.
+type Tuple struct {
+	typ
+	types []Type
+}
+
+// NewTuple creates a new tuple type with the given element types.
+func NewTuple(types ...Type) *Tuple {
+	return &Tuple{types: types}
+}
+
+// Len returns the number of elements.
+func (t *Tuple) Len() int {
+	return len(t.types)
+}
+
+// At returns the element type at index i.
+func (t *Tuple) At(i int) Type {
+	return t.types[i]
+}
+
+// Types returns all element types.
+func (t *Tuple) Types() []Type {
+	return t.types
+}
+
+// Underlying implements Type.
+func (t *Tuple) Underlying() Type {
+	return t
+}
+
+// String implements Type.
+func (t *Tuple) String() string {
+	var buf strings.Builder
+	buf.WriteString("(")
+	for i, typ := range t.types {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(typ.String())
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
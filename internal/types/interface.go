@@ -0,0 +1,150 @@
+package types
+
+import (
+	"sort"
+	"strings"
+)
+
+// Interface represents an interface type interface { Methods... }.
+// The methods are sorted by name; a method's index in this order is its
+// slot in the method tables of the interface.
+type Interface struct {
+	typ
+	methods []*FuncObj // methods, sorted by name
+}
+
+// NewInterface creates a new interface type with the given methods.
+// The method signatures have no receiver and may be set later.
+func NewInterface(methods []*FuncObj) *Interface {
+	sorted := make([]*FuncObj, len(methods))
+	copy(sorted, methods)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name() < sorted[j].Name() })
+	return &Interface{methods: sorted}
+}
+
+// NumMethods returns the number of methods.
+func (t *Interface) NumMethods() int {
+	return len(t.methods)
+}
+
+// Method returns the method at index i.
+func (t *Interface) Method(i int) *FuncObj {
+	return t.methods[i]
+}
+
+// Methods returns all methods, sorted by name.
+func (t *Interface) Methods() []*FuncObj {
+	return t.methods
+}
+
+// LookupMethod returns the index of the method with the given name and the
+// method, or -1 and nil if there is none.
+func (t *Interface) LookupMethod(name string) (int, *FuncObj) {
+	for i, m := range t.methods {
+		if m.Name() == name {
+			return i, m
+		}
+	}
+	return -1, nil
+}
+
+// Underlying implements Type.
+func (t *Interface) Underlying() Type {
+	return t
+}
+
+// String implements Type.
+func (t *Interface) String() string {
+	var buf strings.Builder
+	buf.WriteString("interface{")
+	for i, m := range t.methods {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		buf.WriteString(m.Name())
+		if m.sig != nil {
+			buf.WriteString(strings.TrimPrefix(m.sig.String(), "func"))
+		}
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// IsInterface reports whether T is an interface type.
+func IsInterface(T Type) bool {
+	_, ok := T.Underlying().(*Interface)
+	return ok
+}
+
+// MethodSet returns the method of the method set of T with the given name,
+// or nil if there is none. The method set of a named type T holds its
+// methods with receiver T; the method set of *T or ref T also holds the
+// methods with receiver *T. The method set of an interface type is its
+// methods.
+func MethodSet(T Type, name string) *FuncObj {
+	if t, ok := T.Underlying().(*Interface); ok {
+		_, m := t.LookupMethod(name)
+		return m
+	}
+	named, ptr := receiverBase(T)
+	if named == nil {
+		return nil
+	}
+	m := named.LookupMethod(name)
+	if m == nil || (!ptr && HasPointerRecv(m)) {
+		return nil
+	}
+	return m
+}
+
+// MissingMethod returns the first method of the interface T, in method
+// order, that is not in the method set of V, or nil if V implements T.
+// If V has a method of that name that is not in its method set or whose
+// signature differs, have is that method.
+func MissingMethod(V Type, T *Interface) (missing, have *FuncObj) {
+	for _, m := range T.methods {
+		f := MethodSet(V, m.Name())
+		if f == nil {
+			if named, _ := receiverBase(V); named != nil {
+				have = named.LookupMethod(m.Name())
+			}
+			return m, have
+		}
+		if !identicalMethodSigs(f.sig, m.sig) {
+			return m, f
+		}
+	}
+	return nil, nil
+}
+
+// Implements reports whether a value of type V implements the interface T.
+func Implements(V Type, T *Interface) bool {
+	missing, _ := MissingMethod(V, T)
+	return missing == nil
+}
+
+// receiverBase returns the named type whose methods a value of type T may
+// have, and whether T is a pointer or ref to it.
+func receiverBase(T Type) (*Named, bool) {
+	ptr := false
+	switch t := T.(type) {
+	case *Pointer:
+		T, ptr = t.base, true
+	case *Ref:
+		T, ptr = t.base, true
+	}
+	named, ok := T.(*Named)
+	if !ok || IsInterface(named) {
+		return nil, false
+	}
+	return named, ptr
+}
+
+// HasPointerRecv reports whether the method m has a pointer receiver.
+func HasPointerRecv(m *FuncObj) bool {
+	if m.sig == nil || m.sig.recv == nil {
+		return false
+	}
+	_, ok := m.sig.recv.Type().Underlying().(*Pointer)
+	return ok
+}
@@ -38,6 +38,10 @@ func (n *Named) Underlying() Type {
 // String implements Type.
 func (n *Named) String() string {
 	if n.obj != nil {
+		// Types of imported packages are qualified by the package name.
+		if pkg := n.obj.Pkg(); pkg != nil && pkg.Path() != "" && pkg.Path() != "main" {
+			return pkg.Name() + "." + n.obj.Name()
+		}
 		return n.obj.Name()
 	}
 	return "unnamed"
@@ -1,13 +1,25 @@
 package types
 
-import "github.com/you-not-fish/yoru/internal/syntax"
+import (
+	"unicode"
+	"unicode/utf8"
 
-// Object represents a declared entity: variable, type, function, builtin, or nil.
+	"github.com/you-not-fish/yoru/internal/syntax"
+)
+
+// Object represents a declared entity: variable, type, function, builtin,
+// nil, or imported package name.
 type Object interface {
 	Name() string    // object name
 	Type() Type      // object type
 	Pos() syntax.Pos // declaration position
 	Parent() *Scope  // enclosing scope
+	Pkg() *Package   // declaring package (nil for universe objects and locals)
+	Exported() bool  // whether the name starts with an upper-case letter
+
+	// SetPkg sets the declaring package. It is called by the type checker
+	// for package-level objects, struct fields and methods.
+	SetPkg(*Package)
 
 	setParent(*Scope) // internal: set parent scope
 	aObject()         // marker method to restrict implementations
@@ -19,15 +31,26 @@ type object struct {
 	typ    Type
 	pos    syntax.Pos
 	parent *Scope
+	pkg    *Package
 }
 
 func (o *object) Name() string       { return o.name }
 func (o *object) Type() Type         { return o.typ }
 func (o *object) Pos() syntax.Pos    { return o.pos }
 func (o *object) Parent() *Scope     { return o.parent }
+func (o *object) Pkg() *Package      { return o.pkg }
+func (o *object) Exported() bool     { return IsExported(o.name) }
+func (o *object) SetPkg(p *Package)  { o.pkg = p }
 func (o *object) setParent(s *Scope) { o.parent = s }
 func (*object) aObject()             {}
 
+// IsExported reports whether name starts with an upper-case letter.
+// Only exported names are visible to importing packages.
+func IsExported(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
+
 // Var represents a variable or struct field.
 type Var struct {
 	object
@@ -102,6 +125,12 @@ const (
 	BuiltinPrintln BuiltinKind = iota
 	BuiltinNew
 	BuiltinPanic
+	BuiltinLen
+	BuiltinCap
+	BuiltinAppend
+	BuiltinCopy
+	BuiltinMake
+	BuiltinDelete
 )
 
 // Builtin represents a built-in function.
@@ -129,3 +158,19 @@ type Nil struct {
 func NewNil() *Nil {
 	return &Nil{object: object{name: "nil", typ: Typ[UntypedNil]}}
 }
+
+// PkgName represents an imported package name in a file scope.
+type PkgName struct {
+	object
+	imported *Package
+}
+
+// NewPkgName creates a new package name object for an import declaration.
+func NewPkgName(pos syntax.Pos, name string, imported *Package) *PkgName {
+	return &PkgName{object: object{name: name, pos: pos}, imported: imported}
+}
+
+// Imported returns the imported package.
+func (p *PkgName) Imported() *Package {
+	return p.imported
+}
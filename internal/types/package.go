@@ -3,7 +3,7 @@ package types
 // Package represents a Yoru package.
 type Package struct {
 	name  string // package name (e.g., "main")
-	path  string // import path (for future use)
+	path  string // import path (used for symbol mangling)
 	scope *Scope // package-level scope
 }
 
@@ -26,6 +26,7 @@ func (p *Package) Path() string {
 }
 
 // SetPath sets the package import path.
+// The path is "main" for the program's main package.
 func (p *Package) SetPath(path string) {
 	p.path = path
 }
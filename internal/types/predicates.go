@@ -34,6 +34,14 @@ func identical(x, y Type) bool {
 		if y, ok := y.(*Array); ok {
 			return x.len == y.len && Identical(x.elem, y.elem)
 		}
+	case *Slice:
+		if y, ok := y.(*Slice); ok {
+			return Identical(x.elem, y.elem)
+		}
+	case *Map:
+		if y, ok := y.(*Map); ok {
+			return Identical(x.key, y.key) && Identical(x.elem, y.elem)
+		}
 	case *Struct:
 		if y, ok := y.(*Struct); ok {
 			return identicalStructs(x, y)
@@ -50,6 +58,14 @@ func identical(x, y Type) bool {
 		if y, ok := y.(*Func); ok {
 			return identicalFuncs(x, y)
 		}
+	case *Tuple:
+		if y, ok := y.(*Tuple); ok {
+			return identicalTuples(x, y)
+		}
+	case *Interface:
+		if y, ok := y.(*Interface); ok {
+			return identicalInterfaces(x, y)
+		}
 	}
 	return false
 }
@@ -69,6 +85,42 @@ func identicalStructs(x, y *Struct) bool {
 	return true
 }
 
+func identicalTuples(x, y *Tuple) bool {
+	if len(x.types) != len(y.types) {
+		return false
+	}
+	for i := range x.types {
+		if !Identical(x.types[i], y.types[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func identicalInterfaces(x, y *Interface) bool {
+	if len(x.methods) != len(y.methods) {
+		return false
+	}
+	for i := range x.methods {
+		if x.methods[i].Name() != y.methods[i].Name() {
+			return false
+		}
+		if !identicalMethodSigs(x.methods[i].sig, y.methods[i].sig) {
+			return false
+		}
+	}
+	return true
+}
+
+// identicalMethodSigs reports whether two method signatures have identical
+// parameter and result types, ignoring their receivers.
+func identicalMethodSigs(x, y *Func) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return identicalFuncs(NewFunc(nil, x.params, x.result), NewFunc(nil, y.params, y.result))
+}
+
 func identicalFuncs(x, y *Func) bool {
 	// Check receivers
 	if (x.recv == nil) != (y.recv == nil) {
@@ -106,18 +158,20 @@ func AssignableTo(V, T Type) bool {
 		return true
 	}
 
-	// Untyped nil is assignable to any pointer or ref type
+	// Untyped nil is assignable to any pointer, ref, slice or interface type
 	if isUntyped(V) {
 		Vb, ok := V.(*Basic)
 		if ok && Vb.kind == UntypedNil {
-			Tu := T.Underlying()
-			switch Tu.(type) {
-			case *Pointer, *Ref:
-				return true
-			}
+			return IsNilable(T)
 		}
 	}
 
+	// A value is assignable to an interface type it implements; an
+	// untyped constant converts to its default type first
+	if Ti, ok := T.Underlying().(*Interface); ok && !IsNil(V) {
+		return Implements(DefaultType(V), Ti)
+	}
+
 	// Untyped constants can be assigned to compatible concrete types
 	if isUntyped(V) {
 		return isRepresentableAs(V, T)
@@ -212,6 +266,39 @@ func IsPointerOrRef(T Type) bool {
 	return IsPointer(T) || IsRef(T)
 }
 
+// IsNilable reports whether nil is a value of type T.
+func IsNilable(T Type) bool {
+	switch T.Underlying().(type) {
+	case *Pointer, *Ref, *Slice, *Map, *Interface:
+		return true
+	}
+	return false
+}
+
+// IsSlice reports whether T is a slice type.
+func IsSlice(T Type) bool {
+	_, ok := T.Underlying().(*Slice)
+	return ok
+}
+
+// IsMap reports whether T is a map type.
+func IsMap(T Type) bool {
+	_, ok := T.Underlying().(*Map)
+	return ok
+}
+
+// IsMapKey reports whether T may be the key type of a map: the runtime
+// hash table hashes int, bool, string and ref keys.
+func IsMapKey(T Type) bool {
+	switch t := T.Underlying().(type) {
+	case *Basic:
+		return t.kind == Int || t.kind == Bool || t.kind == String
+	case *Ref:
+		return true
+	}
+	return false
+}
+
 // IsNil reports whether T is the untyped nil type.
 func IsNil(T Type) bool {
 	b, ok := T.(*Basic)
@@ -260,7 +347,8 @@ func Comparable(T Type) bool {
 		}
 		return true
 	default:
-		// Functions are not comparable
+		// Functions, slices and maps are not comparable; a slice or map
+		// may only be compared to nil
 		return false
 	}
 }
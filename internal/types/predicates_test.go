@@ -87,6 +87,29 @@ func TestIdenticalFunc(t *testing.T) {
 	}
 }
 
+func TestIdenticalTuple(t *testing.T) {
+	t1 := NewTuple(Typ[Int], Typ[Bool])
+	t2 := NewTuple(Typ[Int], Typ[Bool])
+	t3 := NewTuple(Typ[Bool], Typ[Int])
+	t4 := NewTuple(Typ[Int], Typ[Bool], Typ[Int])
+
+	if !Identical(t1, t2) {
+		t.Error("Tuples with the same element types should be identical")
+	}
+	if Identical(t1, t3) || Identical(t1, t4) {
+		t.Error("Tuples with different element types should not be identical")
+	}
+
+	f1 := NewFunc(nil, nil, t1)
+	f2 := NewFunc(nil, nil, t3)
+	if Identical(f1, f2) {
+		t.Error("Functions with different result lists should not be identical")
+	}
+	if got := f1.String(); got != "func() (int, bool)" {
+		t.Errorf("String() = %q, want %q", got, "func() (int, bool)")
+	}
+}
+
 func TestIdenticalNamed(t *testing.T) {
 	// Named types are identical only if they refer to same TypeName
 	obj1 := NewTypeName(syntax.Pos{}, "T", nil)
@@ -104,6 +127,75 @@ func TestIdenticalNamed(t *testing.T) {
 	}
 }
 
+// method returns a method named name with the given receiver and result.
+func method(name string, recv Type, result Type) *FuncObj {
+	m := NewFuncObj(syntax.Pos{}, name)
+	var r *Var
+	if recv != nil {
+		r = NewVar(syntax.Pos{}, "r", recv)
+	}
+	m.SetSignature(NewFunc(r, nil, result))
+	return m
+}
+
+func TestIdenticalInterface(t *testing.T) {
+	i1 := NewInterface([]*FuncObj{method("B", nil, Typ[Int]), method("A", nil, nil)})
+	i2 := NewInterface([]*FuncObj{method("A", nil, nil), method("B", nil, Typ[Int])})
+	i3 := NewInterface([]*FuncObj{method("A", nil, nil), method("B", nil, Typ[Bool])})
+	i4 := NewInterface([]*FuncObj{method("A", nil, nil)})
+
+	if !Identical(i1, i2) {
+		t.Error("Interfaces with the same methods should be identical")
+	}
+	if Identical(i1, i3) || Identical(i1, i4) {
+		t.Error("Interfaces with different methods should not be identical")
+	}
+	if got := i1.String(); got != "interface{A(); B() int}" {
+		t.Errorf("String() = %q, want %q", got, "interface{A(); B() int}")
+	}
+}
+
+func TestImplements(t *testing.T) {
+	// T has Get with receiver T and Set with receiver *T.
+	T := NewNamed(NewTypeName(syntax.Pos{}, "T", nil), NewStruct(nil))
+	T.AddMethod(method("Get", T, Typ[Int]))
+	T.AddMethod(method("Set", NewPointer(T), nil))
+
+	getter := NewInterface([]*FuncObj{method("Get", nil, Typ[Int])})
+	both := NewInterface([]*FuncObj{method("Get", nil, Typ[Int]), method("Set", nil, nil)})
+	wrong := NewInterface([]*FuncObj{method("Get", nil, Typ[Bool])})
+	empty := NewInterface(nil)
+
+	tests := []struct {
+		V     Type
+		T     *Interface
+		want  bool
+		descr string
+	}{
+		{T, getter, true, "T has value methods"},
+		{T, both, false, "T lacks pointer methods"},
+		{NewPointer(T), both, true, "*T has all methods"},
+		{NewRef(T), both, true, "ref T has all methods"},
+		{T, wrong, false, "signatures must match"},
+		{Typ[Int], getter, false, "int has no methods"},
+		{Typ[Int], empty, true, "any type implements interface{}"},
+		{both, getter, true, "interfaces have their own methods"},
+	}
+	for _, tt := range tests {
+		if got := Implements(tt.V, tt.T); got != tt.want {
+			t.Errorf("%s: Implements(%s, %s) = %v, want %v", tt.descr, tt.V, tt.T, got, tt.want)
+		}
+	}
+
+	missing, have := MissingMethod(T, both)
+	if missing == nil || missing.Name() != "Set" || have == nil || !HasPointerRecv(have) {
+		t.Errorf("MissingMethod(T, %s) = %v, %v, want Set and the pointer method", both, missing, have)
+	}
+	if !AssignableTo(Typ[UntypedNil], both) || AssignableTo(T, both) || !AssignableTo(NewRef(T), both) {
+		t.Error("AssignableTo does not follow the method sets of interfaces")
+	}
+}
+
 func TestAssignableTo(t *testing.T) {
 	tests := []struct {
 		name string
@@ -121,6 +213,13 @@ func TestAssignableTo(t *testing.T) {
 		{"untyped nil to ptr", Typ[UntypedNil], NewPointer(Typ[Int]), true},
 		{"untyped nil to ref", Typ[UntypedNil], NewRef(Typ[Int]), true},
 		{"untyped nil to int", Typ[UntypedNil], Typ[Int], false},
+		{"untyped nil to slice", Typ[UntypedNil], NewSlice(Typ[Int]), true},
+		{"slice to same slice", NewSlice(Typ[Int]), NewSlice(Typ[Int]), true},
+		{"slice to other slice", NewSlice(Typ[Int]), NewSlice(Typ[Float]), false},
+		{"array to slice", NewArray(2, Typ[Int]), NewSlice(Typ[Int]), false},
+		{"untyped nil to map", Typ[UntypedNil], NewMap(Typ[Int], Typ[Int]), true},
+		{"map to same map", NewMap(Typ[String], Typ[Int]), NewMap(Typ[String], Typ[Int]), true},
+		{"map to other map", NewMap(Typ[String], Typ[Int]), NewMap(Typ[Int], Typ[Int]), false},
 	}
 
 	for _, tt := range tests {
@@ -216,6 +315,31 @@ func TestIsNil(t *testing.T) {
 	}
 }
 
+func TestIsMapKey(t *testing.T) {
+	tests := []struct {
+		typ  Type
+		want bool
+	}{
+		{Typ[Int], true},
+		{Typ[Bool], true},
+		{Typ[String], true},
+		{NewRef(Typ[Int]), true},
+		{Typ[Float], false},
+		{NewPointer(Typ[Int]), false},
+		{NewArray(2, Typ[Int]), false},
+		{NewSlice(Typ[Int]), false},
+		{NewInterface(nil), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ.String(), func(t *testing.T) {
+			if got := IsMapKey(tt.typ); got != tt.want {
+				t.Errorf("IsMapKey(%s) = %v, want %v", tt.typ, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestIsUntypedType(t *testing.T) {
 	tests := []struct {
 		typ  Type
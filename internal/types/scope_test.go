@@ -205,7 +205,7 @@ func TestUniverse(t *testing.T) {
 	}
 
 	// Check predeclared builtins
-	for _, name := range []string{"println", "new", "panic"} {
+	for _, name := range []string{"println", "new", "panic", "len", "cap", "append", "copy", "make", "delete"} {
 		obj := Universe.Lookup(name)
 		if obj == nil {
 			t.Errorf("Universe.Lookup(%q) = nil", name)
@@ -3,11 +3,28 @@ package types
 import "github.com/you-not-fish/yoru/internal/rtabi"
 
 // Sizes provides size and alignment calculations for types.
-// It uses the rtabi constants to ensure ABI consistency with the runtime.
-type Sizes struct{}
+// It uses the rtabi target description to ensure ABI consistency with the
+// runtime and the LLVM data layout.
+type Sizes struct {
+	target *rtabi.Target
+}
+
+// NewSizes returns a Sizes for the given target.
+func NewSizes(target *rtabi.Target) *Sizes {
+	return &Sizes{target: target}
+}
 
-// DefaultSizes is the default Sizes implementation.
-var DefaultSizes = &Sizes{}
+// DefaultSizes is the Sizes for the host target.
+var DefaultSizes = NewSizes(rtabi.HostTarget())
+
+// Target returns the target the sizes are computed for.
+// The zero Sizes uses the host target, like DefaultSizes.
+func (s *Sizes) Target() *rtabi.Target {
+	if s.target == nil {
+		return rtabi.HostTarget()
+	}
+	return s.target
+}
 
 // Sizeof returns the size of type T in bytes.
 func (s *Sizes) Sizeof(T Type) int64 {
@@ -19,10 +36,16 @@ func (s *Sizes) Sizeof(T Type) int64 {
 	case *Struct:
 		s.ComputeLayout(t)
 		return t.Size()
-	case *Pointer, *Ref:
-		return rtabi.SizePtr
-	case *Func:
-		return rtabi.SizePtr
+	case *Tuple:
+		size, _, _ := s.layout(t.types)
+		return size
+	case *Pointer, *Ref, *Func, *Map:
+		return s.Target().PtrSize
+	case *Interface:
+		return 2 * s.Target().PtrSize
+	case *Slice:
+		// { ptr, i64, i64 }
+		return align(s.Target().PtrSize, s.Target().IntAlign) + 2*rtabi.SizeInt
 	case *Named:
 		return s.Sizeof(t.Underlying())
 	}
@@ -42,10 +65,13 @@ func (s *Sizes) Alignof(T Type) int64 {
 	case *Struct:
 		s.ComputeLayout(t)
 		return t.Align()
-	case *Pointer, *Ref:
-		return rtabi.AlignPtr
-	case *Func:
-		return rtabi.AlignPtr
+	case *Tuple:
+		_, alignment, _ := s.layout(t.types)
+		return alignment
+	case *Pointer, *Ref, *Func, *Interface, *Map:
+		return s.Target().PtrAlign
+	case *Slice:
+		return max(s.Target().PtrAlign, s.Target().IntAlign)
 	case *Named:
 		return s.Alignof(t.Underlying())
 	}
@@ -58,20 +84,64 @@ func (s *Sizes) Offsetof(T *Struct, i int) int64 {
 	return T.Offset(i)
 }
 
+// RefOffsets returns the byte offsets of the GC-managed ref slots in a
+// value of type T, in increasing order. Refs inside nested structs and
+// arrays are included, as are the data pointers of interface and slice
+// values and maps; raw pointers and method tables are not traced and are
+// omitted.
+func (s *Sizes) RefOffsets(T Type) []int64 {
+	return s.refOffsets(T, 0, nil)
+}
+
+func (s *Sizes) refOffsets(T Type, base int64, offs []int64) []int64 {
+	switch t := T.Underlying().(type) {
+	case *Ref, *Slice, *Map:
+		offs = append(offs, base)
+	case *Interface:
+		offs = append(offs, base+s.Target().PtrSize)
+	case *Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			offs = s.refOffsets(t.Field(i).Type(), base+s.Offsetof(t, i), offs)
+		}
+	case *Tuple:
+		_, _, elemOffs := s.layout(t.types)
+		for i, elem := range t.types {
+			offs = s.refOffsets(elem, base+elemOffs[i], offs)
+		}
+	case *Array:
+		elemSize := s.Sizeof(t.Elem())
+		for i := int64(0); i < t.Len(); i++ {
+			offs = s.refOffsets(t.Elem(), base+i*elemSize, offs)
+		}
+	}
+	return offs
+}
+
 // ComputeLayout computes the size, alignment, and field offsets for a struct.
-// This function is idempotent and safe to call multiple times.
+// This function is idempotent and safe to call multiple times; a layout
+// computed for another target is recomputed.
 func (s *Sizes) ComputeLayout(st *Struct) {
-	if st.LayoutDone() {
+	if st.LayoutDone(s.Target()) {
 		return
 	}
+	fieldTypes := make([]Type, len(st.fields))
+	for i, f := range st.fields {
+		fieldTypes[i] = f.Type()
+	}
+	size, alignment, offsets := s.layout(fieldTypes)
+	st.SetLayout(s.Target(), size, alignment, offsets)
+}
 
+// layout returns the size, alignment and field offsets of a struct with
+// fields of the given types. Tuples are laid out the same way.
+func (s *Sizes) layout(fieldTypes []Type) (int64, int64, []int64) {
 	var offset int64
 	var maxAlign int64 = 1
-	offsets := make([]int64, len(st.fields))
+	offsets := make([]int64, len(fieldTypes))
 
-	for i, f := range st.fields {
-		fieldSize := s.Sizeof(f.Type())
-		fieldAlign := s.Alignof(f.Type())
+	for i, ft := range fieldTypes {
+		fieldSize := s.Sizeof(ft)
+		fieldAlign := s.Alignof(ft)
 
 		// Align offset to field alignment
 		offset = align(offset, fieldAlign)
@@ -84,9 +154,7 @@ func (s *Sizes) ComputeLayout(st *Struct) {
 	}
 
 	// Add padding at end for struct alignment
-	size := align(offset, maxAlign)
-
-	st.SetLayout(size, maxAlign, offsets)
+	return align(offset, maxAlign), maxAlign, offsets
 }
 
 // basicSize returns the size of a basic type in bytes.
@@ -99,7 +167,8 @@ func (s *Sizes) basicSize(kind BasicKind) int64 {
 	case Float:
 		return rtabi.SizeFloat
 	case String:
-		return rtabi.SizeString
+		// { ptr, i64 }
+		return align(s.Target().PtrSize, s.Target().IntAlign) + rtabi.SizeInt
 	default:
 		// Untyped types have no concrete size
 		return 0
@@ -112,11 +181,11 @@ func (s *Sizes) basicAlign(kind BasicKind) int64 {
 	case Bool:
 		return rtabi.AlignBool
 	case Int:
-		return rtabi.AlignInt
+		return s.Target().IntAlign
 	case Float:
-		return rtabi.AlignFloat
+		return s.Target().FloatAlign
 	case String:
-		return rtabi.AlignString
+		return max(s.Target().PtrAlign, s.Target().IntAlign)
 	default:
 		// Untyped types have no concrete alignment
 		return 1
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/you-not-fish/yoru/internal/rtabi"
@@ -221,6 +222,99 @@ func TestNestedStructLayout(t *testing.T) {
 	}
 }
 
+func TestRefOffsets(t *testing.T) {
+	sizes := DefaultSizes
+
+	// type Node struct { val int; next ref Node }
+	node := NewNamed(NewTypeName(syntax.Pos{}, "Node", nil), nil)
+	node.SetUnderlying(NewStruct([]*Var{
+		NewField(syntax.Pos{}, "val", Typ[Int]),
+		NewField(syntax.Pos{}, "next", NewRef(node)),
+	}))
+
+	// struct { flag bool; left ref Node; p *int; inner Node; arr [2]Node; s string }
+	outer := NewStruct([]*Var{
+		NewField(syntax.Pos{}, "flag", Typ[Bool]),
+		NewField(syntax.Pos{}, "left", NewRef(node)),
+		NewField(syntax.Pos{}, "p", NewPointer(Typ[Int])),
+		NewField(syntax.Pos{}, "inner", node),
+		NewField(syntax.Pos{}, "arr", NewArray(2, node)),
+		NewField(syntax.Pos{}, "s", Typ[String]),
+	})
+
+	tests := []struct {
+		typ  Type
+		want []int64
+	}{
+		{Typ[Int], nil},
+		{NewPointer(Typ[Int]), nil},
+		{NewRef(Typ[Int]), []int64{0}},
+		{node, []int64{8}},
+		{NewArray(3, NewRef(node)), []int64{0, 8, 16}},
+		{outer, []int64{8, 32, 48, 64}},
+		{NewTuple(Typ[Bool], NewRef(node), Typ[String]), []int64{8}},
+		{NewInterface(nil), []int64{8}},
+		{NewSlice(Typ[Int]), []int64{0}},
+		{NewArray(2, NewSlice(Typ[Int])), []int64{0, 24}},
+		{NewMap(Typ[String], Typ[Int]), []int64{0}},
+	}
+	for _, tt := range tests {
+		got := sizes.RefOffsets(tt.typ)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("RefOffsets(%s) = %v, want %v", tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestTupleLayout(t *testing.T) {
+	sizes := DefaultSizes
+
+	// (bool, int, bool) is laid out like struct { a bool; b int; c bool }
+	tuple := NewTuple(Typ[Bool], Typ[Int], Typ[Bool])
+	if got := sizes.Sizeof(tuple); got != 24 {
+		t.Errorf("Sizeof(%s) = %d, want 24", tuple, got)
+	}
+	if got := sizes.Alignof(tuple); got != 8 {
+		t.Errorf("Alignof(%s) = %d, want 8", tuple, got)
+	}
+	if got := sizes.Sizeof(NewTuple(Typ[Bool], Typ[Bool])); got != 2 {
+		t.Errorf("Sizeof((bool, bool)) = %d, want 2", got)
+	}
+}
+
+func TestInterfaceLayout(t *testing.T) {
+	// An interface is an itable pointer and a data pointer.
+	iface := NewInterface(nil)
+	if got := DefaultSizes.Sizeof(iface); got != 16 {
+		t.Errorf("Sizeof(%s) = %d, want 16", iface, got)
+	}
+	if got := DefaultSizes.Alignof(iface); got != 8 {
+		t.Errorf("Alignof(%s) = %d, want 8", iface, got)
+	}
+}
+
+func TestSliceLayout(t *testing.T) {
+	// A slice is a data pointer, a length and a capacity.
+	slice := NewSlice(Typ[Int])
+	if got := DefaultSizes.Sizeof(slice); got != 24 {
+		t.Errorf("Sizeof(%s) = %d, want 24", slice, got)
+	}
+	if got := DefaultSizes.Alignof(slice); got != 8 {
+		t.Errorf("Alignof(%s) = %d, want 8", slice, got)
+	}
+}
+
+func TestMapLayout(t *testing.T) {
+	// A map is a single pointer to the runtime hash table.
+	m := NewMap(Typ[String], NewSlice(Typ[Int]))
+	if got := DefaultSizes.Sizeof(m); got != 8 {
+		t.Errorf("Sizeof(%s) = %d, want 8", m, got)
+	}
+	if got := DefaultSizes.Alignof(m); got != 8 {
+		t.Errorf("Alignof(%s) = %d, want 8", m, got)
+	}
+}
+
 func TestStringSize(t *testing.T) {
 	// String is a special type: ptr + len = 16 bytes
 	size := DefaultSizes.Sizeof(Typ[String])
@@ -228,3 +322,53 @@ func TestStringSize(t *testing.T) {
 		t.Errorf("Sizeof(string) = %d, want %d", size, rtabi.SizeString)
 	}
 }
+
+func TestSizesTarget(t *testing.T) {
+	for _, target := range rtabi.Targets {
+		sizes := NewSizes(target)
+		if sizes.Target() != target {
+			t.Errorf("%s: Target() = %s", target, sizes.Target())
+		}
+		if got := sizes.Sizeof(NewRef(Typ[Int])); got != target.PtrSize {
+			t.Errorf("%s: Sizeof(ref int) = %d, want %d", target, got, target.PtrSize)
+		}
+		if got := sizes.Sizeof(Typ[String]); got != rtabi.SizeString {
+			t.Errorf("%s: Sizeof(string) = %d, want %d", target, got, rtabi.SizeString)
+		}
+	}
+
+	// The zero Sizes lays out types for the host, like DefaultSizes.
+	var zero Sizes
+	if zero.Target() != DefaultSizes.Target() {
+		t.Errorf("zero Sizes target = %s, want %s", zero.Target(), DefaultSizes.Target())
+	}
+}
+
+func TestStructLayoutPerTarget(t *testing.T) {
+	// A target aligning int64 to 4 bytes, as on 32-bit x86.
+	packed := *rtabi.TargetLinuxAMD64
+	packed.IntAlign = 4
+
+	st := NewStruct([]*Var{
+		NewField(syntax.Pos{}, "a", Typ[Bool]),
+		NewField(syntax.Pos{}, "b", Typ[Int]),
+	})
+
+	// Reusing the type with a second target must not return the layout
+	// computed for the first.
+	for _, tt := range []struct {
+		sizes        *Sizes
+		offset, size int64
+	}{
+		{NewSizes(rtabi.TargetLinuxAMD64), 8, 16},
+		{NewSizes(&packed), 4, 12},
+		{NewSizes(rtabi.TargetLinuxAMD64), 8, 16},
+	} {
+		if got := tt.sizes.Offsetof(st, 1); got != tt.offset {
+			t.Errorf("%s: Offsetof(b) = %d, want %d", tt.sizes.Target(), got, tt.offset)
+		}
+		if got := tt.sizes.Sizeof(st); got != tt.size {
+			t.Errorf("%s: Sizeof = %d, want %d", tt.sizes.Target(), got, tt.size)
+		}
+	}
+}
@@ -25,6 +25,12 @@ var (
 	universePrintln *Builtin
 	universeNew     *Builtin
 	universePanic   *Builtin
+	universeLen     *Builtin
+	universeCap     *Builtin
+	universeAppend  *Builtin
+	universeCopy    *Builtin
+	universeMake    *Builtin
+	universeDelete  *Builtin
 )
 
 func init() {
@@ -75,7 +81,8 @@ func defPredeclaredConsts() {
 	Universe.Insert(universeNil)
 }
 
-// defPredeclaredBuiltins defines println, new, panic in Universe.
+// defPredeclaredBuiltins defines println, new, panic, len, cap, append,
+// copy and make in Universe.
 func defPredeclaredBuiltins() {
 	universePrintln = NewBuiltin("println", BuiltinPrintln)
 	Universe.Insert(universePrintln)
@@ -85,6 +92,24 @@ func defPredeclaredBuiltins() {
 
 	universePanic = NewBuiltin("panic", BuiltinPanic)
 	Universe.Insert(universePanic)
+
+	universeLen = NewBuiltin("len", BuiltinLen)
+	Universe.Insert(universeLen)
+
+	universeCap = NewBuiltin("cap", BuiltinCap)
+	Universe.Insert(universeCap)
+
+	universeAppend = NewBuiltin("append", BuiltinAppend)
+	Universe.Insert(universeAppend)
+
+	universeCopy = NewBuiltin("copy", BuiltinCopy)
+	Universe.Insert(universeCopy)
+
+	universeMake = NewBuiltin("make", BuiltinMake)
+	Universe.Insert(universeMake)
+
+	universeDelete = NewBuiltin("delete", BuiltinDelete)
+	Universe.Insert(universeDelete)
 }
 
 // Predeclared type accessors
@@ -102,3 +127,9 @@ func UniverseNil() *Nil     { return universeNil }
 func UniversePrintln() *Builtin { return universePrintln }
 func UniverseNew() *Builtin     { return universeNew }
 func UniversePanic() *Builtin   { return universePanic }
+func UniverseLen() *Builtin     { return universeLen }
+func UniverseCap() *Builtin     { return universeCap }
+func UniverseAppend() *Builtin  { return universeAppend }
+func UniverseCopy() *Builtin    { return universeCopy }
+func UniverseMake() *Builtin    { return universeMake }
+func UniverseDelete() *Builtin  { return universeDelete }
@@ -16,6 +16,19 @@ type Config struct {
 	// Sizes provides type size and alignment information.
 	// If nil, DefaultSizes is used.
 	Sizes *types.Sizes
+
+	// Importer resolves import paths to type-checked packages.
+	// If nil, import declarations are reported as errors.
+	Importer Importer
+}
+
+// Importer resolves import paths to packages.
+//
+// Import is called while collecting declarations, so the imported package
+// (and, transitively, its own imports) is fully type-checked before the
+// importing package's declarations are checked.
+type Importer interface {
+	Import(path string) (*types.Package, error)
 }
 
 // Info holds the results of type checking.
@@ -35,8 +48,27 @@ type Info struct {
 	Uses map[*syntax.Name]types.Object
 
 	// Scopes maps AST nodes to their scopes.
-	// This includes File, FuncDecl, BlockStmt, IfStmt, and ForStmt.
+	// This includes File, FuncDecl, BlockStmt, IfStmt, ForStmt and
+	// CaseClause.
 	Scopes map[syntax.Node]*types.Scope
+
+	// Implicits maps nodes to their implicitly declared objects. For a
+	// type switch declaring a variable, it maps each CaseClause to the
+	// variable of that clause.
+	Implicits map[syntax.Node]types.Object
+
+	// InitOrder lists the package-level variables with initializers in
+	// the order they must be initialized: every variable comes after the
+	// variables its initializer depends on, directly or through function
+	// calls. When several packages are checked with the same Info, their
+	// initializers are appended in checking order.
+	InitOrder []*Initializer
+}
+
+// Initializer is a package-level variable and its initialization expression.
+type Initializer struct {
+	Lhs *types.Var
+	Rhs syntax.Expr
 }
 
 // TypeAndValue holds the type and value information for an expression.
@@ -73,12 +105,21 @@ func (tv TypeAndValue) IsAddressable() bool {
 
 // IsValue reports whether the expression has a value.
 func (tv TypeAndValue) IsValue() bool {
-	return tv.mode == constant_ || tv.mode == variable || tv.mode == value
+	return tv.mode == constant_ || tv.mode == variable || tv.mode == value || tv.mode == mapindex
 }
 
 // Check type-checks a parsed file.
 // It returns the package for the file and the first error encountered, if any.
 func Check(filename string, file *syntax.File, conf *Config, info *Info) (*types.Package, error) {
+	return CheckFiles([]*syntax.File{file}, conf, info)
+}
+
+// CheckFiles type-checks the files of a single package.
+// All top-level declarations share one package scope, so types, functions
+// and methods may be declared in any of the files. Diagnostics carry the
+// position (and thus the filename) of the offending node.
+// It returns the package and the first error encountered, if any.
+func CheckFiles(files []*syntax.File, conf *Config, info *Info) (*types.Package, error) {
 	if conf == nil {
 		conf = &Config{}
 	}
@@ -100,15 +141,23 @@ func Check(filename string, file *syntax.File, conf *Config, info *Info) (*types
 		if info.Scopes == nil {
 			info.Scopes = make(map[syntax.Node]*types.Scope)
 		}
+		if info.Implicits == nil {
+			info.Implicits = make(map[syntax.Node]types.Object)
+		}
 	}
 
 	c := &Checker{
-		conf:      conf,
-		info:      info,
-		funcDecls: make(map[*syntax.FuncDecl]*types.FuncObj),
+		conf:       conf,
+		info:       info,
+		funcDecls:  make(map[*syntax.FuncDecl]*types.FuncObj),
+		declScopes: make(map[syntax.Decl]*types.Scope),
+		badImports: make(map[*types.Package]bool),
+		varDecls:   make(map[*types.Var]*syntax.VarDecl),
+		varChecked: make(map[*types.Var]bool),
+		objDeps:    make(map[types.Object][]types.Object),
 	}
 
-	c.checkFile(file)
+	c.checkFiles(files)
 
 	if c.errors > 0 {
 		return c.pkg, c.first
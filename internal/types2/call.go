@@ -1,14 +1,16 @@
 package types2
 
 import (
+	"go/constant"
+
 	"github.com/you-not-fish/yoru/internal/syntax"
 	"github.com/you-not-fish/yoru/internal/types"
 )
 
 // call checks a function call expression.
 func (c *Checker) call(x *operand, e *syntax.CallExpr) {
-	// Check if this is a method call
-	if sel, ok := e.Fun.(*syntax.SelectorExpr); ok {
+	// Check if this is a method call (pkg.F is a qualified function)
+	if sel, ok := e.Fun.(*syntax.SelectorExpr); ok && !c.isQualified(sel) {
 		c.methodCall(x, e, sel)
 		return
 	}
@@ -78,6 +80,12 @@ func (c *Checker) methodCall(x *operand, e *syntax.CallExpr, sel *syntax.Selecto
 		return
 	}
 
+	if !c.visible(method) {
+		c.errorf(sel.Sel.Pos(), "%s.%s undefined (cannot refer to unexported method %s)", x.typ, sel.Sel.Value, sel.Sel.Value)
+		x.mode = invalid
+		return
+	}
+
 	c.recordUse(sel.Sel, method)
 
 	sig := method.Signature()
@@ -108,6 +116,12 @@ func (c *Checker) methodCall(x *operand, e *syntax.CallExpr, sel *syntax.Selecto
 func (c *Checker) lookupMethod(T types.Type, name string) (*types.FuncObj, bool, bool) {
 	var needAddr, needDeref bool
 
+	// Methods of an interface are called through its method table
+	if it, ok := T.Underlying().(*types.Interface); ok {
+		_, method := it.LookupMethod(name)
+		return method, false, false
+	}
+
 	// Dereference pointers and refs
 	base := T
 	switch t := T.Underlying().(type) {
@@ -143,41 +157,47 @@ func (c *Checker) lookupMethod(T types.Type, name string) (*types.FuncObj, bool,
 	return method, needAddr, needDeref
 }
 
-// checkCallArgs checks function call arguments.
+// checkCallArgs checks function call arguments. As in Go, a single call
+// of a function with several results passes its results as the arguments.
 func (c *Checker) checkCallArgs(e *syntax.CallExpr, sig *types.Func) []*operand {
-	args := make([]*operand, len(e.Args))
+	args, ok := c.exprList(e.Args, false)
+	if !ok {
+		return nil
+	}
 
 	// Check argument count
 	expected := sig.NumParams()
-	got := len(e.Args)
-	if got != expected {
-		c.errorf(e.Pos(), "wrong number of arguments: got %d, want %d", got, expected)
+	if len(args) != expected {
+		if len(e.Args) == 1 && len(args) > 1 {
+			c.errorf(e.Pos(), "wrong number of arguments: %s returns %s, want %d", callString(e.Args[0]), count(len(args), "value"), expected)
+			return nil
+		}
+		c.errorf(e.Pos(), "wrong number of arguments: got %d, want %d", len(args), expected)
 		// Continue checking what we can
 	}
 
 	// Check each argument
-	for i, arg := range e.Args {
-		args[i] = &operand{}
-		c.expr(args[i], arg)
-		if args[i].mode == invalid {
+	for i, arg := range args {
+		if arg.mode == invalid {
 			continue
 		}
-		if args[i].mode == novalue {
-			c.errorf(arg.Pos(), "cannot use no-value expression as argument")
-			args[i].mode = invalid
+		if arg.mode == novalue {
+			c.errorf(arg.pos, "cannot use no-value expression as argument")
+			arg.mode = invalid
 			continue
 		}
 
 		if i < expected {
 			param := sig.Param(i)
-			c.assignment(args[i], param.Type(), "argument")
+			c.assignment(arg, param.Type(), "argument")
 		}
 	}
 
 	return args
 }
 
-// builtinCall handles builtin function calls (println, new, panic).
+// builtinCall handles builtin function calls (println, new, panic, len,
+// cap, append, copy, make, delete).
 func (c *Checker) builtinCall(x *operand, e *syntax.CallExpr) {
 	// Get builtin name
 	name, ok := e.Fun.(*syntax.Name)
@@ -207,6 +227,16 @@ func (c *Checker) builtinCall(x *operand, e *syntax.CallExpr) {
 		c.builtinNew(x, e)
 	case types.BuiltinPanic:
 		c.builtinPanic(x, e)
+	case types.BuiltinLen, types.BuiltinCap:
+		c.builtinLenCap(x, e, builtin)
+	case types.BuiltinAppend:
+		c.builtinAppend(x, e)
+	case types.BuiltinCopy:
+		c.builtinCopy(x, e)
+	case types.BuiltinMake:
+		c.builtinMake(x, e)
+	case types.BuiltinDelete:
+		c.builtinDelete(x, e)
 	default:
 		c.errorf(name.Pos(), "unknown builtin %s", name.Value)
 		x.mode = invalid
@@ -227,6 +257,10 @@ func (c *Checker) builtinPrintln(x *operand, e *syntax.CallExpr) {
 			continue
 		}
 
+		if types.IsUntypedType(a.typ) {
+			c.representable(&a, types.DefaultType(a.typ))
+		}
+
 		// Check that argument is printable
 		if !c.isPrintable(a.typ) {
 			c.errorf(arg.Pos(), "cannot print value of type %s", a.typ)
@@ -297,3 +331,253 @@ func (c *Checker) builtinPanic(x *operand, e *syntax.CallExpr) {
 	x.mode = novalue
 	x.typ = nil
 }
+
+// builtinLenCap handles len(x) and cap(x). len accepts strings, arrays,
+// pointers and refs to arrays, slices and maps; cap accepts all but
+// strings and maps.
+// The length of an array is a constant if x contains no calls, and so is
+// the length of a constant string.
+func (c *Checker) builtinLenCap(x *operand, e *syntax.CallExpr, b *types.Builtin) {
+	if len(e.Args) != 1 {
+		c.errorf(e.Pos(), "%s requires exactly one argument", b.Name())
+		x.mode = invalid
+		return
+	}
+
+	var arg operand
+	c.expr(&arg, e.Args[0])
+	if arg.mode == invalid {
+		x.mode = invalid
+		return
+	}
+
+	var val constant.Value
+	switch {
+	case isStringType(arg.typ) && b.Kind() == types.BuiltinLen:
+		if arg.mode == constant_ {
+			val = constant.MakeInt64(int64(len(constant.StringVal(arg.val))))
+		}
+	case types.IsSlice(arg.typ):
+	case types.IsMap(arg.typ) && b.Kind() == types.BuiltinLen:
+	case arrayOf(arg.typ) != nil:
+		if !containsCall(e.Args[0]) {
+			val = constant.MakeInt64(arrayOf(arg.typ).Len())
+		}
+	default:
+		c.errorf(e.Args[0].Pos(), "invalid argument: %s for built-in %s", arg.typ, b.Name())
+		x.mode = invalid
+		return
+	}
+
+	if val != nil {
+		x.mode = constant_
+		x.val = val
+		x.typ = types.Typ[types.UntypedInt]
+		return
+	}
+	x.mode = value
+	x.typ = types.Typ[types.Int]
+}
+
+// containsCall reports whether the expression e contains a function call.
+func containsCall(e syntax.Expr) bool {
+	found := false
+	syntax.Inspect(e, func(n syntax.Node) bool {
+		if _, ok := n.(*syntax.CallExpr); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// builtinAppend handles append(s, vals...). The values are assigned to the
+// element type of the slice s; the result has the type of s.
+func (c *Checker) builtinAppend(x *operand, e *syntax.CallExpr) {
+	if len(e.Args) == 0 {
+		c.errorf(e.Pos(), "not enough arguments for append() (expected 1, found 0)")
+		x.mode = invalid
+		return
+	}
+
+	var s operand
+	c.expr(&s, e.Args[0])
+	if s.mode == invalid {
+		x.mode = invalid
+		return
+	}
+	st, ok := s.typ.Underlying().(*types.Slice)
+	if !ok {
+		c.errorf(e.Args[0].Pos(), "invalid argument: %s is not a slice", s.typ)
+		x.mode = invalid
+		return
+	}
+
+	for _, arg := range e.Args[1:] {
+		var v operand
+		c.expr(&v, arg)
+		c.assignment(&v, st.Elem(), "argument to append")
+	}
+
+	x.mode = value
+	x.typ = s.typ
+}
+
+// builtinCopy handles copy(dst, src), which copies min(len(dst), len(src))
+// elements between slices of identical element types and returns their
+// number.
+func (c *Checker) builtinCopy(x *operand, e *syntax.CallExpr) {
+	if len(e.Args) != 2 {
+		c.errorf(e.Pos(), "copy requires exactly two arguments")
+		x.mode = invalid
+		return
+	}
+
+	var elems [2]types.Type
+	for i, arg := range e.Args {
+		var a operand
+		c.expr(&a, arg)
+		if a.mode == invalid {
+			x.mode = invalid
+			return
+		}
+		st, ok := a.typ.Underlying().(*types.Slice)
+		if !ok {
+			c.errorf(arg.Pos(), "invalid argument: copy expects slice arguments; found %s", a.typ)
+			x.mode = invalid
+			return
+		}
+		elems[i] = st.Elem()
+	}
+	if !types.Identical(elems[0], elems[1]) {
+		c.errorf(e.Pos(), "invalid argument: arguments to copy have different element types %s and %s", elems[0], elems[1])
+		x.mode = invalid
+		return
+	}
+
+	x.mode = value
+	x.typ = types.Typ[types.Int]
+}
+
+// builtinMake handles make([]T, len), make([]T, len, cap), make(map[K]V)
+// and make(map[K]V, hint).
+func (c *Checker) builtinMake(x *operand, e *syntax.CallExpr) {
+	if len(e.Args) == 0 {
+		c.errorf(e.Pos(), "not enough arguments for make() (expected 1, found 0)")
+		x.mode = invalid
+		return
+	}
+
+	T := c.resolveType(e.Args[0])
+	if T == nil {
+		x.mode = invalid
+		return
+	}
+	switch {
+	case types.IsSlice(T):
+		if len(e.Args) < 2 || len(e.Args) > 3 {
+			c.errorf(e.Pos(), "make(%s) expects 2 or 3 arguments; found %d", T, len(e.Args))
+			x.mode = invalid
+			return
+		}
+	case types.IsMap(T):
+		if len(e.Args) > 2 {
+			c.errorf(e.Pos(), "make(%s) expects 1 or 2 arguments; found %d", T, len(e.Args))
+			x.mode = invalid
+			return
+		}
+	default:
+		c.errorf(e.Args[0].Pos(), "invalid argument: cannot make %s; type must be slice or map", T)
+		x.mode = invalid
+		return
+	}
+
+	// Check the sizes; constant sizes must be non-negative and len <= cap
+	sizes := make([]int64, 0, 2)
+	for _, arg := range e.Args[1:] {
+		var n operand
+		c.expr(&n, arg)
+		if n.mode == invalid {
+			x.mode = invalid
+			return
+		}
+		if !isInteger(n.typ) {
+			c.errorf(arg.Pos(), "make size must be an integer, not %s", n.typ)
+			x.mode = invalid
+			return
+		}
+		if n.mode == constant_ {
+			v, ok := c.constInt64(&n)
+			if !ok {
+				x.mode = invalid
+				return
+			}
+			if v < 0 {
+				c.errorf(arg.Pos(), "invalid argument: make size %s must not be negative", n.val)
+				x.mode = invalid
+				return
+			}
+			sizes = append(sizes, v)
+		}
+	}
+	if len(sizes) == 2 && sizes[0] > sizes[1] {
+		c.errorf(e.Args[1].Pos(), "invalid argument: length and capacity swapped")
+		x.mode = invalid
+		return
+	}
+
+	x.mode = value
+	x.typ = T
+}
+
+// builtinDelete handles delete(m, k), which removes the entry for key k
+// from the map m, if any.
+func (c *Checker) builtinDelete(x *operand, e *syntax.CallExpr) {
+	x.mode = novalue
+	x.typ = nil
+	if len(e.Args) != 2 {
+		c.errorf(e.Pos(), "delete requires exactly two arguments")
+		x.mode = invalid
+		return
+	}
+
+	var m operand
+	c.expr(&m, e.Args[0])
+	if m.mode == invalid {
+		x.mode = invalid
+		return
+	}
+	mt, ok := m.typ.Underlying().(*types.Map)
+	if !ok {
+		c.errorf(e.Args[0].Pos(), "invalid argument: %s is not a map", m.typ)
+		x.mode = invalid
+		return
+	}
+
+	var key operand
+	c.expr(&key, e.Args[1])
+	if key.mode == invalid {
+		x.mode = invalid
+		return
+	}
+	c.assignment(&key, mt.Key(), "argument to delete")
+}
+
+// callString returns a short description of the call expression e for
+// error messages, such as "f()" or "p.Move()".
+func callString(e syntax.Expr) string {
+	call, ok := e.(*syntax.CallExpr)
+	if !ok {
+		return "expression"
+	}
+	switch fun := call.Fun.(type) {
+	case *syntax.Name:
+		return fun.Value + "()"
+	case *syntax.SelectorExpr:
+		if x, ok := fun.X.(*syntax.Name); ok {
+			return x.Value + "." + fun.Sel.Value + "()"
+		}
+		return fun.Sel.Value + "()"
+	}
+	return "call"
+}
@@ -19,41 +19,87 @@ type Checker struct {
 	funcSig *types.Func // current function signature
 
 	// Control-flow context
-	loopDepth int // nested loop depth (for break/continue validation)
+	loopDepth   int // nested loop depth (for break/continue validation)
+	switchDepth int // nested type switch depth (break exits a switch)
 
 	// Declaration objects keyed by AST node.
 	// Methods are not in package scope, so they must be tracked separately.
-	// Lifecycle: allocated per Check invocation and used only while checking one file.
+	// Lifecycle: allocated per Check invocation and used only while checking one package.
 	funcDecls map[*syntax.FuncDecl]*types.FuncObj
 
+	// File scope of each top-level declaration. Imports are visible only
+	// in the file that declares them, so declarations are checked with
+	// their file scope as the current scope.
+	declScopes map[syntax.Decl]*types.Scope
+
+	// Fake packages declared for imports that could not be resolved.
+	badImports map[*types.Package]bool
+
+	// Package-level variables in declaration order, with their
+	// declarations and checking state. Variable declarations are checked
+	// on first use so that initializers may refer to variables declared
+	// later in the package.
+	globals    []*types.Var
+	varDecls   map[*types.Var]*syntax.VarDecl
+	varChecked map[*types.Var]bool
+
+	// Dependencies for initialization order: decl is the package-level
+	// variable or function being checked, and objDeps maps each such object
+	// to the package-level variables and functions it refers to.
+	decl    types.Object
+	objDeps map[types.Object][]types.Object
+
 	// Error tracking
 	errors int        // error count
 	first  *TypeError // first error
 }
 
-// checkFile type-checks a single file.
-func (c *Checker) checkFile(file *syntax.File) {
-	// Create package
+// checkFiles type-checks the files of one package.
+func (c *Checker) checkFiles(files []*syntax.File) {
+	// Create package from the first file's clause; all files must agree.
 	pkgName := "main"
-	if file.PkgName != nil {
-		pkgName = file.PkgName.Value
+	if len(files) > 0 && files[0].PkgName != nil {
+		pkgName = files[0].PkgName.Value
 	}
 	c.pkg = types.NewPackage(pkgName)
 	c.scope = c.pkg.Scope()
 
-	// Record file scope
-	if c.info != nil {
-		c.info.Scopes[file] = c.scope
+	// Each file gets its own scope (for imports) below the package scope.
+	fileScopes := make([]*types.Scope, len(files))
+	for i, file := range files {
+		if file.PkgName != nil && file.PkgName.Value != pkgName {
+			c.errorf(file.PkgName.Pos(), "package %s; expected package %s", file.PkgName.Value, pkgName)
+		}
+		fileScopes[i] = types.NewScope(c.pkg.Scope(), file.Pos(), file.End(), "file "+file.Pos().Filename())
+		for _, decl := range file.Decls {
+			c.declScopes[decl] = fileScopes[i]
+		}
+		// Record file scope
+		if c.info != nil {
+			c.info.Scopes[file] = fileScopes[i]
+		}
+	}
+
+	// Phase 0: Resolve imports (dependencies are checked first)
+	for i, file := range files {
+		c.collectImports(file, fileScopes[i])
 	}
 
 	// Phase 1: Collect all top-level declarations
-	c.collectDecls(file.Decls)
+	for _, file := range files {
+		c.collectDecls(file.Decls)
+	}
+	for _, fs := range fileScopes {
+		c.checkImportConflicts(fs)
+	}
 
 	// Phase 2: Check type declarations (resolve underlying types)
 	var typeDecls []*syntax.TypeDecl
-	for _, decl := range file.Decls {
-		if td, ok := decl.(*syntax.TypeDecl); ok {
-			typeDecls = append(typeDecls, td)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if td, ok := decl.(*syntax.TypeDecl); ok {
+				typeDecls = append(typeDecls, td)
+			}
 		}
 	}
 	// Run multiple passes so forward aliases can settle to final types.
@@ -61,6 +107,7 @@ func (c *Checker) checkFile(file *syntax.File) {
 	for pass := 0; pass < len(typeDecls); pass++ {
 		changed := false
 		for _, td := range typeDecls {
+			c.enterFile(td)
 			if c.checkTypeDecl(td) {
 				changed = true
 			}
@@ -71,25 +118,48 @@ func (c *Checker) checkFile(file *syntax.File) {
 	}
 
 	// Phase 3: Check function signatures
-	for _, decl := range file.Decls {
-		if fd, ok := decl.(*syntax.FuncDecl); ok {
-			c.checkFuncSignature(fd)
-		}
+	for _, fd := range funcDecls(files) {
+		c.enterFile(fd)
+		c.checkFuncSignature(fd)
 	}
 
 	// Phase 4: Check variable declarations
-	for _, decl := range file.Decls {
-		if vd, ok := decl.(*syntax.VarDecl); ok {
-			c.checkVarDecl(vd)
-		}
+	for _, v := range c.globals {
+		c.checkGlobalVar(v)
 	}
 
 	// Phase 5: Check function bodies
-	for _, decl := range file.Decls {
-		if fd, ok := decl.(*syntax.FuncDecl); ok {
-			c.checkFuncBody(fd)
+	for _, fd := range funcDecls(files) {
+		c.enterFile(fd)
+		c.decl = c.funcDecls[fd]
+		c.checkFuncBody(fd)
+	}
+	c.decl = nil
+
+	// Phase 6: Compute the initialization order of package-level variables
+	c.initOrder()
+
+	c.scope = c.pkg.Scope()
+}
+
+// enterFile makes the file scope of a top-level declaration current.
+func (c *Checker) enterFile(decl syntax.Decl) {
+	if s := c.declScopes[decl]; s != nil {
+		c.scope = s
+	}
+}
+
+// funcDecls returns the function declarations of all files in source order.
+func funcDecls(files []*syntax.File) []*syntax.FuncDecl {
+	var fds []*syntax.FuncDecl
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if fd, ok := decl.(*syntax.FuncDecl); ok {
+				fds = append(fds, fd)
+			}
 		}
 	}
+	return fds
 }
 
 // openScope creates a new scope as a child of the current scope.
@@ -113,16 +183,24 @@ func (c *Checker) lookup(name string) types.Object {
 	return obj
 }
 
-// declare declares an object in the current scope.
-// Reports an error if the name is already declared.
-func (c *Checker) declare(name *syntax.Name, obj types.Object) {
-	if existing := c.scope.Insert(obj); existing != nil {
-		c.errorf(name.Pos(), "%s redeclared in this block", name.Value)
-		return
+// declare declares an object in the current scope and reports whether it
+// did. Reports an error if the name is already declared. The blank
+// identifier is never inserted into a scope, so it can be declared any
+// number of times; the object is still recorded in Info.Defs.
+func (c *Checker) declare(name *syntax.Name, obj types.Object) bool {
+	if name.Value != "_" {
+		if existing := c.scope.Insert(obj); existing != nil {
+			c.errorf(name.Pos(), "%s redeclared in this block", name.Value)
+			return false
+		}
+	}
+	if c.scope == c.pkg.Scope() {
+		obj.SetPkg(c.pkg)
 	}
 	if c.info != nil {
 		c.info.Defs[name] = obj
 	}
+	return true
 }
 
 // recordType records the type information for an expression.
@@ -142,4 +220,5 @@ func (c *Checker) recordUse(name *syntax.Name, obj types.Object) {
 	if c.info != nil {
 		c.info.Uses[name] = obj
 	}
+	c.recordDep(obj)
 }
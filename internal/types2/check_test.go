@@ -1,6 +1,8 @@
 package types2
 
 import (
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
@@ -377,6 +379,799 @@ func main() {
 `, "wrong number of arguments")
 }
 
+func TestMultipleResults(t *testing.T) {
+	expectNoErrors(t, `
+package main
+
+type Point struct {
+	x int
+	y int
+}
+
+func (p *Point) Coords() (int, int) {
+	return p.x, p.y
+}
+
+func (p *Point) Move(dx int, dy int) {
+	p.x = p.x + dx
+	p.y = p.y + dy
+}
+
+func divmod(a int, b int) (int, int) {
+	return a / b, a % b
+}
+
+func sum(a int, b int) int {
+	return a + b
+}
+
+func lookup(k int) (int, bool) {
+	if k > 0 {
+		return k * 2, true
+	}
+	return 0, false
+}
+
+func forward(k int) (int, bool) {
+	return lookup(k)
+}
+
+func main() {
+	q, r := divmod(7, 2)
+	v, ok := lookup(q)
+	if ok {
+		println(v + r)
+	}
+	q, r = r, q
+	w, ok := forward(1)
+	_, ok = lookup(w)
+	var p Point
+	x, _ := p.Coords()
+	println(x)
+	divmod(1, 1)
+	println(sum(divmod(7, 2)))
+	p.Move(p.Coords())
+}
+`)
+}
+
+func TestMultipleResultErrors(t *testing.T) {
+	header := `
+package main
+
+func pair() (int, bool) {
+	return 1, true
+}
+
+func none() {
+}
+`
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"x := pair()", "assignment mismatch: 1 variable but pair() returns 2 values"},
+		{"a, b, c := pair()", "assignment mismatch: 3 variables but pair() returns 2 values"},
+		{"a, b := none()", "assignment mismatch: 2 variables but none() returns 0 values"},
+		{"a, b := 1, 2, 3", "assignment mismatch: 2 variables but 3 values"},
+		{"println(pair())", "multiple-value pair() (value of type (int, bool)) in single-value context"},
+		{"x := pair() + 1", "multiple-value pair()"},
+		{"var x int = pair()", "multiple-value pair()"},
+		{"a, b := pair(); a, b := pair()", "no new variables on left side of :="},
+		{"a, a := pair()", "a repeated on left side of :="},
+		{"a, b := pair(); b, a = a, b", "cannot use bool as int in assignment"},
+		{"x := _", "cannot use _ as value"},
+		{"_ = nil", "use of untyped nil in assignment"},
+	}
+	for _, tt := range tests {
+		expectErrors(t, header+"func main() {\n\t"+strings.ReplaceAll(tt.body, "; ", "\n\t")+"\n}\n", tt.want)
+	}
+}
+
+// TestMultiValueArgumentErrors checks that passing the results of a call
+// to a function whose parameters do not match them reports one error.
+func TestMultiValueArgumentErrors(t *testing.T) {
+	header := `
+package main
+
+func pair() (int, bool) {
+	return 1, true
+}
+
+func one(a int) {
+}
+
+func two(a int, b int) {
+}
+
+func three(a int, b bool, c int) {
+}
+`
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"one(pair())", "wrong number of arguments: pair() returns 2 values, want 1"},
+		{"three(pair())", "wrong number of arguments: pair() returns 2 values, want 3"},
+		{"two(pair())", "cannot use bool as int in argument"},
+		{"two(pair(), 1)", "multiple-value pair() (value of type (int, bool)) in single-value context"},
+	}
+	for _, tt := range tests {
+		_, errs := parseAndCheck(header + "func main() {\n\t" + tt.body + "\n}\n")
+		if len(errs) != 1 || !strings.Contains(errs[0], tt.want) {
+			t.Errorf("%s: got errors:\n%s\nwant one containing %q", tt.body, strings.Join(errs, "\n"), tt.want)
+		}
+	}
+}
+
+// TestAssignMismatchDeclares checks that a short variable declaration with
+// a count mismatch or an invalid value still declares its names, so their
+// uses report no follow-on errors.
+func TestAssignMismatchDeclares(t *testing.T) {
+	_, errs := parseAndCheck(`
+package main
+
+func two() (int, bool) {
+	return 1, true
+}
+
+func main() {
+	a, b, c := two()
+	x := two()
+	y := undefined
+	println(a, b, c, x, y)
+}
+`)
+	want := []string{
+		"assignment mismatch: 3 variables but two() returns 2 values",
+		"assignment mismatch: 1 variable but two() returns 2 values",
+		"undefined: undefined",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d:\n%s", len(errs), len(want), strings.Join(errs, "\n"))
+	}
+	for i, msg := range want {
+		if !strings.Contains(errs[i], msg) {
+			t.Errorf("error %d = %q, want %q", i, errs[i], msg)
+		}
+	}
+}
+
+func TestReturnCountMismatch(t *testing.T) {
+	expectErrors(t, `
+package main
+
+func pair() (int, bool) {
+	return 1
+}
+
+func triple() (int, int, int) {
+	return pair()
+}
+
+func single() int {
+	return 1, 2
+}
+`, "not enough return values: have 1, want 2",
+		"too many return values: have 2, want 1")
+	expectErrors(t, `
+package main
+
+func pair() (int, bool) {
+	return 1, true
+}
+
+func triple() (int, int, int) {
+	return pair()
+}
+`, "not enough return values: have 2, want 3")
+}
+
+func TestInterfaces(t *testing.T) {
+	expectNoErrors(t, `
+package main
+
+type Shape interface {
+	Area() int
+	Scale(k int)
+}
+
+type Namer interface {
+	Name() string
+}
+
+type Empty interface{}
+
+type Rect struct {
+	w int
+	h int
+}
+
+func (r Rect) Name() string {
+	return "rect"
+}
+
+func (r *Rect) Area() int {
+	return r.w * r.h
+}
+
+func (r *Rect) Scale(k int) {
+	r.w = r.w * k
+}
+
+type Holder struct {
+	s Shape
+}
+
+var global Namer = Rect{}
+
+func use(s Shape) int {
+	if s == nil {
+		return 0
+	}
+	s.Scale(2)
+	return s.Area()
+}
+
+func pick() (Shape, bool) {
+	return new(Rect), true
+}
+
+func main() {
+	var r Rect
+	var n Namer = r
+	var s Shape = new(Rect)
+	var e Empty = 1
+	var h Holder
+	h.s = s
+	println(use(s), use(nil), n.Name())
+	s, ok := pick()
+	println(ok, s != nil, e == nil, global.Name())
+	var other Shape = s
+	println(use(other))
+}
+`)
+}
+
+func TestInterfaceErrors(t *testing.T) {
+	header := `
+package main
+
+type Shape interface {
+	Area() int
+}
+
+type Sizer interface {
+	Size() int
+}
+
+type Rect struct {
+	w int
+}
+
+func (r *Rect) Area() int {
+	return r.w
+}
+
+type Square struct {
+	w int
+}
+
+func (s Square) Area() bool {
+	return true
+}
+`
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"var s Shape = 1", "cannot use untyped int as Shape in variable declaration: int does not implement Shape (missing method Area)"},
+		{"var r Rect; var s Shape = r", "Rect does not implement Shape (method Area has pointer receiver)"},
+		{"var q Square; var s Shape = q", "(wrong type for method Area: have Area() bool, want Area() int)"},
+		{"var r Rect; var s Shape = &r", "cannot use *Rect as Shape in variable declaration: *T cannot be stored in an interface (may escape)"},
+		{"var s Shape; var z Sizer = s", "conversion between different interface types is not supported"},
+		{"var a Shape; var b Shape; println(a == b)", "cannot compare"},
+		{"var s Shape; s.Size()", "Shape has no method Size"},
+	}
+	for _, tt := range tests {
+		expectErrors(t, header+"func main() {\n\t"+strings.ReplaceAll(tt.body, "; ", "\n\t")+"\n}\n", tt.want)
+	}
+
+	expectErrors(t, `
+package main
+
+type Bad interface {
+	M()
+	M() int
+}
+`, "duplicate method M")
+	expectErrors(t, `
+package main
+
+type Shape interface {
+	Area() int
+}
+
+func (s Shape) Area() int {
+	return 0
+}
+`, "invalid receiver type Shape (pointer or interface type)")
+}
+
+func TestTypeAssertions(t *testing.T) {
+	expectNoErrors(t, `
+package main
+
+type Shape interface {
+	Area() int
+}
+
+type Rect struct {
+	w int
+	h int
+}
+
+func (r Rect) Area() int {
+	return r.w * r.h
+}
+
+type Circle struct {
+	r int
+}
+
+func (c *Circle) Area() int {
+	return 3 * c.r * c.r
+}
+
+func kind(s Shape) int {
+	switch v := s.(type) {
+	case Rect:
+		return v.w
+	case ref Circle:
+		return v.r
+	case nil:
+		return -1
+	default:
+		return v.Area()
+	}
+}
+
+func count(s Shape) int {
+	n := 0
+	switch s.(type) {
+	case Rect, ref Circle:
+		n = 1
+		break
+	}
+	return n
+}
+
+func main() {
+	var s Shape = Rect{w: 2, h: 3}
+	r := s.(Rect)
+	var c ref Circle
+	c, ok := s.(ref Circle)
+	same, ok2 := s.(Shape)
+	println(r.w, c == nil, ok, ok2, same.Area(), kind(s), count(s))
+}
+`)
+
+	info := &Info{
+		Types:     make(map[syntax.Expr]TypeAndValue),
+		Implicits: make(map[syntax.Node]types.Object),
+	}
+	src := `
+package main
+
+type Shape interface {
+	Area() int
+}
+
+type Rect struct {
+	w int
+}
+
+func (r Rect) Area() int {
+	return r.w
+}
+
+func main() {
+	var s Shape = Rect{w: 1}
+	v, ok := s.(Rect)
+	println(v.w, ok)
+	switch x := s.(type) {
+	case Rect:
+		println(x.w)
+	case nil:
+		println(x == nil)
+	}
+}
+`
+	file := syntax.NewParser("test.yoru", strings.NewReader(src), nil).Parse()
+	conf := &Config{
+		Error: func(pos syntax.Pos, msg string) { t.Errorf("%s: %s", pos, msg) },
+		Sizes: types.DefaultSizes,
+	}
+	Check("test.yoru", file, conf, info)
+	var tuples, implicits int
+	for e, tv := range info.Types {
+		if _, ok := e.(*syntax.AssertExpr); ok {
+			if tup, ok := tv.Type.(*types.Tuple); !ok || tup.Len() != 2 {
+				t.Errorf("comma-ok assertion has type %v, want (Rect, bool)", tv.Type)
+			}
+			tuples++
+		}
+	}
+	for _, obj := range info.Implicits {
+		implicits++
+		if obj.Name() != "x" {
+			t.Errorf("implicit object %s, want x", obj.Name())
+		}
+	}
+	if tuples != 1 || implicits != 2 {
+		t.Errorf("got %d assertions and %d implicits, want 1 and 2", tuples, implicits)
+	}
+}
+
+func TestTypeAssertionErrors(t *testing.T) {
+	header := `
+package main
+
+type Shape interface {
+	Area() int
+}
+
+type Sizer interface {
+	Size() int
+}
+
+type Rect struct {
+	w int
+}
+
+func (r *Rect) Area() int {
+	return r.w
+}
+
+type Circle struct {
+	r int
+}
+
+func (c Circle) Area() int {
+	return c.r
+}
+`
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"var s Shape; r := s.(Rect)", "impossible type assertion: Rect does not implement Shape (method Area has pointer receiver)"},
+		{"var s Shape; n := s.(int)", "impossible type assertion: int does not implement Shape (missing method Area)"},
+		{"var s Shape; z := s.(Sizer)", "unsupported type assertion: Sizer is a different interface type than Shape"},
+		{"var s Shape; p := s.(*Circle)", "impossible type assertion: *Circle cannot be stored in an interface"},
+		{"var c Circle; d := c.(Circle)", "invalid operation: Circle is not an interface"},
+		{"var s Shape; t := s.(type)", "use of .(type) outside type switch"},
+		{"break", "break not in for loop or switch"},
+	}
+	for _, tt := range tests {
+		expectErrors(t, header+"func main() {\n\t"+strings.ReplaceAll(tt.body, "; ", "\n\t")+"\n}\n", tt.want)
+	}
+
+	switches := []struct {
+		body string
+		want string
+	}{
+		{"case Circle, Circle:", "duplicate case Circle in type switch"},
+		{"case nil:\n\tcase nil:", "multiple nil cases in type switch"},
+		{"case Rect:", "impossible type switch case: Rect does not implement Shape (method Area has pointer receiver)"},
+		{"case Sizer:", "unsupported type switch case: Sizer is a different interface type than Shape"},
+	}
+	for _, tt := range switches {
+		expectErrors(t, header+"func main() {\n\tvar s Shape\n\tswitch s.(type) {\n\t"+tt.body+"\n\t}\n}\n", tt.want)
+	}
+
+	expectErrors(t, header+`
+func main() {
+	var c Circle
+	switch c.(type) {
+	}
+}
+`, "Circle is not an interface")
+	expectErrors(t, header+`
+func area(s Shape) int {
+	switch v := s.(type) {
+	case Circle:
+		return v.r
+	}
+}
+`, "missing return")
+	expectErrors(t, header+`
+func area(s Shape) int {
+	switch v := s.(type) {
+	case Circle:
+		if v.r > 0 {
+			break
+		}
+		return v.r
+	default:
+		return 0
+	}
+}
+`, "missing return")
+}
+
+func TestSlices(t *testing.T) {
+	expectNoErrors(t, `
+package main
+
+type Point struct {
+	x int
+}
+
+var table [4]int
+
+func sum(s []int) int {
+	total := 0
+	i := 0
+	for i < len(s) {
+		total = total + s[i]
+		i = i + 1
+	}
+	return total
+}
+
+func main() {
+	var arr [5]int
+	s := arr[1:3]
+	s[0] = 7
+	t := s[:]
+	t = append(t, 1, 2)
+	r := new([3]int)
+	u := r[1:]
+	g := table[:2]
+	m := make([]Point, 2, 8)
+	m[1].x = 4
+	var ps []ref Point
+	ps = append(ps, new(Point))
+	n := copy(t, u)
+	var empty []int
+	var k int = len(arr)
+	println(sum(s), len(t), cap(t), len(u), len(g), cap(m), n, empty == nil, k, len("abc"), len(ps))
+}
+`)
+
+	info := &Info{Types: make(map[syntax.Expr]TypeAndValue)}
+	src := `
+package main
+
+func main() {
+	var arr [5]int
+	s := make([]int, 3)
+	println(len(arr), len(s), cap(arr[1:]))
+}
+`
+	file := syntax.NewParser("test.yoru", strings.NewReader(src), nil).Parse()
+	conf := &Config{
+		Error: func(pos syntax.Pos, msg string) { t.Errorf("%s: %s", pos, msg) },
+		Sizes: types.DefaultSizes,
+	}
+	Check("test.yoru", file, conf, info)
+	var consts, lens int
+	for e, tv := range info.Types {
+		call, ok := e.(*syntax.CallExpr)
+		if !ok {
+			continue
+		}
+		if name, ok := call.Fun.(*syntax.Name); ok && (name.Value == "len" || name.Value == "cap") {
+			lens++
+			if tv.IsConstant() {
+				consts++
+			}
+		}
+		if name, ok := call.Fun.(*syntax.Name); ok && name.Value == "make" && tv.Type.String() != "[]int" {
+			t.Errorf("make([]int, 3) has type %s, want []int", tv.Type)
+		}
+	}
+	if lens != 3 || consts != 1 {
+		t.Errorf("got %d len/cap calls with %d constants, want 3 with 1", lens, consts)
+	}
+}
+
+func TestSliceErrors(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"var a [3]int; s := a[1:4]", "invalid slice index 4 (out of bounds for 3-element array)"},
+		{"var a [3]int; s := a[2:1]", "invalid slice indices: 1 < 2"},
+		{"var a [3]int; s := a[-1:]", "invalid slice index -1 (index must be non-negative)"},
+		{"x := 1; s := x[:]", "cannot slice int"},
+		{"var a [3]int; p := &a; s := p[1:]", "cannot slice *[3]int: *T cannot escape to the heap"},
+		{"s := arr()[:]", "cannot slice unaddressable value of type [3]int"},
+		{"var s []int; var t []float; n := copy(s, t)", "arguments to copy have different element types int and float"},
+		{"var s []int; s = append(s, \"a\")", "cannot use untyped string as int in argument to append"},
+		{"var a [3]int; a = append(a, 1)", "invalid argument: [3]int is not a slice"},
+		{"s := make([]int)", "make([]int) expects 2 or 3 arguments; found 1"},
+		{"s := make([]int, 4, 2)", "length and capacity swapped"},
+		{"s := make([]int, -1)", "make size -1 must not be negative"},
+		{"s := make(int, 1)", "cannot make int; type must be slice"},
+		{"var s []int; var t []int; b := s == t", "cannot compare []int and []int"},
+		{"var s []int; println(s)", "cannot print value of type []int"},
+		{"n := len(1)", "invalid argument: untyped int for built-in len"},
+		{"n := cap(\"abc\")", "invalid argument: untyped string for built-in cap"},
+		{"var s []*int", "invalid slice element type *int"},
+		{"var s []int; p := &s[0]", "*T can only be created from &local values"},
+	}
+	for _, tt := range tests {
+		src := "package main\n\nfunc arr() [3]int {\n\tvar a [3]int\n\treturn a\n}\n\nfunc main() {\n\t" +
+			strings.ReplaceAll(tt.body, "; ", "\n\t") + "\n}\n"
+		expectErrors(t, src, tt.want)
+	}
+}
+
+func TestMaps(t *testing.T) {
+	expectNoErrors(t, `
+package main
+
+type Point struct {
+	x int
+}
+
+type Key string
+
+var ages map[string]int
+
+func count(m map[Key]int, k Key) {
+	m[k] = m[k] + 1
+}
+
+func main() {
+	ages = make(map[string]int)
+	ages["bob"] = 31
+	a, ok := ages["bob"]
+	_, ok = ages["eve"]
+	delete(ages, "bob")
+	pts := make(map[int]Point, 8)
+	pts[1] = Point{x: 2}
+	x := pts[1].x
+	refs := make(map[ref Point][]int)
+	p := new(Point)
+	refs[p] = append(refs[p], 1)
+	flags := make(map[bool]ref Point)
+	flags[true] = p
+	var none map[int]int
+	counts := make(map[Key]int)
+	count(counts, "a")
+	println(a, ok, x, len(ages), len(refs[p]), flags[false] == nil, none == nil, len(none), counts["a"])
+}
+`)
+
+	info := &Info{Types: make(map[syntax.Expr]TypeAndValue)}
+	src := `
+package main
+
+func main() {
+	m := make(map[string]int)
+	v, ok := m["a"]
+	w := m["b"]
+	println(v, ok, w)
+}
+`
+	file := syntax.NewParser("test.yoru", strings.NewReader(src), nil).Parse()
+	conf := &Config{
+		Error: func(pos syntax.Pos, msg string) { t.Errorf("%s: %s", pos, msg) },
+		Sizes: types.DefaultSizes,
+	}
+	Check("test.yoru", file, conf, info)
+	var got []string
+	for e, tv := range info.Types {
+		if _, ok := e.(*syntax.IndexExpr); ok {
+			got = append(got, tv.Type.String())
+		}
+	}
+	sort.Strings(got)
+	// The comma-ok lookup records the tuple of the value and the bool.
+	if want := []string{"(int, bool)", "int"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("map index types = %v, want %v", got, want)
+	}
+}
+
+func TestMapErrors(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"var m map[float]int", "invalid map key type float (must be int, bool, string or ref T)"},
+		{"var m map[[2]int]int", "invalid map key type [2]int"},
+		{"var m map[*int]int", "invalid map key type *int (*T cannot escape to the heap; use ref T)"},
+		{"var m map[int]*int", "invalid map value type *int"},
+		{"m := make(map[int]int); m[\"a\"] = 1", "cannot use untyped string as int in map index"},
+		{"m := make(map[int]Point); m[1].x = 2", "cannot assign to"},
+		{"m := make(map[int][2]int); m[1][0] = 2", "cannot assign to"},
+		{"m := make(map[int]int); p := &m[1]", "cannot take address of"},
+		{"m := make(map[int]int, 1, 2)", "make(map[int]int) expects 1 or 2 arguments; found 3"},
+		{"m := make(map[int]int, -1)", "make size -1 must not be negative"},
+		{"var m map[int]int; n := cap(m)", "invalid argument: map[int]int for built-in cap"},
+		{"var m map[int]int; delete(m)", "delete requires exactly two arguments"},
+		{"var s []int; delete(s, 1)", "invalid argument: []int is not a map"},
+		{"var m map[string]int; delete(m, 1)", "cannot use untyped int as string in argument to delete"},
+		{"var m map[int]int; var n map[int]int; b := m == n", "cannot compare map[int]int and map[int]int"},
+		{"m := make(map[int]int); a, b, c := m[1]", "assignment mismatch: 3 variables but 1 value"},
+	}
+	for _, tt := range tests {
+		src := "package main\n\ntype Point struct {\n\tx int\n}\n\nfunc main() {\n\t" +
+			strings.ReplaceAll(tt.body, "; ", "\n\t") + "\n}\n"
+		expectErrors(t, src, tt.want)
+	}
+}
+
+func TestShortVarDeclReuse(t *testing.T) {
+	src := `
+package main
+
+func pair() (int, bool) {
+	return 1, true
+}
+
+func main() {
+	a, ok := pair()
+	b, ok := pair()
+	println(a + b)
+	if ok {
+		println(1)
+	}
+}
+`
+	file := syntax.NewParser("test.yoru", strings.NewReader(src), nil).Parse()
+	info := &Info{
+		Defs: make(map[*syntax.Name]types.Object),
+		Uses: make(map[*syntax.Name]types.Object),
+	}
+	if _, err := Check("test.yoru", file, &Config{Sizes: types.DefaultSizes}, info); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second "ok" reuses the variable declared by the first :=; the
+	// condition uses it too.
+	var defs, uses []types.Object
+	for name, obj := range info.Defs {
+		if name.Value == "ok" {
+			defs = append(defs, obj)
+		}
+	}
+	for name, obj := range info.Uses {
+		if name.Value == "ok" {
+			uses = append(uses, obj)
+		}
+	}
+	if len(defs) != 1 || len(uses) != 2 || uses[0] != defs[0] || uses[1] != defs[0] {
+		t.Errorf("ok: defs = %v, uses = %v, want one variable used twice", defs, uses)
+	}
+}
+
+func TestBlankIdentifier(t *testing.T) {
+	expectNoErrors(t, `
+package main
+
+func pair() (int, bool) {
+	return 1, true
+}
+
+func use(_ int, x int) int {
+	return x
+}
+
+func main() {
+	_, ok := pair()
+	var _ int = 3
+	var _ int = 4
+	_ = ok
+	_, _ = pair()
+	println(use(1, 2))
+}
+`)
+}
+
 func TestArgumentTypeMismatch(t *testing.T) {
 	expectErrors(t, `
 package main
@@ -402,6 +1197,45 @@ func main() {
 `, "cannot index")
 }
 
+func TestIndexConstantOutOfRange(t *testing.T) {
+	expectErrors(t, `
+package main
+
+func main() {
+	var a [3]int
+	println(a[3])
+}
+`, "invalid array index 3 (out of bounds for 3-element array)")
+
+	expectErrors(t, `
+package main
+
+func main() {
+	var a [3]int
+	a[-1] = 1
+}
+`, "invalid array index -1 (index must be non-negative)")
+
+	expectErrors(t, `
+package main
+
+func f(p *[4]int) int {
+	return p[1+3]
+}
+`, "invalid array index 4 (out of bounds for 4-element array)")
+
+	expectNoErrors(t, `
+package main
+
+func main() {
+	var a [3]int
+	a[2] = 1
+	var i int = 5
+	println(a[0], a[i])
+}
+`)
+}
+
 func TestSelectNonStruct(t *testing.T) {
 	expectErrors(t, `
 package main
@@ -428,3 +1262,350 @@ func main() {
 }
 `, "has no field")
 }
+
+// parseAndCheckFiles parses each (filename, source) pair and type-checks
+// them together as one package.
+func parseAndCheckFiles(t *testing.T, srcs ...string) []string {
+	t.Helper()
+	var files []*syntax.File
+	for i := 0; i+1 < len(srcs); i += 2 {
+		parseErrh := func(pos syntax.Pos, msg string) {
+			t.Fatalf("parse error: %s: %s", pos, msg)
+		}
+		files = append(files, syntax.NewParser(srcs[i], strings.NewReader(srcs[i+1]), parseErrh).Parse())
+	}
+
+	var typeErrs []string
+	conf := &Config{
+		Error: func(pos syntax.Pos, msg string) {
+			typeErrs = append(typeErrs, pos.String()+": "+msg)
+		},
+	}
+	_, _ = CheckFiles(files, conf, nil)
+	return typeErrs
+}
+
+func TestCheckFilesCrossFileDecls(t *testing.T) {
+	errs := parseAndCheckFiles(t,
+		"a.yoru", `package main
+
+func main() {
+	var c Counter
+	c.Inc()
+	println(c.n, twice(c.n))
+}
+`,
+		"b.yoru", `package main
+
+type Counter struct {
+	n int
+}
+
+func twice(x int) int {
+	return x * 2
+}
+`,
+		"c.yoru", `package main
+
+func (c *Counter) Inc() {
+	c.n = c.n + 1
+}
+`)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors:\n%s", strings.Join(errs, "\n"))
+	}
+}
+
+func TestCheckFilesDiagnostics(t *testing.T) {
+	errs := parseAndCheckFiles(t,
+		"a.yoru", "package main\n\nfunc f() {}\n",
+		"b.yoru", "package util\n\nfunc f() {}\n")
+	text := strings.Join(errs, "\n")
+	if !strings.Contains(text, "b.yoru:1:9: package util; expected package main") {
+		t.Errorf("missing package clause mismatch error:\n%s", text)
+	}
+	if !strings.Contains(text, "b.yoru:3:6: f redeclared in this block") {
+		t.Errorf("missing cross-file redeclaration error:\n%s", text)
+	}
+}
+
+// srcImporter type-checks imported packages from in-memory sources.
+type srcImporter struct {
+	t    *testing.T
+	srcs map[string]string // import path → source
+}
+
+func (imp srcImporter) Import(path string) (*types.Package, error) {
+	src, ok := imp.srcs[path]
+	if !ok {
+		return nil, fmt.Errorf("cannot find package %s", path)
+	}
+	parseErrh := func(pos syntax.Pos, msg string) {
+		imp.t.Fatalf("parse error: %s: %s", pos, msg)
+	}
+	file := syntax.NewParser(path+".yoru", strings.NewReader(src), parseErrh).Parse()
+	conf := &Config{
+		Error: func(pos syntax.Pos, msg string) {
+			imp.t.Fatalf("type error in %s: %s: %s", path, pos, msg)
+		},
+		Importer: imp,
+	}
+	pkg, _ := Check(path+".yoru", file, conf, nil)
+	pkg.SetPath(path)
+	return pkg, nil
+}
+
+const geoSrc = `package geo
+
+type Point struct {
+	X int
+	Y int
+	tag int
+}
+
+func (p Point) Sum() int {
+	return p.X + p.Y
+}
+
+func (p Point) secret() int {
+	return p.tag
+}
+
+func Origin() Point {
+	return Point{X: 0, Y: 0}
+}
+
+func helper() int {
+	return 1
+}
+`
+
+// checkWithImports type-checks src as the main package, resolving imports
+// from srcs.
+func checkWithImports(t *testing.T, src string, srcs map[string]string) []string {
+	t.Helper()
+	parseErrh := func(pos syntax.Pos, msg string) {
+		t.Fatalf("parse error: %s: %s", pos, msg)
+	}
+	file := syntax.NewParser("main.yoru", strings.NewReader(src), parseErrh).Parse()
+
+	var typeErrs []string
+	conf := &Config{
+		Error: func(pos syntax.Pos, msg string) {
+			typeErrs = append(typeErrs, pos.String()+": "+msg)
+		},
+		Importer: srcImporter{t, srcs},
+	}
+	_, _ = Check("main.yoru", file, conf, nil)
+	return typeErrs
+}
+
+func TestImportQualifiedIdents(t *testing.T) {
+	errs := checkWithImports(t, `package main
+
+import "example/geo"
+
+func main() {
+	var p geo.Point = geo.Point{X: 1, Y: 2}
+	var q *geo.Point = &p
+	println(p.Sum(), q.X, geo.Origin().Y)
+}
+`, map[string]string{"example/geo": geoSrc})
+	if len(errs) > 0 {
+		t.Errorf("unexpected errors:\n%s", strings.Join(errs, "\n"))
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"println(geo.helper())", "name helper not exported by package geo"},
+		{"println(geo.Missing())", "undefined: geo.Missing"},
+		{"var p geo.Point\n\tprintln(p.tag)", "cannot refer to unexported field tag"},
+		{"var p geo.Point\n\tprintln(p.secret())", "cannot refer to unexported method secret"},
+		{"var p geo.Point = geo.Point{tag: 1}\n\tprintln(p.X)", "cannot refer to unexported field tag in struct literal"},
+		{"println(geo)", "use of package geo without selector"},
+		{"var x geo.Origin\n\tprintln(x)", "geo.Origin is not a type"},
+	}
+	for _, tt := range tests {
+		src := "package main\n\nimport \"example/geo\"\n\nfunc main() {\n\t" + tt.body + "\n}\n"
+		errs := checkWithImports(t, src, map[string]string{"example/geo": geoSrc})
+		text := strings.Join(errs, "\n")
+		if !strings.Contains(text, tt.want) {
+			t.Errorf("%s: missing error %q, got:\n%s", tt.body, tt.want, text)
+		}
+	}
+}
+
+func TestImportDeclConflicts(t *testing.T) {
+	errs := checkWithImports(t, `package main
+
+import "example/geo"
+import "example/missing"
+
+func (p geo.Point) Norm() int {
+	return 0
+}
+
+func geo() {}
+
+func main() {
+	println(missing.F())
+}
+`, map[string]string{"example/geo": geoSrc})
+	text := strings.Join(errs, "\n")
+	for _, want := range []string{
+		"could not import example/missing (cannot find package example/missing)",
+		"cannot define new methods on non-local type geo.Point",
+		"geo already declared through import of package example/geo",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing error %q, got:\n%s", want, text)
+		}
+	}
+	// A failed import must not cause follow-on errors at its uses.
+	if strings.Contains(text, "missing.F") || strings.Contains(text, "undefined: missing") {
+		t.Errorf("unexpected follow-on error for failed import:\n%s", text)
+	}
+}
+
+func TestInitOrder(t *testing.T) {
+	src := `package main
+
+var a int = b + c
+var b int = f()
+var c int = 1
+var d int
+var e int = d + 1
+
+func f() int {
+	return c * 2
+}
+
+func main() {
+}
+`
+	file := syntax.NewParser("test.yoru", strings.NewReader(src), nil).Parse()
+	var errs []string
+	conf := &Config{
+		Error: func(pos syntax.Pos, msg string) {
+			errs = append(errs, pos.String()+": "+msg)
+		},
+	}
+	info := &Info{
+		Types: make(map[syntax.Expr]TypeAndValue),
+		Defs:  make(map[*syntax.Name]types.Object),
+		Uses:  make(map[*syntax.Name]types.Object),
+	}
+	if _, err := Check("test.yoru", file, conf, info); err != nil {
+		t.Fatalf("unexpected errors:\n%s", strings.Join(errs, "\n"))
+	}
+
+	var order []string
+	for _, init := range info.InitOrder {
+		order = append(order, init.Lhs.Name())
+	}
+	if got, want := strings.Join(order, " "), "c b a e"; got != want {
+		t.Errorf("init order = %s, want %s", got, want)
+	}
+}
+
+// TestInitOrderBlank checks that package-level blank variables can be
+// declared more than once and are still initialized, in order.
+func TestInitOrderBlank(t *testing.T) {
+	src := `package main
+
+var n int
+
+func f() int {
+	n = n + 1
+	return n
+}
+
+var _ = f()
+var _ int = f()
+
+func _() {
+}
+
+func _() {
+}
+
+func main() {
+}
+`
+	file := syntax.NewParser("test.yoru", strings.NewReader(src), nil).Parse()
+	var errs []string
+	conf := &Config{
+		Error: func(pos syntax.Pos, msg string) {
+			errs = append(errs, pos.String()+": "+msg)
+		},
+	}
+	info := &Info{
+		Types: make(map[syntax.Expr]TypeAndValue),
+		Defs:  make(map[*syntax.Name]types.Object),
+		Uses:  make(map[*syntax.Name]types.Object),
+	}
+	if _, err := Check("test.yoru", file, conf, info); err != nil {
+		t.Fatalf("unexpected errors:\n%s", strings.Join(errs, "\n"))
+	}
+
+	var order []string
+	for _, init := range info.InitOrder {
+		order = append(order, init.Lhs.Name()+":"+init.Lhs.Type().String())
+	}
+	if got, want := strings.Join(order, " "), "_:int _:int"; got != want {
+		t.Errorf("init order = %s, want %s", got, want)
+	}
+}
+
+func TestInitForwardReference(t *testing.T) {
+	expectNoErrors(t, `package main
+
+var x int = y * 2
+var y int = 21
+
+func main() {
+	println(x)
+}
+`)
+}
+
+func TestInitCycles(t *testing.T) {
+	expectErrorAtLine(t, `package main
+
+var x int = x + 1
+
+func main() {
+}
+`, 3, "initialization cycle: x refers to itself")
+
+	expectErrorAtLine(t, `package main
+
+var a int = f()
+
+func f() int {
+	return a
+}
+
+func main() {
+}
+`, 3, "initialization cycle: a refers to f refers to a")
+
+	// Functions may refer to variables that do not depend on them.
+	expectNoErrors(t, `package main
+
+var n int = count()
+var total int
+
+func count() int {
+	total = total + 1
+	return total
+}
+
+func main() {
+}
+`)
+}
@@ -172,3 +172,118 @@ func main() {
 }
 `)
 }
+
+// TestConstantBitwise tests constant folding of bitwise operators and shifts.
+// Array lengths are used to check the folded values: the assignments only
+// type-check if the lengths are equal.
+func TestConstantBitwise(t *testing.T) {
+	expectNoErrors(t, `
+package main
+
+var a [12 & 10]int
+var b [12 | 10]int
+var c [12 ^ 10]int
+var d [12 &^ 10]int
+var e [^-5]int
+var f [1 << 4]int
+var g [256 >> 6]int
+var h [10 / 3 & 7]int
+var i [1 << 100 >> 98]int
+
+var a1 [8]int = a
+var b1 [14]int = b
+var c1 [6]int = c
+var d1 [4]int = d
+var e1 [4]int = e
+var f1 [16]int = f
+var g1 [4]int = g
+var h1 [3]int = h
+var i1 [4]int = i
+`)
+}
+
+// TestShiftNonConstantCount tests shifts whose count is only known at run time.
+func TestShiftNonConstantCount(t *testing.T) {
+	expectNoErrors(t, `
+package main
+
+func f(x int, s int) int {
+	var y int = 1 << s
+	return x>>s + y&^x + ^x
+}
+`)
+}
+
+// TestBitwiseErrors tests invalid operands of bitwise operators and shifts.
+func TestBitwiseErrors(t *testing.T) {
+	expectErrors(t, `
+package main
+
+func f(x float) float {
+	return x & 1.0
+}
+`, "operator & not defined for float")
+
+	expectErrors(t, `
+package main
+
+func f(x float) float {
+	return ^x
+}
+`, "operator ^ requires integer operand")
+
+	expectErrors(t, `
+package main
+
+func f(x float) float {
+	return x << 1
+}
+`, "operator << requires integer operands")
+
+	expectErrors(t, `
+package main
+
+func f(x int, s float) int {
+	return x >> s
+}
+`, "shift count float must be integer")
+
+	expectErrors(t, `
+package main
+
+func f(x int) int {
+	return x << -1
+}
+`, "invalid shift count -1 (must be non-negative)")
+
+	expectErrors(t, `
+package main
+
+var x [1 << 2000]int
+`, "invalid shift count 2000 (too large)")
+}
+
+// TestUntypedShiftOverflow tests that an untyped constant shift is checked
+// against the int range once it gets a type.
+func TestUntypedShiftOverflow(t *testing.T) {
+	for _, body := range []string{
+		"var y int = 1 << 64",
+		"y := 1 << 64",
+		"var y int = 0\n\ty = 1 << 64",
+		"var x int = 1\n\ty := x + 1<<64",
+		"println(1 << 63)",
+	} {
+		expectErrors(t, "package main\n\nfunc main() {\n\t"+body+"\n}\n", "overflows int")
+	}
+
+	expectNoErrors(t, `
+package main
+
+func main() {
+	var a int = 1<<63 - 1
+	var b int = -1 << 63
+	var c float = 1 << 64
+	println(a, b, c, 1<<64>>60)
+}
+`)
+}
@@ -45,17 +45,8 @@ func (c *Checker) checkTypeDecl(decl *syntax.TypeDecl) bool {
 	return true
 }
 
-// checkVarDecl type-checks a top-level variable declaration.
-func (c *Checker) checkVarDecl(decl *syntax.VarDecl) {
-	obj := c.lookup(decl.Name.Value)
-	if obj == nil {
-		return
-	}
-	v, ok := obj.(*types.Var)
-	if !ok {
-		return
-	}
-
+// checkVarDecl type-checks the top-level declaration decl of v.
+func (c *Checker) checkVarDecl(v *types.Var, decl *syntax.VarDecl) {
 	var typ types.Type
 	var val operand
 
@@ -65,6 +56,10 @@ func (c *Checker) checkVarDecl(decl *syntax.VarDecl) {
 		if typ == nil {
 			return
 		}
+		// Set the type before checking the initializer, so that a
+		// self-referential initializer is reported as an initialization
+		// cycle rather than a type error.
+		v.SetType(typ)
 	}
 
 	if decl.Value != nil {
@@ -81,6 +76,7 @@ func (c *Checker) checkVarDecl(decl *syntax.VarDecl) {
 			// Type inference
 			if types.IsUntypedType(val.typ) {
 				typ = types.DefaultType(val.typ)
+				c.representable(&val, typ)
 			} else {
 				typ = val.typ
 			}
@@ -120,13 +116,10 @@ func (c *Checker) checkFuncSignature(decl *syntax.FuncDecl) {
 		params[i] = types.NewVar(p.Pos(), name, ptype)
 	}
 
-	// Resolve return type
-	var result types.Type
-	if decl.Result != nil {
-		result = c.resolveType(decl.Result)
-		if result == nil {
-			return
-		}
+	// Resolve result types
+	result, ok := c.resultType(decl.Results)
+	if !ok {
+		return
 	}
 
 	// Resolve receiver (for methods)
@@ -151,6 +144,25 @@ func (c *Checker) checkFuncSignature(decl *syntax.FuncDecl) {
 	fn.SetSignature(sig)
 }
 
+// resultType resolves a result type list: nil for no results, the type
+// of a single result, or a tuple of several results.
+func (c *Checker) resultType(list []syntax.Expr) (types.Type, bool) {
+	if len(list) == 0 {
+		return nil, true
+	}
+	elems := make([]types.Type, len(list))
+	for i, r := range list {
+		elems[i] = c.resolveType(r)
+		if elems[i] == nil {
+			return nil, false
+		}
+	}
+	if len(elems) == 1 {
+		return elems[0], true
+	}
+	return types.NewTuple(elems...), true
+}
+
 // addMethod adds a method to the receiver's named type.
 func (c *Checker) addMethod(pos syntax.Pos, recvType types.Type, method *types.FuncObj) {
 	// Get base type (strip pointer/ref)
@@ -162,9 +174,17 @@ func (c *Checker) addMethod(pos syntax.Pos, recvType types.Type, method *types.F
 		c.errorf(pos, "method receiver cannot be ref type %s", recvType)
 		return
 	}
+	if types.IsInterface(base) {
+		c.errorf(pos, "invalid receiver type %s (pointer or interface type)", recvType)
+		return
+	}
 
 	// Find the named type
 	if named, ok := base.(*types.Named); ok {
+		if named.Obj().Pkg() != c.pkg {
+			c.errorf(pos, "cannot define new methods on non-local type %s", named)
+			return
+		}
 		if existing := named.LookupMethod(method.Name()); existing != nil {
 			c.errorf(pos, "method %s already declared for %s", method.Name(), named)
 			return
@@ -202,14 +222,14 @@ func (c *Checker) checkFuncBody(decl *syntax.FuncDecl) {
 	// Add receiver to scope
 	if sig.Recv() != nil {
 		recv := sig.Recv()
-		if recv.Name() != "" {
+		if recv.Name() != "" && recv.Name() != "_" {
 			c.scope.Insert(recv)
 		}
 	}
 
 	// Add parameters to scope
 	for _, p := range sig.Params() {
-		if p.Name() != "" {
+		if p.Name() != "" && p.Name() != "_" {
 			c.scope.Insert(p)
 		}
 	}
@@ -64,7 +64,7 @@ func (c *Checker) checkCallArgEscape(e *syntax.CallExpr, args []*operand) {
 		}
 	}
 
-	for i, arg := range args {
+	for _, arg := range args {
 		if arg == nil || arg.mode == invalid {
 			continue
 		}
@@ -73,7 +73,7 @@ func (c *Checker) checkCallArgEscape(e *syntax.CallExpr, args []*operand) {
 		}
 
 		if !isBuiltin {
-			c.errorf(e.Args[i].Pos(),
+			c.errorf(arg.pos,
 				"*T cannot be passed to function (may escape); use ref T for heap data")
 		}
 	}
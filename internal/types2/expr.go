@@ -1,16 +1,26 @@
 package types2
 
 import (
+	"fmt"
 	"go/constant"
 	"go/token"
 	"strconv"
+	"strings"
 
 	"github.com/you-not-fish/yoru/internal/syntax"
 	"github.com/you-not-fish/yoru/internal/types"
 )
 
 // expr evaluates an expression and sets x to the result.
+// The expression must denote a single value.
 func (c *Checker) expr(x *operand, e syntax.Expr) {
+	c.multiExpr(x, e)
+	c.singleValue(x, e)
+}
+
+// multiExpr is like expr but also accepts a call of a function with
+// several results, whose operand type is a *types.Tuple.
+func (c *Checker) multiExpr(x *operand, e syntax.Expr) {
 	c.exprInternal(x, e)
 
 	// Record type information
@@ -19,6 +29,18 @@ func (c *Checker) expr(x *operand, e syntax.Expr) {
 	}
 }
 
+// singleValue reports an error if x, the operand of e, denotes several
+// values.
+func (c *Checker) singleValue(x *operand, e syntax.Expr) {
+	if x.mode != value {
+		return
+	}
+	if t, ok := x.typ.(*types.Tuple); ok {
+		c.errorf(x.pos, "multiple-value %s (value of type %s) in single-value context", callString(e), t)
+		x.mode = invalid
+	}
+}
+
 // exprInternal is the main expression checking function.
 func (c *Checker) exprInternal(x *operand, e syntax.Expr) {
 	x.mode = invalid
@@ -40,15 +62,19 @@ func (c *Checker) exprInternal(x *operand, e syntax.Expr) {
 		c.call(x, e)
 	case *syntax.IndexExpr:
 		c.index(x, e)
+	case *syntax.SliceExpr:
+		c.sliceExpr(x, e)
 	case *syntax.SelectorExpr:
 		c.selector(x, e)
+	case *syntax.AssertExpr:
+		c.typeAssertion(x, e)
 	case *syntax.NewExpr:
 		c.newExpr(x, e)
 	case *syntax.CompositeLit:
 		c.compositeLit(x, e)
 	case *syntax.ParenExpr:
-		c.exprInternal(x, e.X)
-	case *syntax.ArrayType, *syntax.PointerType, *syntax.RefType, *syntax.StructType:
+		c.expr(x, e.X)
+	case *syntax.ArrayType, *syntax.SliceType, *syntax.MapType, *syntax.PointerType, *syntax.RefType, *syntax.StructType:
 		c.typExpr(x, e)
 	default:
 		c.errorf(e.Pos(), "unexpected expression %T", e)
@@ -57,14 +83,33 @@ func (c *Checker) exprInternal(x *operand, e syntax.Expr) {
 
 // ident evaluates an identifier.
 func (c *Checker) ident(x *operand, name *syntax.Name) {
+	if name.Value == "_" {
+		c.errorf(name.Pos(), "cannot use _ as value")
+		x.mode = invalid
+		return
+	}
 	obj := c.resolve(name)
 	if obj == nil {
 		x.mode = invalid
 		return
 	}
+	c.objOperand(x, obj, name)
+}
 
+// objOperand sets x to the operand denoted by the object obj, referenced
+// by the identifier name.
+func (c *Checker) objOperand(x *operand, obj types.Object, name *syntax.Name) {
 	switch obj := obj.(type) {
 	case *types.Var:
+		if _, ok := c.varDecls[obj]; ok {
+			c.checkGlobalVar(obj)
+		}
+		if obj.Type() == nil {
+			// Invalid declaration or initialization cycle; already
+			// reported or reported by initOrder.
+			x.mode = invalid
+			return
+		}
 		x.mode = variable
 		x.typ = obj.Type()
 		// Handle true/false as constants
@@ -85,6 +130,9 @@ func (c *Checker) ident(x *operand, name *syntax.Name) {
 		x.mode = constant_
 		x.typ = types.Typ[types.UntypedNil]
 		x.val = nil
+	case *types.PkgName:
+		c.errorf(name.Pos(), "use of package %s without selector", name.Value)
+		x.mode = invalid
 	default:
 		c.errorf(name.Pos(), "unexpected object %T", obj)
 		x.mode = invalid
@@ -167,6 +215,16 @@ func (c *Checker) unary(x *operand, e *syntax.Operation) {
 			x.val = constant.UnaryOp(token.SUB, x.val, 0)
 		}
 
+	case syntax.Xor: // ^ (bitwise complement)
+		if !isInteger(x.typ) {
+			c.errorf(e.Pos(), "operator ^ requires integer operand")
+			x.mode = invalid
+			return
+		}
+		if x.mode == constant_ {
+			x.val = constant.UnaryOp(token.XOR, intConst(x.val), 0)
+		}
+
 	case syntax.And: // &
 		if x.mode != variable {
 			c.errorf(e.Pos(), "cannot take address of %s", e.X)
@@ -228,6 +286,11 @@ func (c *Checker) binary(x *operand, e *syntax.Operation) {
 		return
 	}
 
+	if op.IsShift() {
+		c.shift(x, &y, op)
+		return
+	}
+
 	// Arithmetic operators
 	c.arithmetic(x, &y, op)
 }
@@ -324,10 +387,10 @@ func (c *Checker) arithmetic(x, y *operand, op syntax.Token) {
 		return
 	}
 
-	// Check for % on floats
-	if op.IsRem() {
+	// Check for % and bitwise operators on floats
+	if op.IsRem() || op.IsBitwise() {
 		if isFloat(x.typ) || isFloat(y.typ) {
-			c.errorf(x.pos, "operator %% not defined for float")
+			c.errorf(x.pos, "operator %s not defined for float", op)
 			x.mode = invalid
 			return
 		}
@@ -343,9 +406,16 @@ func (c *Checker) arithmetic(x, y *operand, op syntax.Token) {
 			x.typ = types.Typ[types.UntypedInt]
 		}
 	} else if types.IsUntypedType(x.typ) {
+		if !c.representable(x, y.typ) {
+			return
+		}
 		x.typ = y.typ
 	} else if types.IsUntypedType(y.typ) {
 		// x.typ stays the same
+		if !c.representable(y, x.typ) {
+			x.mode = invalid
+			return
+		}
 	} else {
 		// Both typed: must be identical
 		if !types.Identical(x.typ, y.typ) {
@@ -361,20 +431,71 @@ func (c *Checker) arithmetic(x, y *operand, op syntax.Token) {
 	}
 }
 
+// maxShift is the largest constant shift count accepted in constant
+// expressions.
+const maxShift = 1023
+
+// shift handles the shift operators (<<, >>). The result has the type of
+// the left operand; a non-constant shift of an untyped constant is an int.
+func (c *Checker) shift(x, y *operand, op syntax.Token) {
+	if !isInteger(x.typ) {
+		c.errorf(x.pos, "operator %s requires integer operands", op)
+		x.mode = invalid
+		return
+	}
+	if !isInteger(y.typ) {
+		c.errorf(y.pos, "shift count %s must be integer", y.typ)
+		x.mode = invalid
+		return
+	}
+
+	if y.mode == constant_ {
+		if constant.Sign(y.val) < 0 {
+			c.errorf(y.pos, "invalid shift count %s (must be non-negative)", y.val)
+			x.mode = invalid
+			return
+		}
+		if x.mode == constant_ {
+			s, ok := constant.Uint64Val(y.val)
+			if !ok || s > maxShift {
+				c.errorf(y.pos, "invalid shift count %s (too large)", y.val)
+				x.mode = invalid
+				return
+			}
+			goTok, _ := toGoToken(op)
+			x.val = constant.Shift(intConst(x.val), goTok, uint(s))
+			if !types.IsUntypedType(x.typ) {
+				c.representable(x, x.typ)
+			}
+			return
+		}
+	}
+
+	if types.IsUntypedType(x.typ) {
+		x.typ = types.Typ[types.Int]
+	}
+	x.mode = value
+}
+
 // comparable reports whether x and y can be compared.
 func (c *Checker) comparable(x, y *operand) bool {
 	if x.typ == nil || y.typ == nil {
 		return false
 	}
 
-	// nil can be compared to any pointer or ref type
-	if x.isNil() && types.IsPointerOrRef(y.typ) {
+	// nil can be compared to any pointer, ref, slice or interface type
+	if x.isNil() && types.IsNilable(y.typ) {
 		return true
 	}
-	if y.isNil() && types.IsPointerOrRef(x.typ) {
+	if y.isNil() && types.IsNilable(x.typ) {
 		return true
 	}
 
+	// Interface values can only be compared to nil
+	if types.IsInterface(x.typ) || types.IsInterface(y.typ) {
+		return false
+	}
+
 	// Types must be compatible
 	if types.AssignableTo(x.typ, y.typ) || types.AssignableTo(y.typ, x.typ) {
 		return types.Comparable(x.typ) || types.Comparable(y.typ)
@@ -451,7 +572,7 @@ func (c *Checker) isAddressOfLocal(e syntax.Expr) bool {
 	case *syntax.IndexExpr:
 		var base operand
 		c.expr(&base, e.X)
-		if base.mode == invalid || types.IsRef(base.typ) {
+		if base.mode == invalid || types.IsRef(base.typ) || types.IsSlice(base.typ) {
 			return false
 		}
 		return c.isAddressOfLocal(e.X)
@@ -465,36 +586,29 @@ func (c *Checker) index(x *operand, e *syntax.IndexExpr) {
 	if x.mode == invalid {
 		return
 	}
+	if m, ok := x.typ.Underlying().(*types.Map); ok {
+		c.mapIndex(x, e, m)
+		return
+	}
 
-	// Check that x is an array or pointer to array
-	var elemType types.Type
-	switch t := x.typ.Underlying().(type) {
-	case *types.Array:
-		elemType = t.Elem()
-		x.mode = variable // array elements are addressable
-	case *types.Pointer:
-		if arr, ok := t.Elem().Underlying().(*types.Array); ok {
-			elemType = arr.Elem()
-			x.mode = variable
-		} else {
-			c.errorf(e.Pos(), "cannot index into %s", x.typ)
-			x.mode = invalid
-			return
-		}
-	case *types.Ref:
-		if arr, ok := t.Elem().Underlying().(*types.Array); ok {
-			elemType = arr.Elem()
-			x.mode = variable
-		} else {
-			c.errorf(e.Pos(), "cannot index into %s", x.typ)
-			x.mode = invalid
-			return
-		}
-	default:
+	// Check that x is an array, a pointer or ref to an array, or a slice
+	var elem types.Type
+	length := int64(-1) // unknown for slices
+	if s, ok := x.typ.Underlying().(*types.Slice); ok {
+		elem = s.Elem()
+	} else if arr := arrayOf(x.typ); arr != nil {
+		elem, length = arr.Elem(), arr.Len()
+	} else {
 		c.errorf(e.Pos(), "cannot index into %s", x.typ)
 		x.mode = invalid
 		return
 	}
+	if _, isArray := x.typ.Underlying().(*types.Array); isArray && x.mode == mapindex {
+		// An array stored in a map can only be replaced as a whole.
+		x.mode = value
+	} else {
+		x.mode = variable // array and slice elements are addressable
+	}
 
 	// Check index
 	var idx operand
@@ -510,11 +624,173 @@ func (c *Checker) index(x *operand, e *syntax.IndexExpr) {
 		return
 	}
 
-	x.typ = elemType
+	// Constant indexes are checked against the array length here; all
+	// others get a bounds check at run time.
+	if idx.mode == constant_ {
+		n, ok := c.constInt64(&idx)
+		if !ok {
+			x.mode = invalid
+			return
+		}
+		if n < 0 {
+			c.errorf(e.Index.Pos(), "invalid array index %s (index must be non-negative)", idx.val)
+			x.mode = invalid
+			return
+		}
+		if length >= 0 && n >= length {
+			c.errorf(e.Index.Pos(), "invalid array index %s (out of bounds for %d-element array)", idx.val, length)
+			x.mode = invalid
+			return
+		}
+	}
+
+	x.typ = elem
+}
+
+// mapIndex evaluates the map index expression m[k]. The result can be
+// read and assigned but not addressed; in a two-value assignment it also
+// reports whether the key is present.
+func (c *Checker) mapIndex(x *operand, e *syntax.IndexExpr, m *types.Map) {
+	var key operand
+	c.expr(&key, e.Index)
+	if key.mode == invalid {
+		x.mode = invalid
+		return
+	}
+	c.assignment(&key, m.Key(), "map index")
+	if key.mode == invalid {
+		x.mode = invalid
+		return
+	}
+	x.mode = mapindex
+	x.typ = m.Elem()
+}
+
+// arrayOf returns the array type of T if T is an array or a pointer or ref
+// to an array, and nil otherwise.
+func arrayOf(T types.Type) *types.Array {
+	switch t := T.Underlying().(type) {
+	case *types.Array:
+		return t
+	case *types.Pointer:
+		a, _ := t.Elem().Underlying().(*types.Array)
+		return a
+	case *types.Ref:
+		a, _ := t.Elem().Underlying().(*types.Array)
+		return a
+	}
+	return nil
+}
+
+// sliceExpr evaluates a slice expression x[lo:hi]. x may be an addressable
+// array, a ref to an array, or a slice; the result is a slice sharing x's
+// elements.
+func (c *Checker) sliceExpr(x *operand, e *syntax.SliceExpr) {
+	c.expr(x, e.X)
+	if x.mode == invalid {
+		return
+	}
+
+	var elem types.Type
+	length := int64(-1) // unknown for slices
+	switch t := x.typ.Underlying().(type) {
+	case *types.Slice:
+		elem = t.Elem()
+	case *types.Array:
+		if x.mode != variable {
+			c.errorf(e.Pos(), "invalid operation: cannot slice unaddressable value of type %s", x.typ)
+			x.mode = invalid
+			return
+		}
+		elem, length = t.Elem(), t.Len()
+	case *types.Pointer:
+		// The slice could outlive the stack array the pointer points to.
+		c.errorf(e.Pos(), "cannot slice %s: *T cannot escape to the heap (use ref T for heap data)", x.typ)
+		x.mode = invalid
+		return
+	default:
+		arr := arrayOf(x.typ)
+		if arr == nil {
+			c.errorf(e.Pos(), "cannot slice %s", x.typ)
+			x.mode = invalid
+			return
+		}
+		elem, length = arr.Elem(), arr.Len()
+	}
+
+	// Check the bounds; constant bounds must be in range and ordered
+	bounds := [2]int64{-1, -1}
+	for i, b := range []syntax.Expr{e.Lo, e.Hi} {
+		if b == nil {
+			continue
+		}
+		var idx operand
+		c.expr(&idx, b)
+		if idx.mode == invalid {
+			x.mode = invalid
+			return
+		}
+		if !isInteger(idx.typ) {
+			c.errorf(b.Pos(), "slice index must be an integer")
+			x.mode = invalid
+			return
+		}
+		if idx.mode != constant_ {
+			continue
+		}
+		n, ok := c.constInt64(&idx)
+		if !ok {
+			x.mode = invalid
+			return
+		}
+		if n < 0 {
+			c.errorf(b.Pos(), "invalid slice index %s (index must be non-negative)", idx.val)
+			x.mode = invalid
+			return
+		}
+		if length >= 0 && n > length {
+			c.errorf(b.Pos(), "invalid slice index %s (out of bounds for %d-element array)", idx.val, length)
+			x.mode = invalid
+			return
+		}
+		bounds[i] = n
+	}
+	if bounds[0] >= 0 && bounds[1] >= 0 && bounds[0] > bounds[1] {
+		c.errorf(e.Pos(), "invalid slice indices: %d < %d", bounds[1], bounds[0])
+		x.mode = invalid
+		return
+	}
+
+	x.mode = value
+	x.typ = types.NewSlice(elem)
+	if !c.checkSliceElem(e.Pos(), elem) {
+		x.mode = invalid
+	}
+}
+
+// checkSliceElem reports an error if elem cannot be the element type of a
+// slice. Slice elements live on the heap, so *T elements would escape.
+func (c *Checker) checkSliceElem(pos syntax.Pos, elem types.Type) bool {
+	if types.IsPointer(elem) {
+		c.errorf(pos, "invalid slice element type %s (*T cannot escape to the heap; use ref T)", elem)
+		return false
+	}
+	return true
 }
 
 // selector evaluates a selector expression x.sel.
 func (c *Checker) selector(x *operand, e *syntax.SelectorExpr) {
+	// Qualified identifier: pkg.Name
+	if c.isQualified(e) {
+		obj := c.qualifiedObject(e)
+		if obj == nil {
+			x.mode = invalid
+			return
+		}
+		c.objOperand(x, obj, e.Sel)
+		return
+	}
+
 	c.expr(x, e.X)
 	if x.mode == invalid {
 		return
@@ -524,8 +800,18 @@ func (c *Checker) selector(x *operand, e *syntax.SelectorExpr) {
 
 	// Try field access
 	if field := c.lookupField(x.typ, sel); field != nil {
+		if !c.visible(field) {
+			c.errorf(e.Sel.Pos(), "%s.%s undefined (cannot refer to unexported field %s)", x.typ, sel, sel)
+			x.mode = invalid
+			return
+		}
 		c.recordUse(e.Sel, field)
-		x.mode = variable
+		if x.mode == mapindex && !types.IsPointerOrRef(x.typ) {
+			// A struct stored in a map can only be replaced as a whole.
+			x.mode = value
+		} else {
+			x.mode = variable
+		}
 		x.typ = field.Type()
 		return
 	}
@@ -542,6 +828,60 @@ func (c *Checker) selector(x *operand, e *syntax.SelectorExpr) {
 	x.mode = invalid
 }
 
+// isQualified reports whether e is a qualified identifier pkg.Name,
+// i.e. e.X names an imported package.
+func (c *Checker) isQualified(e *syntax.SelectorExpr) bool {
+	name, ok := e.X.(*syntax.Name)
+	if !ok {
+		return false
+	}
+	_, ok = c.lookup(name.Value).(*types.PkgName)
+	return ok
+}
+
+// qualifiedObject resolves a qualified identifier pkg.Name to the exported
+// object it denotes. It reports an error and returns nil if the package
+// has no such exported name.
+func (c *Checker) qualifiedObject(e *syntax.SelectorExpr) types.Object {
+	name, ok := e.X.(*syntax.Name)
+	if !ok {
+		c.errorf(e.Pos(), "%T is not a package", e.X)
+		return nil
+	}
+	obj := c.resolve(name)
+	if obj == nil {
+		return nil
+	}
+	pn, ok := obj.(*types.PkgName)
+	if !ok {
+		c.errorf(name.Pos(), "%s is not a package", name.Value)
+		return nil
+	}
+
+	pkg := pn.Imported()
+	if c.badImports[pkg] {
+		return nil // import error already reported
+	}
+	sel := e.Sel.Value
+	if !types.IsExported(sel) {
+		c.errorf(e.Sel.Pos(), "name %s not exported by package %s", sel, pkg.Name())
+		return nil
+	}
+	obj = pkg.Scope().Lookup(sel)
+	if obj == nil {
+		c.errorf(e.Sel.Pos(), "undefined: %s.%s", name.Value, sel)
+		return nil
+	}
+	c.recordUse(e.Sel, obj)
+	return obj
+}
+
+// visible reports whether a field or method is accessible from the
+// package being checked: it must be declared locally or exported.
+func (c *Checker) visible(obj types.Object) bool {
+	return obj.Pkg() == nil || obj.Pkg() == c.pkg || obj.Exported()
+}
+
 // lookupField looks up a field by name in type T.
 func (c *Checker) lookupField(T types.Type, name string) *types.Var {
 	if T == nil {
@@ -588,6 +928,9 @@ func (c *Checker) lookupMethodObj(T types.Type, name string) *types.FuncObj {
 		return c.lookupMethodObj(t.Elem(), name)
 	case *types.Ref:
 		return c.lookupMethodObj(t.Elem(), name)
+	case *types.Interface:
+		_, m := t.LookupMethod(name)
+		return m
 	}
 
 	if named, ok := T.(*types.Named); ok {
@@ -670,6 +1013,10 @@ func (c *Checker) compositeLit(x *operand, e *syntax.CompositeLit) {
 				c.errorf(key.Pos(), "unknown field %s", key.Value)
 				continue
 			}
+			if !c.visible(field) {
+				c.errorf(key.Pos(), "cannot refer to unexported field %s in struct literal of type %s", key.Value, T)
+				continue
+			}
 
 			// Check value
 			var val operand
@@ -685,6 +1032,9 @@ func (c *Checker) compositeLit(x *operand, e *syntax.CompositeLit) {
 				break
 			}
 			field := st.Field(i)
+			if !c.visible(field) {
+				c.errorf(elem.Pos(), "implicit assignment to unexported field %s in struct literal of type %s", field.Name(), T)
+			}
 			var val operand
 			c.expr(&val, elem)
 			if val.mode != invalid {
@@ -697,6 +1047,21 @@ func (c *Checker) compositeLit(x *operand, e *syntax.CompositeLit) {
 	x.typ = T
 }
 
+// representable reports whether the value of x fits in type T. An integer
+// constant, typed or untyped, that overflows the integer type T is
+// reported and x is invalidated; other operands are always representable.
+func (c *Checker) representable(x *operand, T types.Type) bool {
+	if x.mode != constant_ || x.val == nil || x.val.Kind() != constant.Int || !isInteger(T) {
+		return true
+	}
+	if _, exact := constant.Int64Val(x.val); !exact {
+		c.errorf(x.pos, "constant %s overflows %s", x.val, T)
+		x.mode = invalid
+		return false
+	}
+	return true
+}
+
 // constInt64 returns the int64 value of a constant operand.
 func (c *Checker) constInt64(x *operand) (int64, bool) {
 	if x.mode != constant_ {
@@ -744,9 +1109,20 @@ func (c *Checker) assignment(x *operand, T types.Type, context string) {
 		return
 	}
 
+	// Implicit conversion to an interface type
+	if it, ok := T.Underlying().(*types.Interface); ok && !x.isNil() {
+		if !c.interfaceAssignment(x, T, it, context) {
+			x.mode = invalid
+		}
+		return
+	}
+
 	if types.AssignableTo(x.typ, T) {
 		// Convert untyped to typed
 		if types.IsUntypedType(x.typ) {
+			if !c.representable(x, T) {
+				return
+			}
 			x.typ = T
 		}
 		return
@@ -756,6 +1132,98 @@ func (c *Checker) assignment(x *operand, T types.Type, context string) {
 	x.mode = invalid
 }
 
+// interfaceAssignment checks the implicit conversion of x to the interface
+// type T with underlying type it. An untyped constant is converted to its
+// default type. It reports whether the conversion is valid.
+func (c *Checker) interfaceAssignment(x *operand, T types.Type, it *types.Interface, context string) bool {
+	V := types.DefaultType(x.typ)
+	if types.IsInterface(V) {
+		if types.Identical(V.Underlying(), it) {
+			return true
+		}
+		c.errorf(x.pos, "cannot use %s as %s in %s: conversion between different interface types is not supported", x.typ, T, context)
+		return false
+	}
+	if types.IsPointer(V) {
+		c.errorf(x.pos, "cannot use %s as %s in %s: *T cannot be stored in an interface (may escape); use ref T for heap data", x.typ, T, context)
+		return false
+	}
+	if missing, have := types.MissingMethod(V, it); missing != nil {
+		c.errorf(x.pos, "cannot use %s as %s in %s: %s", x.typ, T, context, missingMethodReason(V, T, missing, have))
+		return false
+	}
+	x.typ = V
+	return true
+}
+
+// typeAssertion checks a type assertion x.(T).
+func (c *Checker) typeAssertion(x *operand, e *syntax.AssertExpr) {
+	if e.Type == nil {
+		c.errorf(e.Pos(), "use of .(type) outside type switch")
+		return
+	}
+	c.expr(x, e.X)
+	if x.mode == invalid {
+		return
+	}
+	it, ok := x.typ.Underlying().(*types.Interface)
+	if !ok {
+		c.errorf(x.pos, "invalid operation: %s is not an interface", x.typ)
+		x.mode = invalid
+		return
+	}
+	T := c.resolveType(e.Type)
+	if T == nil || !c.assertableTo(e.Type.Pos(), x.typ, it, T, "type assertion") {
+		x.mode = invalid
+		return
+	}
+	x.mode = value
+	x.typ = T
+}
+
+// assertableTo reports whether a value of the interface type V with
+// underlying type it may hold a value of type T, and reports an error at
+// pos if not. what names the construct being checked.
+func (c *Checker) assertableTo(pos syntax.Pos, V types.Type, it *types.Interface, T types.Type, what string) bool {
+	if types.IsInterface(T) {
+		if types.Identical(T.Underlying(), it) {
+			return true
+		}
+		c.errorf(pos, "unsupported %s: %s is a different interface type than %s", what, T, V)
+		return false
+	}
+	if types.IsPointer(T) {
+		c.errorf(pos, "impossible %s: %s cannot be stored in an interface", what, T)
+		return false
+	}
+	if missing, have := types.MissingMethod(T, it); missing != nil {
+		c.errorf(pos, "impossible %s: %s", what, missingMethodReason(T, V, missing, have))
+		return false
+	}
+	return true
+}
+
+// missingMethodReason explains why V does not implement the interface T,
+// given the results of types.MissingMethod.
+func missingMethodReason(V, T types.Type, missing, have *types.FuncObj) string {
+	switch {
+	case have == nil:
+		return fmt.Sprintf("%s does not implement %s (missing method %s)", V, T, missing.Name())
+	case types.HasPointerRecv(have) && types.MethodSet(V, have.Name()) == nil:
+		return fmt.Sprintf("%s does not implement %s (method %s has pointer receiver)", V, T, missing.Name())
+	default:
+		return fmt.Sprintf("%s does not implement %s (wrong type for method %s: have %s, want %s)",
+			V, T, missing.Name(), methodString(have), methodString(missing))
+	}
+}
+
+// methodString returns the signature of a method without its receiver,
+// such as Area() int.
+func methodString(m *types.FuncObj) string {
+	sig := m.Signature()
+	return m.Name() + strings.TrimPrefix(types.NewFunc(nil, sig.Params(), sig.Result()).String(), "func")
+}
+
 // Constant evaluation helpers
 func (c *Checker) evalComparison(x, y constant.Value, op syntax.Token) constant.Value {
 	goTok, ok := toGoToken(op)
@@ -782,9 +1250,22 @@ func (c *Checker) evalArithmetic(x, y constant.Value, op syntax.Token) constant.
 	if !ok {
 		return nil
 	}
+	if op.IsBitwise() {
+		x, y = intConst(x), intConst(y)
+	}
 	return constant.BinaryOp(x, goTok, y)
 }
 
+// intConst returns the integer value of an integer constant. Integer
+// division yields exact rationals (e.g. 10/3), which are truncated toward
+// zero as at run time.
+func intConst(x constant.Value) constant.Value {
+	if x.Kind() != constant.Float {
+		return x
+	}
+	return constant.BinaryOp(constant.Num(x), token.QUO_ASSIGN, constant.Denom(x)) // integer division
+}
+
 func toGoToken(op syntax.Token) (token.Token, bool) {
 	switch op.String() {
 	case "||":
@@ -823,6 +1304,8 @@ func toGoToken(op syntax.Token) (token.Token, bool) {
 		return token.SHL, true
 	case ">>":
 		return token.SHR, true
+	case "&^":
+		return token.AND_NOT, true
 	}
 	return token.ILLEGAL, false
 }
@@ -0,0 +1,166 @@
+package types2
+
+import (
+	"strings"
+
+	"github.com/you-not-fish/yoru/internal/types"
+)
+
+// checkGlobalVar type-checks the declaration of a package-level variable,
+// unless it has already been checked or is being checked.
+func (c *Checker) checkGlobalVar(v *types.Var) {
+	if c.varChecked[v] {
+		return
+	}
+	c.varChecked[v] = true
+
+	decl := c.varDecls[v]
+	oldScope, oldDecl := c.scope, c.decl
+	c.enterFile(decl)
+	c.decl = v
+	c.checkVarDecl(v, decl)
+	c.scope, c.decl = oldScope, oldDecl
+}
+
+// recordDep records that the declaration being checked refers to obj, if
+// obj is a package-level variable or function of the current package.
+func (c *Checker) recordDep(obj types.Object) {
+	if c.decl == nil || obj.Pkg() != c.pkg {
+		return
+	}
+	switch obj := obj.(type) {
+	case *types.Var:
+		if _, ok := c.varDecls[obj]; !ok {
+			return
+		}
+	case *types.FuncObj:
+	default:
+		return
+	}
+	for _, d := range c.objDeps[c.decl] {
+		if d == obj {
+			return
+		}
+	}
+	c.objDeps[c.decl] = append(c.objDeps[c.decl], obj)
+}
+
+// initOrder computes the initialization order of package-level variables
+// and records it in Info.InitOrder. Following the Go specification, it
+// repeatedly selects the earliest variable in declaration order that has
+// no dependencies on uninitialized variables. Initialization cycles are
+// reported.
+func (c *Checker) initOrder() {
+	// Variables each variable depends on, directly or through functions.
+	deps := make(map[*types.Var][]*types.Var)
+	for _, v := range c.globals {
+		deps[v] = c.varDeps(v)
+	}
+
+	done := make(map[*types.Var]bool)
+	var pending []*types.Var
+	for _, v := range c.globals {
+		if c.varDecls[v].Value == nil {
+			done[v] = true // zero value; nothing to initialize
+			continue
+		}
+		pending = append(pending, v)
+	}
+
+	for len(pending) > 0 {
+		next := -1
+		for i, v := range pending {
+			if c.ready(v, deps[v], done) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			// Every remaining variable waits on another one: there is a
+			// cycle. Report it and initialize its first variable anyway.
+			next = 0
+			v := pending[0]
+			for i, p := range pending {
+				if cycle := c.findPath(p, p, make(map[types.Object]bool)); cycle != nil {
+					next, v = i, p
+					c.reportCycle(v, cycle)
+					break
+				}
+			}
+		}
+
+		v := pending[next]
+		pending = append(pending[:next], pending[next+1:]...)
+		done[v] = true
+		if c.info != nil {
+			c.info.InitOrder = append(c.info.InitOrder, &Initializer{Lhs: v, Rhs: c.varDecls[v].Value})
+		}
+	}
+}
+
+// ready reports whether all variables in deps other than v are initialized.
+// A variable depending on itself is never ready.
+func (c *Checker) ready(v *types.Var, deps []*types.Var, done map[*types.Var]bool) bool {
+	for _, d := range deps {
+		if d == v || !done[d] {
+			return false
+		}
+	}
+	return true
+}
+
+// varDeps returns the package-level variables v's initializer refers to,
+// directly or through the bodies of the functions it refers to.
+func (c *Checker) varDeps(v *types.Var) []*types.Var {
+	var result []*types.Var
+	seen := make(map[types.Object]bool)
+	var visit func(obj types.Object)
+	visit = func(obj types.Object) {
+		for _, d := range c.objDeps[obj] {
+			if seen[d] {
+				continue
+			}
+			seen[d] = true
+			switch d := d.(type) {
+			case *types.Var:
+				result = append(result, d)
+			case *types.FuncObj:
+				visit(d)
+			}
+		}
+	}
+	visit(v)
+	return result
+}
+
+// findPath returns a path of references from 'from' to 'to', excluding
+// 'from' itself, or nil if there is none.
+func (c *Checker) findPath(from, to types.Object, seen map[types.Object]bool) []types.Object {
+	if seen[from] {
+		return nil
+	}
+	seen[from] = true
+	for _, d := range c.objDeps[from] {
+		if d == to {
+			return []types.Object{d}
+		}
+		if path := c.findPath(d, to, seen); path != nil {
+			return append([]types.Object{d}, path...)
+		}
+	}
+	return nil
+}
+
+// reportCycle reports the initialization cycle v → cycle..., where the
+// last element of cycle is v.
+func (c *Checker) reportCycle(v *types.Var, cycle []types.Object) {
+	if len(cycle) == 1 {
+		c.errorf(v.Pos(), "initialization cycle: %s refers to itself", v.Name())
+		return
+	}
+	names := []string{v.Name()}
+	for _, obj := range cycle {
+		names = append(names, obj.Name())
+	}
+	c.errorf(v.Pos(), "initialization cycle: %s", strings.Join(names, " refers to "))
+}
@@ -18,6 +18,7 @@ const (
 	constant_                    // operand is a constant value
 	variable                     // operand is an addressable variable
 	value                        // operand is a computed value (not addressable)
+	mapindex                     // operand is a map index expression (assignable, not addressable)
 )
 
 // operand represents the result of evaluating an expression.
@@ -99,7 +99,7 @@ func TestBreakOutsideLoop(t *testing.T) {
 	func main() {
 		break
 	}
-	`, 5, "break not in for loop")
+	`, 5, "break not in for loop or switch")
 }
 
 func TestContinueOutsideLoop(t *testing.T) {
@@ -1,6 +1,8 @@
 package types2
 
 import (
+	pathpkg "path"
+
 	"github.com/you-not-fish/yoru/internal/syntax"
 	"github.com/you-not-fish/yoru/internal/types"
 )
@@ -16,9 +18,52 @@ func (c *Checker) collectDecls(decls []syntax.Decl) {
 			c.collectVarDecl(decl)
 		case *syntax.FuncDecl:
 			c.collectFuncDecl(decl)
-		case *syntax.ImportDecl:
-			// Imports are parsed but not semantically analyzed in v1
-			c.errorf(decl.Pos(), "import statements are not supported")
+		}
+	}
+}
+
+// collectImports resolves the imports of a file and declares the imported
+// package names in the file scope.
+func (c *Checker) collectImports(file *syntax.File, fileScope *types.Scope) {
+	for _, imp := range file.Imports {
+		if imp.Path == nil {
+			continue // syntax error already reported
+		}
+		path := imp.Path.Value
+		if c.conf.Importer == nil {
+			c.errorf(imp.Pos(), "import statements are not supported")
+			continue
+		}
+		if path == "" {
+			c.errorf(imp.Path.Pos(), "invalid import path: %q", path)
+			continue
+		}
+		pkg, err := c.conf.Importer.Import(path)
+		if err != nil {
+			c.errorf(imp.Path.Pos(), "could not import %s (%v)", path, err)
+			// Declare a fake package so uses of it do not cause
+			// follow-on errors.
+			pkg = types.NewPackage(pathpkg.Base(path))
+			pkg.SetPath(path)
+			c.badImports[pkg] = true
+		}
+		obj := types.NewPkgName(imp.Pos(), pkg.Name(), pkg)
+		if existing := fileScope.Insert(obj); existing != nil {
+			c.errorf(imp.Pos(), "%s redeclared in this block", pkg.Name())
+		}
+	}
+}
+
+// checkImportConflicts reports package-level declarations that collide
+// with an imported package name of the given file scope.
+func (c *Checker) checkImportConflicts(fileScope *types.Scope) {
+	for _, name := range fileScope.Names() {
+		pn, ok := fileScope.Lookup(name).(*types.PkgName)
+		if !ok {
+			continue
+		}
+		if obj := c.pkg.Scope().Lookup(name); obj != nil {
+			c.errorf(obj.Pos(), "%s already declared through import of package %s", name, pn.Imported().Path())
 		}
 	}
 }
@@ -41,13 +86,17 @@ func (c *Checker) collectVarDecl(decl *syntax.VarDecl) {
 	// Create a Var object with nil type
 	// The type will be resolved in checkVarDecl
 	obj := types.NewVar(decl.Name.Pos(), decl.Name.Value, nil)
-	c.declare(decl.Name, obj)
+	if c.declare(decl.Name, obj) {
+		c.globals = append(c.globals, obj)
+		c.varDecls[obj] = decl
+	}
 }
 
 // collectFuncDecl collects a function declaration.
 func (c *Checker) collectFuncDecl(decl *syntax.FuncDecl) {
 	name := decl.Name.Value
 	obj := types.NewFuncObj(decl.Name.Pos(), name)
+	obj.SetPkg(c.pkg)
 	c.funcDecls[decl] = obj
 
 	// Methods are attached to their receiver type; they are not package-scope
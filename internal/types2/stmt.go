@@ -1,6 +1,8 @@
 package types2
 
 import (
+	"strconv"
+
 	"github.com/you-not-fish/yoru/internal/syntax"
 	"github.com/you-not-fish/yoru/internal/types"
 )
@@ -33,6 +35,9 @@ func (c *Checker) stmt(s syntax.Stmt) {
 	case *syntax.ForStmt:
 		c.forStmt(s)
 
+	case *syntax.TypeSwitchStmt:
+		c.typeSwitchStmt(s)
+
 	case *syntax.ReturnStmt:
 		c.returnStmt(s)
 
@@ -50,7 +55,7 @@ func (c *Checker) stmt(s syntax.Stmt) {
 // exprStmt checks an expression statement.
 func (c *Checker) exprStmt(s *syntax.ExprStmt) {
 	var x operand
-	c.expr(&x, s.X)
+	c.multiExpr(&x, s.X)
 	// Expression statements are typically function calls
 	// The result (if any) is discarded
 }
@@ -112,6 +117,83 @@ func (c *Checker) forStmt(s *syntax.ForStmt) {
 	c.stmts(s.Body.Stmts)
 }
 
+// typeSwitchStmt checks a type switch. Each clause has its own scope. If
+// the switch declares a variable, each clause declares its own: of the
+// clause's type if the clause lists exactly one type other than nil, and
+// of the type of the operand otherwise.
+func (c *Checker) typeSwitchStmt(s *syntax.TypeSwitchStmt) {
+	var x operand
+	c.expr(&x, s.X)
+	if x.mode == invalid {
+		return
+	}
+	it, ok := x.typ.Underlying().(*types.Interface)
+	if !ok {
+		c.errorf(x.pos, "%s is not an interface", x.typ)
+		return
+	}
+	if s.Lhs != nil && s.Lhs.Value == "_" {
+		c.errorf(s.Lhs.Pos(), "no new variable on left side of :=")
+	}
+
+	c.switchDepth++
+	defer func() { c.switchDepth-- }()
+
+	var seen []types.Type
+	seenNil := false
+	for _, clause := range s.Body {
+		var T types.Type
+		for _, e := range clause.Types {
+			if c.isNilName(e) {
+				var n operand
+				c.expr(&n, e)
+				if seenNil {
+					c.errorf(e.Pos(), "multiple nil cases in type switch")
+				}
+				seenNil = true
+				continue
+			}
+			typ := c.resolveType(e)
+			if typ == nil || !c.assertableTo(e.Pos(), x.typ, it, typ, "type switch case") {
+				continue
+			}
+			c.recordType(e, &operand{mode: typexpr, pos: e.Pos(), expr: e, typ: typ})
+			for _, prev := range seen {
+				if types.Identical(prev, typ) {
+					c.errorf(e.Pos(), "duplicate case %s in type switch", typ)
+				}
+			}
+			seen = append(seen, typ)
+			T = typ
+		}
+
+		c.openScope(clause, "case")
+		if s.Lhs != nil && s.Lhs.Value != "_" {
+			typ := x.typ
+			if len(clause.Types) == 1 && T != nil {
+				typ = T
+			}
+			v := types.NewVar(s.Lhs.Pos(), s.Lhs.Value, typ)
+			c.scope.Insert(v)
+			if c.info != nil {
+				c.info.Implicits[clause] = v
+			}
+		}
+		c.stmts(clause.Body)
+		c.closeScope()
+	}
+}
+
+// isNilName reports whether e is the predeclared nil.
+func (c *Checker) isNilName(e syntax.Expr) bool {
+	name, ok := e.(*syntax.Name)
+	if !ok {
+		return false
+	}
+	_, isNil := c.lookup(name.Value).(*types.Nil)
+	return isNil
+}
+
 // returnStmt checks a return statement.
 func (c *Checker) returnStmt(s *syntax.ReturnStmt) {
 	if c.funcSig == nil {
@@ -119,42 +201,109 @@ func (c *Checker) returnStmt(s *syntax.ReturnStmt) {
 		return
 	}
 
-	resultType := c.funcSig.Result()
+	want := resultTypes(c.funcSig.Result())
 
-	if s.Result == nil {
+	if len(s.Results) == 0 {
 		// Bare return
-		if resultType != nil {
+		if len(want) > 0 {
 			c.errorf(s.Pos(), "missing return value")
 		}
 		return
 	}
 
-	// Check return value
-	var x operand
-	c.expr(&x, s.Result)
-	if x.mode == invalid {
+	// Check return values
+	values, ok := c.exprList(s.Results, false)
+	if !ok {
 		return
 	}
 
-	if resultType == nil {
+	if len(want) == 0 {
 		c.errorf(s.Pos(), "unexpected return value in void function")
 		return
 	}
+	if len(values) < len(want) {
+		c.errorf(s.Pos(), "not enough return values: have %d, want %d", len(values), len(want))
+		return
+	}
+	if len(values) > len(want) {
+		c.errorf(s.Pos(), "too many return values: have %d, want %d", len(values), len(want))
+		return
+	}
 
-	// Check escape: *T cannot be returned
-	c.checkReturnEscape(s, &x)
+	for i, x := range values {
+		if x.mode == invalid {
+			continue
+		}
+		if x.mode == novalue {
+			c.errorf(x.pos, "cannot return no-value expression")
+			continue
+		}
 
-	// Check assignment
-	c.assignment(&x, resultType, "return statement")
+		// Check escape: *T cannot be returned
+		c.checkReturnEscape(s, x)
+
+		// Check assignment
+		c.assignment(x, want[i], "return statement")
+	}
+}
+
+// resultTypes returns the list of result types of a function with the
+// given result type: none for void functions, the tuple elements for
+// several results.
+func resultTypes(result types.Type) []types.Type {
+	switch t := result.(type) {
+	case nil:
+		return nil
+	case *types.Tuple:
+		return t.Types()
+	default:
+		return []types.Type{t}
+	}
+}
+
+// exprList evaluates the right-hand side of an assignment or the values
+// of a return statement. A single call of a function with several results
+// yields one operand per result. If commaOk is set, a single type
+// assertion or map index yields the value and a bool reporting success;
+// its recorded type is the tuple of both. Invalid operands are kept in the
+// list so that counts stay accurate; ok is false if a single expression
+// is invalid.
+func (c *Checker) exprList(list []syntax.Expr, commaOk bool) (values []*operand, ok bool) {
+	if len(list) == 1 {
+		x := new(operand)
+		c.multiExpr(x, list[0])
+		if x.mode == invalid {
+			return nil, false
+		}
+		if _, isAssert := list[0].(*syntax.AssertExpr); (isAssert || x.mode == mapindex) && commaOk {
+			x.mode = value
+			x.typ = types.NewTuple(x.typ, types.Typ[types.Bool])
+			c.recordType(list[0], x)
+		}
+		if t, isTuple := x.typ.(*types.Tuple); isTuple && x.mode == value {
+			for _, typ := range t.Types() {
+				values = append(values, &operand{mode: value, pos: x.pos, expr: list[0], typ: typ})
+			}
+			return values, true
+		}
+		return []*operand{x}, true
+	}
+
+	for _, e := range list {
+		x := new(operand)
+		c.expr(x, e)
+		values = append(values, x)
+	}
+	return values, true
 }
 
 // branchStmt checks a break or continue statement.
 func (c *Checker) branchStmt(s *syntax.BranchStmt) {
-	if c.loopDepth > 0 {
+	if c.loopDepth > 0 || s.Tok.IsBreak() && c.switchDepth > 0 {
 		return
 	}
 	if s.Tok.IsBreak() {
-		c.errorf(s.Pos(), "break not in for loop")
+		c.errorf(s.Pos(), "break not in for loop or switch")
 		return
 	}
 	if s.Tok.IsContinue() {
@@ -201,6 +350,7 @@ func (c *Checker) localVarDecl(decl *syntax.VarDecl) {
 			// Type inference
 			if types.IsUntypedType(val.typ) {
 				typ = types.DefaultType(val.typ)
+				c.representable(&val, typ)
 			} else {
 				typ = val.typ
 			}
@@ -222,79 +372,191 @@ func (c *Checker) localVarDecl(decl *syntax.VarDecl) {
 
 // assignStmt checks an assignment statement.
 func (c *Checker) assignStmt(s *syntax.AssignStmt) {
-	if len(s.LHS) != len(s.RHS) {
-		c.errorf(s.Pos(), "assignment mismatch: %d variables but %d values", len(s.LHS), len(s.RHS))
+	if s.Op.IsDefine() {
+		// Short variable declaration :=
+		c.shortVarDecl(s)
 		return
 	}
 
-	for i := range s.LHS {
-		if s.Op.IsDefine() {
-			// Short variable declaration :=
-			c.shortVarDecl(s.LHS[i], s.RHS[i])
-		} else {
-			// Regular assignment =
-			c.regularAssign(s.LHS[i], s.RHS[i])
+	// Regular assignment =: the left-hand side is evaluated first.
+	// A nil entry stands for the blank identifier.
+	lhs := make([]*operand, len(s.LHS))
+	for i, e := range s.LHS {
+		if isBlank(e) {
+			continue
 		}
+		lhs[i] = new(operand)
+		c.expr(lhs[i], e)
 	}
-}
 
-// shortVarDecl handles short variable declaration (x := expr).
-func (c *Checker) shortVarDecl(lhs syntax.Expr, rhs syntax.Expr) {
-	name, ok := lhs.(*syntax.Name)
-	if !ok {
-		c.errorf(lhs.Pos(), "non-name on left side of :=")
+	rhs, ok := c.exprList(s.RHS, len(s.LHS) == 2)
+	if !ok || !c.assignCount(s, rhs) {
 		return
 	}
 
-	var val operand
-	c.expr(&val, rhs)
-	if val.mode == invalid {
-		return
+	for i, e := range s.LHS {
+		c.regularAssign(e, lhs[i], rhs[i])
 	}
-	if val.mode == novalue {
-		c.errorf(rhs.Pos(), "cannot use no-value expression in := declaration")
-		return
+}
+
+// assignCount reports whether the number of values on the right-hand side
+// of s matches the number of variables, and reports an error if not.
+func (c *Checker) assignCount(s *syntax.AssignStmt, rhs []*operand) bool {
+	if len(s.LHS) == len(rhs) {
+		return true
+	}
+	if len(s.RHS) == 1 {
+		if _, isCall := s.RHS[0].(*syntax.CallExpr); isCall {
+			n := len(rhs)
+			if rhs[0].mode == novalue {
+				n = 0
+			}
+			c.errorf(s.Pos(), "assignment mismatch: %s but %s returns %s", count(len(s.LHS), "variable"), callString(s.RHS[0]), count(n, "value"))
+			return false
+		}
 	}
+	c.errorf(s.Pos(), "assignment mismatch: %s but %s", count(len(s.LHS), "variable"), count(len(rhs), "value"))
+	return false
+}
 
-	// Determine type
-	typ := val.typ
-	if types.IsUntypedType(typ) {
-		typ = types.DefaultType(typ)
+// count returns n followed by noun, pluralized unless n is 1.
+func count(n int, noun string) string {
+	s := strconv.Itoa(n) + " " + noun
+	if n != 1 {
+		s += "s"
 	}
+	return s
+}
 
-	// Create and declare variable
-	v := types.NewVar(name.Pos(), name.Value, typ)
-	c.declare(name, v)
+// isBlank reports whether e is the blank identifier _.
+func isBlank(e syntax.Expr) bool {
+	name, ok := e.(*syntax.Name)
+	return ok && name.Value == "_"
 }
 
-// regularAssign handles regular assignment (lhs = rhs).
-func (c *Checker) regularAssign(lhs syntax.Expr, rhs syntax.Expr) {
-	var left, right operand
+// shortVarDecl handles short variable declaration (a, b := x, y).
+// As in Go, names already declared in the current scope are assigned
+// instead of redeclared, and at least one non-blank name must be new.
+func (c *Checker) shortVarDecl(s *syntax.AssignStmt) {
+	names := make([]*syntax.Name, len(s.LHS))
+	hasNew := false
+	seen := make(map[string]bool)
+	for i, e := range s.LHS {
+		name, ok := e.(*syntax.Name)
+		if !ok {
+			c.errorf(e.Pos(), "non-name on left side of :=")
+			return
+		}
+		names[i] = name
+		if name.Value == "_" {
+			continue
+		}
+		if seen[name.Value] {
+			c.errorf(name.Pos(), "%s repeated on left side of :=", name.Value)
+			return
+		}
+		seen[name.Value] = true
+		if c.scope.Lookup(name.Value) == nil {
+			hasNew = true
+		}
+	}
+
+	rhs, ok := c.exprList(s.RHS, len(s.LHS) == 2)
+	if !ok || !c.assignCount(s, rhs) {
+		// Declare the new names anyway, without a type like other invalid
+		// declarations, so that their uses report no follow-on errors.
+		for _, name := range names {
+			if c.scope.Lookup(name.Value) == nil {
+				c.declare(name, types.NewVar(name.Pos(), name.Value, nil))
+			}
+		}
+		return
+	}
+
+	if !hasNew {
+		c.errorf(s.Pos(), "no new variables on left side of :=")
+	}
+
+	// Declare the new variables only after all values have been checked,
+	// so that the right-hand side cannot refer to them.
+	type newVar struct {
+		name *syntax.Name
+		v    *types.Var
+	}
+	var newVars []newVar
+	for i, name := range names {
+		val := rhs[i]
+		if val.mode == novalue {
+			c.errorf(val.pos, "cannot use no-value expression in := declaration")
+			val.mode = invalid
+		}
+
+		if obj := c.scope.Lookup(name.Value); obj != nil && name.Value != "_" {
+			// Redeclaration: assign to the existing variable
+			c.recordUse(name, obj)
+			v, isVar := obj.(*types.Var)
+			if !isVar {
+				c.errorf(name.Pos(), "cannot assign to %s", name.Value)
+				continue
+			}
+			if val.mode != invalid {
+				c.assignment(val, v.Type(), "assignment")
+			}
+			continue
+		}
 
-	c.expr(&left, lhs)
-	c.expr(&right, rhs)
+		// Determine type; a variable initialized with an invalid value
+		// is declared without one.
+		var typ types.Type
+		if val.mode != invalid {
+			typ = val.typ
+			if types.IsUntypedType(typ) {
+				typ = types.DefaultType(typ)
+				c.representable(val, typ)
+			}
+		}
+		newVars = append(newVars, newVar{name, types.NewVar(name.Pos(), name.Value, typ)})
+	}
 
-	if left.mode == invalid || right.mode == invalid {
+	// Create and declare variables
+	for _, nv := range newVars {
+		c.declare(nv.name, nv.v)
+	}
+}
+
+// regularAssign handles a single regular assignment (lhs = rhs), where
+// left is the already evaluated left-hand side, or nil for the blank
+// identifier.
+func (c *Checker) regularAssign(lhs syntax.Expr, left, right *operand) {
+	if right.mode == invalid || left != nil && left.mode == invalid {
 		return
 	}
 	if right.mode == novalue {
-		c.errorf(rhs.Pos(), "cannot assign no-value expression")
+		c.errorf(right.pos, "cannot assign no-value expression")
+		return
+	}
+
+	if left == nil {
+		// Assignment to _ only evaluates the value.
+		if types.IsNil(right.typ) {
+			c.errorf(right.pos, "use of untyped nil in assignment")
+		}
 		return
 	}
 
 	// Check that lhs is assignable
-	if left.mode != variable {
+	if left.mode != variable && left.mode != mapindex {
 		c.errorf(lhs.Pos(), "cannot assign to %s", lhs)
 		return
 	}
 
 	// Check escape: *T cannot be assigned to certain locations
 	if types.IsPointer(right.typ) {
-		c.checkPointerEscape(lhs, &right)
+		c.checkPointerEscape(lhs, right)
 	}
 
 	// Check assignment compatibility
-	c.assignment(&right, left.typ, "assignment")
+	c.assignment(right, left.typ, "assignment")
 }
 
 // blockMustReturn reports whether all control-flow paths in this statement list return.
@@ -328,6 +590,39 @@ func (c *Checker) stmtMustReturn(s syntax.Stmt) bool {
 		default:
 			return false
 		}
+	case *syntax.TypeSwitchStmt:
+		hasDefault := false
+		for _, clause := range s.Body {
+			if clause.Types == nil {
+				hasDefault = true
+			}
+			if !c.blockMustReturn(clause.Body) || hasBreak(clause.Body) {
+				return false
+			}
+		}
+		return hasDefault
+	}
+	return false
+}
+
+// hasBreak reports whether stmts contain a break that exits the enclosing
+// switch, that is, one not nested in a loop or another switch.
+func hasBreak(stmts []syntax.Stmt) bool {
+	for _, s := range stmts {
+		switch s := s.(type) {
+		case *syntax.BranchStmt:
+			if s.Tok.IsBreak() {
+				return true
+			}
+		case *syntax.BlockStmt:
+			if hasBreak(s.Stmts) {
+				return true
+			}
+		case *syntax.IfStmt:
+			if hasBreak(s.Then.Stmts) || s.Else != nil && hasBreak([]syntax.Stmt{s.Else}) {
+				return true
+			}
+		}
 	}
 	return false
 }
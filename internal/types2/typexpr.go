@@ -13,14 +13,22 @@ func (c *Checker) typExpr(x *operand, e syntax.Expr) {
 	switch e := e.(type) {
 	case *syntax.Name:
 		c.typeName(x, e)
+	case *syntax.SelectorExpr:
+		c.qualifiedTypeName(x, e)
 	case *syntax.ArrayType:
 		c.arrayType(x, e)
+	case *syntax.SliceType:
+		c.sliceType(x, e)
+	case *syntax.MapType:
+		c.mapType(x, e)
 	case *syntax.PointerType:
 		c.pointerType(x, e)
 	case *syntax.RefType:
 		c.refType(x, e)
 	case *syntax.StructType:
 		c.structType(x, e)
+	case *syntax.InterfaceType:
+		c.interfaceType(x, e)
 	default:
 		c.errorf(e.Pos(), "%T is not a type", e)
 		x.mode = invalid
@@ -54,6 +62,22 @@ func (c *Checker) typeName(x *operand, name *syntax.Name) {
 	}
 }
 
+// qualifiedTypeName resolves a type name qualified by a package: pkg.T.
+func (c *Checker) qualifiedTypeName(x *operand, e *syntax.SelectorExpr) {
+	obj := c.qualifiedObject(e)
+	if obj == nil {
+		x.mode = invalid
+		return
+	}
+	tn, ok := obj.(*types.TypeName)
+	if !ok || tn.Type() == nil {
+		c.errorf(e.Pos(), "%s.%s is not a type", e.X.(*syntax.Name).Value, e.Sel.Value)
+		x.mode = invalid
+		return
+	}
+	x.typ = tn.Type()
+}
+
 // arrayType resolves an array type [N]Elem.
 func (c *Checker) arrayType(x *operand, e *syntax.ArrayType) {
 	// Evaluate length expression (must be a constant integer)
@@ -91,6 +115,44 @@ func (c *Checker) arrayType(x *operand, e *syntax.ArrayType) {
 	x.typ = types.NewArray(length, elem)
 }
 
+// mapType resolves a map type map[Key]Value. Keys and values live in the
+// runtime hash table on the heap, so neither may be a *T.
+func (c *Checker) mapType(x *operand, e *syntax.MapType) {
+	key := c.resolveType(e.Key)
+	elem := c.resolveType(e.Value)
+	if key == nil || elem == nil {
+		x.mode = invalid
+		return
+	}
+	// The underlying type of a named type declared later is not known
+	// yet; such keys are not checked.
+	if key.Underlying() != nil && !types.IsMapKey(key) {
+		if types.IsPointer(key) {
+			c.errorf(e.Key.Pos(), "invalid map key type %s (*T cannot escape to the heap; use ref T)", key)
+		} else {
+			c.errorf(e.Key.Pos(), "invalid map key type %s (must be int, bool, string or ref T)", key)
+		}
+		x.mode = invalid
+		return
+	}
+	if types.IsPointer(elem) {
+		c.errorf(e.Value.Pos(), "invalid map value type %s (*T cannot escape to the heap; use ref T)", elem)
+		x.mode = invalid
+		return
+	}
+	x.typ = types.NewMap(key, elem)
+}
+
+// sliceType resolves a slice type []Elem.
+func (c *Checker) sliceType(x *operand, e *syntax.SliceType) {
+	elem := c.resolveType(e.Elem)
+	if elem == nil || !c.checkSliceElem(e.Pos(), elem) {
+		x.mode = invalid
+		return
+	}
+	x.typ = types.NewSlice(elem)
+}
+
 // pointerType resolves a pointer type *T.
 func (c *Checker) pointerType(x *operand, e *syntax.PointerType) {
 	base := c.resolveType(e.Base)
@@ -135,6 +197,7 @@ func (c *Checker) structType(x *operand, e *syntax.StructType) {
 		}
 
 		fields[i] = types.NewField(field.Pos(), name, fieldType)
+		fields[i].SetPkg(c.pkg)
 	}
 
 	st := types.NewStruct(fields)
@@ -142,3 +205,50 @@ func (c *Checker) structType(x *operand, e *syntax.StructType) {
 	c.conf.Sizes.ComputeLayout(st)
 	x.typ = st
 }
+
+// interfaceType resolves an interface type.
+func (c *Checker) interfaceType(x *operand, e *syntax.InterfaceType) {
+	methods := make([]*types.FuncObj, len(e.Methods))
+	seen := make(map[string]bool)
+
+	for i, m := range e.Methods {
+		name := m.Name.Value
+		if name == "_" {
+			c.errorf(m.Name.Pos(), "methods must have a unique non-blank name")
+		} else if seen[name] {
+			c.errorf(m.Name.Pos(), "duplicate method %s", name)
+		}
+		seen[name] = true
+
+		sig := c.methodSignature(m)
+		if sig == nil {
+			x.mode = invalid
+			return
+		}
+		methods[i] = types.NewFuncObj(m.Pos(), name)
+		methods[i].SetPkg(c.pkg)
+		methods[i].SetSignature(sig)
+	}
+
+	x.typ = types.NewInterface(methods)
+}
+
+// methodSignature resolves the signature of an interface method. The
+// signature has no receiver.
+func (c *Checker) methodSignature(m *syntax.MethodSpec) *types.Func {
+	params := make([]*types.Var, len(m.Params))
+	for i, p := range m.Params {
+		ptype := c.resolveType(p.Type)
+		if ptype == nil {
+			return nil
+		}
+		params[i] = types.NewVar(p.Pos(), p.Name.Value, ptype)
+	}
+
+	result, ok := c.resultType(m.Results)
+	if !ok {
+		return nil
+	}
+
+	return types.NewFunc(nil, params, result)
+}
@@ -38,9 +38,32 @@ static int gc_enabled = 0;
 /* GC stress mode (YORU_GC_STRESS=1) */
 static int gc_stress = 0;
 
+/* Print statistics at shutdown (YORU_GC_STATS=1) */
+static int gc_stats = 0;
+
 /* LLVM GC root chain (defined by LLVM, we just declare it) */
 struct StackEntry* llvm_gc_root_chain = NULL;
 
+/* Global root slots registered by rt_register_root */
+static void*** global_roots = NULL;
+static size_t num_global_roots = 0;
+static size_t cap_global_roots = 0;
+
+/*
+ * Heap index: all objects sorted by address, rebuilt at the start of each
+ * collection so that interior pointers (slice data) can be resolved.
+ */
+static Object** heap_index = NULL;
+static size_t heap_index_len = 0;
+static size_t heap_index_cap = 0;
+
+/*
+ * The map a map function is updating. It is marked as a root while the
+ * function allocates its tables, since the caller may not keep it in a
+ * root slot.
+ */
+static YoruMap* pinned_map = NULL;
+
 /*
  * =============================================================================
  * Built-in Type Descriptors
@@ -75,6 +98,22 @@ const TypeDesc rt_type_string = {
     .offsets = NULL,
 };
 
+/* YoruMap objects: the refs are the array objects of both tables */
+static const uint32_t map_offsets[] = {
+    offsetof(YoruMap, table.hashes),
+    offsetof(YoruMap, table.keys),
+    offsetof(YoruMap, table.elems),
+    offsetof(YoruMap, old.hashes),
+    offsetof(YoruMap, old.keys),
+    offsetof(YoruMap, old.elems),
+};
+
+static const TypeDesc rt_type_map = {
+    .size = sizeof(YoruMap),
+    .num_ptrs = sizeof(map_offsets) / sizeof(map_offsets[0]),
+    .offsets = map_offsets,
+};
+
 /*
  * =============================================================================
  * Runtime Initialization
@@ -102,6 +141,12 @@ void rt_init(void) {
         gc_threshold = 0;
     }
 
+    /* Print statistics at shutdown */
+    const char* print_stats = getenv("YORU_GC_STATS");
+    if (print_stats && (strcmp(print_stats, "1") == 0 || strcmp(print_stats, "true") == 0)) {
+        gc_stats = 1;
+    }
+
     /* Reset statistics */
     memset(&stats, 0, sizeof(stats));
 
@@ -120,9 +165,21 @@ void rt_shutdown(void) {
     }
     alloc_list = NULL;
 
+    free(global_roots);
+    global_roots = NULL;
+    num_global_roots = 0;
+    cap_global_roots = 0;
+
+    free(heap_index);
+    heap_index = NULL;
+    heap_index_len = 0;
+    heap_index_cap = 0;
+
     if (gc_verbose) {
         fprintf(stderr, "[GC] Runtime shutdown. Final stats:\n");
         rt_print_stats();
+    } else if (gc_stats) {
+        rt_print_stats();
     }
 }
 
@@ -132,7 +189,25 @@ void rt_shutdown(void) {
  * =============================================================================
  */
 
-void* rt_alloc(uint64_t size, const TypeDesc* type) {
+/* Size of an object's data area */
+static uint64_t obj_data_size(Object* obj) {
+    if (OBJ_IS_ARRAY(obj)) {
+        ArrayObject* arr = (ArrayObject*)obj;
+        return sizeof(uint64_t) + arr->len * obj->header.type->size;
+    }
+    return obj->header.type->size;
+}
+
+/* Start of an object's payload: the fields, or the array elements */
+static char* obj_payload(Object* obj) {
+    if (OBJ_IS_ARRAY(obj)) {
+        return ((ArrayObject*)obj)->data;
+    }
+    return obj->data;
+}
+
+/* Allocate and link an object with a zeroed data area of size bytes */
+static Object* alloc_object(uint64_t size, const TypeDesc* type) {
     /* Check if we should trigger GC */
     uint64_t alloc_size = sizeof(ObjHeader) + size;
     bytes_since_gc += alloc_size;
@@ -151,10 +226,6 @@ void* rt_alloc(uint64_t size, const TypeDesc* type) {
     obj->header.type = type;
     obj->header.next_mark = (uintptr_t)alloc_list;  /* link to list, mark=0 */
 
-    if (type && type->size != size) {
-        rt_panic("rt_alloc size mismatch");
-    }
-
     /* Zero-initialize the data area */
     memset(obj->data, 0, size);
 
@@ -171,7 +242,317 @@ void* rt_alloc(uint64_t size, const TypeDesc* type) {
                 (unsigned long long)size, (void*)obj->data);
     }
 
-    return obj->data;
+    return obj;
+}
+
+void* rt_alloc(uint64_t size, const TypeDesc* type) {
+    if (type && type->size != size) {
+        rt_panic("rt_alloc size mismatch");
+    }
+    return alloc_object(size, type)->data;
+}
+
+/* Allocate a zeroed array object of n elements of type elem */
+static void* alloc_array(const TypeDesc* elem, int64_t n) {
+    Object* obj = alloc_object(sizeof(uint64_t) + (uint64_t)n * elem->size, elem);
+    ArrayObject* arr = (ArrayObject*)obj;
+    arr->len = (uint64_t)n;
+    OBJ_SET_ARRAY(obj);
+    return arr->data;
+}
+
+/*
+ * =============================================================================
+ * Slices
+ * =============================================================================
+ */
+
+/* Largest element count whose allocation size cannot overflow */
+static int64_t max_elems(const TypeDesc* elem) {
+    if (elem->size == 0) {
+        return INT64_MAX;
+    }
+    return (int64_t)((UINT64_MAX / 2) / elem->size);
+}
+
+void* rt_makeslice(const TypeDesc* elem, int64_t len, int64_t cap) {
+    if (len < 0 || len > max_elems(elem)) {
+        rt_panic("makeslice: len out of range");
+    }
+    if (cap < len || cap > max_elems(elem)) {
+        rt_panic("makeslice: cap out of range");
+    }
+    return alloc_array(elem, cap);
+}
+
+void rt_growslice(YoruSlice* s, const TypeDesc* elem, int64_t newlen) {
+    int64_t newcap = s->cap * 2;
+    if (newcap < newlen) {
+        newcap = newlen;
+    }
+    if (newcap < 4) {
+        newcap = 4;
+    }
+    if (newcap > max_elems(elem)) {
+        rt_panic("growslice: len out of range");
+    }
+
+    /*
+     * The caller keeps s in a GC root slot, so the old array stays live
+     * while the new one is allocated.
+     */
+    void* data = alloc_array(elem, newcap);
+    if (s->len > 0) {
+        memcpy(data, s->ptr, (size_t)s->len * elem->size);
+    }
+    s->ptr = data;
+    s->cap = newcap;
+}
+
+int64_t rt_slicecopy(void* dst, int64_t dstlen, void* src, int64_t srclen,
+                     int64_t elemsize) {
+    int64_t n = dstlen < srclen ? dstlen : srclen;
+    if (n > 0 && elemsize > 0) {
+        memmove(dst, src, (size_t)(n * elemsize));
+    }
+    return n;
+}
+
+/*
+ * =============================================================================
+ * Maps
+ * =============================================================================
+ */
+
+#define MAP_EMPTY     0  /* slot never used */
+#define MAP_DELETED   1  /* slot of a deleted or evacuated entry */
+#define MAP_MIN_CAP   8  /* smallest table */
+#define MAP_EVAC_STEP 8  /* old slots evacuated per assignment or deletion */
+
+/*
+ * Hash a key with FNV-1a: scalar keys by their bytes, string keys by their
+ * contents. The values MAP_EMPTY and MAP_DELETED are never returned.
+ */
+static uint64_t map_hash(const YoruMap* m, const void* key) {
+    const unsigned char* p = key;
+    size_t n = m->key->size;
+    if (m->kind == MAP_KEY_STRING) {
+        const YoruString* s = key;
+        p = (const unsigned char*)s->ptr;
+        n = (size_t)s->len;
+    }
+    uint64_t h = 14695981039346656037ULL;
+    for (size_t i = 0; i < n; i++) {
+        h ^= p[i];
+        h *= 1099511628211ULL;
+    }
+    return h > MAP_DELETED ? h : h + 2;
+}
+
+static int map_key_equal(const YoruMap* m, const void* a, const void* b) {
+    if (m->kind == MAP_KEY_STRING) {
+        const YoruString* x = a;
+        const YoruString* y = b;
+        return x->len == y->len && (x->len == 0 || memcmp(x->ptr, y->ptr, (size_t)x->len) == 0);
+    }
+    return memcmp(a, b, m->key->size) == 0;
+}
+
+static char* map_key_at(const YoruMap* m, const MapTable* t, int64_t i) {
+    return t->keys + (size_t)i * m->key->size;
+}
+
+static char* map_elem_at(const YoruMap* m, const MapTable* t, int64_t i) {
+    return t->elems + (size_t)i * m->elem->size;
+}
+
+/* Slot of key with hash h in t, or -1 */
+static int64_t table_find(const YoruMap* m, const MapTable* t, const void* key, uint64_t h) {
+    if (t->cap == 0) {
+        return -1;
+    }
+    uint64_t mask = (uint64_t)t->cap - 1;
+    for (uint64_t i = h & mask;; i = (i + 1) & mask) {
+        uint64_t s = t->hashes[i];
+        if (s == MAP_EMPTY) {
+            return -1;
+        }
+        if (s == h && map_key_equal(m, map_key_at(m, t, (int64_t)i), key)) {
+            return (int64_t)i;
+        }
+    }
+}
+
+/*
+ * Add key with hash h, which is not in t, to a free slot of t and return
+ * the slot. t must have an empty slot left.
+ */
+static int64_t table_insert(const YoruMap* m, MapTable* t, const void* key, uint64_t h) {
+    uint64_t mask = (uint64_t)t->cap - 1;
+    uint64_t i = h & mask;
+    while (t->hashes[i] > MAP_DELETED) {
+        i = (i + 1) & mask;
+    }
+    if (t->hashes[i] == MAP_EMPTY) {
+        t->used++;
+    }
+    t->hashes[i] = h;
+    memcpy(map_key_at(m, t, (int64_t)i), key, m->key->size);
+    return (int64_t)i;
+}
+
+/*
+ * Remove the entry in slot i of t. The key and element are cleared so
+ * that the GC does not retain what they referred to.
+ */
+static void table_remove(const YoruMap* m, MapTable* t, int64_t i) {
+    t->hashes[i] = MAP_DELETED;
+    memset(map_key_at(m, t, i), 0, m->key->size);
+    memset(map_elem_at(m, t, i), 0, m->elem->size);
+}
+
+/* Move the entries of up to n slots of the old table to the current one */
+static void map_evacuate(YoruMap* m, int64_t n) {
+    MapTable* old = &m->old;
+    for (; n > 0 && m->evacuated < old->cap; n--) {
+        int64_t i = m->evacuated++;
+        uint64_t h = old->hashes[i];
+        if (h > MAP_DELETED) {
+            int64_t j = table_insert(m, &m->table, map_key_at(m, old, i), h);
+            memcpy(map_elem_at(m, &m->table, j), map_elem_at(m, old, i), m->elem->size);
+            table_remove(m, old, i);
+        }
+    }
+    if (old->cap > 0 && m->evacuated == old->cap) {
+        memset(old, 0, sizeof(*old));
+        m->evacuated = 0;
+    }
+}
+
+/* Smallest table capacity holding n entries at most half full */
+static int64_t map_table_cap(int64_t n) {
+    int64_t cap = MAP_MIN_CAP;
+    while (cap <= n * 2) {
+        cap *= 2;
+    }
+    return cap;
+}
+
+/*
+ * Replace the table of the pinned map m by an empty table of cap slots.
+ * The table is built in place: each array is reachable from m as soon as
+ * it is allocated.
+ */
+static void map_new_table(YoruMap* m, int64_t cap) {
+    memset(&m->table, 0, sizeof(m->table));
+    m->table.hashes = alloc_array(&rt_type_int, cap);
+    m->table.keys = alloc_array(m->key, cap);
+    m->table.elems = alloc_array(m->elem, cap);
+    m->table.cap = cap;
+}
+
+/*
+ * Start moving the entries of m to a new table, large enough for twice
+ * the entries and never smaller than the current one. The new table
+ * receives at least cap/4 insertions before it fills up again, by which
+ * time MAP_EVAC_STEP slots per insertion have emptied the old table; an
+ * unfinished evacuation is completed first anyway.
+ */
+static void map_grow(YoruMap* m) {
+    map_evacuate(m, m->old.cap);
+    int64_t cap = map_table_cap(m->count);
+    if (cap < m->table.cap) {
+        cap = m->table.cap;
+    }
+    m->old = m->table;
+    m->evacuated = 0;
+    pinned_map = m;
+    map_new_table(m, cap);
+    pinned_map = NULL;
+}
+
+void* rt_makemap(const TypeDesc* key, const TypeDesc* elem, int64_t kind,
+                 int64_t hint) {
+    if (hint < 0 || hint > max_elems(key) / 4 || hint > max_elems(elem) / 4) {
+        rt_panic("makemap: size out of range");
+    }
+    YoruMap* m = (YoruMap*)alloc_object(sizeof(YoruMap), &rt_type_map)->data;
+    m->key = key;
+    m->elem = elem;
+    m->kind = kind;
+    if (hint > 0) {
+        pinned_map = m;
+        map_new_table(m, map_table_cap(hint));
+        pinned_map = NULL;
+    }
+    return m;
+}
+
+int8_t rt_mapaccess(void* map, const void* key, void* out) {
+    YoruMap* m = map;
+    if (!m || m->count == 0) {
+        return 0;
+    }
+    uint64_t h = map_hash(m, key);
+    MapTable* t = &m->table;
+    int64_t i = table_find(m, t, key, h);
+    if (i < 0) {
+        t = &m->old;
+        i = table_find(m, t, key, h);
+    }
+    if (i < 0) {
+        return 0;
+    }
+    memcpy(out, map_elem_at(m, t, i), m->elem->size);
+    return 1;
+}
+
+void rt_mapassign(void* map, const void* key, const void* val) {
+    YoruMap* m = map;
+    if (!m) {
+        rt_panic("assignment to entry in nil map");
+    }
+    map_evacuate(m, MAP_EVAC_STEP);
+    uint64_t h = map_hash(m, key);
+    int64_t i = table_find(m, &m->table, key, h);
+    if (i < 0) {
+        /* A new entry, or one that moves from the old table */
+        int64_t j = table_find(m, &m->old, key, h);
+        if (j >= 0) {
+            table_remove(m, &m->old, j);
+        } else {
+            m->count++;
+        }
+        if ((m->table.used + 1) * 4 > m->table.cap * 3) {
+            map_grow(m);
+        }
+        i = table_insert(m, &m->table, key, h);
+    }
+    memcpy(map_elem_at(m, &m->table, i), val, m->elem->size);
+}
+
+void rt_mapdelete(void* map, const void* key) {
+    YoruMap* m = map;
+    if (!m || m->count == 0) {
+        return;
+    }
+    map_evacuate(m, MAP_EVAC_STEP);
+    uint64_t h = map_hash(m, key);
+    MapTable* t = &m->table;
+    int64_t i = table_find(m, t, key, h);
+    if (i < 0) {
+        t = &m->old;
+        i = table_find(m, t, key, h);
+    }
+    if (i >= 0) {
+        table_remove(m, t, i);
+        m->count--;
+    }
+}
+
+int64_t rt_maplen(void* map) {
+    YoruMap* m = map;
+    return m ? m->count : 0;
 }
 
 /*
@@ -183,11 +564,64 @@ void* rt_alloc(uint64_t size, const TypeDesc* type) {
 /* Forward declaration */
 static void mark_object(void* ptr);
 
-/* Mark an object and recursively mark its pointer fields */
+static int compare_objects(const void* a, const void* b) {
+    uintptr_t x = (uintptr_t)*(Object* const*)a;
+    uintptr_t y = (uintptr_t)*(Object* const*)b;
+    return x < y ? -1 : x > y;
+}
+
+/* Rebuild the heap index from the allocation list */
+static void build_heap_index(void) {
+    size_t n = (size_t)stats.live_objects;
+    if (n > heap_index_cap) {
+        Object** index = realloc(heap_index, n * sizeof(Object*));
+        if (!index) {
+            rt_panic("out of memory");
+        }
+        heap_index = index;
+        heap_index_cap = n;
+    }
+    heap_index_len = 0;
+    for (Object* obj = alloc_list; obj; obj = OBJ_NEXT(obj)) {
+        heap_index[heap_index_len++] = obj;
+    }
+    qsort(heap_index, heap_index_len, sizeof(Object*), compare_objects);
+}
+
+/*
+ * Find the heap object whose payload contains ptr, or NULL if ptr does not
+ * point into the heap (e.g. a global array). A pointer to the start of an
+ * empty payload belongs to that object.
+ */
+static Object* find_object(void* ptr) {
+    size_t lo = 0, hi = heap_index_len;
+    while (lo < hi) {
+        size_t mid = lo + (hi - lo) / 2;
+        if ((uintptr_t)heap_index[mid] <= (uintptr_t)ptr) {
+            lo = mid + 1;
+        } else {
+            hi = mid;
+        }
+    }
+    if (lo == 0) {
+        return NULL;
+    }
+    Object* obj = heap_index[lo - 1];
+    char* start = obj_payload(obj);
+    char* end = obj->data + obj_data_size(obj);
+    char* p = (char*)ptr;
+    if (p == start || (p >= start && p < end)) {
+        return obj;
+    }
+    return NULL;
+}
+
+/* Mark the object ptr points into and recursively mark its pointer fields */
 static void mark_object(void* ptr) {
     if (!ptr) return;
 
-    Object* obj = DATA_TO_OBJ(ptr);
+    Object* obj = find_object(ptr);
+    if (!obj) return;
 
     /* Already marked? */
     if (OBJ_MARKED(obj)) return;
@@ -197,30 +631,72 @@ static void mark_object(void* ptr) {
 
     /* Get type descriptor */
     const TypeDesc* type = obj->header.type;
-    if (!type) return;
-
-    /* Recursively mark pointer fields */
-    for (size_t i = 0; i < type->num_ptrs; i++) {
-        uint32_t offset = type->offsets[i];
-        void** field_ptr = (void**)(obj->data + offset);
-        void* field_val = *field_ptr;
-        if (field_val) {
-            mark_object(field_val);
+    if (!type || type->num_ptrs == 0) return;
+
+    /* Recursively mark pointer fields of every element */
+    uint64_t n = 1;
+    char* data = obj->data;
+    if (OBJ_IS_ARRAY(obj)) {
+        n = ((ArrayObject*)obj)->len;
+        data = ((ArrayObject*)obj)->data;
+    }
+    for (uint64_t e = 0; e < n; e++) {
+        char* elem = data + e * type->size;
+        for (size_t i = 0; i < type->num_ptrs; i++) {
+            void* field_val = *(void**)(elem + type->offsets[i]);
+            if (field_val) {
+                mark_object(field_val);
+            }
         }
     }
 }
 
-/* Mark all roots from LLVM's shadow stack */
+void rt_register_root(void** slot) {
+    if (num_global_roots == cap_global_roots) {
+        size_t cap = cap_global_roots ? cap_global_roots * 2 : 16;
+        void*** roots = realloc(global_roots, cap * sizeof(void**));
+        if (!roots) {
+            rt_panic("out of memory");
+        }
+        global_roots = roots;
+        cap_global_roots = cap;
+    }
+    global_roots[num_global_roots++] = slot;
+}
+
+/* Mark all roots: the pinned map, registered global slots and LLVM's shadow stack */
 static void mark_roots(void) {
+    mark_object(pinned_map);
+
+    for (size_t i = 0; i < num_global_roots; i++) {
+        void** slot = global_roots[i];
+        if (slot && *slot) {
+            mark_object(*slot);
+        }
+    }
+
+    /*
+     * Shadow-stack roots are stored inline in each frame, in the order of
+     * the frame map: the num_meta roots with metadata first, then plain
+     * ref slots. A root with metadata is a stack object described by the
+     * TypeDesc in meta[i]; its refs are at the TypeDesc offsets.
+     */
     struct StackEntry* entry = llvm_gc_root_chain;
 
     while (entry) {
         const struct FrameMap* map = entry->map;
         if (map) {
+            char* slot = (char*)entry->roots;
             for (int32_t i = 0; i < map->num_roots; i++) {
-                void** slot = (void**)entry->roots[i];
-                if (slot && *slot) {
-                    mark_object(*slot);
+                const TypeDesc* type = i < map->num_meta ? map->meta[i] : NULL;
+                if (type) {
+                    for (size_t j = 0; j < type->num_ptrs; j++) {
+                        mark_object(*(void**)(slot + type->offsets[j]));
+                    }
+                    slot += type->size;
+                } else {
+                    mark_object(*(void**)slot);
+                    slot += sizeof(void*);
                 }
             }
         }
@@ -254,15 +730,14 @@ static void sweep(void) {
                 OBJ_SET_NEXT(prev_obj, next);
             }
 
-            uint64_t obj_size = sizeof(ObjHeader) + obj->header.type->size;
-            stats.heap_size -= obj_size;
+            uint64_t data_size = obj_data_size(obj);
+            stats.heap_size -= sizeof(ObjHeader) + data_size;
             stats.live_objects--;
             freed++;
 
             if (gc_verbose) {
                 fprintf(stderr, "[GC] Freed object at %p (size=%llu)\n",
-                        (void*)obj->data,
-                        (unsigned long long)obj->header.type->size);
+                        (void*)obj->data, (unsigned long long)data_size);
             }
 
             free(obj);
@@ -289,6 +764,7 @@ void rt_collect(void) {
     }
 
     /* Mark phase */
+    build_heap_index();
     mark_roots();
 
     /* Sweep phase */
@@ -302,9 +778,12 @@ void rt_collect(void) {
 
     /* Adjust threshold based on live data */
     /* New threshold = 2 * current heap size, minimum 1MB */
-    gc_threshold = stats.heap_size * 2;
-    if (gc_threshold < 1024 * 1024) {
-        gc_threshold = 1024 * 1024;
+    /* Stress mode keeps collecting on every allocation */
+    if (!gc_stress) {
+        gc_threshold = stats.heap_size * 2;
+        if (gc_threshold < 1024 * 1024) {
+            gc_threshold = 1024 * 1024;
+        }
     }
 
     if (gc_verbose) {
@@ -349,6 +828,18 @@ void rt_panic_string(YoruString msg) {
     exit(1);
 }
 
+void rt_panic_assert(const YoruItab* tab, YoruString iface, YoruString want) {
+    YoruString have = {"nil", 3};
+    if (tab != NULL) {
+        have = tab->name;
+    }
+    char buf[512];
+    snprintf(buf, sizeof(buf), "interface conversion: %.*s is %.*s, not %.*s",
+             (int)iface.len, iface.ptr, (int)have.len, have.ptr,
+             (int)want.len, want.ptr);
+    rt_panic(buf);
+}
+
 /*
  * =============================================================================
  * I/O Functions
@@ -381,16 +872,48 @@ void rt_println(void) {
  * =============================================================================
  */
 
-void rt_bounds_check(int64_t index, int64_t len) {
+void rt_bounds_check(int64_t index, int64_t len, YoruString pos) {
     if (index < 0 || index >= len) {
-        char buf[128];
-        snprintf(buf, sizeof(buf),
-                 "index out of range [%lld] with length %lld",
-                 (long long)index, (long long)len);
+        char buf[512];
+        if (pos.len > 0) {
+            snprintf(buf, sizeof(buf),
+                     "%.*s: index out of range [%lld] with length %lld",
+                     (int)pos.len, pos.ptr, (long long)index, (long long)len);
+        } else {
+            snprintf(buf, sizeof(buf),
+                     "index out of range [%lld] with length %lld",
+                     (long long)index, (long long)len);
+        }
         rt_panic(buf);
     }
 }
 
+void rt_shift_check(int64_t count) {
+    if (count < 0) {
+        rt_panic("negative shift amount");
+    }
+}
+
+void rt_slice_check(int64_t lo, int64_t hi, int64_t cap, YoruString pos) {
+    char msg[256];
+    if (hi < 0 || hi > cap) {
+        snprintf(msg, sizeof(msg), "slice bounds out of range [:%lld] with capacity %lld",
+                 (long long)hi, (long long)cap);
+    } else if (lo < 0 || lo > hi) {
+        snprintf(msg, sizeof(msg), "slice bounds out of range [%lld:%lld]",
+                 (long long)lo, (long long)hi);
+    } else {
+        return;
+    }
+    char buf[512];
+    if (pos.len > 0) {
+        snprintf(buf, sizeof(buf), "%.*s: %s", (int)pos.len, pos.ptr, msg);
+    } else {
+        snprintf(buf, sizeof(buf), "%s", msg);
+    }
+    rt_panic(buf);
+}
+
 /*
  * =============================================================================
  * Runtime Statistics
@@ -426,12 +949,24 @@ void yoru_main(void) {
     fprintf(stderr, "No yoru_main defined\n");
 }
 
+/*
+ * The compiler generates yoru_init when the program has package-level
+ * variables: it registers global GC roots and runs package initializers.
+ */
+extern void yoru_init(void);
+
+/* Weak symbol - programs without globals need no initialization */
+__attribute__((weak))
+void yoru_init(void) {
+}
+
 #ifndef YORU_NO_MAIN
 int main(int argc, char** argv) {
     (void)argc;
     (void)argv;
 
     rt_init();
+    yoru_init();
     yoru_main();
     rt_shutdown();
 
@@ -44,7 +44,8 @@ typedef struct TypeDesc {
  *   +-------------------------------------+
  *
  * next_mark layout:
- *   - bits [63:1] = next pointer (aligned, so bit 0 is always 0)
+ *   - bits [63:2] = next pointer (aligned, so bits 0 and 1 are always 0)
+ *   - bit 1 = array flag (see ArrayObject)
  *   - bit 0 = GC mark bit
  */
 
@@ -59,13 +60,27 @@ typedef struct Object {
     char data[];             /* flexible array member */
 } Object;
 
+/*
+ * Array object: the backing store of a slice. The header's type is the
+ * element TypeDesc and the array flag is set in next_mark; the element
+ * count follows the header.
+ */
+typedef struct ArrayObject {
+    ObjHeader header;
+    uint64_t len;            /* number of elements */
+    char data[];             /* len elements of header.type->size bytes */
+} ArrayObject;
+
 /* Macros for next_mark manipulation */
-#define OBJ_NEXT(obj)       ((Object*)((obj)->header.next_mark & ~(uintptr_t)1))
+#define OBJ_FLAGS           ((uintptr_t)3)
+#define OBJ_NEXT(obj)       ((Object*)((obj)->header.next_mark & ~OBJ_FLAGS))
 #define OBJ_MARKED(obj)     ((obj)->header.next_mark & 1)
+#define OBJ_IS_ARRAY(obj)   (((obj)->header.next_mark >> 1) & 1)
 #define OBJ_SET_NEXT(obj, n) ((obj)->header.next_mark = \
-    ((uintptr_t)(n) & ~(uintptr_t)1) | OBJ_MARKED(obj))
+    ((uintptr_t)(n) & ~OBJ_FLAGS) | ((obj)->header.next_mark & OBJ_FLAGS))
 #define OBJ_SET_MARK(obj)   ((obj)->header.next_mark |= 1)
 #define OBJ_CLEAR_MARK(obj) ((obj)->header.next_mark &= ~(uintptr_t)1)
+#define OBJ_SET_ARRAY(obj)  ((obj)->header.next_mark |= 2)
 
 /* Get data pointer from object */
 #define OBJ_DATA(obj)       ((void*)((obj)->data))
@@ -92,14 +107,76 @@ typedef struct YoruString {
  * Interface Type
  * =============================================================================
  *
- * Interfaces are fat pointers: (type_info, data_ptr)
+ * Interfaces are fat pointers: (itable, data_ptr). The itable of a
+ * (dynamic type, interface) pair starts with the TypeDesc of the dynamic
+ * type, which identifies it in type assertions, and its name, followed by
+ * one function pointer per interface method in method order.
  */
 
+typedef struct YoruItab {
+    const TypeDesc* type;    /* dynamic type (identity for assertions) */
+    YoruString name;         /* dynamic type name, for panic messages */
+    void* methods[];         /* method table */
+} YoruItab;
+
 typedef struct YoruInterface {
-    const void* itable;      /* interface method table */
+    const YoruItab* itable;  /* interface method table, NULL for nil */
     void* data;              /* pointer to concrete value */
 } YoruInterface;
 
+/*
+ * =============================================================================
+ * Slice Type
+ * =============================================================================
+ *
+ * Slices are (ptr, len, cap) triples. ptr points into an array object, a
+ * heap object holding an array, or a global array; the GC resolves it to
+ * the enclosing object. A nil slice has a NULL ptr.
+ */
+
+typedef struct YoruSlice {
+    void* ptr;               /* pointer to the first element */
+    int64_t len;             /* number of elements */
+    int64_t cap;             /* number of elements available from ptr */
+} YoruSlice;
+
+/*
+ * =============================================================================
+ * Map Type
+ * =============================================================================
+ *
+ * A map value is a pointer to a YoruMap heap object, NULL for a nil map.
+ * The entries live in an open-addressing hash table with linear probing:
+ * three parallel array objects of hashes, keys and elements, so that the
+ * GC traces the refs of keys and elements through their TypeDescs.
+ *
+ * When the table grows, the previous table is kept as the old table and
+ * its entries are moved to the new one a few slots at a time by each
+ * assignment and deletion; lookups check both tables until it is empty.
+ */
+
+/* Map key kinds (rtabi.MapKeyScalar, rtabi.MapKeyString) */
+#define MAP_KEY_SCALAR 0     /* int, bool, ref: hashed and compared by bytes */
+#define MAP_KEY_STRING 1     /* string: hashed and compared by contents */
+
+typedef struct MapTable {
+    uint64_t* hashes;        /* per slot: 0 empty, 1 deleted, else the hash */
+    char* keys;              /* cap keys */
+    char* elems;             /* cap elements */
+    int64_t cap;             /* number of slots, a power of two or 0 */
+    int64_t used;            /* slots that are not empty */
+} MapTable;
+
+typedef struct YoruMap {
+    int64_t count;           /* number of entries */
+    const TypeDesc* key;     /* key type */
+    const TypeDesc* elem;    /* element type */
+    int64_t kind;            /* MAP_KEY_SCALAR or MAP_KEY_STRING */
+    MapTable table;          /* current table */
+    MapTable old;            /* table being evacuated, cap 0 if none */
+    int64_t evacuated;       /* slots of old already evacuated */
+} YoruMap;
+
 /*
  * =============================================================================
  * Runtime Functions - Memory Allocation
@@ -116,6 +193,91 @@ typedef struct YoruInterface {
  */
 void* rt_alloc(uint64_t size, const TypeDesc* type);
 
+/*
+ * =============================================================================
+ * Runtime Functions - Slices
+ * =============================================================================
+ *
+ * YoruSlice is 24 bytes and cannot be passed by value between C and LLVM
+ * aggregates portably, so these functions take its fields or its address.
+ */
+
+/*
+ * Allocate the backing array of make([]T, len, cap).
+ * Panics if len is negative or cap is less than len.
+ *
+ * @param elem  Type descriptor of the element type
+ * @param len   Requested length
+ * @param cap   Requested capacity
+ * @return      Pointer to the first of cap zeroed elements
+ */
+void* rt_makeslice(const TypeDesc* elem, int64_t len, int64_t cap);
+
+/*
+ * Grow the slice at s in place so that it has room for newlen elements.
+ * The new capacity is at least double the old one; the first s->len
+ * elements are copied. s->len is left unchanged.
+ *
+ * @param s       Address of the slice to grow
+ * @param elem    Type descriptor of the element type
+ * @param newlen  Required length (greater than s->cap)
+ */
+void rt_growslice(YoruSlice* s, const TypeDesc* elem, int64_t newlen);
+
+/*
+ * Copy min(dstlen, srclen) elements from src to dst; the ranges may
+ * overlap.
+ *
+ * @return  Number of elements copied
+ */
+int64_t rt_slicecopy(void* dst, int64_t dstlen, void* src, int64_t srclen,
+                     int64_t elemsize);
+
+/*
+ * =============================================================================
+ * Runtime Functions - Maps
+ * =============================================================================
+ *
+ * Keys and elements are passed by address.
+ */
+
+/*
+ * Allocate the map of make(map[K]V, hint).
+ * Panics if hint is negative or too large.
+ *
+ * @param key   Type descriptor of the key type
+ * @param elem  Type descriptor of the element type
+ * @param kind  Key kind (MAP_KEY_SCALAR or MAP_KEY_STRING)
+ * @param hint  Number of entries to make room for
+ * @return      The new map
+ */
+void* rt_makemap(const TypeDesc* key, const TypeDesc* elem, int64_t kind,
+                 int64_t hint);
+
+/*
+ * Look up key in m, which may be nil. If it is present, copy its element
+ * to out; otherwise out is left unchanged (the compiler zeroes it).
+ *
+ * @return  1 if key is present, 0 otherwise
+ */
+int8_t rt_mapaccess(void* m, const void* key, void* out);
+
+/*
+ * Set the element of key in m to *val, adding the entry if needed.
+ * Panics if m is nil.
+ */
+void rt_mapassign(void* m, const void* key, const void* val);
+
+/*
+ * Remove the entry of key from m, if any. m may be nil.
+ */
+void rt_mapdelete(void* m, const void* key);
+
+/*
+ * Number of entries of m, 0 for a nil map.
+ */
+int64_t rt_maplen(void* m);
+
 /*
  * =============================================================================
  * Runtime Functions - Garbage Collection
@@ -128,6 +290,15 @@ void* rt_alloc(uint64_t size, const TypeDesc* type);
  */
 void rt_collect(void);
 
+/*
+ * Register the address of a package-level ref variable as a GC root.
+ * The compiler-generated yoru_init registers every global ref slot
+ * before running package initializers.
+ *
+ * @param slot  Address of the global slot holding a ref (or NULL)
+ */
+void rt_register_root(void** slot);
+
 /*
  * Initialize the runtime system.
  * Must be called before any other runtime function.
@@ -161,6 +332,16 @@ void rt_panic(const char* msg) __attribute__((noreturn));
  */
 void rt_panic_string(YoruString msg) __attribute__((noreturn));
 
+/*
+ * Panic for a failed type assertion x.(T).
+ *
+ * @param tab    Itable of x, or NULL if x is nil
+ * @param iface  Name of the interface type of x
+ * @param want   Name of the asserted type T
+ */
+void rt_panic_assert(const YoruItab* tab, YoruString iface, YoruString want)
+    __attribute__((noreturn));
+
 /*
  * =============================================================================
  * Runtime Functions - I/O
@@ -200,11 +381,31 @@ void rt_println(void);
 
 /*
  * Check array bounds and panic if out of range.
+ * The panic message is prefixed with the source position, if any.
  *
  * @param index  The index being accessed
  * @param len    The length of the array
+ * @param pos    Source position of the index ("file:line:col"), or empty
+ */
+void rt_bounds_check(int64_t index, int64_t len, YoruString pos);
+
+/*
+ * Check a shift count and panic if it is negative.
+ *
+ * @param count  The shift count
+ */
+void rt_shift_check(int64_t count);
+
+/*
+ * Check the bounds of a slice expression x[lo:hi] and panic unless
+ * 0 <= lo <= hi <= cap.
+ *
+ * @param lo   Low bound
+ * @param hi   High bound
+ * @param cap  Capacity of the sliced operand
+ * @param pos  Source position of the expression, or empty
  */
-void rt_bounds_check(int64_t index, int64_t len);
+void rt_slice_check(int64_t lo, int64_t hi, int64_t cap, YoruString pos);
 
 /*
  * =============================================================================
@@ -254,7 +455,7 @@ struct FrameMap {
 struct StackEntry {
     struct StackEntry* next; /* link to caller's frame */
     const struct FrameMap* map;  /* static frame descriptor */
-    void* roots[];           /* addresses of root slots (void**) */
+    void* roots[];           /* root slots, stored inline (see mark_roots) */
 };
 
 /* Global GC root chain (defined by LLVM runtime) */
@@ -0,0 +1,260 @@
+package layout
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/rtabi"
+	"github.com/you-not-fish/yoru/internal/syntax"
+	"github.com/you-not-fish/yoru/internal/types"
+	"github.com/you-not-fish/yoru/internal/types2"
+)
+
+const (
+	layoutSource   = "../../types/testdata/layout_basic.yoru"
+	typeDescSource = "../../types/testdata/typedesc_basic.yoru"
+)
+
+// TestLayoutGolden checks the compiler's struct layouts against
+// layout_basic.golden for every supported target.
+func TestLayoutGolden(t *testing.T) {
+	want, err := os.ReadFile("../layout_basic.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range rtabi.Targets {
+		t.Run(target.Name, func(t *testing.T) {
+			got := formatLayouts(checkLayouts(t, target))
+			if got != string(want) {
+				t.Errorf("layout mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestLayoutMatchesC compiles layout_basic.c for every supported target
+// with static assertions generated from the compiler's layouts, so the C
+// ABI of each target is checked without running cross-compiled code.
+func TestLayoutMatchesC(t *testing.T) {
+	if _, err := exec.LookPath("clang"); err != nil {
+		t.Skip("clang not found, skipping C layout check")
+	}
+	cFile, err := filepath.Abs("../layout_basic.c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range rtabi.Targets {
+		t.Run(target.Name, func(t *testing.T) {
+			var b strings.Builder
+			fmt.Fprintf(&b, "#define LAYOUT_CHECK_ONLY\n#include %q\n", cFile)
+			for _, l := range checkLayouts(t, target) {
+				fmt.Fprintf(&b, "_Static_assert(sizeof(%s) == %d, \"%s size\");\n", l.name, l.size, l.name)
+				fmt.Fprintf(&b, "_Static_assert(_Alignof(%s) == %d, \"%s align\");\n", l.name, l.align, l.name)
+				for _, f := range l.fields {
+					fmt.Fprintf(&b, "_Static_assert(offsetof(%s, %s) == %d, \"%s.%s offset\");\n",
+						l.name, f.name, f.offset, l.name, f.name)
+				}
+			}
+
+			check := filepath.Join(t.TempDir(), "check.c")
+			if err := os.WriteFile(check, []byte(b.String()), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command("clang", "-target", target.Triple,
+				"-ffreestanding", "-fsyntax-only", check)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("clang layout check failed:\n%s\n%v", out, err)
+			}
+		})
+	}
+}
+
+type structLayout struct {
+	name        string
+	size, align int64
+	fields      []fieldLayout
+}
+
+type fieldLayout struct {
+	name                string
+	offset, size, align int64
+}
+
+// checkFile parses and type-checks the Yoru file filename for target.
+func checkFile(t *testing.T, filename string, target *rtabi.Target) (*syntax.File, *types2.Info, *types.Sizes) {
+	t.Helper()
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var errs []string
+	errh := func(pos syntax.Pos, msg string) {
+		errs = append(errs, pos.String()+": "+msg)
+	}
+	file := syntax.NewParser(filename, f, errh).Parse()
+	if len(errs) > 0 {
+		t.Fatalf("parse errors:\n%s", strings.Join(errs, "\n"))
+	}
+
+	sizes := types.NewSizes(target)
+	conf := &types2.Config{Error: errh, Sizes: sizes}
+	info := &types2.Info{
+		Types:  make(map[syntax.Expr]types2.TypeAndValue),
+		Defs:   make(map[*syntax.Name]types.Object),
+		Uses:   make(map[*syntax.Name]types.Object),
+		Scopes: make(map[syntax.Node]*types.Scope),
+	}
+	_, _ = types2.Check(filename, file, conf, info)
+	if len(errs) > 0 {
+		t.Fatalf("type errors:\n%s", strings.Join(errs, "\n"))
+	}
+	return file, info, sizes
+}
+
+// checkLayouts type-checks layoutSource for target and returns the layout
+// of each struct type declared in it.
+func checkLayouts(t *testing.T, target *rtabi.Target) []structLayout {
+	t.Helper()
+	file, info, sizes := checkFile(t, layoutSource, target)
+
+	var layouts []structLayout
+	for _, decl := range file.Decls {
+		td, ok := decl.(*syntax.TypeDecl)
+		if !ok {
+			continue
+		}
+		st, ok := info.Defs[td.Name].Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		l := structLayout{name: td.Name.Value, size: sizes.Sizeof(st), align: sizes.Alignof(st)}
+		for i, field := range st.Fields() {
+			l.fields = append(l.fields, fieldLayout{
+				name:   field.Name(),
+				offset: sizes.Offsetof(st, i),
+				size:   sizes.Sizeof(field.Type()),
+				align:  sizes.Alignof(field.Type()),
+			})
+		}
+		layouts = append(layouts, l)
+	}
+	return layouts
+}
+
+// formatLayouts renders layouts in the format printed by layout_basic.c.
+func formatLayouts(layouts []structLayout) string {
+	var b strings.Builder
+	for _, l := range layouts {
+		fmt.Fprintf(&b, "struct %s size=%d align=%d\n", l.name, l.size, l.align)
+		for _, f := range l.fields {
+			fmt.Fprintf(&b, "  field %s offset=%d size=%d align=%d\n", f.name, f.offset, f.size, f.align)
+		}
+	}
+	return b.String()
+}
+
+// TestTypeDescGolden checks the compiler's GC type descriptors against
+// typedesc_basic.golden for every supported target.
+func TestTypeDescGolden(t *testing.T) {
+	want, err := os.ReadFile("../typedesc_basic.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range rtabi.Targets {
+		t.Run(target.Name, func(t *testing.T) {
+			got := formatTypeDescs(checkTypeDescs(t, target))
+			if got != string(want) {
+				t.Errorf("typedesc mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestTypeDescMatchesC compiles typedesc_basic.c for every supported target
+// with static assertions checking each type's size and ref offsets against
+// the C structs and their <T>_REF<i> macros.
+func TestTypeDescMatchesC(t *testing.T) {
+	if _, err := exec.LookPath("clang"); err != nil {
+		t.Skip("clang not found, skipping C typedesc check")
+	}
+	cFile, err := filepath.Abs("../typedesc_basic.c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range rtabi.Targets {
+		t.Run(target.Name, func(t *testing.T) {
+			var b strings.Builder
+			fmt.Fprintf(&b, "#define LAYOUT_CHECK_ONLY\n#include %q\n", cFile)
+			for _, d := range checkTypeDescs(t, target) {
+				fmt.Fprintf(&b, "_Static_assert(sizeof(%s) == %d, \"%s size\");\n", d.name, d.size, d.name)
+				fmt.Fprintf(&b, "_Static_assert(%s_NUM_REFS == %d, \"%s num_ptrs\");\n", d.name, len(d.offsets), d.name)
+				for i, off := range d.offsets {
+					fmt.Fprintf(&b, "_Static_assert(%s_REF%d == %d, \"%s offsets[%d]\");\n",
+						d.name, i, off, d.name, i)
+				}
+			}
+
+			check := filepath.Join(t.TempDir(), "check.c")
+			if err := os.WriteFile(check, []byte(b.String()), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command("clang", "-target", target.Triple,
+				"-ffreestanding", "-fsyntax-only", check)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("clang typedesc check failed:\n%s\n%v", out, err)
+			}
+		})
+	}
+}
+
+type typeDesc struct {
+	name    string
+	size    int64
+	offsets []int64
+}
+
+// checkTypeDescs type-checks typeDescSource for target and returns the GC
+// type descriptor of each type declared in it.
+func checkTypeDescs(t *testing.T, target *rtabi.Target) []typeDesc {
+	t.Helper()
+	file, info, sizes := checkFile(t, typeDescSource, target)
+
+	var descs []typeDesc
+	for _, decl := range file.Decls {
+		td, ok := decl.(*syntax.TypeDecl)
+		if !ok {
+			continue
+		}
+		typ := info.Defs[td.Name].Type()
+		descs = append(descs, typeDesc{
+			name:    td.Name.Value,
+			size:    sizes.Sizeof(typ),
+			offsets: sizes.RefOffsets(typ),
+		})
+	}
+	return descs
+}
+
+// formatTypeDescs renders descs in the format printed by typedesc_basic.c
+// and yoruc -emit-typedesc.
+func formatTypeDescs(descs []typeDesc) string {
+	var b strings.Builder
+	for _, d := range descs {
+		offs := make([]string, len(d.offsets))
+		for i, off := range d.offsets {
+			offs[i] = fmt.Sprint(off)
+		}
+		fmt.Fprintf(&b, "typedesc %s size=%d num_ptrs=%d offsets=[%s]\n",
+			d.name, d.size, len(d.offsets), strings.Join(offs, " "))
+	}
+	return b.String()
+}
@@ -1,6 +1,14 @@
 #include <stdint.h>
 #include <stddef.h>
+
+/*
+ * LAYOUT_CHECK_ONLY keeps just the type definitions, so the file can be
+ * included by the generated static-assert checks in layout/layout_test.go and
+ * compiled (-fsyntax-only) for any target without a C library.
+ */
+#ifndef LAYOUT_CHECK_ONLY
 #include <stdio.h>
+#endif
 
 typedef struct Point {
     int64_t x;
@@ -14,6 +22,7 @@ typedef struct Mixed {
     double f;
 } Mixed;
 
+#ifndef LAYOUT_CHECK_ONLY
 static size_t field_align_int64(void) { return _Alignof(int64_t); }
 static size_t field_align_bool(void) { return _Alignof(uint8_t); }
 static size_t field_align_ptr(void) { return _Alignof(void*); }
@@ -38,3 +47,4 @@ int main(void) {
 
     return 0;
 }
+#endif
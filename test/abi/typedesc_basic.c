@@ -0,0 +1,85 @@
+#include <stdint.h>
+#include <stddef.h>
+
+/*
+ * C reference for the GC type descriptors of typedesc_basic.yoru. Each
+ * struct mirrors the Yoru type (ref fields are plain pointers), and the
+ * X_REFS lists name the ref slots the compiler must put in the TypeDesc
+ * offsets table, in increasing offset order.
+ *
+ * LAYOUT_CHECK_ONLY keeps just the definitions, so the file can be included
+ * by the generated static-assert checks in layout/layout_test.go.
+ */
+#ifndef LAYOUT_CHECK_ONLY
+#include <stdio.h>
+#endif
+
+typedef struct Point {
+    int64_t x;
+    int64_t y;
+} Point;
+
+typedef struct Node {
+    int64_t val;
+    struct Node* next;
+} Node;
+
+typedef struct Pair {
+    uint8_t flag;
+    Node* left;
+    Point pt;
+    Node* right;
+} Pair;
+
+typedef struct YoruString {
+    const char* ptr;
+    int64_t len;
+} YoruString;
+
+typedef struct Tree {
+    Node* nodes[3];
+    Pair pairs[2];
+    YoruString name;
+} Tree;
+
+#define Point_NUM_REFS 0
+
+#define Node_NUM_REFS 1
+#define Node_REF0 offsetof(Node, next)
+
+#define Pair_NUM_REFS 2
+#define Pair_REF0 offsetof(Pair, left)
+#define Pair_REF1 offsetof(Pair, right)
+
+#define Tree_NUM_REFS 7
+#define Tree_REF0 offsetof(Tree, nodes[0])
+#define Tree_REF1 offsetof(Tree, nodes[1])
+#define Tree_REF2 offsetof(Tree, nodes[2])
+#define Tree_REF3 offsetof(Tree, pairs[0].left)
+#define Tree_REF4 offsetof(Tree, pairs[0].right)
+#define Tree_REF5 offsetof(Tree, pairs[1].left)
+#define Tree_REF6 offsetof(Tree, pairs[1].right)
+
+#ifndef LAYOUT_CHECK_ONLY
+static void print_typedesc(const char* name, size_t size, size_t n, const size_t* offs) {
+    printf("typedesc %s size=%zu num_ptrs=%zu offsets=[", name, size, n);
+    for (size_t i = 0; i < n; i++) {
+        printf(i == 0 ? "%zu" : " %zu", offs[i]);
+    }
+    printf("]\n");
+}
+
+int main(void) {
+    const size_t node_offs[] = {Node_REF0};
+    const size_t pair_offs[] = {Pair_REF0, Pair_REF1};
+    const size_t tree_offs[] = {
+        Tree_REF0, Tree_REF1, Tree_REF2, Tree_REF3, Tree_REF4, Tree_REF5, Tree_REF6,
+    };
+
+    print_typedesc("Point", sizeof(Point), Point_NUM_REFS, NULL);
+    print_typedesc("Node", sizeof(Node), Node_NUM_REFS, node_offs);
+    print_typedesc("Pair", sizeof(Pair), Pair_NUM_REFS, pair_offs);
+    print_typedesc("Tree", sizeof(Tree), Tree_NUM_REFS, tree_offs);
+    return 0;
+}
+#endif
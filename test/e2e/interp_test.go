@@ -0,0 +1,62 @@
+package e2e
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/you-not-fish/yoru/internal/ssa"
+	"github.com/you-not-fish/yoru/internal/ssa/interp"
+	"github.com/you-not-fish/yoru/internal/ssa/passes"
+)
+
+// TestInterp runs the end-to-end test programs with the SSA interpreter,
+// which needs no clang. Each program runs once on the unoptimized SSA,
+// once after each prefix of the -O2 pass pipeline, so that a pass changing
+// the program's behavior is caught at the pass that introduced the change,
+// and once at -O1.
+func TestInterp(t *testing.T) {
+	for _, testFile := range testPrograms(t) {
+		name := strings.TrimSuffix(filepath.Base(testFile), ".yoru")
+		t.Run(name, func(t *testing.T) {
+			want := readExpectations(t, testFile)
+			run := func(stage string, pipeline func(funcs []*ssa.Func) []passes.Pass) {
+				pkgs, funcs := buildSSA(t, testFile)
+				runPasses(t, funcs, pipeline(funcs))
+
+				var out bytes.Buffer
+				err := interp.Run(pkgs, &out)
+				var p *interp.Panic
+				switch {
+				case err != nil && !errors.As(err, &p):
+					t.Fatalf("%s: %v", stage, err)
+				case want.panic == "" && err != nil:
+					t.Errorf("%s: unexpected %v", stage, err)
+				case want.panic != "" && err == nil:
+					t.Errorf("%s: program exited normally, want %q", stage, want.panic)
+				case want.panic != "" && err.Error() != want.panic:
+					t.Errorf("%s: got %q, want %q", stage, err, want.panic)
+				}
+				if got := out.String(); got != want.output {
+					t.Errorf("%s: output mismatch:\ngot:  %q\nwant: %q", stage, got, want.output)
+				}
+			}
+
+			full := pipeline(passes.MaxOptLevel, nil)
+			for i := 0; i <= len(full); i++ {
+				stage := "unoptimized"
+				if i > 0 {
+					stage = "after " + full[i-1].Name
+				}
+				run(stage, func(funcs []*ssa.Func) []passes.Pass {
+					return pipeline(passes.MaxOptLevel, funcs)[:i]
+				})
+			}
+			run("-O1", func(funcs []*ssa.Func) []passes.Pass {
+				return pipeline(1, funcs)
+			})
+		})
+	}
+}
@@ -8,29 +8,30 @@ import (
 	"testing"
 
 	"github.com/you-not-fish/yoru/internal/codegen"
+	"github.com/you-not-fish/yoru/internal/loader"
+	"github.com/you-not-fish/yoru/internal/rtabi"
 	"github.com/you-not-fish/yoru/internal/ssa"
 	"github.com/you-not-fish/yoru/internal/ssa/passes"
 	"github.com/you-not-fish/yoru/internal/syntax"
 	"github.com/you-not-fish/yoru/internal/types"
-	"github.com/you-not-fish/yoru/internal/types2"
 )
 
-// TestE2E runs end-to-end tests for all .yoru files in testdata/.
+// TestE2E runs end-to-end tests for all .yoru files in testdata/, and for
+// each directory in testdata/ holding a multi-package program (a yoru.mod
+// module whose main package is the directory itself).
 // Each test:
 //  1. Runs the full pipeline: parse → typecheck → SSA → mem2reg → codegen
 //  2. Writes the LLVM IR to a temp .ll file
 //  3. Compiles with clang, linking against the runtime
 //  4. Runs the binary and captures stdout
 //  5. Compares output against the .golden file
+//
+// A test may also have a .panic file (main.panic for a directory) holding
+// the message the program is expected to panic with after printing the
+// golden output, and a .env file (main.env) listing KEY=VALUE environment
+// variables for the run, such as YORU_GC_ENABLE=1 to turn on collection.
 func TestE2E(t *testing.T) {
-	// Find all .yoru files in testdata.
-	testFiles, err := filepath.Glob("testdata/*.yoru")
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(testFiles) == 0 {
-		t.Fatal("no .yoru test files found in testdata/")
-	}
+	testFiles := testPrograms(t)
 
 	// Check that clang is available.
 	if _, err := exec.LookPath("clang"); err != nil {
@@ -48,16 +49,33 @@ func TestE2E(t *testing.T) {
 	}
 }
 
-// runE2ETest runs a single end-to-end test.
-func runE2ETest(t *testing.T, yoruFile, runtimeC string) {
-	t.Helper()
+// TestE2EOptimized runs the end-to-end tests that expect a panic with the
+// LLVM IR compiled by clang -O2, so that LLVM cannot move a faulting
+// instruction, such as a division, before the check that panics instead.
+func TestE2EOptimized(t *testing.T) {
+	testFiles := testPrograms(t)
 
-	// Read expected output from .golden file.
-	goldenFile := strings.TrimSuffix(yoruFile, ".yoru") + ".golden"
-	expected, err := os.ReadFile(goldenFile)
-	if err != nil {
-		t.Fatalf("reading golden file: %v", err)
+	if _, err := exec.LookPath("clang"); err != nil {
+		t.Skip("clang not found, skipping E2E tests")
+	}
+	runtimeC := findRuntime(t)
+
+	for _, testFile := range testFiles {
+		if readExpectations(t, testFile).panic == "" {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(testFile), ".yoru")
+		t.Run(name, func(t *testing.T) {
+			runE2ETest(t, testFile, runtimeC, "-O2")
+		})
 	}
+}
+
+// runE2ETest runs a single end-to-end test, passing clangFlags to clang.
+func runE2ETest(t *testing.T, yoruFile, runtimeC string, clangFlags ...string) {
+	t.Helper()
+
+	want := readExpectations(t, yoruFile)
 
 	// Create temp directory for build artifacts.
 	tmpDir := t.TempDir()
@@ -68,95 +86,166 @@ func runE2ETest(t *testing.T, yoruFile, runtimeC string) {
 	compileTo(t, yoruFile, llFile)
 
 	// Step 2: Link with clang.
-	cmd := exec.Command("clang",
-		"-target", "arm64-apple-macosx26.0.0",
-		llFile, runtimeC,
-		"-o", binFile,
-	)
+	args := append([]string{"-target", rtabi.HostTarget().Triple}, clangFlags...)
+	cmd := exec.Command("clang", append(args, llFile, runtimeC, "-o", binFile)...)
 	if out, err := cmd.CombinedOutput(); err != nil {
 		t.Fatalf("clang failed:\n%s\n%v", out, err)
 	}
 
 	// Step 3: Run binary and capture stdout.
 	cmd = exec.Command(binFile)
+	cmd.Env = append(os.Environ(), want.env...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
 	out, err := cmd.Output()
-	if err != nil {
-		t.Fatalf("binary execution failed: %v", err)
+	switch {
+	case want.panic == "" && err != nil:
+		t.Fatalf("binary execution failed: %v\nstderr:\n%s", err, stderr.String())
+	case want.panic != "" && err == nil:
+		t.Fatalf("binary exited normally, want %q", want.panic)
+	case want.panic != "" && !strings.Contains(stderr.String(), want.panic):
+		t.Fatalf("stderr missing %q:\n%s", want.panic, stderr.String())
 	}
 
 	// Step 4: Compare output.
-	got := string(out)
-	want := string(expected)
-	if got != want {
-		t.Errorf("output mismatch:\ngot:  %q\nwant: %q", got, want)
+	if got := string(out); got != want.output {
+		t.Errorf("output mismatch:\ngot:  %q\nwant: %q", got, want.output)
 	}
 }
 
-// compileTo runs the full compilation pipeline in-process and writes LLVM IR to llFile.
-func compileTo(t *testing.T, yoruFile, llFile string) {
+// testPrograms returns the test programs in testdata/: the .yoru files and
+// the multi-package program directories.
+func testPrograms(t *testing.T) []string {
 	t.Helper()
+	testFiles, err := filepath.Glob("testdata/*.yoru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(testFiles) == 0 {
+		t.Fatal("no .yoru test files found in testdata/")
+	}
+	modFiles, err := filepath.Glob("testdata/*/yoru.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mod := range modFiles {
+		testFiles = append(testFiles, filepath.Dir(mod))
+	}
+	return testFiles
+}
+
+// expectations describes the expected behavior of a test program.
+type expectations struct {
+	output string   // the .golden file
+	panic  string   // "panic: " and the .panic file, or ""
+	env    []string // the .env file
+}
 
-	// Parse.
-	f, err := os.Open(yoruFile)
+// readExpectations reads the .golden, .panic and .env files of the test
+// program yoruFile.
+func readExpectations(t *testing.T, yoruFile string) expectations {
+	t.Helper()
+	goldenFile := strings.TrimSuffix(yoruFile, ".yoru") + ".golden"
+	if fi, err := os.Stat(yoruFile); err == nil && fi.IsDir() {
+		goldenFile = filepath.Join(yoruFile, "main.golden")
+	}
+	expected, err := os.ReadFile(goldenFile)
 	if err != nil {
-		t.Fatalf("open: %v", err)
+		t.Fatalf("reading golden file: %v", err)
+	}
+	want := expectations{output: string(expected)}
+	if msg, err := os.ReadFile(strings.TrimSuffix(goldenFile, ".golden") + ".panic"); err == nil {
+		want.panic = "panic: " + strings.TrimSpace(string(msg))
 	}
-	defer f.Close()
+	if data, err := os.ReadFile(strings.TrimSuffix(goldenFile, ".golden") + ".env"); err == nil {
+		want.env = strings.Fields(string(data))
+	}
+	return want
+}
+
+// compileTo runs the full compilation pipeline in-process and writes LLVM IR to llFile.
+// input is a single .yoru file or a main package directory.
+func compileTo(t *testing.T, input, llFile string) {
+	t.Helper()
 
-	var parseErrs []string
-	parseErrh := func(pos syntax.Pos, msg string) {
-		parseErrs = append(parseErrs, pos.String()+": "+msg)
+	pkgs, funcs := buildSSA(t, input)
+	runPasses(t, funcs, pipeline(passes.MaxOptLevel, funcs))
+
+	// Generate LLVM IR.
+	out, err := os.Create(llFile)
+	if err != nil {
+		t.Fatalf("create: %v", err)
 	}
-	p := syntax.NewParser(yoruFile, f, parseErrh)
-	ast := p.Parse()
-	if len(parseErrs) > 0 {
-		t.Fatalf("parse errors:\n%s", strings.Join(parseErrs, "\n"))
+	defer out.Close()
+
+	if err := codegen.GenerateProgram(out, pkgs, types.DefaultSizes); err != nil {
+		t.Fatalf("codegen: %v", err)
 	}
+}
+
+// buildSSA parses and type-checks input, a single .yoru file or a main
+// package directory, and builds its SSA. It returns the packages in
+// initialization order and their functions, callees first.
+func buildSSA(t *testing.T, input string) ([]*ssa.Package, []*ssa.Func) {
+	t.Helper()
 
-	// Type check.
-	var typeErrs []string
-	typeErrh := func(pos syntax.Pos, msg string) {
-		typeErrs = append(typeErrs, pos.String()+": "+msg)
+	filenames := []string{input}
+	if fi, err := os.Stat(input); err == nil && fi.IsDir() {
+		filenames, err = filepath.Glob(filepath.Join(input, "*.yoru"))
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
-	conf := &types2.Config{
-		Error: typeErrh,
+
+	// Parse and type check, with imports.
+	var errs []string
+	conf := &loader.Config{
 		Sizes: types.DefaultSizes,
+		Error: func(pos syntax.Pos, msg string) {
+			errs = append(errs, pos.String()+": "+msg)
+		},
 	}
-	info := &types2.Info{
-		Types:  make(map[syntax.Expr]types2.TypeAndValue),
-		Defs:   make(map[*syntax.Name]types.Object),
-		Uses:   make(map[*syntax.Name]types.Object),
-		Scopes: make(map[syntax.Node]*types.Scope),
+	prog, err := loader.Load(conf, filenames)
+	if len(errs) > 0 {
+		t.Fatalf("errors:\n%s", strings.Join(errs, "\n"))
 	}
-	_, _ = types2.Check(yoruFile, ast, conf, info)
-	if len(typeErrs) > 0 {
-		t.Fatalf("type errors:\n%s", strings.Join(typeErrs, "\n"))
+	if err != nil {
+		t.Fatalf("load: %v", err)
 	}
 
 	// Build SSA.
-	funcs := ssa.BuildFile(ast, info, types.DefaultSizes)
+	var pkgs []*ssa.Package
+	var funcs []*ssa.Func
+	for _, pkg := range prog.Packages {
+		p := ssa.BuildPackage(pkg.Files, prog.Info, types.DefaultSizes)
+		pkgs = append(pkgs, p)
+		funcs = append(funcs, p.Funcs...)
+		if p.Init != nil {
+			funcs = append(funcs, p.Init)
+		}
+	}
+	return pkgs, passes.BottomUp(funcs)
+}
 
-	// Run passes.
-	pipeline := []passes.Pass{
-		{Name: "mem2reg", Fn: passes.Mem2Reg},
+// pipeline returns the pass pipeline of yoruc at optimization level for
+// the program made of funcs.
+func pipeline(level int, funcs []*ssa.Func) []passes.Pass {
+	p, err := passes.NewPipeline(passes.Preset(level), &passes.Context{Funcs: funcs})
+	if err != nil {
+		panic(err) // presets only name registered passes
 	}
+	return p
+}
+
+// runPasses runs pipeline on each of funcs, in order.
+func runPasses(t *testing.T, funcs []*ssa.Func, pipeline []passes.Pass) {
+	t.Helper()
 	for _, fn := range funcs {
 		ssa.ComputeDom(fn)
 		if err := passes.Run(fn, pipeline, passes.Config{}); err != nil {
 			t.Fatalf("pass pipeline failed for %s: %v", fn.Name, err)
 		}
 	}
-
-	// Generate LLVM IR.
-	out, err := os.Create(llFile)
-	if err != nil {
-		t.Fatalf("create: %v", err)
-	}
-	defer out.Close()
-
-	if err := codegen.Generate(out, funcs, types.DefaultSizes); err != nil {
-		t.Fatalf("codegen: %v", err)
-	}
 }
 
 // findRuntime locates the runtime/runtime.c file relative to the test directory.